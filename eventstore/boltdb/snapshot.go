@@ -0,0 +1,48 @@
+package boltdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"fiatjaf.com/nostr/eventstore"
+	"go.etcd.io/bbolt"
+)
+
+var _ eventstore.Snapshotter = (*BoltBackend)(nil)
+
+// Backup writes the entire bolt database to w from inside a read transaction, so writes can continue meanwhile.
+func (b *BoltBackend) Backup(w io.Writer) error {
+	return b.DB.View(func(txn *bbolt.Tx) error {
+		_, err := txn.WriteTo(w)
+		return err
+	})
+}
+
+// Restore replaces the current database file with one previously written by Backup.
+// It can only be called when the database is not being used.
+func (b *BoltBackend) Restore(r io.Reader) error {
+	tmpfile, err := os.CreateTemp(filepath.Dir(b.Path), filepath.Base(b.Path)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := io.Copy(tmpfile, r); err != nil {
+		tmpfile.Close()
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		return err
+	}
+
+	if err := b.DB.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpfile.Name(), b.Path); err != nil {
+		return err
+	}
+
+	return b.Init()
+}
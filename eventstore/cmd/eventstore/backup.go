@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"fiatjaf.com/nostr/eventstore"
+	"github.com/urfave/cli/v3"
+)
+
+var backup = &cli.Command{
+	Name:        "backup",
+	ArgsUsage:   "[<file>]",
+	Usage:       "writes a consistent snapshot of the store",
+	Description: "takes a point-in-time snapshot of the currently open eventstore without stopping writers and writes it to the given file or to stdout.\n only supported by lmdb, boltdb and mmm.",
+	Action: func(ctx context.Context, c *cli.Command) error {
+		snapshotter, ok := db.(eventstore.Snapshotter)
+		if !ok {
+			return fmt.Errorf("this store type doesn't support backups")
+		}
+
+		var w io.Writer = os.Stdout
+		if path := c.Args().First(); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return fmt.Errorf("failed to create '%s': %w", path, err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		return snapshotter.Backup(w)
+	},
+}
+
+var restore = &cli.Command{
+	Name:        "restore",
+	ArgsUsage:   "[<file>]",
+	Usage:       "replaces the contents of the store with a snapshot",
+	Description: "reads a snapshot written by the backup command, from the given file or from stdin, and replaces everything in the currently open eventstore with it.\n the snapshot must have been taken from the same type of store and nothing else should be using the store meanwhile.",
+	Action: func(ctx context.Context, c *cli.Command) error {
+		snapshotter, ok := db.(eventstore.Snapshotter)
+		if !ok {
+			return fmt.Errorf("this store type doesn't support restoring")
+		}
+
+		var r io.Reader = os.Stdin
+		if path := c.Args().First(); path != "" {
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open '%s': %w", path, err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		return snapshotter.Restore(r)
+	},
+}
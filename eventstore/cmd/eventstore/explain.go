@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"github.com/mailru/easyjson"
+	"github.com/urfave/cli/v3"
+)
+
+var explain = &cli.Command{
+	Name:        "explain",
+	ArgsUsage:   "[<filter-json>]",
+	Usage:       "shows the query plan chosen for a filter",
+	Description: "tells which index the currently open eventstore picks for the filter and compares the estimated number of keys scanned with the actual number.\n only supported by lmdb and mmm.",
+	Action: func(ctx context.Context, c *cli.Command) error {
+		explainer, ok := db.(eventstore.Explainer)
+		if !ok {
+			return fmt.Errorf("this store type doesn't support explaining queries")
+		}
+
+		hasError := false
+		for line := range getStdinLinesOrFirstArgument(c) {
+			filter := nostr.Filter{}
+			if err := easyjson.Unmarshal([]byte(line), &filter); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid filter '%s': %s\n", line, err)
+				hasError = true
+				continue
+			}
+
+			plan, err := explainer.Explain(filter)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to explain '%s': %s\n", filter, err)
+				hasError = true
+				continue
+			}
+
+			j, _ := json.Marshal(plan)
+			fmt.Println(string(j))
+		}
+
+		if hasError {
+			os.Exit(123)
+		}
+		return nil
+	},
+}
@@ -22,7 +22,7 @@ var db eventstore.Store
 var app = &cli.Command{
 	Name:      "eventstore",
 	Usage:     "a CLI for all the eventstore backends",
-	UsageText: "eventstore -d ./data <query|save|delete> ...",
+	UsageText: "eventstore -d ./data <query|save|delete|count|explain|backup|restore> ...",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:     "store",
@@ -116,9 +116,12 @@ var app = &cli.Command{
 		queryOrSave,
 		query,
 		count,
+		explain,
 		save,
 		delete_,
 		neg,
+		backup,
+		restore,
 	},
 	DefaultCommand: "query-or-save",
 }
@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"iter"
+	"slices"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+)
+
+// Cardinalities is what a backend must expose from its statistics table so we can estimate query costs.
+type Cardinalities interface {
+	Total() uint64
+	Kind(nostr.Kind) uint64
+	PubKey(nostr.PubKey) uint64
+	// Tag returns how many times a tag with this key was indexed and an estimate of how many distinct values it had
+	Tag(letter byte) (occurrences uint64, distinct uint64)
+}
+
+type Index int
+
+const (
+	IndexCreatedAt Index = iota
+	IndexTag
+	IndexPubkey
+	IndexKind
+)
+
+func (i Index) String() string {
+	switch i {
+	case IndexTag:
+		return "tag"
+	case IndexPubkey:
+		return "pubkey"
+	case IndexKind:
+		return "kind"
+	default:
+		return "created_at"
+	}
+}
+
+type IndexChoice struct {
+	Index      Index
+	Estimated  uint64
+	Candidates []eventstore.PlanCandidate
+}
+
+// ChooseIndex decides which index should be used to answer a filter.
+// When there are no statistics available it follows the same fixed rules we always had, otherwise it picks
+// the candidate with the smallest estimated number of keys to scan.
+func ChooseIndex(filter nostr.Filter, stats Cardinalities) IndexChoice {
+	// these are the fixed rules
+	choice := IndexChoice{Index: IndexCreatedAt}
+	if len(filter.Tags) > 0 {
+		if _, _, goodness := ChooseNarrowestTag(filter); goodness >= 2 || (len(filter.Authors) == 0 && len(filter.Kinds) == 0) {
+			choice.Index = IndexTag
+		}
+	}
+	if choice.Index == IndexCreatedAt {
+		if len(filter.Authors) > 0 {
+			choice.Index = IndexPubkey
+		} else if len(filter.Kinds) > 0 {
+			choice.Index = IndexKind
+		}
+	}
+
+	if stats == nil {
+		return choice
+	}
+	total := stats.Total()
+	if total == 0 {
+		return choice
+	}
+
+	// kind counts are used by multiple estimates
+	kindFraction := func() float64 {
+		var sum uint64
+		for _, kind := range filter.Kinds {
+			sum += stats.Kind(kind)
+		}
+		return min(1, float64(sum)/float64(total))
+	}
+
+	costs := make(map[Index]uint64, 4)
+
+	if len(filter.Tags) > 0 {
+		tagKey, tagValues, _ := ChooseNarrowestTag(filter)
+		if len(tagKey) > 0 {
+			occurrences, distinct := stats.Tag(tagKey[0])
+			perValue := float64(occurrences) / float64(max(1, distinct))
+			cost := perValue * float64(len(tagValues))
+			if tagKey == "p" && len(filter.Kinds) > 0 {
+				// the "p" tag index also has the kind in it
+				cost *= kindFraction()
+			}
+			costs[IndexTag] = uint64(cost + 0.5)
+		}
+	}
+
+	if len(filter.Authors) > 0 {
+		var cost float64
+		for _, pk := range filter.Authors {
+			cost += float64(stats.PubKey(pk))
+		}
+		if len(filter.Kinds) > 0 {
+			// the pubkey index also has the kind in it
+			cost *= kindFraction()
+		}
+		costs[IndexPubkey] = uint64(cost + 0.5)
+	}
+
+	if len(filter.Kinds) > 0 {
+		var cost uint64
+		for _, kind := range filter.Kinds {
+			cost += stats.Kind(kind)
+		}
+		costs[IndexKind] = cost
+	}
+
+	if len(costs) == 0 {
+		costs[IndexCreatedAt] = total
+	}
+
+	// start from the fixed rules choice and only move away from it if something else is strictly better
+	best, ok := costs[choice.Index]
+	if !ok {
+		best = total
+	}
+	for _, index := range []Index{IndexTag, IndexPubkey, IndexKind, IndexCreatedAt} {
+		cost, ok := costs[index]
+		if !ok {
+			continue
+		}
+		choice.Candidates = append(choice.Candidates, eventstore.PlanCandidate{
+			Index:         index.String(),
+			EstimatedKeys: cost,
+		})
+		if cost < best {
+			best = cost
+			choice.Index = index
+		}
+	}
+	choice.Estimated = best
+
+	return choice
+}
+
+// StatsCounterKeys yields the keys of all the counters in a statistics table that must be
+// incremented when this event is saved and decremented when it is deleted.
+func StatsCounterKeys(evt nostr.Event) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		if !yield([]byte{'n'}) {
+			return
+		}
+
+		k := make([]byte, 1+2)
+		k[0] = 'k'
+		binary.BigEndian.PutUint16(k[1:], uint16(evt.Kind))
+		if !yield(k) {
+			return
+		}
+
+		p := make([]byte, 1+8)
+		p[0] = 'p'
+		copy(p[1:], evt.PubKey[0:8])
+		if !yield(p) {
+			return
+		}
+
+		for _, tag := range IndexableTags(evt) {
+			if !yield([]byte{'t', tag[0][0]}) {
+				return
+			}
+		}
+	}
+}
+
+// StatsDistinctKeys yields, for each indexable tag, the key of the hyperloglog registers that estimate the
+// number of distinct values of that tag key, along with the item that must be added to it.
+func StatsDistinctKeys(evt nostr.Event) iter.Seq2[[]byte, [32]byte] {
+	return func(yield func([]byte, [32]byte) bool) {
+		for _, tag := range IndexableTags(evt) {
+			if !yield([]byte{'d', tag[0][0]}, sha256.Sum256([]byte(tag[1]))) {
+				return
+			}
+		}
+	}
+}
+
+// IndexableTags yields the tags that are indexed by our backends, skipping duplicates.
+func IndexableTags(evt nostr.Event) iter.Seq2[int, nostr.Tag] {
+	return func(yield func(int, nostr.Tag) bool) {
+		for i, tag := range evt.Tags {
+			if len(tag) < 2 || len(tag[0]) != 1 || len(tag[1]) == 0 || len(tag[1]) > 100 {
+				// not indexable
+				continue
+			}
+			firstIndex := slices.IndexFunc(evt.Tags, func(t nostr.Tag) bool {
+				return len(t) >= 2 && t[0] == tag[0] && t[1] == tag[1]
+			})
+			if firstIndex != i {
+				// duplicate
+				continue
+			}
+			if !yield(i, tag) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/codec/betterbinary"
+	"fiatjaf.com/nostr/eventstore/internal"
+	"fiatjaf.com/nostr/nip45/hyperloglog"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// updateStats adds (delta=1) or removes (delta=-1) an event from the cardinality statistics used by the query planner.
+func (b *LMDBBackend) updateStats(txn *lmdb.Txn, evt nostr.Event, delta int) error {
+	for k := range internal.StatsCounterKeys(evt) {
+		var curr uint64
+		if val, err := txn.Get(b.stats, k); err == nil {
+			curr = binary.BigEndian.Uint64(val)
+		} else if !lmdb.IsNotFound(err) {
+			return err
+		}
+
+		if delta > 0 {
+			curr++
+		} else if curr > 0 {
+			curr--
+		}
+
+		if err := txn.Put(b.stats, k, binary.BigEndian.AppendUint64(nil, curr), 0); err != nil {
+			return err
+		}
+	}
+
+	// distinct values can only grow, we can't remove things from a hyperloglog
+	if delta > 0 {
+		for k, item := range internal.StatsDistinctKeys(evt) {
+			hll := hyperloglog.New(0)
+			if val, err := txn.Get(b.stats, k); err == nil {
+				hll.SetRegisters(append([]byte(nil), val...))
+			} else if !lmdb.IsNotFound(err) {
+				return err
+			}
+
+			hll.Add(item)
+
+			if err := txn.Put(b.stats, k, hll.GetRegisters(), 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ensureStats rebuilds the statistics from scratch if they're missing (i.e. on databases created before they existed).
+func (b *LMDBBackend) ensureStats() error {
+	return b.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		if _, err := txn.Get(b.stats, []byte{'n'}); err == nil {
+			return nil
+		} else if !lmdb.IsNotFound(err) {
+			return err
+		}
+
+		cursor, err := txn.OpenCursor(b.rawEventStore)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+
+		var evt nostr.Event
+		for {
+			idx, val, err := cursor.Get(nil, nil, lmdb.Next)
+			if lmdb.IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get next when computing stats: %w", err)
+			}
+
+			if err := betterbinary.Unmarshal(val, &evt); err != nil {
+				log.Printf("failed to unmarshal event %x, skipping: %s", idx, err)
+				continue
+			}
+
+			if err := b.updateStats(txn, evt, 1); err != nil {
+				return fmt.Errorf("failed to compute stats for %s: %w", evt.ID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+type statsReader struct {
+	txn *lmdb.Txn
+	dbi lmdb.DBI
+}
+
+var _ internal.Cardinalities = statsReader{}
+
+func (b *LMDBBackend) statsReader(txn *lmdb.Txn) statsReader {
+	return statsReader{txn: txn, dbi: b.stats}
+}
+
+func (sr statsReader) counter(key []byte) uint64 {
+	val, err := sr.txn.Get(sr.dbi, key)
+	if err != nil || len(val) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(val)
+}
+
+func (sr statsReader) Total() uint64 { return sr.counter([]byte{'n'}) }
+
+func (sr statsReader) Kind(kind nostr.Kind) uint64 {
+	return sr.counter(binary.BigEndian.AppendUint16([]byte{'k'}, uint16(kind)))
+}
+
+func (sr statsReader) PubKey(pk nostr.PubKey) uint64 {
+	return sr.counter(append([]byte{'p'}, pk[0:8]...))
+}
+
+func (sr statsReader) Tag(letter byte) (occurrences uint64, distinct uint64) {
+	occurrences = sr.counter([]byte{'t', letter})
+	if val, err := sr.txn.Get(sr.dbi, []byte{'d', letter}); err == nil && len(val) == 256 {
+		distinct = hyperloglog.NewWithRegisters(append([]byte(nil), val...), 0).Count()
+	}
+	return occurrences, distinct
+}
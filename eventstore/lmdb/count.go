@@ -16,12 +16,12 @@ import (
 func (b *LMDBBackend) CountEvents(filter nostr.Filter) (uint32, error) {
 	var count uint32 = 0
 
-	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
-	if err != nil {
-		return 0, err
-	}
+	err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(txn, filter)
+		if err != nil {
+			return err
+		}
 
-	err = b.lmdbEnv.View(func(txn *lmdb.Txn) error {
 		// actually iterate
 		for _, q := range queries {
 			cursor, err := txn.OpenCursor(q.dbi)
@@ -59,13 +59,13 @@ func (b *LMDBBackend) CountEvents(filter nostr.Filter) (uint32, error) {
 					}
 
 					// check it against pubkeys without decoding the entire thing
-					if !slices.Contains(extraAuthors, betterbinary.GetPubKey(bin)) {
+					if extraAuthors != nil && !slices.Contains(extraAuthors, betterbinary.GetPubKey(bin)) {
 						it.next()
 						continue
 					}
 
 					// check it against kinds without decoding the entire thing
-					if !slices.Contains(extraKinds, betterbinary.GetKind(bin)) {
+					if extraKinds != nil && !slices.Contains(extraKinds, betterbinary.GetKind(bin)) {
 						it.next()
 						continue
 					}
@@ -77,13 +77,15 @@ func (b *LMDBBackend) CountEvents(filter nostr.Filter) (uint32, error) {
 					}
 
 					// if there is still a tag to be checked, do it now
-					if !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
+					if extraTagValues != nil && !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
 						it.next()
 						continue
 					}
 
 					count++
 				}
+
+				it.next()
 			}
 		}
 
@@ -102,15 +104,15 @@ func (b *LMDBBackend) CountEventsHLL(filter nostr.Filter, offset int) (uint32, *
 
 	var count uint32 = 0
 
-	// this is different than CountEvents because some of these extra checks are not applicable in HLL-valid filters
-	queries, _, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(filter)
-	if err != nil {
-		return 0, nil, err
-	}
-
 	hll := hyperloglog.New(offset)
 
-	err = b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+	err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		// this is different than CountEvents because some of these extra checks are not applicable in HLL-valid filters
+		queries, _, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(txn, filter)
+		if err != nil {
+			return err
+		}
+
 		// actually iterate
 		for _, q := range queries {
 			cursor, err := txn.OpenCursor(q.dbi)
@@ -150,7 +152,7 @@ func (b *LMDBBackend) CountEventsHLL(filter nostr.Filter, offset int) (uint32, *
 					hll.AddBytes(betterbinary.GetPubKey(bin))
 				} else {
 					// check it against kinds without decoding the entire thing
-					if !slices.Contains(extraKinds, betterbinary.GetKind(bin)) {
+					if extraKinds != nil && !slices.Contains(extraKinds, betterbinary.GetKind(bin)) {
 						it.next()
 						continue
 					}
@@ -162,7 +164,7 @@ func (b *LMDBBackend) CountEventsHLL(filter nostr.Filter, offset int) (uint32, *
 					}
 
 					// if there is still a tag to be checked, do it now
-					if !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
+					if extraTagValues != nil && !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
 						it.next()
 						continue
 					}
@@ -170,6 +172,8 @@ func (b *LMDBBackend) CountEventsHLL(filter nostr.Filter, offset int) (uint32, *
 					count++
 					hll.Add(evt.PubKey)
 				}
+
+				it.next()
 			}
 		}
 
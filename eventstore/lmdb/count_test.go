@@ -0,0 +1,53 @@
+package lmdb
+
+import (
+	"os"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountEvents(t *testing.T) {
+	path, err := os.MkdirTemp("", "lmdb_count_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path, extraFlags: lmdb.NoSync}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	alice := nostr.Generate()
+	bob := nostr.Generate()
+	for i := 0; i < 10; i++ {
+		tags := nostr.Tags{}
+		if i%2 == 0 {
+			tags = append(tags, nostr.Tag{"t", "nostr"})
+		}
+		evt := nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1000 + i), Tags: tags, Content: "hello"}
+		require.NoError(t, evt.Sign(alice))
+		require.NoError(t, db.SaveEvent(evt))
+
+		evt = nostr.Event{Kind: 7, CreatedAt: nostr.Timestamp(1000 + i), Tags: tags, Content: "+"}
+		require.NoError(t, evt.Sign(bob))
+		require.NoError(t, db.SaveEvent(evt))
+	}
+
+	for _, tc := range []struct {
+		filter nostr.Filter
+		count  uint32
+	}{
+		// no extra checks, this used to never move the cursor forward
+		{nostr.Filter{Kinds: []nostr.Kind{1}}, 10},
+		{nostr.Filter{Authors: []nostr.PubKey{bob.Public()}}, 10},
+		// extra authors but no extra kinds, which used to reject everything
+		{nostr.Filter{Authors: []nostr.PubKey{alice.Public()}, Tags: nostr.TagMap{"t": []string{"nostr"}}}, 5},
+		// extra kinds but no extra authors
+		{nostr.Filter{Kinds: []nostr.Kind{7}, Tags: nostr.TagMap{"t": []string{"nostr"}}}, 5},
+	} {
+		count, err := db.CountEvents(tc.filter)
+		require.NoError(t, err)
+		require.Equal(t, tc.count, count, "%s", tc.filter)
+	}
+}
@@ -44,6 +44,10 @@ func (b *LMDBBackend) delete(txn *lmdb.Txn, id nostr.ID) error {
 		}
 	}
 
+	if err := b.updateStats(txn, evt, -1); err != nil {
+		return fmt.Errorf("failed to update stats for %x: %w", evt.ID[0:8], err)
+	}
+
 	// delete the raw event
 	if err := txn.Del(b.rawEventStore, idx, nil); err != nil {
 		return fmt.Errorf("failed to delete raw event %x (idx %x): %w", evt.ID[0:8], idx, err)
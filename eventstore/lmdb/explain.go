@@ -0,0 +1,111 @@
+package lmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"fiatjaf.com/nostr/eventstore/codec/betterbinary"
+	"fiatjaf.com/nostr/eventstore/internal"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+var _ eventstore.Explainer = (*LMDBBackend)(nil)
+
+// Explain tells which index would be used for the given filter and how many keys the statistics expected it
+// to scan, then actually scans everything in the chosen ranges (ignoring the limit) to tell how it went.
+func (b *LMDBBackend) Explain(filter nostr.Filter) (eventstore.QueryPlan, error) {
+	var plan eventstore.QueryPlan
+
+	if filter.IDs != nil {
+		plan.Index = b.dbiName(b.indexId)
+		plan.Ranges = len(filter.IDs)
+		plan.EstimatedKeys = uint64(len(filter.IDs))
+		err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+			txn.RawRead = true
+			for _, id := range filter.IDs {
+				if _, err := txn.Get(b.indexId, id[0:8]); err == nil {
+					plan.ScannedKeys++
+					plan.Matched++
+				}
+			}
+			return nil
+		})
+		return plan, err
+	}
+
+	err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+
+		choice := internal.ChooseIndex(filter, b.statsReader(txn))
+		plan.EstimatedKeys = choice.Estimated
+		plan.Candidates = choice.Candidates
+
+		queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := b.prepareQueries(txn, filter)
+		if err != nil {
+			return err
+		}
+		if len(queries) == 0 {
+			// some empty list in the filter means nothing can match
+			return nil
+		}
+
+		plan.Index = b.dbiName(queries[0].dbi)
+		plan.Ranges = len(queries)
+		if extraAuthors != nil {
+			plan.ExtraChecks = append(plan.ExtraChecks, "authors")
+		}
+		if extraKinds != nil {
+			plan.ExtraChecks = append(plan.ExtraChecks, "kinds")
+		}
+		if extraTagValues != nil {
+			plan.ExtraChecks = append(plan.ExtraChecks, "#"+extraTagKey)
+		}
+
+		for _, q := range queries {
+			cursor, err := txn.OpenCursor(q.dbi)
+			if err != nil {
+				return err
+			}
+
+			it := &iterator{cursor: cursor}
+			it.seek(q.startingPoint)
+
+			for ; it.err == nil && len(it.key) == q.keySize && bytes.HasPrefix(it.key, q.prefix); it.next() {
+				if binary.BigEndian.Uint32(it.key[len(it.key)-4:]) < since {
+					break
+				}
+				plan.ScannedKeys++
+
+				bin, err := txn.Get(b.rawEventStore, it.valIdx)
+				if err != nil {
+					continue
+				}
+				if extraAuthors != nil && !slices.Contains(extraAuthors, betterbinary.GetPubKey(bin)) {
+					continue
+				}
+				if extraKinds != nil && !slices.Contains(extraKinds, betterbinary.GetKind(bin)) {
+					continue
+				}
+				if extraTagValues != nil {
+					evt := nostr.Event{}
+					if err := betterbinary.Unmarshal(bin, &evt); err != nil {
+						continue
+					}
+					if !evt.Tags.ContainsAny(extraTagKey, extraTagValues) {
+						continue
+					}
+				}
+				plan.Matched++
+			}
+
+			cursor.Close()
+		}
+
+		return nil
+	})
+
+	return plan, err
+}
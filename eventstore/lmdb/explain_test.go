@@ -0,0 +1,106 @@
+package lmdb
+
+import (
+	"os"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	path, err := os.MkdirTemp("", "lmdb_explain_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	db := &LMDBBackend{Path: path, extraFlags: lmdb.NoSync}
+	require.NoError(t, db.Init())
+	defer db.Close()
+
+	// one author writes a lot of kind 1 about #nostr, a lot of people write a few kind 7s
+	prolific := nostr.Generate()
+	for i := 0; i < 200; i++ {
+		evt := nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1000 + i), Content: "hello", Tags: nostr.Tags{{"t", "nostr"}}}
+		require.NoError(t, evt.Sign(prolific))
+		require.NoError(t, db.SaveEvent(evt))
+	}
+	var rare nostr.SecretKey
+	for i := 0; i < 10; i++ {
+		rare = nostr.Generate()
+		evt := nostr.Event{Kind: 7, CreatedAt: nostr.Timestamp(2000 + i), Content: "+", Tags: nostr.Tags{{"t", "nostr"}}}
+		require.NoError(t, evt.Sign(rare))
+		require.NoError(t, db.SaveEvent(evt))
+	}
+
+	// the fixed rules would pick the tag index, but the pubkey index is much narrower here
+	filter := nostr.Filter{
+		Authors: []nostr.PubKey{rare.Public()},
+		Tags:    nostr.TagMap{"t": []string{"nostr"}},
+	}
+	plan, err := db.Explain(filter)
+	require.NoError(t, err)
+	require.Equal(t, "indexPubkey", plan.Index)
+	require.Equal(t, uint64(1), plan.EstimatedKeys)
+	require.Equal(t, uint64(1), plan.ScannedKeys)
+	require.Equal(t, uint64(1), plan.Matched)
+	require.Equal(t, []string{"#t"}, plan.ExtraChecks)
+
+	plan, err = db.Explain(nostr.Filter{
+		Authors: []nostr.PubKey{prolific.Public()},
+		Kinds:   []nostr.Kind{1, 7},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), plan.Matched)
+	require.Len(t, plan.Candidates, 2)
+
+	plan, err = db.Explain(nostr.Filter{Authors: []nostr.PubKey{prolific.Public()}})
+	require.NoError(t, err)
+	require.Equal(t, "indexPubkey", plan.Index)
+	require.Equal(t, uint64(200), plan.EstimatedKeys)
+	require.Equal(t, uint64(200), plan.ScannedKeys)
+
+	// stats must follow deletions
+	for evt := range db.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{7}}, 500) {
+		require.NoError(t, db.DeleteEvent(evt.ID))
+	}
+	plan, err = db.Explain(nostr.Filter{Kinds: []nostr.Kind{7}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), plan.EstimatedKeys)
+	require.Equal(t, uint64(0), plan.Matched)
+
+	// without stats we go back to the fixed rules
+	require.NoError(t, db.lmdbEnv.Update(func(txn *lmdb.Txn) error {
+		return txn.Drop(db.stats, false)
+	}))
+	plan, err = db.Explain(filter)
+	require.NoError(t, err)
+	require.Equal(t, "indexTag", plan.Index)
+	require.Equal(t, uint64(200), plan.ScannedKeys)
+	require.Empty(t, plan.Candidates)
+
+	// and they get rebuilt when missing
+	require.NoError(t, db.ensureStats())
+	plan, err = db.Explain(nostr.Filter{Kinds: []nostr.Kind{1}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), plan.EstimatedKeys)
+
+	count, err := db.CountEvents(nostr.Filter{Kinds: []nostr.Kind{1}, Authors: []nostr.PubKey{prolific.Public()}})
+	require.NoError(t, err)
+	require.Equal(t, uint32(200), count)
+
+	// empty lists must not break anything and must agree with what the query does
+	for _, filter := range []nostr.Filter{
+		{Tags: nostr.TagMap{"t": []string{}}},
+		{Authors: []nostr.PubKey{}},
+		{Kinds: []nostr.Kind{}},
+	} {
+		plan, err := db.Explain(filter)
+		require.NoError(t, err)
+		var n uint64
+		for range db.QueryEvents(filter, 1000) {
+			n++
+		}
+		require.Equal(t, n, plan.Matched)
+	}
+}
@@ -318,6 +318,8 @@ func (b *LMDBBackend) dbiName(dbi lmdb.DBI) string {
 	switch dbi {
 	case b.hllCache:
 		return "hllCache"
+	case b.stats:
+		return "stats"
 	case b.settingsStore:
 		return "settingsStore"
 	case b.rawEventStore:
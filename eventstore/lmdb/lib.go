@@ -32,6 +32,8 @@ type LMDBBackend struct {
 	indexTagAddr    lmdb.DBI
 	indexPTagKind   lmdb.DBI
 
+	stats lmdb.DBI
+
 	hllCache          lmdb.DBI
 	EnableHLLCacheFor func(kind nostr.Kind) (useCache bool, skipSavingActualEvent bool)
 
@@ -104,7 +106,7 @@ func (b *LMDBBackend) initialize() error {
 		return err
 	}
 
-	env.SetMaxDBs(12)
+	env.SetMaxDBs(13)
 	env.SetMaxReaders(1000)
 	if b.MapSize == 0 {
 		env.SetMapSize(1 << 38) // ~273GB
@@ -181,6 +183,11 @@ func (b *LMDBBackend) initialize() error {
 		} else {
 			b.hllCache = dbi
 		}
+		if dbi, err := txn.OpenDBI("stats", lmdb.Create); err != nil {
+			return err
+		} else {
+			b.stats = dbi
+		}
 		return nil
 	}); err != nil {
 		return err
@@ -209,5 +216,9 @@ func (b *LMDBBackend) initialize() error {
 		return err
 	}
 
-	return b.migrate()
+	if err := b.migrate(); err != nil {
+		return err
+	}
+
+	return b.ensureStats()
 }
@@ -18,7 +18,7 @@ type query struct {
 	startingPoint []byte
 }
 
-func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
+func (b *LMDBBackend) prepareQueries(txn *lmdb.Txn, filter nostr.Filter) (
 	queries []query,
 	extraAuthors []nostr.PubKey,
 	extraKinds []nostr.Kind,
@@ -54,15 +54,13 @@ func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 		}
 	}
 
-	if len(filter.Tags) > 0 {
+	// the planner tells us which index is cheaper based on the cardinality statistics we have
+	index := internal.ChooseIndex(filter, b.statsReader(txn)).Index
+
+	if index == internal.IndexTag {
 		// we will select ONE tag to query for and ONE extra tag to do further narrowing, if available
 		tagKey, tagValues, goodness := internal.ChooseNarrowestTag(filter)
 
-		// we won't use a tag index for this as long as we have something else to match with
-		if goodness < 2 && (len(filter.Authors) > 0 || len(filter.Kinds) > 0) {
-			goto pubkeyMatching
-		}
-
 		// only "p" tag has a goodness of 2, so
 		if goodness == 2 {
 			// this means we got a "p" tag, so we will use the ptag-kind index
@@ -136,8 +134,7 @@ func (b *LMDBBackend) prepareQueries(filter nostr.Filter) (
 		return queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, nil
 	}
 
-pubkeyMatching:
-	if len(filter.Authors) > 0 {
+	if index == internal.IndexPubkey {
 		if len(filter.Kinds) == 0 {
 			// will use pubkey index
 			queries = make([]query, len(filter.Authors))
@@ -164,7 +161,7 @@ pubkeyMatching:
 		return queries, nil, nil, extraTagKey, extraTagValues, since, nil
 	}
 
-	if len(filter.Kinds) > 0 {
+	if index == internal.IndexKind {
 		// will use a kind index
 		queries = make([]query, len(filter.Kinds))
 		for i, kind := range filter.Kinds {
@@ -173,9 +170,15 @@ pubkeyMatching:
 			queries[i] = query{i: i, dbi: b.indexKind, prefix: prefix[0:2], keySize: 2 + 4}
 		}
 
+		// the planner may have preferred this over the pubkey index, in that case we check authors later
+		if filter.Authors != nil {
+			extraAuthors = make([]nostr.PubKey, len(filter.Authors))
+			copy(extraAuthors, filter.Authors)
+		}
+
 		// potentially with an extra useless tag filtering
 		tagKey, tagValues, _ := internal.ChooseNarrowestTag(filter)
-		return queries, nil, nil, tagKey, tagValues, since, nil
+		return queries, extraAuthors, nil, tagKey, tagValues, since, nil
 	}
 
 	// if we got here our query will have nothing to filter with
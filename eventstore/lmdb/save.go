@@ -62,5 +62,5 @@ func (b *LMDBBackend) save(txn *lmdb.Txn, evt nostr.Event) error {
 		}
 	}
 
-	return nil
+	return b.updateStats(txn, evt, 1)
 }
@@ -0,0 +1,72 @@
+package lmdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"fiatjaf.com/nostr/eventstore"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+var _ eventstore.Snapshotter = (*LMDBBackend)(nil)
+
+// Backup writes a compacted copy of the entire lmdb environment to w.
+// LMDB does this inside a read transaction so the database can keep being written to meanwhile.
+func (b *LMDBBackend) Backup(w io.Writer) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+
+	copyErr := make(chan error, 1)
+	go func() {
+		copyErr <- b.lmdbEnv.CopyFDFlag(pw.Fd(), lmdb.CopyCompact)
+		pw.Close()
+	}()
+
+	if _, err := io.Copy(w, pr); err != nil {
+		// make the copy goroutine fail instead of blocking forever
+		pr.Close()
+		<-copyErr
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := <-copyErr; err != nil {
+		return fmt.Errorf("failed to copy lmdb environment: %w", err)
+	}
+
+	return nil
+}
+
+// Restore replaces the current database with one previously written by Backup.
+// Like Compact, it can only be called when the database is not being used.
+func (b *LMDBBackend) Restore(r io.Reader) error {
+	tmpfile, err := os.CreateTemp(b.Path, "restore-*.mdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := io.Copy(tmpfile, r); err != nil {
+		tmpfile.Close()
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		return err
+	}
+
+	if err := b.lmdbEnv.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpfile.Name(), filepath.Join(b.Path, "data.mdb")); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(b.Path, "lock.mdb")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return b.initialize()
+}
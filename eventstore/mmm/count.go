@@ -13,12 +13,12 @@ import (
 func (il *IndexingLayer) CountEvents(filter nostr.Filter) (uint32, error) {
 	var count uint32 = 0
 
-	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := il.prepareQueries(filter)
-	if err != nil {
-		return 0, err
-	}
+	err := il.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := il.prepareQueries(txn, filter)
+		if err != nil {
+			return err
+		}
 
-	err = il.lmdbEnv.View(func(txn *lmdb.Txn) error {
 		// actually iterate
 		for _, q := range queries {
 			cursor, err := txn.OpenCursor(q.dbi)
@@ -73,13 +73,15 @@ func (il *IndexingLayer) CountEvents(filter nostr.Filter) (uint32, error) {
 					}
 
 					// if there is still a tag to be checked, do it now
-					if !event.Tags.ContainsAny(extraTagKey, extraTagValues) {
+					if extraTagValues != nil && !event.Tags.ContainsAny(extraTagKey, extraTagValues) {
 						it.next()
 						continue
 					}
 
 					count++
 				}
+
+				it.next()
 			}
 		}
 
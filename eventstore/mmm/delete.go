@@ -134,5 +134,5 @@ func (il *IndexingLayer) deleteIndexes(iltxn *lmdb.Txn, event nostr.Event, posby
 		}
 	}
 
-	return nil
+	return il.updateStats(iltxn, event, -1)
 }
@@ -0,0 +1,71 @@
+package mmm
+
+import (
+	"os"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mmm_explain_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	mmmm := &MultiMmapManager{
+		Dir: tmpDir,
+	}
+	err = mmmm.Init()
+	require.NoError(t, err)
+	defer mmmm.Close()
+	il, err := mmmm.EnsureLayer("testlayer")
+	require.NoError(t, err)
+
+	prolific := nostr.Generate()
+	for i := 0; i < 100; i++ {
+		evt := nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1000 + i), Content: "hello", Tags: nostr.Tags{{"t", "nostr"}}}
+		require.NoError(t, evt.Sign(prolific))
+		require.NoError(t, il.SaveEvent(evt))
+	}
+	rare := nostr.Generate()
+	evt := nostr.Event{Kind: 1, CreatedAt: 3000, Content: "gm", Tags: nostr.Tags{{"t", "nostr"}}}
+	require.NoError(t, evt.Sign(rare))
+	require.NoError(t, il.SaveEvent(evt))
+
+	filter := nostr.Filter{
+		Authors: []nostr.PubKey{rare.Public()},
+		Tags:    nostr.TagMap{"t": []string{"nostr"}},
+	}
+	plan, err := il.Explain(filter)
+	require.NoError(t, err)
+	require.Equal(t, "indexPubkey", plan.Index)
+	require.Equal(t, uint64(1), plan.ScannedKeys)
+	require.Equal(t, uint64(1), plan.Matched)
+
+	require.NoError(t, il.DeleteEvent(evt.ID))
+	plan, err = il.Explain(filter)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), plan.EstimatedKeys)
+	require.Equal(t, uint64(0), plan.Matched)
+
+	plan, err = il.Explain(nostr.Filter{Tags: nostr.TagMap{"t": []string{"nostr"}}})
+	require.NoError(t, err)
+	require.Equal(t, "indexTag", plan.Index)
+	require.Equal(t, uint64(100), plan.EstimatedKeys)
+	require.Equal(t, uint64(100), plan.Matched)
+
+	// empty lists must not break anything and must agree with what the query does
+	for _, filter := range []nostr.Filter{
+		{Tags: nostr.TagMap{"t": []string{}}},
+		{Authors: []nostr.PubKey{}},
+		{Kinds: []nostr.Kind{}},
+	} {
+		plan, err := il.Explain(filter)
+		require.NoError(t, err)
+		var n uint64
+		for range il.QueryEvents(filter, 1000) {
+			n++
+		}
+		require.Equal(t, n, plan.Matched)
+	}
+}
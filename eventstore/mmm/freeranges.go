@@ -48,6 +48,12 @@ func (b *MultiMmapManager) gatherFreeRanges(txn *lmdb.Txn) (positions, error) {
 }
 
 func (b *MultiMmapManager) mergeNewFreeRange(newFreeRange position) {
+	if b.snapshotsRunning > 0 {
+		// a snapshot may still be reading from this range, so we can't give it away yet
+		b.pendingFreeRanges = append(b.pendingFreeRanges, newFreeRange)
+		return
+	}
+
 	// use binary search to find the insertion point for the new pos
 	idx, exists := slices.BinarySearchFunc(b.freeRanges, newFreeRange.start, func(item position, target uint64) int {
 		return cmp.Compare(item.start, target)
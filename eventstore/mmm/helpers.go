@@ -296,3 +296,30 @@ func (il *IndexingLayer) getTagIndexPrefix(tagName string, tagValue string) (lmd
 
 	return dbi, k[0 : 1+n+4], offset
 }
+
+func (il *IndexingLayer) dbiName(dbi lmdb.DBI) string {
+	switch dbi {
+	case il.settings:
+		return "settings"
+	case il.stats:
+		return "stats"
+	case il.indexCreatedAt:
+		return "indexCreatedAt"
+	case il.indexKind:
+		return "indexKind"
+	case il.indexPubkey:
+		return "indexPubkey"
+	case il.indexPubkeyKind:
+		return "indexPubkeyKind"
+	case il.indexTag:
+		return "indexTag"
+	case il.indexTag32:
+		return "indexTag32"
+	case il.indexTagAddr:
+		return "indexTagAddr"
+	case il.indexPTagKind:
+		return "indexPTagKind"
+	default:
+		return "<unexpected>"
+	}
+}
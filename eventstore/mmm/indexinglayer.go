@@ -30,6 +30,8 @@ type IndexingLayer struct {
 	indexTag32      lmdb.DBI
 	indexTagAddr    lmdb.DBI
 	indexPTagKind   lmdb.DBI
+
+	stats lmdb.DBI
 }
 
 type IndexingLayers []*IndexingLayer
@@ -59,7 +61,7 @@ func (il *IndexingLayer) Init() error {
 		return err
 	}
 
-	env.SetMaxDBs(9)
+	env.SetMaxDBs(10)
 	env.SetMaxReaders(1000)
 	env.SetMapSize(1 << 38) // ~273GB
 
@@ -121,6 +123,11 @@ func (il *IndexingLayer) Init() error {
 		} else {
 			il.indexPTagKind = dbi
 		}
+		if dbi, err := txn.OpenDBI("stats", lmdb.Create); err != nil {
+			return err
+		} else {
+			il.stats = dbi
+		}
 		return nil
 	}); err != nil {
 		return err
@@ -130,6 +137,10 @@ func (il *IndexingLayer) Init() error {
 		return err
 	}
 
+	if err := il.ensureStats(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -38,6 +38,10 @@ type MultiMmapManager struct {
 	indexId     lmdb.DBI
 
 	freeRanges positions
+
+	// while snapshots are running we can't reuse the space of deleted events, so they go here instead
+	snapshotsRunning  int
+	pendingFreeRanges positions
 }
 
 func (b *MultiMmapManager) String() string {
@@ -232,6 +236,7 @@ func (b *MultiMmapManager) DropLayer(name string) error {
 			il.indexTag32,
 			il.indexTagAddr,
 			il.indexPTagKind,
+			il.stats,
 		} {
 			if err := txn.Drop(dbi, true); err != nil {
 				return err
@@ -111,7 +111,7 @@ func (il *IndexingLayer) QueryEvents(filter nostr.Filter, maxLimit int) iter.Seq
 }
 
 func (il *IndexingLayer) query(txn *lmdb.Txn, filter nostr.Filter, limit int, yield func(nostr.Event) bool) error {
-	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := il.prepareQueries(filter)
+	queries, extraAuthors, extraKinds, extraTagKey, extraTagValues, since, err := il.prepareQueries(txn, filter)
 	if err != nil {
 		return err
 	}
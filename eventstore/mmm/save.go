@@ -159,6 +159,9 @@ func (b *MultiMmapManager) storeOn(
 			b.Logger.Warn().Str("name", il.name).Msg("failed to index event on layer")
 		}
 	}
+	if err := il.updateStats(iltxn, evt, 1); err != nil {
+		b.Logger.Warn().Str("name", il.name).Err(err).Msg("failed to update stats on layer")
+	}
 
 	// add layer to the id index val
 	val = binary.BigEndian.AppendUint16(val, il.id)
@@ -0,0 +1,287 @@
+package mmm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"fiatjaf.com/nostr/eventstore/codec/betterbinary"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// snapshots are a stream of events, each encoded with betterbinary and prefixed by its size as an uint32.
+// on snapshots of the whole MultiMmapManager each event is followed by the names of the layers it belongs to:
+// [uint8 number of layers][uint8 name length][name]...
+
+var _ eventstore.Snapshotter = (*IndexingLayer)(nil)
+
+// beginSnapshot opens read transactions on the given environments and prevents the space of events deleted
+// from now on from being reused until the returned function is called, so everything we read from the mmapped
+// file stays valid for as long as the snapshot is running.
+func (b *MultiMmapManager) beginSnapshot(envs ...*lmdb.Env) ([]*lmdb.Txn, func(), error) {
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+
+	txns := make([]*lmdb.Txn, 0, len(envs))
+	for _, env := range envs {
+		txn, err := env.BeginTxn(nil, lmdb.Readonly)
+		if err != nil {
+			for _, txn := range txns {
+				txn.Abort()
+			}
+			return nil, nil, err
+		}
+		txn.RawRead = true
+		txns = append(txns, txn)
+	}
+	b.snapshotsRunning++
+
+	return txns, func() {
+		for _, txn := range txns {
+			txn.Abort()
+		}
+
+		b.writeMutex.Lock()
+		defer b.writeMutex.Unlock()
+
+		b.snapshotsRunning--
+		if b.snapshotsRunning == 0 {
+			pending := b.pendingFreeRanges
+			b.pendingFreeRanges = nil
+			for _, pos := range pending {
+				b.mergeNewFreeRange(pos)
+			}
+		}
+	}, nil
+}
+
+// Backup writes all the events in this layer to w as they were when it was called, without blocking writes.
+func (il *IndexingLayer) Backup(w io.Writer) error {
+	txns, end, err := il.mmmm.beginSnapshot(il.lmdbEnv)
+	if err != nil {
+		return fmt.Errorf("failed to start snapshot: %w", err)
+	}
+	defer end()
+	iltxn := txns[0]
+
+	// every event in this layer appears exactly once in this index
+	cursor, err := iltxn.OpenCursor(il.indexPubkeyKind)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	bw := bufio.NewWriter(w)
+	for {
+		_, posb, err := cursor.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to iterate layer %s: %w", il.name, err)
+		}
+
+		pos := positionFromBytes(posb)
+		if err := writeSnapshotEvent(bw, il.mmmm.mmapf[pos.start:pos.start+uint64(pos.size)]); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Restore removes all the events from this layer and replaces them with the events from a snapshot.
+func (il *IndexingLayer) Restore(r io.Reader) error {
+	// gather everything we currently have
+	var ids []nostr.ID
+	if err := il.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+
+		cursor, err := txn.OpenCursor(il.indexPubkeyKind)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close()
+
+		for {
+			_, posb, err := cursor.Get(nil, nil, lmdb.Next)
+			if lmdb.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			pos := positionFromBytes(posb)
+			ids = append(ids, betterbinary.GetID(il.mmmm.mmapf[pos.start:pos.start+uint64(pos.size)]))
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to list current events on layer %s: %w", il.name, err)
+	}
+
+	for _, id := range ids {
+		if err := il.DeleteEvent(id); err != nil {
+			return fmt.Errorf("failed to delete %s from layer %s: %w", id, il.name, err)
+		}
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		evt, err := readSnapshotEvent(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := il.SaveEvent(evt); err != nil && err != eventstore.ErrDupEvent {
+			return fmt.Errorf("failed to restore %s: %w", evt.ID, err)
+		}
+	}
+}
+
+// Backup writes all the events from all the layers to w, along with the names of the layers they belong to,
+// as they were when it was called, without blocking writes.
+func (b *MultiMmapManager) Backup(w io.Writer) error {
+	txns, end, err := b.beginSnapshot(b.lmdbEnv)
+	if err != nil {
+		return fmt.Errorf("failed to start snapshot: %w", err)
+	}
+	defer end()
+	txn := txns[0]
+
+	// we may have layers in the database that weren't loaded by the application
+	names := make(map[uint16]string)
+	layersCursor, err := txn.OpenCursor(b.knownLayers)
+	if err != nil {
+		return err
+	}
+	for name, idv, err := layersCursor.Get(nil, nil, lmdb.First); err == nil; name, idv, err = layersCursor.Get(nil, nil, lmdb.Next) {
+		names[binary.BigEndian.Uint16(idv)] = string(name)
+	}
+	layersCursor.Close()
+
+	cursor, err := txn.OpenCursor(b.indexId)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	bw := bufio.NewWriter(w)
+	for {
+		_, val, err := cursor.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to iterate ids: %w", err)
+		}
+
+		pos := positionFromBytes(val[0:12])
+		if err := writeSnapshotEvent(bw, b.mmapf[pos.start:pos.start+uint64(pos.size)]); err != nil {
+			return err
+		}
+
+		bw.WriteByte(byte((len(val) - 12) / 2))
+		for s := 12; s < len(val); s += 2 {
+			name := names[binary.BigEndian.Uint16(val[s:s+2])]
+			bw.WriteByte(byte(len(name)))
+			if _, err := bw.WriteString(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Restore loads a snapshot written by Backup, creating layers as needed. It can only be called on an empty database.
+func (b *MultiMmapManager) Restore(r io.Reader) error {
+	if err := b.lmdbEnv.View(func(txn *lmdb.Txn) error {
+		stat, err := txn.Stat(b.indexId)
+		if err != nil {
+			return err
+		}
+		if stat.Entries > 0 {
+			return errors.New("can only restore into an empty database")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		evt, err := readSnapshotEvent(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		nlayers, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("failed to read layers for %s: %w", evt.ID, err)
+		}
+		for range nlayers {
+			size, err := br.ReadByte()
+			if err != nil {
+				return fmt.Errorf("failed to read layer name for %s: %w", evt.ID, err)
+			}
+			name := make([]byte, size)
+			if _, err := io.ReadFull(br, name); err != nil {
+				return fmt.Errorf("failed to read layer name for %s: %w", evt.ID, err)
+			}
+
+			var il *IndexingLayer
+			if idx := slices.IndexFunc(b.layers, func(il *IndexingLayer) bool { return il.name == string(name) }); idx != -1 {
+				il = b.layers[idx]
+			} else if il, err = b.EnsureLayer(string(name)); err != nil {
+				return fmt.Errorf("failed to create layer %s: %w", name, err)
+			}
+
+			if err := il.SaveEvent(evt); err != nil && err != eventstore.ErrDupEvent {
+				return fmt.Errorf("failed to restore %s on %s: %w", evt.ID, name, err)
+			}
+		}
+	}
+}
+
+func writeSnapshotEvent(w *bufio.Writer, bin []byte) error {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(bin)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(bin)
+	return err
+}
+
+func readSnapshotEvent(r *bufio.Reader) (nostr.Event, error) {
+	var evt nostr.Event
+
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		if err == io.EOF {
+			return evt, io.EOF
+		}
+		return evt, fmt.Errorf("failed to read event size: %w", err)
+	}
+
+	bin := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(r, bin); err != nil {
+		return evt, fmt.Errorf("failed to read event: %w", err)
+	}
+
+	if err := betterbinary.Unmarshal(bin, &evt); err != nil {
+		return evt, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	return evt, nil
+}
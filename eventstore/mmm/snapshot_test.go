@@ -0,0 +1,79 @@
+package mmm
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerSnapshot(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mmm_snapshot_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	mmmm := &MultiMmapManager{Dir: tmpDir + "/a"}
+	require.NoError(t, mmmm.Init())
+	defer mmmm.Close()
+	one, err := mmmm.EnsureLayer("one")
+	require.NoError(t, err)
+	two, err := mmmm.EnsureLayer("two")
+	require.NoError(t, err)
+
+	sk := nostr.Generate()
+	events := make([]nostr.Event, 6)
+	for i := range events {
+		events[i] = nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(100 + i), Content: "x"}
+		require.NoError(t, events[i].Sign(sk))
+		if i%2 == 0 {
+			require.NoError(t, one.SaveEvent(events[i]))
+		}
+		if i%3 == 0 {
+			require.NoError(t, two.SaveEvent(events[i]))
+		}
+	}
+
+	// space freed while a snapshot is running must not be reused until it ends
+	txns, end, err := mmmm.beginSnapshot(mmmm.lmdbEnv)
+	require.NoError(t, err)
+	require.Len(t, txns, 1)
+	rangesBefore := len(mmmm.freeRanges)
+	require.NoError(t, one.DeleteEvent(events[2].ID))
+	require.Len(t, mmmm.freeRanges, rangesBefore)
+	require.Len(t, mmmm.pendingFreeRanges, 1)
+	end()
+	require.Empty(t, mmmm.pendingFreeRanges)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, mmmm.Backup(buf))
+
+	restored := &MultiMmapManager{Dir: tmpDir + "/b"}
+	require.NoError(t, restored.Init())
+	defer restored.Close()
+	require.NoError(t, restored.Restore(bytes.NewReader(buf.Bytes())))
+	require.Len(t, restored.layers, 2)
+
+	for _, il := range restored.layers {
+		ids := make([]nostr.ID, 0, 3)
+		for evt := range il.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{1}}, 10) {
+			ids = append(ids, evt.ID)
+		}
+		slices.SortFunc(ids, func(a, b nostr.ID) int { return bytes.Compare(a[:], b[:]) })
+
+		var expected []nostr.ID
+		switch il.name {
+		case "one":
+			expected = []nostr.ID{events[0].ID, events[4].ID}
+		case "two":
+			expected = []nostr.ID{events[0].ID, events[3].ID}
+		}
+		slices.SortFunc(expected, func(a, b nostr.ID) int { return bytes.Compare(a[:], b[:]) })
+		require.Equal(t, expected, ids, "layer %s", il.name)
+	}
+
+	// restoring again is not allowed
+	require.Error(t, restored.Restore(bytes.NewReader(buf.Bytes())))
+}
@@ -0,0 +1,40 @@
+package eventstore
+
+import "fiatjaf.com/nostr"
+
+// Explainer is implemented by stores that can tell how they would answer a filter.
+type Explainer interface {
+	// Explain runs the filter against the store and returns the plan that was chosen for it
+	// along with the estimated and actual number of index keys scanned.
+	Explain(filter nostr.Filter) (QueryPlan, error)
+}
+
+// QueryPlan describes how a store answered (or would answer) a given filter.
+type QueryPlan struct {
+	// Index is the name of the index that was used for the main scan.
+	Index string `json:"index"`
+
+	// Ranges is the number of prefix ranges scanned on that index, e.g. one per author.
+	Ranges int `json:"ranges"`
+
+	// ExtraChecks lists the filter attributes that had to be checked on each event after it was
+	// read because the chosen index couldn't account for them.
+	ExtraChecks []string `json:"extra_checks,omitempty"`
+
+	// EstimatedKeys is what the store statistics predicted this plan would scan (ignoring since/until).
+	EstimatedKeys uint64 `json:"estimated_keys"`
+
+	// ScannedKeys is the number of index keys actually read.
+	ScannedKeys uint64 `json:"scanned_keys"`
+
+	// Matched is the number of events that passed all the checks.
+	Matched uint64 `json:"matched"`
+
+	// Candidates are all the indexes that were considered, with their estimated costs.
+	Candidates []PlanCandidate `json:"candidates,omitempty"`
+}
+
+type PlanCandidate struct {
+	Index         string `json:"index"`
+	EstimatedKeys uint64 `json:"estimated_keys"`
+}
@@ -0,0 +1,364 @@
+package sharded
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"fiatjaf.com/nostr/eventstore/internal"
+)
+
+var _ eventstore.Store = (*ShardedStore)(nil)
+
+// ShardedStore spreads events across multiple child stores, either by pubkey or by created_at.
+//
+// To shard by pubkey set Shards: each event goes to one of them according to its pubkey, so all events
+// from the same author live in the same shard. The number of shards can't be changed later.
+//
+// To shard by time set Dir, PartitionSize and Open instead: each event goes to a partition covering
+// PartitionSize seconds, kept in its own subdirectory of Dir and opened with Open. Partitions are created
+// as needed and old ones can be dropped entirely with DropBefore.
+//
+// Queries only go to the shards that can have matching events.
+type ShardedStore struct {
+	Shards []eventstore.Store
+
+	Dir           string
+	PartitionSize nostr.Timestamp
+	Open          func(path string) eventstore.Store
+
+	mu         sync.RWMutex
+	partitions []partition // sorted from newest to oldest
+}
+
+type partition struct {
+	start nostr.Timestamp
+	store eventstore.Store
+}
+
+func (p partition) covers(filter nostr.Filter, size nostr.Timestamp) bool {
+	if filter.Since != 0 && p.start+size <= filter.Since {
+		return false
+	}
+	if filter.Until != 0 && p.start > filter.Until {
+		return false
+	}
+	return true
+}
+
+func (s *ShardedStore) byTime() bool { return s.Dir != "" }
+
+func (s *ShardedStore) Init() error {
+	if s.byTime() {
+		if len(s.Shards) > 0 {
+			return fmt.Errorf("can't shard by pubkey and by time at the same time")
+		}
+		if s.PartitionSize <= 0 || s.Open == nil {
+			return fmt.Errorf("sharding by time requires PartitionSize and Open")
+		}
+
+		if err := os.MkdirAll(s.Dir, 0755); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(s.Dir)
+		if err != nil {
+			return err
+		}
+		s.partitions = make([]partition, 0, len(entries))
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			start, err := strconv.ParseUint(entry.Name(), 10, 32)
+			if err != nil {
+				continue
+			}
+			store := s.Open(filepath.Join(s.Dir, entry.Name()))
+			if err := store.Init(); err != nil {
+				return fmt.Errorf("failed to open partition %s: %w", entry.Name(), err)
+			}
+			s.partitions = append(s.partitions, partition{start: nostr.Timestamp(start), store: store})
+		}
+		slices.SortFunc(s.partitions, func(a, b partition) int { return int(b.start) - int(a.start) })
+		return nil
+	}
+
+	if len(s.Shards) == 0 {
+		return fmt.Errorf("no shards")
+	}
+	for i, shard := range s.Shards {
+		if err := shard.Init(); err != nil {
+			return fmt.Errorf("failed to init shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, shard := range s.Shards {
+		shard.Close()
+	}
+	for _, p := range s.partitions {
+		p.store.Close()
+	}
+	s.partitions = nil
+}
+
+// shardFor returns the store an event with these attributes should go to, creating its partition if necessary.
+func (s *ShardedStore) shardFor(pubkey nostr.PubKey, createdAt nostr.Timestamp) (eventstore.Store, error) {
+	if !s.byTime() {
+		// pubkeys are already uniformly distributed, no need to hash them again
+		return s.Shards[binary.BigEndian.Uint64(pubkey[0:8])%uint64(len(s.Shards))], nil
+	}
+
+	start := createdAt - createdAt%s.PartitionSize
+
+	s.mu.RLock()
+	idx, found := s.findPartition(start)
+	var store eventstore.Store
+	if found {
+		store = s.partitions[idx].store
+	}
+	s.mu.RUnlock()
+	if found {
+		return store, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// check again as someone else may have created it in the meantime
+	idx, found = s.findPartition(start)
+	if found {
+		return s.partitions[idx].store, nil
+	}
+
+	path := filepath.Join(s.Dir, strconv.FormatUint(uint64(start), 10))
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create partition directory: %w", err)
+	}
+	store = s.Open(path)
+	if err := store.Init(); err != nil {
+		return nil, fmt.Errorf("failed to open partition %d: %w", start, err)
+	}
+	s.partitions = slices.Insert(s.partitions, idx, partition{start: start, store: store})
+	return store, nil
+}
+
+// findPartition must be called with the lock held.
+func (s *ShardedStore) findPartition(start nostr.Timestamp) (int, bool) {
+	return slices.BinarySearchFunc(s.partitions, start, func(p partition, start nostr.Timestamp) int {
+		return int(start) - int(p.start)
+	})
+}
+
+// shardsFor returns the stores that may have events matching the filter.
+func (s *ShardedStore) shardsFor(filter nostr.Filter) []eventstore.Store {
+	if !s.byTime() {
+		if filter.IDs == nil && len(filter.Authors) > 0 {
+			stores := make([]eventstore.Store, 0, min(len(filter.Authors), len(s.Shards)))
+			for _, pk := range filter.Authors {
+				shard, _ := s.shardFor(pk, 0)
+				if !slices.Contains(stores, shard) {
+					stores = append(stores, shard)
+				}
+			}
+			return stores
+		}
+		return s.Shards
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stores := make([]eventstore.Store, 0, len(s.partitions))
+	for _, p := range s.partitions {
+		if filter.IDs != nil || p.covers(filter, s.PartitionSize) {
+			stores = append(stores, p.store)
+		}
+	}
+	return stores
+}
+
+func (s *ShardedStore) QueryEvents(filter nostr.Filter, maxLimit int) iter.Seq[nostr.Event] {
+	if tlimit := filter.GetTheoreticalLimit(); tlimit == 0 {
+		return func(yield func(nostr.Event) bool) {}
+	} else if tlimit < maxLimit {
+		maxLimit = tlimit
+	}
+
+	stores := s.shardsFor(filter)
+	if len(stores) == 0 {
+		return func(yield func(nostr.Event) bool) {}
+	}
+
+	if filter.IDs != nil {
+		// each id can only be in one place, so just go through them all
+		return func(yield func(nostr.Event) bool) {
+			for _, store := range stores {
+				for evt := range store.QueryEvents(filter, maxLimit) {
+					if !yield(evt) {
+						return
+					}
+				}
+			}
+		}
+	}
+
+	if s.byTime() {
+		// partitions don't overlap and are sorted from newest to oldest,
+		// so we can just go through them in order until we reach the limit
+		return func(yield func(nostr.Event) bool) {
+			count := 0
+			for _, store := range stores {
+				for evt := range store.QueryEvents(filter, maxLimit-count) {
+					if !yield(evt) {
+						return
+					}
+					count++
+				}
+				if count >= maxLimit {
+					return
+				}
+			}
+		}
+	}
+
+	return mergeAll(stores, filter, maxLimit)
+}
+
+// mergeAll merges the results from all the stores with eventstore.SortedMerge, pairing them like a tree so
+// no event has to go through more than log2(len(stores)) comparisons.
+func mergeAll(stores []eventstore.Store, filter nostr.Filter, maxLimit int) iter.Seq[nostr.Event] {
+	if len(stores) == 1 {
+		return stores[0].QueryEvents(filter, maxLimit)
+	}
+	half := len(stores) / 2
+	return eventstore.SortedMerge(
+		mergeAll(stores[0:half], filter, maxLimit),
+		mergeAll(stores[half:], filter, maxLimit),
+		maxLimit,
+	)
+}
+
+func (s *ShardedStore) CountEvents(filter nostr.Filter) (uint32, error) {
+	// every event lives in a single shard, so we can just sum
+	var total uint32
+	for _, store := range s.shardsFor(filter) {
+		count, err := store.CountEvents(filter)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func (s *ShardedStore) SaveEvent(evt nostr.Event) error {
+	store, err := s.shardFor(evt.PubKey, evt.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return store.SaveEvent(evt)
+}
+
+func (s *ShardedStore) ReplaceEvent(evt nostr.Event) error {
+	store, err := s.shardFor(evt.PubKey, evt.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if s.byTime() {
+		// the previous versions may be in other partitions, so we have to take care of them here
+		filter := nostr.Filter{Kinds: []nostr.Kind{evt.Kind}, Authors: []nostr.PubKey{evt.PubKey}}
+		if evt.Kind.IsAddressable() {
+			filter.Tags = nostr.TagMap{"d": []string{evt.Tags.GetD()}}
+		}
+
+		type older struct {
+			store eventstore.Store
+			id    nostr.ID
+		}
+		var olderOnes []older
+		for _, other := range s.shardsFor(nostr.Filter{}) {
+			if other == store {
+				continue
+			}
+			for previous := range other.QueryEvents(filter, 10) {
+				if internal.IsOlder(previous, evt) {
+					olderOnes = append(olderOnes, older{other, previous.ID})
+				} else {
+					// there is a newer event already stored, so we won't store this
+					return nil
+				}
+			}
+		}
+		for _, o := range olderOnes {
+			if err := o.store.DeleteEvent(o.id); err != nil {
+				return fmt.Errorf("failed to delete event %s for replacing: %w", o.id, err)
+			}
+		}
+	}
+
+	return store.ReplaceEvent(evt)
+}
+
+func (s *ShardedStore) DeleteEvent(id nostr.ID) error {
+	// we don't know where it is, so find it first
+	filter := nostr.Filter{IDs: []nostr.ID{id}}
+	for _, store := range s.shardsFor(filter) {
+		for range store.QueryEvents(filter, 1) {
+			return store.DeleteEvent(id)
+		}
+	}
+	return nil
+}
+
+// DropBefore closes and deletes all partitions whose events are all older than the given timestamp.
+// It only works when sharding by time.
+func (s *ShardedStore) DropBefore(ts nostr.Timestamp) error {
+	if !s.byTime() {
+		return errors.New("not sharding by time")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.partitions) > 0 {
+		oldest := s.partitions[len(s.partitions)-1]
+		if oldest.start+s.PartitionSize > ts {
+			break
+		}
+
+		oldest.store.Close()
+		if err := os.RemoveAll(filepath.Join(s.Dir, strconv.FormatUint(uint64(oldest.start), 10))); err != nil {
+			return fmt.Errorf("failed to remove partition %d: %w", oldest.start, err)
+		}
+		s.partitions = s.partitions[0 : len(s.partitions)-1]
+	}
+
+	return nil
+}
+
+// Partitions returns the starting timestamp of all current partitions, from newest to oldest.
+func (s *ShardedStore) Partitions() []nostr.Timestamp {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	starts := make([]nostr.Timestamp, len(s.partitions))
+	for i, p := range s.partitions {
+		starts[i] = p.start
+	}
+	return starts
+}
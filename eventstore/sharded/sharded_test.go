@@ -0,0 +1,127 @@
+package sharded
+
+import (
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedByTime(t *testing.T) {
+	opened := make(map[string]*slicestore.SliceStore)
+	s := &ShardedStore{
+		Dir:           t.TempDir(),
+		PartitionSize: 100,
+		Open: func(path string) eventstore.Store {
+			store := &slicestore.SliceStore{}
+			opened[path] = store
+			return store
+		},
+	}
+	require.NoError(t, s.Init())
+	defer s.Close()
+
+	sk := nostr.Generate()
+	for i := range 50 {
+		evt := nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1000 + i*10), Content: "x"}
+		require.NoError(t, evt.Sign(sk))
+		require.NoError(t, s.SaveEvent(evt))
+	}
+	require.Equal(t, []nostr.Timestamp{1400, 1300, 1200, 1100, 1000}, s.Partitions())
+
+	// results come in order across partitions and respect the limit
+	results := slices.Collect(s.QueryEvents(nostr.Filter{}, 25))
+	require.Len(t, results, 25)
+	require.True(t, slices.IsSortedFunc(results, nostr.CompareEventReverse))
+	require.Equal(t, nostr.Timestamp(1490), results[0].CreatedAt)
+	require.Equal(t, nostr.Timestamp(1250), results[24].CreatedAt)
+
+	// only the partitions that can match are queried
+	require.Len(t, s.shardsFor(nostr.Filter{Since: 1250, Until: 1320}), 2)
+	count, err := s.CountEvents(nostr.Filter{Since: 1250, Until: 1320})
+	require.NoError(t, err)
+	require.Equal(t, uint32(8), count)
+
+	// replaceable events are replaced even when the older version is in another partition
+	v1 := nostr.Event{Kind: 0, CreatedAt: 1010, Content: "{}"}
+	require.NoError(t, v1.Sign(sk))
+	require.NoError(t, s.ReplaceEvent(v1))
+	v2 := nostr.Event{Kind: 0, CreatedAt: 1420, Content: `{"name":"x"}`}
+	require.NoError(t, v2.Sign(sk))
+	require.NoError(t, s.ReplaceEvent(v2))
+	profiles := slices.Collect(s.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{0}}, 10))
+	require.Len(t, profiles, 1)
+	require.Equal(t, v2.ID, profiles[0].ID)
+
+	// and an older one doesn't replace a newer
+	v0 := nostr.Event{Kind: 0, CreatedAt: 1005, Content: "{}"}
+	require.NoError(t, v0.Sign(sk))
+	require.NoError(t, s.ReplaceEvent(v0))
+	profiles = slices.Collect(s.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{0}}, 10))
+	require.Len(t, profiles, 1)
+	require.Equal(t, v2.ID, profiles[0].ID)
+
+	// deleting finds the right partition
+	require.NoError(t, s.DeleteEvent(results[0].ID))
+	require.Empty(t, slices.Collect(s.QueryEvents(nostr.Filter{IDs: []nostr.ID{results[0].ID}}, 1)))
+
+	// dropping old partitions
+	require.NoError(t, s.DropBefore(1250))
+	require.Equal(t, []nostr.Timestamp{1400, 1300, 1200}, s.Partitions())
+	count, err = s.CountEvents(nostr.Filter{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(30-1+1), count)
+
+	// reopening finds the remaining partitions
+	s.Close()
+	require.NoError(t, s.Init())
+	require.Equal(t, []nostr.Timestamp{1400, 1300, 1200}, s.Partitions())
+}
+
+func TestShardedByPubKey(t *testing.T) {
+	shards := []*slicestore.SliceStore{{}, {}, {}, {}}
+	s := &ShardedStore{}
+	for _, shard := range shards {
+		s.Shards = append(s.Shards, shard)
+	}
+	require.NoError(t, s.Init())
+	defer s.Close()
+
+	keys := make([]nostr.SecretKey, 12)
+	for k := range keys {
+		keys[k] = nostr.Generate()
+		for i := range 10 {
+			evt := nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1000 + i*12 + k), Content: "x"}
+			require.NoError(t, evt.Sign(keys[k]))
+			require.NoError(t, s.SaveEvent(evt))
+		}
+	}
+
+	// every author lives in a single shard
+	for _, sk := range keys {
+		with := 0
+		for _, shard := range shards {
+			if len(slices.Collect(shard.QueryEvents(nostr.Filter{Authors: []nostr.PubKey{sk.Public()}}, 100))) > 0 {
+				with++
+			}
+		}
+		require.Equal(t, 1, with)
+		require.Len(t, s.shardsFor(nostr.Filter{Authors: []nostr.PubKey{sk.Public()}}), 1)
+	}
+
+	for _, limit := range []int{7, 50, 100, 500} {
+		results := slices.Collect(s.QueryEvents(nostr.Filter{}, limit))
+		require.Len(t, results, min(limit, 120))
+		require.True(t, slices.IsSortedFunc(results, nostr.CompareEventReverse))
+		require.Equal(t, nostr.Timestamp(1000+9*12+11), results[0].CreatedAt)
+	}
+
+	count, err := s.CountEvents(nostr.Filter{Kinds: []nostr.Kind{1}})
+	require.NoError(t, err)
+	require.Equal(t, uint32(120), count)
+
+	require.Error(t, s.DropBefore(2000))
+}
@@ -42,7 +42,8 @@ func (b *SliceStore) QueryEvents(filter nostr.Filter, maxLimit int) iter.Seq[nos
 			start, _ = slices.BinarySearchFunc(b.internal, filter.Until, eventTimestampComparator)
 		}
 		if filter.Since != 0 {
-			end, _ = slices.BinarySearchFunc(b.internal, filter.Since, eventTimestampComparator)
+			// events are sorted from newest to oldest, so we want to stop at the first one older than since
+			end, _ = slices.BinarySearchFunc(b.internal, filter.Since-1, eventTimestampComparator)
 		}
 
 		// ham
@@ -49,4 +49,11 @@ func TestBasicStuff(t *testing.T) {
 		list = append(list, event)
 	}
 	require.Len(t, list, 5)
+
+	// since is inclusive
+	list = make([]nostr.Event, 0, 5)
+	for event := range ss.QueryEvents(nostr.Filter{Since: nostr.Timestamp(10010)}, 500) {
+		list = append(list, event)
+	}
+	require.Len(t, list, 5)
 }
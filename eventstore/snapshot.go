@@ -0,0 +1,15 @@
+package eventstore
+
+import "io"
+
+// Snapshotter is implemented by stores that can produce a consistent copy of themselves while they are
+// still being written to, and that can be restored from such a copy.
+type Snapshotter interface {
+	// Backup writes a point-in-time snapshot of the entire store to w.
+	// It happens inside a read transaction, so writers are not blocked while it runs.
+	Backup(w io.Writer) error
+
+	// Restore replaces everything in the store with the contents of a snapshot previously produced by
+	// Backup on the same type of store. It must not be called while the store is being used.
+	Restore(r io.Reader) error
+}
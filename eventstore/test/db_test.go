@@ -10,6 +10,7 @@ import (
 	"fiatjaf.com/nostr/eventstore/boltdb"
 	"fiatjaf.com/nostr/eventstore/lmdb"
 	"fiatjaf.com/nostr/eventstore/mmm"
+	"fiatjaf.com/nostr/eventstore/sharded"
 	"fiatjaf.com/nostr/eventstore/slicestore"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
@@ -32,6 +33,7 @@ var tests = []struct {
 	{"second", runSecondTestOn},
 	{"manyauthors", manyAuthorsTest},
 	{"unbalanced", unbalancedTest},
+	{"snapshot", snapshotTest},
 }
 
 func TestSliceStore(t *testing.T) {
@@ -75,3 +77,28 @@ func TestMMM(t *testing.T) {
 		})
 	}
 }
+
+func TestShardedByPubKey(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, &sharded.ShardedStore{
+				Shards: []eventstore.Store{&slicestore.SliceStore{}, &slicestore.SliceStore{}, &slicestore.SliceStore{}},
+			})
+		})
+	}
+}
+
+func TestShardedByTime(t *testing.T) {
+	for _, test := range tests {
+		os.RemoveAll(dbpath + "sharded")
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, &sharded.ShardedStore{
+				Dir:           dbpath + "sharded",
+				PartitionSize: 50,
+				Open: func(path string) eventstore.Store {
+					return &slicestore.SliceStore{}
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotTest(t *testing.T, db eventstore.Store) {
+	err := db.Init()
+	require.NoError(t, err)
+
+	snapshotter, ok := db.(eventstore.Snapshotter)
+	if !ok {
+		t.Skip("store doesn't implement Snapshotter")
+		return
+	}
+
+	before := make([]nostr.Event, 0, 20)
+	for i := 0; i < 20; i++ {
+		evt := nostr.Event{
+			CreatedAt: nostr.Timestamp(100 + i),
+			Content:   fmt.Sprintf("before %d", i),
+			Tags:      nostr.Tags{{"t", "snapshot"}},
+			Kind:      1,
+		}
+		evt.Sign(sk3)
+		require.NoError(t, db.SaveEvent(evt))
+		before = append(before, evt)
+	}
+
+	// take a snapshot while writes keep happening
+	buf := &bytes.Buffer{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			evt := nostr.Event{
+				CreatedAt: nostr.Timestamp(200 + i),
+				Content:   fmt.Sprintf("during %d", i),
+				Kind:      1,
+			}
+			evt.Sign(sk4)
+			db.SaveEvent(evt)
+		}
+	}()
+	require.NoError(t, snapshotter.Backup(buf))
+	<-done
+
+	// delete some events that were in the snapshot and add some that weren't
+	require.NoError(t, db.DeleteEvent(before[0].ID))
+	require.NoError(t, db.DeleteEvent(before[1].ID))
+	after := nostr.Event{CreatedAt: 300, Content: "after", Kind: 1}
+	after.Sign(sk3)
+	require.NoError(t, db.SaveEvent(after))
+
+	require.NoError(t, snapshotter.Restore(buf))
+
+	results := slices.Collect(db.QueryEvents(nostr.Filter{Authors: []nostr.PubKey{sk3.Public()}}, 500))
+	require.Len(t, results, len(before), "should have exactly the events from before the snapshot")
+	for _, evt := range before {
+		require.True(t, slices.ContainsFunc(results, func(res nostr.Event) bool { return res.ID == evt.ID }),
+			"missing %s", evt.ID)
+	}
+
+	// indexes still work after restoring
+	tagged := slices.Collect(db.QueryEvents(nostr.Filter{Tags: nostr.TagMap{"t": []string{"snapshot"}}, Limit: 5}, 500))
+	require.Len(t, tagged, 5)
+	require.Equal(t, before[19].ID, tagged[0].ID)
+}
@@ -0,0 +1,466 @@
+package wrappers
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"fiatjaf.com/nostr/eventstore/codec/betterbinary"
+)
+
+type ChangeOp uint8
+
+const (
+	OpSave ChangeOp = iota + 1
+	OpReplace
+	OpDelete
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case OpSave:
+		return "save"
+	case OpReplace:
+		return "replace"
+	case OpDelete:
+		return "delete"
+	default:
+		return "<unknown>"
+	}
+}
+
+// Change is something that happened to the underlying store.
+type Change struct {
+	Seq  uint64
+	Op   ChangeOp
+	Time nostr.Timestamp
+
+	// ID is always set, for deletions it's the only thing we have.
+	ID nostr.ID
+
+	// Event is only set for OpSave and OpReplace.
+	Event nostr.Event
+}
+
+var _ eventstore.Store = (*ChangeFeed)(nil)
+
+// ChangeFeed wraps a store and records every successful SaveEvent, ReplaceEvent and DeleteEvent in a log,
+// giving each change a monotonic sequence number. Followers (search indexes, caches, replicas) can then
+// call Subscribe with the last sequence number they've processed and resume from there after a restart.
+//
+// Changes are appended to the log right after the underlying store accepts them, so a crash between the
+// two may cause a single change to be missing from the log.
+type ChangeFeed struct {
+	eventstore.Store
+
+	// Dir is where the log segments are kept. If empty the log will live only in memory.
+	Dir string
+
+	// KeepChanges is the minimum number of changes that must be kept, zero means keep everything.
+	// KeepFor is the minimum amount of time a change must be kept for, zero means it doesn't matter.
+	// When both are set a change is only discarded after it's both older and outside the count.
+	KeepChanges uint64
+	KeepFor     time.Duration
+
+	// SegmentSize is the number of changes per log file, old changes are discarded one file at a time.
+	SegmentSize int
+
+	mu       sync.Mutex
+	lastSeq  uint64
+	segments []segment
+	current  *os.File
+	memory   []Change
+	notify   chan struct{}
+	closed   bool
+}
+
+type segment struct {
+	path     string
+	first    uint64
+	last     uint64
+	count    int
+	lastTime nostr.Timestamp
+}
+
+func (cf *ChangeFeed) Init() error {
+	if err := cf.Store.Init(); err != nil {
+		return err
+	}
+
+	if cf.SegmentSize == 0 {
+		cf.SegmentSize = 10_000
+	}
+	cf.notify = make(chan struct{})
+
+	if cf.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(cf.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create change log directory: %w", err)
+	}
+	entries, err := os.ReadDir(cf.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		seg := segment{path: filepath.Join(cf.Dir, name), first: first}
+		validSize, err := scanSegment(seg.path, func(c Change) bool {
+			seg.last = c.Seq
+			seg.count++
+			seg.lastTime = c.Time
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read change log segment %s: %w", name, err)
+		}
+
+		// get rid of partially written records at the end (from a crash)
+		if err := os.Truncate(seg.path, validSize); err != nil {
+			return err
+		}
+
+		if seg.count > 0 {
+			cf.segments = append(cf.segments, seg)
+		} else {
+			os.Remove(seg.path)
+		}
+	}
+	slices.SortFunc(cf.segments, func(a, b segment) int { return cmp.Compare(a.first, b.first) })
+
+	if len(cf.segments) > 0 {
+		last := cf.segments[len(cf.segments)-1]
+		cf.lastSeq = last.last
+
+		if last.count < cf.SegmentSize {
+			cf.current, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (cf *ChangeFeed) Close() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if cf.closed {
+		return
+	}
+	cf.closed = true
+	close(cf.notify)
+
+	if cf.current != nil {
+		cf.current.Close()
+	}
+	cf.Store.Close()
+}
+
+func (cf *ChangeFeed) SaveEvent(evt nostr.Event) error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.Store.SaveEvent(evt); err != nil {
+		return err
+	}
+	return cf.append(Change{Op: OpSave, ID: evt.ID, Event: evt})
+}
+
+func (cf *ChangeFeed) ReplaceEvent(evt nostr.Event) error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.Store.ReplaceEvent(evt); err != nil {
+		return err
+	}
+	return cf.append(Change{Op: OpReplace, ID: evt.ID, Event: evt})
+}
+
+func (cf *ChangeFeed) DeleteEvent(id nostr.ID) error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.Store.DeleteEvent(id); err != nil {
+		return err
+	}
+	return cf.append(Change{Op: OpDelete, ID: id})
+}
+
+// LastSeq is the sequence number of the latest change, or zero if nothing has happened yet.
+func (cf *ChangeFeed) LastSeq() uint64 {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.lastSeq
+}
+
+// OldestSeq is the sequence number of the oldest change still in the log, or zero if the log is empty.
+func (cf *ChangeFeed) OldestSeq() uint64 {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if cf.Dir == "" {
+		if len(cf.memory) == 0 {
+			return 0
+		}
+		return cf.memory[0].Seq
+	}
+	if len(cf.segments) == 0 {
+		return 0
+	}
+	return cf.segments[0].first
+}
+
+// Subscribe emits all changes starting at fromSeq (or the oldest change still kept, if fromSeq was already
+// discarded -- callers can tell that happened by checking the Seq of the first change they get), then keeps
+// emitting new changes as they happen until ctx is canceled or the store is closed.
+func (cf *ChangeFeed) Subscribe(ctx context.Context, fromSeq uint64) iter.Seq[Change] {
+	return func(yield func(Change) bool) {
+		next := max(fromSeq, 1)
+
+		for {
+			cf.mu.Lock()
+			closed := cf.closed
+			notify := cf.notify
+			lastSeq := cf.lastSeq
+			segments := slices.Clone(cf.segments)
+			var memory []Change
+			if cf.Dir == "" {
+				memory = slices.Clone(cf.memory)
+			}
+			cf.mu.Unlock()
+
+			if next <= lastSeq {
+				stop := false
+				emit := func(c Change) bool {
+					if c.Seq < next {
+						return true
+					}
+					if c.Seq > lastSeq {
+						return false
+					}
+					next = c.Seq + 1
+					if !yield(c) {
+						stop = true
+						return false
+					}
+					return true
+				}
+
+				if cf.Dir == "" {
+					for _, c := range memory {
+						if !emit(c) {
+							break
+						}
+					}
+				} else {
+					for _, seg := range segments {
+						if seg.last < next {
+							continue
+						}
+						if _, err := scanSegment(seg.path, emit); err != nil {
+							// segment was probably removed by retention while we were reading, start again
+							break
+						}
+						if stop || next > lastSeq {
+							break
+						}
+					}
+				}
+
+				if stop {
+					return
+				}
+				if next <= lastSeq {
+					// something we expected was gone, try again with fresh segments
+					continue
+				}
+			}
+
+			if closed {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			}
+		}
+	}
+}
+
+// append must be called with the lock held.
+func (cf *ChangeFeed) append(change Change) error {
+	cf.lastSeq++
+	change.Seq = cf.lastSeq
+	change.Time = nostr.Now()
+
+	defer func() {
+		// wake up subscribers
+		if !cf.closed {
+			close(cf.notify)
+			cf.notify = make(chan struct{})
+		}
+	}()
+
+	if cf.Dir == "" {
+		cf.memory = append(cf.memory, change)
+		if cf.KeepChanges > 0 || cf.KeepFor > 0 {
+			cutoff := nostr.Now() - nostr.Timestamp(cf.KeepFor.Seconds())
+			drop := 0
+			for drop < len(cf.memory)-1 &&
+				(cf.KeepChanges == 0 || uint64(len(cf.memory)-drop) > cf.KeepChanges) &&
+				(cf.KeepFor == 0 || cf.memory[drop].Time < cutoff) {
+				drop++
+			}
+			cf.memory = slices.Delete(cf.memory, 0, drop)
+		}
+		return nil
+	}
+
+	if cf.current == nil {
+		path := filepath.Join(cf.Dir, fmt.Sprintf("%020d.log", change.Seq))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create change log segment: %w", err)
+		}
+		cf.current = f
+		cf.segments = append(cf.segments, segment{path: path, first: change.Seq})
+	}
+
+	if _, err := cf.current.Write(encodeChange(change)); err != nil {
+		return fmt.Errorf("failed to write to change log: %w", err)
+	}
+	if err := cf.current.Sync(); err != nil {
+		return fmt.Errorf("failed to sync change log: %w", err)
+	}
+
+	seg := &cf.segments[len(cf.segments)-1]
+	seg.last = change.Seq
+	seg.count++
+	seg.lastTime = change.Time
+
+	if seg.count >= cf.SegmentSize {
+		cf.current.Close()
+		cf.current = nil
+		cf.applyRetention()
+	}
+
+	return nil
+}
+
+// applyRetention deletes old segments, it must be called with the lock held.
+func (cf *ChangeFeed) applyRetention() {
+	if cf.KeepChanges == 0 && cf.KeepFor == 0 {
+		return
+	}
+
+	cutoff := nostr.Now() - nostr.Timestamp(cf.KeepFor.Seconds())
+	for len(cf.segments) > 1 {
+		oldest := cf.segments[0]
+		if cf.KeepChanges > 0 && cf.lastSeq-oldest.last < cf.KeepChanges {
+			break
+		}
+		if cf.KeepFor > 0 && oldest.lastTime >= cutoff {
+			break
+		}
+		os.Remove(oldest.path)
+		cf.segments = cf.segments[1:]
+	}
+}
+
+// changes are encoded as [size: 4][seq: 8][op: 1][time: 4][id: 32][event in betterbinary, if any]
+func encodeChange(change Change) []byte {
+	size := 8 + 1 + 4 + 32
+	if change.Op != OpDelete {
+		size += betterbinary.Measure(change.Event)
+	}
+
+	buf := make([]byte, 4+size)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(size))
+	binary.BigEndian.PutUint64(buf[4:12], change.Seq)
+	buf[12] = byte(change.Op)
+	binary.BigEndian.PutUint32(buf[13:17], uint32(change.Time))
+	copy(buf[17:49], change.ID[:])
+	if change.Op != OpDelete {
+		betterbinary.Marshal(change.Event, buf[49:])
+	}
+
+	return buf
+}
+
+// scanSegment calls fn with every change in a segment file and returns the size of the file up to
+// the last complete record.
+func scanSegment(path string, fn func(Change) bool) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var valid int64
+	var sizeb [4]byte
+	for {
+		if _, err := io.ReadFull(r, sizeb[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return valid, nil
+			}
+			return valid, err
+		}
+		size := binary.BigEndian.Uint32(sizeb[:])
+		if size < 8+1+4+32 {
+			return valid, nil
+		}
+
+		rec := make([]byte, size)
+		if _, err := io.ReadFull(r, rec); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return valid, nil
+			}
+			return valid, err
+		}
+
+		change := Change{
+			Seq:  binary.BigEndian.Uint64(rec[0:8]),
+			Op:   ChangeOp(rec[8]),
+			Time: nostr.Timestamp(binary.BigEndian.Uint32(rec[9:13])),
+		}
+		copy(change.ID[:], rec[13:45])
+		if change.Op != OpDelete {
+			if err := betterbinary.Unmarshal(rec[45:], &change.Event); err != nil {
+				return valid, nil
+			}
+		}
+
+		valid += 4 + int64(size)
+		if !fn(change) {
+			return valid, nil
+		}
+	}
+}
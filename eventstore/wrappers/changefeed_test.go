@@ -0,0 +1,93 @@
+package wrappers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeed(t *testing.T) {
+	dir, err := os.MkdirTemp("", "changefeed_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cf := &ChangeFeed{Store: &slicestore.SliceStore{}, Dir: dir, SegmentSize: 4, KeepChanges: 6}
+	require.NoError(t, cf.Init())
+
+	events := make([]nostr.Event, 10)
+	for i := range events {
+		events[i] = nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(i), Content: "hello"}
+		events[i].Sign(sk)
+		require.NoError(t, cf.SaveEvent(events[i]))
+	}
+	require.NoError(t, cf.DeleteEvent(events[3].ID))
+
+	replaceable := nostr.Event{Kind: 0, CreatedAt: 1, Content: "{}"}
+	replaceable.Sign(sk)
+	require.NoError(t, cf.ReplaceEvent(replaceable))
+
+	// a save that fails is not recorded
+	require.Error(t, cf.SaveEvent(events[5]))
+
+	require.Equal(t, uint64(12), cf.LastSeq())
+	// first segments should have been dropped
+	require.Equal(t, uint64(5), cf.OldestSeq())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	seen := make([]Change, 0, 8)
+	for change := range cf.Subscribe(ctx, 7) {
+		seen = append(seen, change)
+		if change.Seq == 12 {
+			break
+		}
+	}
+	require.Len(t, seen, 6)
+	require.Equal(t, uint64(7), seen[0].Seq)
+	require.Equal(t, events[6].ID, seen[0].Event.ID)
+	require.Equal(t, OpDelete, seen[4].Op)
+	require.Equal(t, events[3].ID, seen[4].ID)
+	require.Equal(t, OpReplace, seen[5].Op)
+	require.Equal(t, replaceable.Content, seen[5].Event.Content)
+
+	// live changes and resuming after a restart
+	live := make(chan Change)
+	go func() {
+		for change := range cf.Subscribe(ctx, 13) {
+			live <- change
+		}
+		close(live)
+	}()
+	time.Sleep(time.Millisecond * 20)
+	require.NoError(t, cf.DeleteEvent(events[0].ID))
+	change := <-live
+	require.Equal(t, uint64(13), change.Seq)
+	require.Equal(t, events[0].ID, change.ID)
+
+	cf.Close()
+	_, open := <-live
+	require.False(t, open, "subscription should end when the store is closed")
+
+	cf = &ChangeFeed{Store: &slicestore.SliceStore{}, Dir: dir, SegmentSize: 4}
+	require.NoError(t, cf.Init())
+	defer cf.Close()
+	require.Equal(t, uint64(13), cf.LastSeq())
+	require.NoError(t, cf.SaveEvent(events[0]))
+	require.Equal(t, uint64(14), cf.LastSeq())
+
+	seen = seen[:0]
+	for change := range cf.Subscribe(ctx, 12) {
+		seen = append(seen, change)
+		if change.Seq == 14 {
+			break
+		}
+	}
+	require.Len(t, seen, 3)
+	require.Equal(t, events[0].ID, seen[2].Event.ID)
+}
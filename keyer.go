@@ -41,3 +41,9 @@ type Cipher interface {
 	// Returns the decrypted plaintext.
 	Decrypt(ctx context.Context, base64ciphertext string, sender PubKey) (plaintext string, err error)
 }
+
+// NIP04Decrypter is an optional interface for Keyers that can also decrypt the deprecated NIP-04
+// ciphertexts that are still found in old events.
+type NIP04Decrypter interface {
+	NIP04Decrypt(ctx context.Context, ciphertext string, sender PubKey) (plaintext string, err error)
+}
@@ -9,7 +9,10 @@ import (
 	"fiatjaf.com/nostr/nip46"
 )
 
-var _ nostr.Keyer = (*BunkerSigner)(nil)
+var (
+	_ nostr.Keyer          = (*BunkerSigner)(nil)
+	_ nostr.NIP04Decrypter = (*BunkerSigner)(nil)
+)
 
 // BunkerSigner is a signer that delegates operations to a remote bunker using NIP-46.
 // It communicates with the bunker for all cryptographic operations rather than
@@ -50,5 +53,10 @@ func (bs BunkerSigner) Encrypt(ctx context.Context, plaintext string, recipient
 
 // Decrypt decrypts a base64-encoded ciphertext from a sender using the remote bunker.
 func (bs BunkerSigner) Decrypt(ctx context.Context, base64ciphertext string, sender nostr.PubKey) (plaintext string, err error) {
-	return bs.bunker.NIP44Encrypt(ctx, sender, base64ciphertext)
+	return bs.bunker.NIP44Decrypt(ctx, sender, base64ciphertext)
+}
+
+// NIP04Decrypt decrypts a legacy NIP-04 ciphertext from a sender using the remote bunker.
+func (bs BunkerSigner) NIP04Decrypt(ctx context.Context, ciphertext string, sender nostr.PubKey) (plaintext string, err error) {
+	return bs.bunker.NIP04Decrypt(ctx, sender, ciphertext)
 }
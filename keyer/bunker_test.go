@@ -0,0 +1,58 @@
+package keyer
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"fiatjaf.com/nostr/khatru"
+	"fiatjaf.com/nostr/nip44"
+	"fiatjaf.com/nostr/nip46"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunkerSignerDecrypt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := khatru.NewRelay()
+	db := &slicestore.SliceStore{}
+	db.Init()
+	relay.UseEventstore(db, 500)
+	server := httptest.NewServer(relay)
+	defer server.Close()
+	url := "ws" + server.URL[4:]
+
+	// run a bunker holding bunkerSK that answers requests through the relay
+	bunkerSK := nostr.Generate()
+	signer := nip46.NewStaticKeySigner(bunkerSK)
+	pool := nostr.NewPool(nostr.PoolOptions{})
+	go func() {
+		for ie := range pool.SubscribeMany(ctx, []string{url}, nostr.Filter{
+			Kinds: []nostr.Kind{nostr.KindNostrConnect},
+			Tags:  nostr.TagMap{"p": []string{bunkerSK.Public().Hex()}},
+		}, nostr.SubscriptionOptions{}) {
+			_, _, resp, err := signer.HandleRequest(ctx, ie.Event)
+			if err != nil {
+				continue
+			}
+			pool.PublishMany(ctx, []string{url}, resp)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	bs := NewBunkerSignerFromBunkerClient(nip46.NewBunker(ctx, nostr.Generate(), bunkerSK.Public(), []string{url}, pool, nil))
+
+	sender := nostr.Generate()
+	ck, err := nip44.GenerateConversationKey(bunkerSK.Public(), sender)
+	require.NoError(t, err)
+	ciphertext, err := nip44.Encrypt("hello from the sender", ck)
+	require.NoError(t, err)
+
+	plaintext, err := bs.Decrypt(ctx, ciphertext, sender.Public())
+	require.NoError(t, err)
+	require.Equal(t, "hello from the sender", plaintext)
+}
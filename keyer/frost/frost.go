@@ -0,0 +1,352 @@
+// Package frost implements FROST threshold Schnorr signatures (t-of-n) over secp256k1, adapted so the
+// resulting signatures are valid BIP-340 signatures for the group public key, i.e. valid nostr events.
+//
+// Keys can be created by a trusted dealer (GenerateWithTrustedDealer) or without anyone ever knowing the
+// full key through a distributed key generation (NewDKG, RunDKG). Signing happens in two rounds between
+// the participants, coordinated by the Signer, which implements nostr.Signer.
+package frost
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"fiatjaf.com/nostr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Point is a compressed secp256k1 point, as exchanged between participants.
+type Point [33]byte
+
+func (p Point) MarshalJSON() ([]byte, error) { return json.Marshal(hex.EncodeToString(p[:])) }
+
+func (p *Point) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if len(s) != 66 {
+		return fmt.Errorf("point must be 66 hex characters, got %d", len(s))
+	}
+	_, err := hex.Decode(p[:], []byte(s))
+	return err
+}
+
+func (p Point) jacobian() (secp256k1.JacobianPoint, error) {
+	var j secp256k1.JacobianPoint
+	pk, err := secp256k1.ParsePubKey(p[:])
+	if err != nil {
+		return j, err
+	}
+	pk.AsJacobian(&j)
+	return j, nil
+}
+
+func pointFromJacobian(j *secp256k1.JacobianPoint) Point {
+	affine := *j
+	affine.ToAffine()
+	return Point(secp256k1.NewPublicKey(&affine.X, &affine.Y).SerializeCompressed())
+}
+
+// Scalar is a number modulo the curve order, as exchanged between participants.
+type Scalar [32]byte
+
+func (s Scalar) MarshalJSON() ([]byte, error) { return json.Marshal(hex.EncodeToString(s[:])) }
+
+func (s *Scalar) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	if len(str) != 64 {
+		return fmt.Errorf("scalar must be 64 hex characters, got %d", len(str))
+	}
+	_, err := hex.Decode(s[:], []byte(str))
+	return err
+}
+
+func (s Scalar) modN() (secp256k1.ModNScalar, error) {
+	var m secp256k1.ModNScalar
+	if overflow := m.SetBytes((*[32]byte)(&s)); overflow != 0 {
+		return m, errors.New("scalar is not below the curve order")
+	}
+	return m, nil
+}
+
+// KeyShare is what each participant holds: its share of the secret key plus the public information
+// needed to sign and to verify the other participants' contributions.
+type KeyShare struct {
+	// Index identifies this participant, from 1 to the total number of participants.
+	Index int
+
+	// Threshold is the number of participants needed to produce a signature.
+	Threshold int
+
+	// PublicKey is the group key, the one that shows up in the signed events.
+	PublicKey nostr.PubKey
+
+	// PublicShares has the public counterpart of the share of every participant, by index.
+	PublicShares map[int]Point
+
+	secret secp256k1.ModNScalar
+}
+
+// Participants returns the indexes of all the participants, sorted.
+func (ks *KeyShare) Participants() []int {
+	indexes := make([]int, 0, len(ks.PublicShares))
+	for idx := range ks.PublicShares {
+		indexes = append(indexes, idx)
+	}
+	slices.Sort(indexes)
+	return indexes
+}
+
+func (ks *KeyShare) check() error {
+	if ks.Threshold < 1 || ks.Threshold > len(ks.PublicShares) {
+		return fmt.Errorf("invalid threshold %d for %d participants", ks.Threshold, len(ks.PublicShares))
+	}
+	var computed secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&ks.secret, &computed)
+	if pointFromJacobian(&computed) != ks.PublicShares[ks.Index] {
+		return fmt.Errorf("secret share doesn't match the public share for %d", ks.Index)
+	}
+	return nil
+}
+
+// Commitment is the public part of the nonces a participant generates for one signing session.
+type Commitment struct {
+	Index   int   `json:"index"`
+	Hiding  Point `json:"hiding"`
+	Binding Point `json:"binding"`
+}
+
+type nonces struct {
+	hiding     secp256k1.ModNScalar
+	binding    secp256k1.ModNScalar
+	commitment Commitment
+}
+
+func newNonces(ks *KeyShare) nonces {
+	// nonces are derived from the secret share too, so a bad random number generator isn't catastrophic
+	secret := ks.secret.Bytes()
+	n := nonces{commitment: Commitment{Index: ks.Index}}
+	for i, nonce := range []*secp256k1.ModNScalar{&n.hiding, &n.binding} {
+		for nonce.IsZero() {
+			var random [32]byte
+			rand.Read(random[:])
+			nonce.SetByteSlice(taggedHash("FROST/nostr/nonce", random[:], secret[:]))
+		}
+		var p secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(nonce, &p)
+		if i == 0 {
+			n.commitment.Hiding = pointFromJacobian(&p)
+		} else {
+			n.commitment.Binding = pointFromJacobian(&p)
+		}
+	}
+	return n
+}
+
+// signingContext has everything derived from the group key, message and commitments that both the
+// coordinator and the participants need.
+type signingContext struct {
+	groupKey     nostr.PubKey
+	msg          [32]byte
+	commitments  map[int]secp256k1.JacobianPoint // D_i + rho_i*E_i
+	rho          map[int]secp256k1.ModNScalar
+	lambda       map[int]secp256k1.ModNScalar
+	r            secp256k1.JacobianPoint // affine
+	rOdd         bool
+	c            secp256k1.ModNScalar
+	participants []int
+}
+
+func newSigningContext(groupKey nostr.PubKey, msg [32]byte, commitments []Commitment) (*signingContext, error) {
+	if len(commitments) == 0 {
+		return nil, errors.New("no commitments")
+	}
+	commitments = slices.Clone(commitments)
+	slices.SortFunc(commitments, func(a, b Commitment) int { return a.Index - b.Index })
+
+	sc := &signingContext{
+		groupKey:     groupKey,
+		msg:          msg,
+		commitments:  make(map[int]secp256k1.JacobianPoint, len(commitments)),
+		rho:          make(map[int]secp256k1.ModNScalar, len(commitments)),
+		lambda:       make(map[int]secp256k1.ModNScalar, len(commitments)),
+		participants: make([]int, len(commitments)),
+	}
+
+	encoded := make([]byte, 0, len(commitments)*(4+33+33))
+	for i, c := range commitments {
+		if i > 0 && commitments[i-1].Index == c.Index {
+			return nil, fmt.Errorf("duplicate commitment from %d", c.Index)
+		}
+		if c.Index < 1 {
+			return nil, fmt.Errorf("invalid participant index %d", c.Index)
+		}
+		sc.participants[i] = c.Index
+		encoded = binary.BigEndian.AppendUint32(encoded, uint32(c.Index))
+		encoded = append(encoded, c.Hiding[:]...)
+		encoded = append(encoded, c.Binding[:]...)
+	}
+
+	for _, c := range commitments {
+		var idx [4]byte
+		binary.BigEndian.PutUint32(idx[:], uint32(c.Index))
+		var rho secp256k1.ModNScalar
+		rho.SetByteSlice(taggedHash("FROST/nostr/rho", groupKey[:], msg[:], encoded, idx[:]))
+		sc.rho[c.Index] = rho
+
+		d, err := c.Hiding.jacobian()
+		if err != nil {
+			return nil, fmt.Errorf("invalid hiding commitment from %d: %w", c.Index, err)
+		}
+		e, err := c.Binding.jacobian()
+		if err != nil {
+			return nil, fmt.Errorf("invalid binding commitment from %d: %w", c.Index, err)
+		}
+		var ri secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(&rho, &e, &ri)
+		secp256k1.AddNonConst(&d, &ri, &ri)
+		sc.commitments[c.Index] = ri
+		secp256k1.AddNonConst(&sc.r, &ri, &sc.r)
+
+		sc.lambda[c.Index] = lagrangeCoefficient(c.Index, sc.participants)
+	}
+
+	if isInfinity(&sc.r) {
+		return nil, errors.New("group commitment is the point at infinity")
+	}
+	sc.r.ToAffine()
+	sc.rOdd = sc.r.Y.IsOdd()
+
+	rx := sc.r.X.Bytes()
+	sc.c.SetByteSlice(taggedHash("BIP0340/challenge", rx[:], groupKey[:], msg[:]))
+
+	return sc, nil
+}
+
+// signShare computes z_i = d_i + rho_i*e_i + lambda_i*s_i*c (with the nonce part negated if R has odd y).
+func (sc *signingContext) signShare(ks *KeyShare, n *nonces) Scalar {
+	rho := sc.rho[ks.Index]
+	lambda := sc.lambda[ks.Index]
+
+	var z secp256k1.ModNScalar
+	z.Mul2(&n.binding, &rho).Add(&n.hiding)
+	if sc.rOdd {
+		z.Negate()
+	}
+
+	var sl secp256k1.ModNScalar
+	sl.Mul2(&ks.secret, &lambda).Mul(&sc.c)
+	z.Add(&sl)
+
+	return Scalar(z.Bytes())
+}
+
+// verifyShare checks z_i*G == ±(D_i + rho_i*E_i) + c*lambda_i*Y_i.
+func (sc *signingContext) verifyShare(index int, share Scalar, publicShare Point) error {
+	z, err := share.modN()
+	if err != nil {
+		return err
+	}
+	y, err := publicShare.jacobian()
+	if err != nil {
+		return err
+	}
+
+	var lhs secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&z, &lhs)
+
+	rhs := sc.commitments[index]
+	if sc.rOdd {
+		rhs.Y.Normalize().Negate(1).Normalize()
+	}
+	lambda := sc.lambda[index]
+	var cl secp256k1.ModNScalar
+	cl.Mul2(&sc.c, &lambda)
+	var cly secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(&cl, &y, &cly)
+	secp256k1.AddNonConst(&rhs, &cly, &rhs)
+
+	if !lhs.EquivalentNonConst(&rhs) {
+		return fmt.Errorf("invalid signature share from %d", index)
+	}
+	return nil
+}
+
+// aggregate sums the shares into the final BIP-340 signature (R.x, z).
+func (sc *signingContext) aggregate(shares map[int]Scalar) ([64]byte, error) {
+	var sig [64]byte
+	var z secp256k1.ModNScalar
+	for _, idx := range sc.participants {
+		share, ok := shares[idx]
+		if !ok {
+			return sig, fmt.Errorf("missing signature share from %d", idx)
+		}
+		zi, err := share.modN()
+		if err != nil {
+			return sig, err
+		}
+		z.Add(&zi)
+	}
+
+	rx := sc.r.X.Bytes()
+	copy(sig[0:32], rx[:])
+	zb := z.Bytes()
+	copy(sig[32:64], zb[:])
+	return sig, nil
+}
+
+// lagrangeCoefficient computes the coefficient for index at x=0 given all the participants.
+func lagrangeCoefficient(index int, participants []int) secp256k1.ModNScalar {
+	var num, den secp256k1.ModNScalar
+	num.SetInt(1)
+	den.SetInt(1)
+	for _, j := range participants {
+		if j == index {
+			continue
+		}
+		var xj, diff secp256k1.ModNScalar
+		xj.SetInt(uint32(j))
+		num.Mul(&xj)
+
+		diff.SetInt(uint32(index))
+		diff.Negate().Add(&xj) // j - i
+		den.Mul(&diff)
+	}
+	return *num.Mul(den.InverseNonConst())
+}
+
+func isInfinity(p *secp256k1.JacobianPoint) bool {
+	return (p.X.IsZero() && p.Y.IsZero()) || p.Z.IsZero()
+}
+
+func taggedHash(tag string, parts ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+func randomScalar() secp256k1.ModNScalar {
+	var s secp256k1.ModNScalar
+	for s.IsZero() {
+		var b [32]byte
+		rand.Read(b[:])
+		if overflow := s.SetBytes(&b); overflow != 0 {
+			s.Zero()
+		}
+	}
+	return s
+}
@@ -0,0 +1,197 @@
+package frost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"fiatjaf.com/nostr/keyer"
+	"fiatjaf.com/nostr/khatru"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/stretchr/testify/require"
+)
+
+func startSigners(t *testing.T, ctx context.Context, shares []*KeyShare, transports []Transport) []*Signer {
+	signers := make([]*Signer, len(shares))
+	for i, share := range shares {
+		signer, err := NewSigner(share, transports[i])
+		require.NoError(t, err)
+		go signer.Run(ctx)
+		signers[i] = signer
+	}
+	return signers
+}
+
+func memoryTransports(n int) []Transport {
+	transports := make([]Transport, n)
+	for i, mt := range NewMemoryTransports(n) {
+		transports[i] = mt
+	}
+	return transports
+}
+
+func TestTrustedDealer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sk := nostr.Generate()
+	shares, err := GenerateWithTrustedDealer(2, 3, &sk)
+	require.NoError(t, err)
+	require.Equal(t, sk.Public(), shares[0].PublicKey)
+
+	signers := startSigners(t, ctx, shares, memoryTransports(3))
+	for _, signer := range signers {
+		evt := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "signed by a committee"}
+		require.NoError(t, signer.SignEvent(ctx, &evt))
+		require.True(t, evt.VerifySignature())
+		require.True(t, evt.CheckID())
+		require.Equal(t, sk.Public(), evt.PubKey)
+	}
+}
+
+func TestDKG(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, params := range [][2]int{{1, 1}, {2, 2}, {3, 5}, {5, 5}} {
+		threshold, total := params[0], params[1]
+		t.Run(fmt.Sprintf("%d-of-%d", threshold, total), func(t *testing.T) {
+			transports := memoryTransports(total)
+			shares := make([]*KeyShare, total)
+			errs := make([]error, total)
+			wg := sync.WaitGroup{}
+			for i := range total {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					shares[i], errs[i] = RunDKG(ctx, transports[i], i+1, threshold, total, "test")
+				}()
+			}
+			wg.Wait()
+			require.NoError(t, errors.Join(errs...))
+			for _, share := range shares {
+				require.Equal(t, shares[0].PublicKey, share.PublicKey)
+				require.Equal(t, shares[0].PublicShares, share.PublicShares)
+			}
+
+			signers := startSigners(t, ctx, shares, transports)
+			for i := range 5 {
+				evt := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: fmt.Sprintf("dkg %d", i)}
+				require.NoError(t, signers[i%total].SignEvent(ctx, &evt))
+				require.True(t, evt.VerifySignature())
+				require.Equal(t, shares[0].PublicKey, evt.PubKey)
+			}
+		})
+	}
+}
+
+func TestNotEnoughSigners(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shares, err := GenerateWithTrustedDealer(3, 4, nil)
+	require.NoError(t, err)
+	signers := startSigners(t, ctx, shares, memoryTransports(4))
+
+	// two refuse, so we can't reach 3
+	for _, signer := range signers[2:] {
+		signer.Approve = func(ctx context.Context, evt nostr.Event) error {
+			return errors.New("no")
+		}
+	}
+	evt := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "hello"}
+	require.ErrorContains(t, signers[0].SignEvent(ctx, &evt), "rejected: no")
+
+	// one refuses, still enough
+	signers[2].Approve = nil
+	require.NoError(t, signers[0].SignEvent(ctx, &evt))
+	require.True(t, evt.VerifySignature())
+}
+
+func TestInvalidShareIsDetected(t *testing.T) {
+	shares, err := GenerateWithTrustedDealer(2, 2, nil)
+	require.NoError(t, err)
+
+	id := nostr.ID{1, 2, 3}
+	n1 := newNonces(shares[0])
+	n2 := newNonces(shares[1])
+	sc, err := newSigningContext(shares[0].PublicKey, id, []Commitment{n2.commitment, n1.commitment})
+	require.NoError(t, err)
+
+	z1 := sc.signShare(shares[0], &n1)
+	z2 := sc.signShare(shares[1], &n2)
+	require.NoError(t, sc.verifyShare(1, z1, shares[0].PublicShares[1]))
+	require.NoError(t, sc.verifyShare(2, z2, shares[0].PublicShares[2]))
+	require.Error(t, sc.verifyShare(2, z1, shares[0].PublicShares[2]))
+
+	sig, err := sc.aggregate(map[int]Scalar{1: z1, 2: z2})
+	require.NoError(t, err)
+	pk, err := schnorr.ParsePubKey(shares[0].PublicKey[:])
+	require.NoError(t, err)
+	parsed, err := schnorr.ParseSignature(sig[:])
+	require.NoError(t, err)
+	require.True(t, parsed.Verify(id[:], pk))
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	shares, err := GenerateWithTrustedDealer(2, 3, nil)
+	require.NoError(t, err)
+
+	data, err := shares[1].Encrypt("hunter2", 8)
+	require.NoError(t, err)
+	secret := shares[1].secret.Bytes()
+	require.NotContains(t, string(data), nostr.HexEncodeToString(secret[:]))
+
+	loaded, err := DecryptKeyShare(data, "hunter2")
+	require.NoError(t, err)
+	require.Equal(t, shares[1].Index, loaded.Index)
+	require.Equal(t, shares[1].PublicKey, loaded.PublicKey)
+	require.Equal(t, shares[1].PublicShares, loaded.PublicShares)
+	require.True(t, shares[1].secret.Equals(&loaded.secret))
+
+	_, err = DecryptKeyShare(data, "wrong")
+	require.Error(t, err)
+}
+
+func TestNostrTransport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	relay := khatru.NewRelay()
+	db := &slicestore.SliceStore{}
+	db.Init()
+	relay.UseEventstore(db, 500)
+	server := httptest.NewServer(relay)
+	defer server.Close()
+	url := "ws" + server.URL[4:]
+
+	shares, err := GenerateWithTrustedDealer(2, 3, nil)
+	require.NoError(t, err)
+
+	pool := nostr.NewPool(nostr.PoolOptions{})
+	keyers := make([]nostr.Keyer, 3)
+	peers := make(map[int]nostr.PubKey, 3)
+	for i := range keyers {
+		kr := keyer.NewPlainKeySigner(nostr.Generate())
+		keyers[i] = kr
+		peers[i+1], _ = kr.GetPublicKey(ctx)
+	}
+	transports := make([]Transport, 3)
+	for i := range transports {
+		transports[i], err = NewNostrTransport(ctx, pool, keyers[i], []string{url}, peers)
+		require.NoError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond) // let the subscriptions start
+
+	signers := startSigners(t, ctx, shares, transports)
+	evt := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "signed over nostr"}
+	require.NoError(t, signers[2].SignEvent(ctx, &evt))
+	require.True(t, evt.VerifySignature())
+	require.Equal(t, shares[0].PublicKey, evt.PubKey)
+}
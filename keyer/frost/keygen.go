@@ -0,0 +1,319 @@
+package frost
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"fiatjaf.com/nostr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// GenerateWithTrustedDealer splits a key into total shares such that any threshold of them can sign.
+// If secret is nil a new random key is generated. The dealer must destroy the key and deliver each share
+// to its participant privately.
+func GenerateWithTrustedDealer(threshold, total int, secret *nostr.SecretKey) ([]*KeyShare, error) {
+	if err := checkParameters(threshold, total); err != nil {
+		return nil, err
+	}
+
+	coefficients := make([]secp256k1.ModNScalar, threshold)
+	if secret != nil {
+		if overflow := coefficients[0].SetBytes((*[32]byte)(secret)); overflow != 0 || coefficients[0].IsZero() {
+			return nil, errors.New("invalid secret key")
+		}
+	} else {
+		coefficients[0] = randomScalar()
+	}
+	for i := 1; i < threshold; i++ {
+		coefficients[i] = randomScalar()
+	}
+
+	// BIP-340 keys must have an even y, so if it isn't we negate everything
+	var y secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&coefficients[0], &y)
+	y.ToAffine()
+	if y.Y.IsOdd() {
+		for i := range coefficients {
+			coefficients[i].Negate()
+		}
+		y.Y.Negate(1).Normalize()
+	}
+	groupKey := nostr.PubKey(*y.X.Bytes())
+
+	shares := make([]*KeyShare, total)
+	publicShares := make(map[int]Point, total)
+	for i := range shares {
+		index := i + 1
+		shares[i] = &KeyShare{
+			Index:        index,
+			Threshold:    threshold,
+			PublicKey:    groupKey,
+			PublicShares: publicShares,
+			secret:       evaluatePolynomial(coefficients, index),
+		}
+		var p secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(&shares[i].secret, &p)
+		publicShares[index] = pointFromJacobian(&p)
+	}
+
+	return shares, nil
+}
+
+// DKGRound1 is broadcast by each participant to all the others in the first round of the DKG.
+type DKGRound1 struct {
+	Index       int     `json:"index"`
+	Commitments []Point `json:"commitments"`
+	ProofR      Point   `json:"proof_r"`
+	ProofMu     Scalar  `json:"proof_mu"`
+}
+
+// DKGRound2 is sent by each participant privately to each of the others in the second round of the DKG.
+type DKGRound2 struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Share Scalar `json:"share"`
+}
+
+// DKG holds the state of one participant during a distributed key generation, in which nobody ever
+// knows the full key. Each participant calls Round1 and broadcasts the result, then gives everything it
+// received to Round2 and sends each of the results privately to its recipient, then gives what it
+// received to Finish.
+type DKG struct {
+	index        int
+	threshold    int
+	total        int
+	context      []byte
+	coefficients []secp256k1.ModNScalar
+	commitments  map[int][]secp256k1.JacobianPoint
+}
+
+// NewDKG starts a distributed key generation for the participant with the given index (from 1 to total).
+// context must be the same for all participants and unique to this key generation (it prevents replays).
+func NewDKG(index, threshold, total int, context string) (*DKG, error) {
+	if err := checkParameters(threshold, total); err != nil {
+		return nil, err
+	}
+	if index < 1 || index > total {
+		return nil, fmt.Errorf("index must be between 1 and %d", total)
+	}
+
+	dkg := &DKG{
+		index:        index,
+		threshold:    threshold,
+		total:        total,
+		context:      []byte(context),
+		coefficients: make([]secp256k1.ModNScalar, threshold),
+	}
+	for i := range dkg.coefficients {
+		dkg.coefficients[i] = randomScalar()
+	}
+	return dkg, nil
+}
+
+// Round1 returns the commitments to our polynomial and a proof that we know its constant term.
+func (dkg *DKG) Round1() DKGRound1 {
+	res := DKGRound1{Index: dkg.index, Commitments: make([]Point, dkg.threshold)}
+	for i := range dkg.coefficients {
+		var p secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(&dkg.coefficients[i], &p)
+		res.Commitments[i] = pointFromJacobian(&p)
+	}
+
+	k := randomScalar()
+	var r secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&k, &r)
+	res.ProofR = pointFromJacobian(&r)
+	c := dkg.proofChallenge(dkg.index, res.Commitments[0], res.ProofR)
+	mu := new(secp256k1.ModNScalar).Mul2(&dkg.coefficients[0], &c).Add(&k)
+	res.ProofMu = Scalar(mu.Bytes())
+
+	return res
+}
+
+// Round2 checks the round 1 packages from all the other participants and returns the secret shares that
+// must be sent to each of them.
+func (dkg *DKG) Round2(packages []DKGRound1) ([]DKGRound2, error) {
+	dkg.commitments = make(map[int][]secp256k1.JacobianPoint, dkg.total)
+	for _, pkg := range packages {
+		if pkg.Index == dkg.index {
+			continue
+		}
+		if pkg.Index < 1 || pkg.Index > dkg.total {
+			return nil, fmt.Errorf("invalid participant index %d", pkg.Index)
+		}
+		if _, ok := dkg.commitments[pkg.Index]; ok {
+			return nil, fmt.Errorf("duplicate round 1 package from %d", pkg.Index)
+		}
+		if len(pkg.Commitments) != dkg.threshold {
+			return nil, fmt.Errorf("participant %d sent %d commitments, expected %d", pkg.Index, len(pkg.Commitments), dkg.threshold)
+		}
+
+		commitments := make([]secp256k1.JacobianPoint, len(pkg.Commitments))
+		for i, c := range pkg.Commitments {
+			var err error
+			if commitments[i], err = c.jacobian(); err != nil {
+				return nil, fmt.Errorf("invalid commitment from %d: %w", pkg.Index, err)
+			}
+		}
+
+		// check the proof of knowledge: mu*G - c*C_0 == R
+		mu, err := pkg.ProofMu.modN()
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof from %d: %w", pkg.Index, err)
+		}
+		r, err := pkg.ProofR.jacobian()
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof from %d: %w", pkg.Index, err)
+		}
+		c := dkg.proofChallenge(pkg.Index, pkg.Commitments[0], pkg.ProofR)
+		var muG, cC secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(&mu, &muG)
+		secp256k1.ScalarMultNonConst(c.Negate(), &commitments[0], &cC)
+		secp256k1.AddNonConst(&muG, &cC, &muG)
+		if !muG.EquivalentNonConst(&r) {
+			return nil, fmt.Errorf("invalid proof of knowledge from %d", pkg.Index)
+		}
+
+		dkg.commitments[pkg.Index] = commitments
+	}
+	if len(dkg.commitments) != dkg.total-1 {
+		return nil, fmt.Errorf("got round 1 packages from %d participants, expected %d", len(dkg.commitments), dkg.total-1)
+	}
+
+	shares := make([]DKGRound2, 0, dkg.total-1)
+	for to := 1; to <= dkg.total; to++ {
+		if to == dkg.index {
+			continue
+		}
+		share := evaluatePolynomial(dkg.coefficients, to)
+		shares = append(shares, DKGRound2{From: dkg.index, To: to, Share: Scalar(share.Bytes())})
+	}
+	return shares, nil
+}
+
+// Finish checks the shares received from all the other participants against their commitments and
+// computes our final KeyShare.
+func (dkg *DKG) Finish(shares []DKGRound2) (*KeyShare, error) {
+	if dkg.commitments == nil {
+		return nil, errors.New("Round2 must be called before Finish")
+	}
+
+	// include our own polynomial
+	own := make([]secp256k1.JacobianPoint, dkg.threshold)
+	for i := range dkg.coefficients {
+		secp256k1.ScalarBaseMultNonConst(&dkg.coefficients[i], &own[i])
+	}
+	dkg.commitments[dkg.index] = own
+
+	secret := evaluatePolynomial(dkg.coefficients, dkg.index)
+	seen := make(map[int]bool, len(shares))
+	for _, share := range shares {
+		if share.To != dkg.index {
+			return nil, fmt.Errorf("got a share meant for %d", share.To)
+		}
+		commitments, ok := dkg.commitments[share.From]
+		if !ok || share.From == dkg.index {
+			return nil, fmt.Errorf("unexpected share from %d", share.From)
+		}
+		if seen[share.From] {
+			return nil, fmt.Errorf("duplicate share from %d", share.From)
+		}
+		seen[share.From] = true
+
+		s, err := share.Share.modN()
+		if err != nil {
+			return nil, fmt.Errorf("invalid share from %d: %w", share.From, err)
+		}
+		var sG secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(&s, &sG)
+		expected := evaluateCommitments(commitments, dkg.index)
+		if !sG.EquivalentNonConst(&expected) {
+			return nil, fmt.Errorf("share from %d doesn't match its commitments", share.From)
+		}
+		secret.Add(&s)
+	}
+	if len(seen) != dkg.total-1 {
+		return nil, fmt.Errorf("got shares from %d participants, expected %d", len(seen), dkg.total-1)
+	}
+
+	var y secp256k1.JacobianPoint
+	for _, commitments := range dkg.commitments {
+		secp256k1.AddNonConst(&y, &commitments[0], &y)
+	}
+	if isInfinity(&y) {
+		return nil, errors.New("group key is the point at infinity")
+	}
+	y.ToAffine()
+	negate := y.Y.IsOdd()
+
+	// BIP-340 keys must have an even y, so if it isn't we all negate our shares
+	if negate {
+		secret.Negate()
+	}
+	ks := &KeyShare{
+		Index:        dkg.index,
+		Threshold:    dkg.threshold,
+		PublicKey:    nostr.PubKey(*y.X.Bytes()),
+		PublicShares: make(map[int]Point, dkg.total),
+		secret:       secret,
+	}
+	for idx := 1; idx <= dkg.total; idx++ {
+		var p secp256k1.JacobianPoint
+		for _, commitments := range dkg.commitments {
+			c := evaluateCommitments(commitments, idx)
+			secp256k1.AddNonConst(&p, &c, &p)
+		}
+		if negate {
+			p.Y.Normalize().Negate(1).Normalize()
+		}
+		ks.PublicShares[idx] = pointFromJacobian(&p)
+	}
+
+	// we don't need these anymore
+	for i := range dkg.coefficients {
+		dkg.coefficients[i].Zero()
+	}
+
+	return ks, ks.check()
+}
+
+func (dkg *DKG) proofChallenge(index int, c0 Point, r Point) secp256k1.ModNScalar {
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], uint32(index))
+	var c secp256k1.ModNScalar
+	c.SetByteSlice(taggedHash("FROST/nostr/dkg", idx[:], dkg.context, c0[:], r[:]))
+	return c
+}
+
+func checkParameters(threshold, total int) error {
+	if threshold < 1 || total < threshold {
+		return fmt.Errorf("threshold must be between 1 and total, got %d-of-%d", threshold, total)
+	}
+	if total > 255 {
+		return errors.New("at most 255 participants are supported")
+	}
+	return nil
+}
+
+// evaluatePolynomial computes f(x) = a_0 + a_1*x + ... + a_(t-1)*x^(t-1).
+func evaluatePolynomial(coefficients []secp256k1.ModNScalar, x int) secp256k1.ModNScalar {
+	var xs, result secp256k1.ModNScalar
+	xs.SetInt(uint32(x))
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result.Mul(&xs).Add(&coefficients[i])
+	}
+	return result
+}
+
+// evaluateCommitments computes f(x)*G from the commitments to the coefficients of f.
+func evaluateCommitments(commitments []secp256k1.JacobianPoint, x int) secp256k1.JacobianPoint {
+	var xs secp256k1.ModNScalar
+	xs.SetInt(uint32(x))
+	var result secp256k1.JacobianPoint
+	for i := len(commitments) - 1; i >= 0; i-- {
+		secp256k1.ScalarMultNonConst(&xs, &result, &result)
+		secp256k1.AddNonConst(&result, &commitments[i], &result)
+	}
+	return result
+}
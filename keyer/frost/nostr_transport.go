@@ -0,0 +1,127 @@
+package frost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"fiatjaf.com/nostr"
+)
+
+// KindMessage is the ephemeral kind used by NostrTransport.
+const KindMessage nostr.Kind = 24137
+
+// NostrTransport is a Transport that sends messages as NIP-44-encrypted ephemeral events through relays.
+// Each participant uses its own normal nostr key for this, not the key share.
+type NostrTransport struct {
+	pool    *nostr.Pool
+	keyer   nostr.Keyer
+	relays  []string
+	peers   map[int]nostr.PubKey
+	indexes map[nostr.PubKey]int
+	inbox   chan Message
+}
+
+// NewNostrTransport starts listening on relays for messages sent to the given keyer by any of the peers
+// (a map of participant index to the pubkey they use for messaging). It stops when ctx is canceled.
+func NewNostrTransport(
+	ctx context.Context,
+	pool *nostr.Pool,
+	kr nostr.Keyer,
+	relays []string,
+	peers map[int]nostr.PubKey,
+) (*NostrTransport, error) {
+	self, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get our public key: %w", err)
+	}
+
+	nt := &NostrTransport{
+		pool:    pool,
+		keyer:   kr,
+		relays:  relays,
+		peers:   peers,
+		indexes: make(map[nostr.PubKey]int, len(peers)),
+		inbox:   make(chan Message, 64),
+	}
+	for idx, pk := range peers {
+		nt.indexes[pk] = idx
+	}
+
+	events := pool.SubscribeMany(ctx, relays, nostr.Filter{
+		Kinds:     []nostr.Kind{KindMessage},
+		Tags:      nostr.TagMap{"p": []string{self.Hex()}},
+		Since:     nostr.Now(),
+		LimitZero: true,
+	}, nostr.SubscriptionOptions{Label: "frost"})
+
+	go func() {
+		for ie := range events {
+			from, ok := nt.indexes[ie.PubKey]
+			if !ok {
+				continue
+			}
+			plain, err := kr.Decrypt(ctx, ie.Content, ie.PubKey)
+			if err != nil {
+				continue
+			}
+			var msg Message
+			if err := json.Unmarshal([]byte(plain), &msg); err != nil {
+				continue
+			}
+			msg.From = from
+
+			select {
+			case nt.inbox <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nt, nil
+}
+
+func (nt *NostrTransport) Send(ctx context.Context, to int, msg Message) error {
+	target, ok := nt.peers[to]
+	if !ok {
+		return fmt.Errorf("unknown participant %d", to)
+	}
+
+	plain, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := nt.keyer.Encrypt(ctx, string(plain), target)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	evt := nostr.Event{
+		Kind:      KindMessage,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", target.Hex()}},
+		Content:   ciphertext,
+	}
+	if err := nt.keyer.SignEvent(ctx, &evt); err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	lastErr := fmt.Errorf("no relays")
+	for res := range nt.pool.PublishMany(ctx, nt.relays, evt) {
+		if res.Error == nil {
+			return nil
+		}
+		lastErr = res.Error
+	}
+	return fmt.Errorf("failed to publish to any relay: %w", lastErr)
+}
+
+func (nt *NostrTransport) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg := <-nt.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, context.Cause(ctx)
+	}
+}
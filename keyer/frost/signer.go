@@ -0,0 +1,410 @@
+package frost
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/puzpuzpuz/xsync/v3"
+)
+
+var _ nostr.Signer = (*Signer)(nil)
+
+const (
+	msgCommitRequest = "commit-request"
+	msgCommitment    = "commitment"
+	msgSignRequest   = "sign-request"
+	msgShare         = "share"
+	msgReject        = "reject"
+	msgAbort         = "abort"
+)
+
+type commitRequest struct {
+	Event nostr.Event `json:"event"`
+}
+
+type signRequest struct {
+	Commitments []Commitment `json:"commitments"`
+}
+
+type shareResponse struct {
+	Share Scalar `json:"share"`
+}
+
+type rejectResponse struct {
+	Reason string `json:"reason"`
+}
+
+// Signer is one of the participants holding a KeyShare. It implements nostr.Signer: calling SignEvent
+// makes it coordinate a signing session with the other participants through the Transport. Run must be
+// running for that to work and also for it to take part in the sessions started by the others.
+type Signer struct {
+	share     *KeyShare
+	transport Transport
+
+	// Approve, if set, is called before we take part in a signing session started by someone else,
+	// so a participant can refuse to sign some events. If it returns an error the session is rejected.
+	Approve func(ctx context.Context, evt nostr.Event) error
+
+	// NonceExpiration is how long our nonces for a session started by someone else are kept while
+	// waiting for the signing request, defaults to 5 minutes.
+	NonceExpiration time.Duration
+
+	// Timeout is how long SignEvent waits for the other participants, defaults to 30 seconds.
+	Timeout time.Duration
+
+	coordinating *xsync.MapOf[string, chan Message]
+	pending      *xsync.MapOf[string, pendingSession]
+}
+
+type pendingSession struct {
+	coordinator int
+	id          nostr.ID
+	nonces      nonces
+	createdAt   time.Time
+}
+
+// NewSigner creates a Signer for the given share. Call Run to start it.
+func NewSigner(share *KeyShare, transport Transport) (*Signer, error) {
+	if err := share.check(); err != nil {
+		return nil, err
+	}
+	return &Signer{
+		share:           share,
+		transport:       transport,
+		NonceExpiration: time.Minute * 5,
+		Timeout:         time.Second * 30,
+		coordinating:    xsync.NewMapOf[string, chan Message](),
+		pending:         xsync.NewMapOf[string, pendingSession](),
+	}, nil
+}
+
+// GetPublicKey returns the group public key.
+func (s *Signer) GetPublicKey(ctx context.Context) (nostr.PubKey, error) {
+	return s.share.PublicKey, nil
+}
+
+// Run receives messages from the transport until ctx is canceled, answering the requests from other
+// participants and routing the responses to our own sessions.
+func (s *Signer) Run(ctx context.Context) error {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for session, p := range s.pending.Range {
+					if time.Since(p.createdAt) > s.NonceExpiration {
+						s.pending.Delete(session)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := s.transport.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case msgCommitRequest:
+			go s.handleCommitRequest(ctx, msg)
+		case msgSignRequest:
+			go s.handleSignRequest(ctx, msg)
+		case msgAbort:
+			if p, ok := s.pending.Load(msg.Session); ok && p.coordinator == msg.From {
+				s.pending.Delete(msg.Session)
+			}
+		case msgCommitment, msgShare, msgReject:
+			if ch, ok := s.coordinating.Load(msg.Session); ok {
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (s *Signer) handleCommitRequest(ctx context.Context, msg Message) {
+	var req commitRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{"invalid request"}))
+		return
+	}
+	if req.Event.PubKey != s.share.PublicKey {
+		s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{"event has a different pubkey"}))
+		return
+	}
+	if s.Approve != nil {
+		if err := s.Approve(ctx, req.Event); err != nil {
+			s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{err.Error()}))
+			return
+		}
+	}
+
+	p := pendingSession{
+		coordinator: msg.From,
+		id:          req.Event.GetID(),
+		nonces:      newNonces(s.share),
+		createdAt:   time.Now(),
+	}
+	if _, exists := s.pending.LoadOrStore(msg.Session, p); exists {
+		return
+	}
+	s.send(ctx, msg.From, newMessage(msg.Session, msgCommitment, p.nonces.commitment))
+}
+
+func (s *Signer) handleSignRequest(ctx context.Context, msg Message) {
+	// nonces must never be used twice, so whatever happens this session is over for us
+	p, ok := s.pending.LoadAndDelete(msg.Session)
+	if !ok || p.coordinator != msg.From {
+		return
+	}
+
+	var req signRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{"invalid request"}))
+		return
+	}
+
+	found := false
+	for _, c := range req.Commitments {
+		if c.Index == s.share.Index {
+			found = c == p.nonces.commitment
+			break
+		}
+	}
+	if !found {
+		s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{"our commitment is missing or wrong"}))
+		return
+	}
+	if len(req.Commitments) < s.share.Threshold {
+		s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{"not enough commitments"}))
+		return
+	}
+
+	sc, err := newSigningContext(s.share.PublicKey, p.id, req.Commitments)
+	if err != nil {
+		s.send(ctx, msg.From, newMessage(msg.Session, msgReject, rejectResponse{err.Error()}))
+		return
+	}
+	share := sc.signShare(s.share, &p.nonces)
+	s.send(ctx, msg.From, newMessage(msg.Session, msgShare, shareResponse{share}))
+}
+
+// SignEvent coordinates a signing session: it asks all the other participants for commitments, picks
+// the first ones to answer until the threshold is reached, asks them for signature shares, checks them
+// and aggregates them into the signature. It sets the event's ID, PubKey, and Sig fields.
+func (s *Signer) SignEvent(ctx context.Context, evt *nostr.Event) error {
+	if evt.Tags == nil {
+		evt.Tags = make(nostr.Tags, 0)
+	}
+	evt.PubKey = s.share.PublicKey
+	evt.ID = evt.GetID()
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var sessionBytes [16]byte
+	rand.Read(sessionBytes[:])
+	session := hex.EncodeToString(sessionBytes[:])
+	responses := make(chan Message, len(s.share.PublicShares)*2)
+	s.coordinating.Store(session, responses)
+	defer s.coordinating.Delete(session)
+
+	own := newNonces(s.share)
+	commitments := []Commitment{own.commitment}
+
+	// round 1: get commitments
+	others := make([]int, 0, len(s.share.PublicShares)-1)
+	for _, idx := range s.share.Participants() {
+		if idx == s.share.Index {
+			continue
+		}
+		if err := s.send(ctx, idx, newMessage(session, msgCommitRequest, commitRequest{*evt})); err == nil {
+			others = append(others, idx)
+		}
+	}
+
+	// release the nonces of whoever isn't going to be asked for a signature share
+	signRequested := make(map[int]bool, s.share.Threshold)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		for _, idx := range others {
+			if !signRequested[idx] {
+				s.send(ctx, idx, newMessage(session, msgAbort, nil))
+			}
+		}
+	}()
+
+	var rejections []error
+	selected := make(map[int]bool, s.share.Threshold)
+	selected[s.share.Index] = true
+	for len(commitments) < s.share.Threshold {
+		if remaining := len(others) - len(rejections) - (len(commitments) - 1); remaining < s.share.Threshold-len(commitments) {
+			return fmt.Errorf("not enough participants to sign: %w", errors.Join(rejections...))
+		}
+
+		select {
+		case msg := <-responses:
+			switch msg.Type {
+			case msgCommitment:
+				var c Commitment
+				if err := json.Unmarshal(msg.Payload, &c); err != nil || c.Index != msg.From || selected[msg.From] {
+					continue
+				}
+				selected[msg.From] = true
+				commitments = append(commitments, c)
+			case msgReject:
+				var r rejectResponse
+				json.Unmarshal(msg.Payload, &r)
+				rejections = append(rejections, fmt.Errorf("%d rejected: %s", msg.From, r.Reason))
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("got only %d of %d commitments: %w", len(commitments), s.share.Threshold, context.Cause(ctx))
+		}
+	}
+
+	sc, err := newSigningContext(s.share.PublicKey, evt.ID, commitments)
+	if err != nil {
+		return err
+	}
+
+	// round 2: get signature shares
+	shares := make(map[int]Scalar, len(commitments))
+	shares[s.share.Index] = sc.signShare(s.share, &own)
+	for _, c := range commitments {
+		if c.Index == s.share.Index {
+			continue
+		}
+		signRequested[c.Index] = true
+		if err := s.send(ctx, c.Index, newMessage(session, msgSignRequest, signRequest{commitments})); err != nil {
+			return fmt.Errorf("failed to send signing request to %d: %w", c.Index, err)
+		}
+	}
+	for len(shares) < len(commitments) {
+		select {
+		case msg := <-responses:
+			if !selected[msg.From] {
+				continue
+			}
+			switch msg.Type {
+			case msgShare:
+				var res shareResponse
+				if err := json.Unmarshal(msg.Payload, &res); err != nil {
+					return fmt.Errorf("invalid response from %d: %w", msg.From, err)
+				}
+				if err := sc.verifyShare(msg.From, res.Share, s.share.PublicShares[msg.From]); err != nil {
+					return err
+				}
+				shares[msg.From] = res.Share
+			case msgReject:
+				var r rejectResponse
+				json.Unmarshal(msg.Payload, &r)
+				return fmt.Errorf("%d rejected: %s", msg.From, r.Reason)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("got only %d of %d signature shares: %w", len(shares), len(commitments), context.Cause(ctx))
+		}
+	}
+
+	sig, err := sc.aggregate(shares)
+	if err != nil {
+		return err
+	}
+	evt.Sig = sig
+	if !evt.VerifySignature() {
+		return errors.New("aggregated signature is invalid")
+	}
+
+	return nil
+}
+
+func (s *Signer) send(ctx context.Context, to int, msg Message) error {
+	return s.transport.Send(ctx, to, msg)
+}
+
+// RunDKG performs a distributed key generation with the other participants through the transport.
+// All participants must call it at the same time with the same threshold, total and session.
+func RunDKG(ctx context.Context, transport Transport, index, threshold, total int, session string) (*KeyShare, error) {
+	dkg, err := NewDKG(index, threshold, total, session)
+	if err != nil {
+		return nil, err
+	}
+
+	round1 := dkg.Round1()
+	for to := 1; to <= total; to++ {
+		if to == index {
+			continue
+		}
+		if err := transport.Send(ctx, to, newMessage(session, "dkg-1", round1)); err != nil {
+			return nil, fmt.Errorf("failed to send round 1 to %d: %w", to, err)
+		}
+	}
+
+	// messages from round 2 may arrive before we're done with round 1
+	round1s := make(map[int]DKGRound1, total)
+	round2s := make(map[int]DKGRound2, total)
+	sentRound2 := false
+	for {
+		if !sentRound2 && len(round1s) == total-1 {
+			packages := make([]DKGRound1, 0, total-1)
+			for _, pkg := range round1s {
+				packages = append(packages, pkg)
+			}
+			shares, err := dkg.Round2(packages)
+			if err != nil {
+				return nil, err
+			}
+			for _, share := range shares {
+				if err := transport.Send(ctx, share.To, newMessage(session, "dkg-2", share)); err != nil {
+					return nil, fmt.Errorf("failed to send round 2 to %d: %w", share.To, err)
+				}
+			}
+			sentRound2 = true
+		}
+		if sentRound2 && len(round2s) == total-1 {
+			break
+		}
+
+		msg, err := transport.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Session != session {
+			continue
+		}
+
+		switch msg.Type {
+		case "dkg-1":
+			var pkg DKGRound1
+			if err := json.Unmarshal(msg.Payload, &pkg); err != nil || pkg.Index != msg.From {
+				return nil, fmt.Errorf("invalid round 1 package from %d", msg.From)
+			}
+			round1s[msg.From] = pkg
+		case "dkg-2":
+			var pkg DKGRound2
+			if err := json.Unmarshal(msg.Payload, &pkg); err != nil || pkg.From != msg.From {
+				return nil, fmt.Errorf("invalid round 2 package from %d", msg.From)
+			}
+			round2s[msg.From] = pkg
+		}
+	}
+
+	shares := make([]DKGRound2, 0, total-1)
+	for _, share := range round2s {
+		shares = append(shares, share)
+	}
+	return dkg.Finish(shares)
+}
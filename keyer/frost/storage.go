@@ -0,0 +1,60 @@
+package frost
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip49"
+)
+
+type storedShare struct {
+	Index        int           `json:"index"`
+	Threshold    int           `json:"threshold"`
+	PublicKey    nostr.PubKey  `json:"pubkey"`
+	PublicShares map[int]Point `json:"public_shares"`
+	Secret       string        `json:"ncryptsec"`
+}
+
+// Encrypt serializes the share as JSON with the secret part encrypted with nip49, so it can be stored.
+func (ks *KeyShare) Encrypt(password string, logn uint8) ([]byte, error) {
+	ncryptsec, err := nip49.Encrypt(ks.secret.Bytes(), password, logn, nip49.ClientDoesNotTrackThisData)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(storedShare{
+		Index:        ks.Index,
+		Threshold:    ks.Threshold,
+		PublicKey:    ks.PublicKey,
+		PublicShares: ks.PublicShares,
+		Secret:       ncryptsec,
+	})
+}
+
+// DecryptKeyShare loads a share that was serialized with KeyShare.Encrypt.
+func DecryptKeyShare(data []byte, password string) (*KeyShare, error) {
+	var stored storedShare
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid share: %w", err)
+	}
+
+	sec, err := nip49.DecryptToBytes(stored.Secret, password)
+	if err != nil {
+		return nil, err
+	}
+	if len(sec) != 32 {
+		return nil, fmt.Errorf("invalid secret share")
+	}
+
+	ks := &KeyShare{
+		Index:        stored.Index,
+		Threshold:    stored.Threshold,
+		PublicKey:    stored.PublicKey,
+		PublicShares: stored.PublicShares,
+	}
+	if overflow := ks.secret.SetBytes((*[32]byte)(sec)); overflow != 0 {
+		return nil, fmt.Errorf("invalid secret share")
+	}
+
+	return ks, ks.check()
+}
@@ -0,0 +1,76 @@
+package frost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Message is what participants exchange during the DKG and the signing sessions.
+type Message struct {
+	// From is set by the Transport on receipt, after authenticating the sender.
+	From int `json:"-"`
+
+	Session string          `json:"session"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Transport delivers messages between the participants, identified by their indexes. Implementations must
+// make sure messages are private and that Message.From is authentic.
+type Transport interface {
+	// Send delivers msg to the participant with the given index.
+	Send(ctx context.Context, to int, msg Message) error
+
+	// Receive blocks until a message addressed to us arrives.
+	Receive(ctx context.Context) (Message, error)
+}
+
+// MemoryTransport is a Transport for participants living in the same process, mostly useful for tests.
+type MemoryTransport struct {
+	index int
+	inbox chan Message
+	peers map[int]*MemoryTransport
+}
+
+// NewMemoryTransports returns connected transports for participants 1 to total, in this order.
+func NewMemoryTransports(total int) []*MemoryTransport {
+	peers := make(map[int]*MemoryTransport, total)
+	transports := make([]*MemoryTransport, total)
+	for i := range transports {
+		transports[i] = &MemoryTransport{index: i + 1, inbox: make(chan Message, 64), peers: peers}
+		peers[i+1] = transports[i]
+	}
+	return transports
+}
+
+func (mt *MemoryTransport) Send(ctx context.Context, to int, msg Message) error {
+	peer, ok := mt.peers[to]
+	if !ok {
+		return fmt.Errorf("unknown participant %d", to)
+	}
+	msg.From = mt.index
+	select {
+	case peer.inbox <- msg:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func (mt *MemoryTransport) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg := <-mt.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, context.Cause(ctx)
+	}
+}
+
+func newMessage(session string, typ string, payload any) Message {
+	msg := Message{Session: session, Type: typ}
+	if payload != nil {
+		msg.Payload, _ = json.Marshal(payload)
+	}
+	return msg
+}
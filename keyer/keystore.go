@@ -0,0 +1,352 @@
+package keyer
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip06"
+	"fiatjaf.com/nostr/nip49"
+)
+
+// Keystore holds a single BIP-39 seed, encrypted at rest with a password, from which any number of
+// accounts can be derived following NIP-06 (m/44'/1237'/<account>'/0/0).
+//
+// Account information (labels, public keys and metadata) is stored in plaintext so it can be listed
+// without the password, but the seed must be unlocked before keys can be used or new accounts derived.
+type Keystore struct {
+	mu sync.Mutex
+
+	encrypted []byte
+	accounts  []Account
+
+	// only present while unlocked
+	words      string
+	passphrase string
+	seed       []byte
+}
+
+// Account is one identity derived from the keystore seed.
+type Account struct {
+	Index     uint32            `json:"index"`
+	Label     string            `json:"label,omitempty"`
+	PubKey    nostr.PubKey      `json:"pubkey"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt nostr.Timestamp   `json:"created_at"`
+}
+
+type keystoreSecret struct {
+	Words      string `json:"words"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+type storedKeystore struct {
+	Version   int       `json:"version"`
+	Encrypted []byte    `json:"encrypted"`
+	Accounts  []Account `json:"accounts"`
+}
+
+// NewKeystore creates an unlocked keystore from the given seed words, or from freshly generated words
+// if words is blank. bip39Passphrase is the optional BIP-39 passphrase (the "25th word"), while password
+// is what will be used to encrypt the seed at rest, with scrypt cost 2^logn. Account 0 is derived
+// immediately.
+func NewKeystore(words string, bip39Passphrase string, password string, logn uint8) (*Keystore, error) {
+	if words == "" {
+		var err error
+		words, err = nip06.GenerateSeedWords()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate seed words: %w", err)
+		}
+	} else if !nip06.ValidateWords(words) {
+		return nil, fmt.Errorf("invalid seed words")
+	}
+
+	plain, _ := json.Marshal(keystoreSecret{Words: words, Passphrase: bip39Passphrase})
+	encrypted, err := nip49.EncryptBytes(plain, password, logn, nip49.ClientDoesNotTrackThisData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt seed: %w", err)
+	}
+
+	ks := &Keystore{
+		encrypted:  encrypted,
+		words:      words,
+		passphrase: bip39Passphrase,
+		seed:       nip06.SeedFromWordsWithPassphrase(words, bip39Passphrase),
+	}
+	if _, err := ks.DeriveAccount(0, ""); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// LoadKeystore reads a keystore serialized with Keystore.Save. It starts locked.
+func LoadKeystore(data []byte) (*Keystore, error) {
+	var stored storedKeystore
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %w", err)
+	}
+	if stored.Version != 1 {
+		return nil, fmt.Errorf("unsupported keystore version %d", stored.Version)
+	}
+	if len(stored.Encrypted) < 2 {
+		return nil, fmt.Errorf("invalid keystore: missing encrypted seed")
+	}
+
+	return &Keystore{
+		encrypted: stored.Encrypted,
+		accounts:  stored.Accounts,
+	}, nil
+}
+
+// Save serializes the keystore. The seed is always saved in its encrypted form, so this works
+// regardless of the keystore being locked or not.
+func (ks *Keystore) Save() ([]byte, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	return json.Marshal(storedKeystore{
+		Version:   1,
+		Encrypted: ks.encrypted,
+		Accounts:  ks.accounts,
+	})
+}
+
+// Unlock decrypts the seed with the password. If any of the stored accounts doesn't match what is
+// derived from the seed the keystore is considered corrupted and stays locked.
+func (ks *Keystore) Unlock(password string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	plain, err := nip49.DecryptBytes(ks.encrypted, password)
+	if err != nil {
+		return fmt.Errorf("invalid password: %w", err)
+	}
+	var secret keystoreSecret
+	if err := json.Unmarshal(plain, &secret); err != nil {
+		return fmt.Errorf("invalid keystore: %w", err)
+	}
+
+	seed := nip06.SeedFromWordsWithPassphrase(secret.Words, secret.Passphrase)
+	for _, acct := range ks.accounts {
+		sk, err := nip06.SecretKeyFromSeed(seed, acct.Index)
+		if err != nil {
+			return err
+		}
+		if sk.Public() != acct.PubKey {
+			return fmt.Errorf("account %d doesn't match the seed", acct.Index)
+		}
+	}
+
+	ks.words = secret.Words
+	ks.passphrase = secret.Passphrase
+	ks.seed = seed
+	return nil
+}
+
+// Lock forgets the decrypted seed. Keyers obtained before remain usable.
+func (ks *Keystore) Lock() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	clear(ks.seed)
+	ks.seed = nil
+	ks.words = ""
+	ks.passphrase = ""
+}
+
+// IsLocked tells whether Unlock must be called before using the seed.
+func (ks *Keystore) IsLocked() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.seed == nil
+}
+
+// ChangePassword re-encrypts the seed with a new password. The keystore must be unlocked.
+func (ks *Keystore) ChangePassword(password string, logn uint8) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.seed == nil {
+		return fmt.Errorf("keystore is locked")
+	}
+	plain, _ := json.Marshal(keystoreSecret{Words: ks.words, Passphrase: ks.passphrase})
+	encrypted, err := nip49.EncryptBytes(plain, password, logn, nip49.ClientDoesNotTrackThisData)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt seed: %w", err)
+	}
+	ks.encrypted = encrypted
+	return nil
+}
+
+// Words returns the seed words so they can be backed up. The keystore must be unlocked.
+func (ks *Keystore) Words() (string, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.seed == nil {
+		return "", fmt.Errorf("keystore is locked")
+	}
+	return ks.words, nil
+}
+
+// Accounts returns a copy of the list of accounts, ordered by index.
+func (ks *Keystore) Accounts() []Account {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	accounts := make([]Account, len(ks.accounts))
+	for i, acct := range ks.accounts {
+		acct.Metadata = maps.Clone(acct.Metadata)
+		accounts[i] = acct
+	}
+	return accounts
+}
+
+// Account returns the account with the given index, if it was already derived.
+func (ks *Keystore) Account(index uint32) (Account, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if pos, ok := ks.find(index); ok {
+		acct := ks.accounts[pos]
+		acct.Metadata = maps.Clone(acct.Metadata)
+		return acct, true
+	}
+	return Account{}, false
+}
+
+// AddAccount derives the account following the highest index already in the keystore.
+func (ks *Keystore) AddAccount(label string) (Account, error) {
+	ks.mu.Lock()
+	next := uint32(0)
+	if len(ks.accounts) > 0 {
+		next = ks.accounts[len(ks.accounts)-1].Index + 1
+	}
+	ks.mu.Unlock()
+
+	return ks.DeriveAccount(next, label)
+}
+
+// DeriveAccount adds the account with the given index to the keystore, or just returns it if it was
+// already there. The keystore must be unlocked.
+func (ks *Keystore) DeriveAccount(index uint32, label string) (Account, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if pos, ok := ks.find(index); ok {
+		acct := ks.accounts[pos]
+		acct.Metadata = maps.Clone(acct.Metadata)
+		return acct, nil
+	}
+
+	sk, err := ks.secretKey(index)
+	if err != nil {
+		return Account{}, err
+	}
+
+	acct := Account{
+		Index:     index,
+		Label:     label,
+		PubKey:    sk.Public(),
+		CreatedAt: nostr.Now(),
+	}
+	pos, _ := ks.find(index)
+	ks.accounts = slices.Insert(ks.accounts, pos, acct)
+	return acct, nil
+}
+
+// RemoveAccount forgets the account with the given index. Since keys are deterministic it can be
+// derived again later.
+func (ks *Keystore) RemoveAccount(index uint32) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if pos, ok := ks.find(index); ok {
+		ks.accounts = slices.Delete(ks.accounts, pos, pos+1)
+	}
+}
+
+// SetLabel changes the label of an account.
+func (ks *Keystore) SetLabel(index uint32, label string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	pos, ok := ks.find(index)
+	if !ok {
+		return fmt.Errorf("account %d not found", index)
+	}
+	ks.accounts[pos].Label = label
+	return nil
+}
+
+// SetMetadata sets an arbitrary key-value pair on an account. An empty value deletes the key.
+func (ks *Keystore) SetMetadata(index uint32, key string, value string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	pos, ok := ks.find(index)
+	if !ok {
+		return fmt.Errorf("account %d not found", index)
+	}
+	acct := &ks.accounts[pos]
+	if value == "" {
+		delete(acct.Metadata, key)
+		return nil
+	}
+	if acct.Metadata == nil {
+		acct.Metadata = make(map[string]string)
+	}
+	acct.Metadata[key] = value
+	return nil
+}
+
+// Keyer returns a nostr.Keyer for the account with the given index. The keystore must be unlocked.
+func (ks *Keystore) Keyer(index uint32) (nostr.Keyer, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, ok := ks.find(index); !ok {
+		return nil, fmt.Errorf("account %d not found", index)
+	}
+	sk, err := ks.secretKey(index)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlainKeySigner(sk), nil
+}
+
+// ExportNcryptsec exports the key of a single account as a NIP-49 ncryptsec, encrypted with the given
+// password (which doesn't have to be the same as the keystore password). The keystore must be unlocked.
+func (ks *Keystore) ExportNcryptsec(index uint32, password string, logn uint8) (string, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, ok := ks.find(index); !ok {
+		return "", fmt.Errorf("account %d not found", index)
+	}
+	sk, err := ks.secretKey(index)
+	if err != nil {
+		return "", err
+	}
+	return nip49.Encrypt(sk, password, logn, nip49.ClientDoesNotTrackThisData)
+}
+
+func (ks *Keystore) secretKey(index uint32) (nostr.SecretKey, error) {
+	if ks.seed == nil {
+		return nostr.SecretKey{}, fmt.Errorf("keystore is locked")
+	}
+	if index >= 1<<31 {
+		return nostr.SecretKey{}, fmt.Errorf("account index %d is too big", index)
+	}
+	return nip06.SecretKeyFromSeed(ks.seed, index)
+}
+
+func (ks *Keystore) find(index uint32) (int, bool) {
+	return slices.BinarySearchFunc(ks.accounts, index, func(acct Account, index uint32) int {
+		return cmp.Compare(acct.Index, index)
+	})
+}
@@ -0,0 +1,90 @@
+package keyer
+
+import (
+	"context"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip06"
+	"fiatjaf.com/nostr/nip49"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	ctx := context.Background()
+	words := "leader monkey parrot ring guide accident before fence cannon height naive bean"
+
+	ks, err := NewKeystore(words, "", "hunter2", 8)
+	require.NoError(t, err)
+
+	// account 0 matches the plain nip06 derivation
+	hex, err := nip06.PrivateKeyFromSeed(nip06.SeedFromWords(words))
+	require.NoError(t, err)
+	sk0, _ := nostr.SecretKeyFromHex(hex)
+	accounts := ks.Accounts()
+	require.Len(t, accounts, 1)
+	require.Equal(t, sk0.Public(), accounts[0].PubKey)
+
+	second, err := ks.AddAccount("work")
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), second.Index)
+	require.NotEqual(t, accounts[0].PubKey, second.PubKey)
+	_, err = ks.DeriveAccount(7, "anon")
+	require.NoError(t, err)
+	require.NoError(t, ks.SetMetadata(7, "nip05", "anon@example.com"))
+
+	kr, err := ks.Keyer(1)
+	require.NoError(t, err)
+	evt := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "from work"}
+	require.NoError(t, kr.SignEvent(ctx, &evt))
+	require.Equal(t, second.PubKey, evt.PubKey)
+
+	ncryptsec, err := ks.ExportNcryptsec(7, "other", 8)
+	require.NoError(t, err)
+	sk7, err := nip49.Decrypt(ncryptsec, "other")
+	require.NoError(t, err)
+
+	// save and load
+	data, err := ks.Save()
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "monkey")
+
+	loaded, err := LoadKeystore(data)
+	require.NoError(t, err)
+	require.True(t, loaded.IsLocked())
+	require.Equal(t, ks.Accounts(), loaded.Accounts())
+	_, err = loaded.Keyer(7)
+	require.ErrorContains(t, err, "locked")
+	require.Error(t, loaded.Unlock("wrong"))
+	require.NoError(t, loaded.Unlock("hunter2"))
+
+	kr, err = loaded.Keyer(7)
+	require.NoError(t, err)
+	pk, _ := kr.GetPublicKey(ctx)
+	require.Equal(t, sk7.Public(), pk)
+
+	acct, _ := loaded.AddAccount("")
+	require.Equal(t, uint32(8), acct.Index)
+	acct, _ = loaded.Account(7)
+	require.Equal(t, "anon@example.com", acct.Metadata["nip05"])
+}
+
+func TestKeystorePassphrase(t *testing.T) {
+	ks1, err := NewKeystore("", "", "pw", 8)
+	require.NoError(t, err)
+	words, err := ks1.Words()
+	require.NoError(t, err)
+
+	ks2, err := NewKeystore(words, "extra", "pw", 8)
+	require.NoError(t, err)
+	require.NotEqual(t, ks1.Accounts()[0].PubKey, ks2.Accounts()[0].PubKey)
+
+	ks2.Lock()
+	_, err = ks2.Words()
+	require.Error(t, err)
+	require.NoError(t, ks2.Unlock("pw"))
+	kr, err := ks2.Keyer(0)
+	require.NoError(t, err)
+	pk, _ := kr.GetPublicKey(context.Background())
+	require.Equal(t, ks2.Accounts()[0].PubKey, pk)
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 
 	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip04"
 	"fiatjaf.com/nostr/nip44"
 	"github.com/puzpuzpuz/xsync/v3"
 )
 
-var _ nostr.Keyer = (*KeySigner)(nil)
+var (
+	_ nostr.Keyer          = (*KeySigner)(nil)
+	_ nostr.NIP04Decrypter = (*KeySigner)(nil)
+)
 
 // KeySigner is a signer that holds the private key in memory
 type KeySigner struct {
@@ -60,3 +64,12 @@ func (ks KeySigner) Decrypt(ctx context.Context, base64ciphertext string, sender
 	}
 	return nip44.Decrypt(base64ciphertext, ck)
 }
+
+// NIP04Decrypt decrypts a legacy NIP-04 ciphertext from a sender.
+func (ks KeySigner) NIP04Decrypt(ctx context.Context, ciphertext string, sender nostr.PubKey) (string, error) {
+	key, err := nip04.ComputeSharedSecret(sender, ks.sk)
+	if err != nil {
+		return "", err
+	}
+	return nip04.Decrypt(ciphertext, key)
+}
@@ -30,6 +30,7 @@ export default {
           { text: 'Generating Events Live', link: '/cookbook/custom-live-events' },
           { text: 'Custom Stores', link: '/cookbook/custom-stores' },
           { text: 'Using something like Google Drive', link: '/cookbook/google-drive' },
+          { text: 'Replication', link: '/cookbook/replication' },
         ]
       }
     ],
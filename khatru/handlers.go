@@ -165,7 +165,13 @@ func (rl *Relay) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 					}
 
 					// check signature
-					if !env.Event.VerifySignature() {
+					var valid bool
+					if rl.Verifier != nil {
+						valid = rl.Verifier.Verify(env.Event)
+					} else {
+						valid = env.Event.VerifySignature()
+					}
+					if !valid {
 						ws.WriteJSON(nostr.OKEnvelope{EventID: env.Event.ID, OK: false, Reason: "invalid: signature is invalid"})
 						return
 					}
@@ -210,7 +216,8 @@ func (rl *Relay) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 					if env.Event.Kind == nostr.KindDeletion {
 						// store the delete event first
 						skipBroadcast, writeErr = srl.handleNormal(ctx, env.Event)
-						if writeErr == nil {
+						if writeErr == nil && srl.replica == nil {
+							// (replicas just forward the delete event, the primary will do the deleting)
 							// this always returns "blocked: " whenever it returns an error
 							writeErr = srl.handleDeleteRequest(ctx, env.Event)
 						}
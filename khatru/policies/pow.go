@@ -0,0 +1,64 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/khatru"
+	"fiatjaf.com/nostr/nip11"
+	"fiatjaf.com/nostr/nip13"
+)
+
+// PoWRules says how much NIP-13 proof-of-work (in committed difficulty, i.e. the target in the "nonce"
+// tag, not just the leading zeroes of the id) is required from events. When more than one rule applies
+// to an event the highest difficulty is required.
+type PoWRules struct {
+	// MinDifficulty applies to all events.
+	MinDifficulty int
+
+	// Kinds sets a minimum difficulty for specific kinds.
+	Kinds map[nostr.Kind]int
+
+	// Unauthenticated applies to events whose author is not authenticated (with NIP-42) on the connection
+	// that is sending them, so known users can skip the work.
+	Unauthenticated int
+}
+
+// RequiredDifficulty returns the difficulty an event must have to be accepted.
+func (rules PoWRules) RequiredDifficulty(ctx context.Context, event nostr.Event) int {
+	required := rules.MinDifficulty
+	if kindDifficulty, ok := rules.Kinds[event.Kind]; ok && kindDifficulty > required {
+		required = kindDifficulty
+	}
+	if rules.Unauthenticated > required && !khatru.IsAuthed(ctx, event.PubKey) {
+		required = rules.Unauthenticated
+	}
+	return required
+}
+
+// RequirePoW returns a function that can be used as OnEvent that will reject events that don't commit
+// to the difficulty required by the given rules. Call AdvertisePoW to let clients know about it.
+func RequirePoW(rules PoWRules) func(context.Context, nostr.Event) (bool, string) {
+	return func(ctx context.Context, event nostr.Event) (reject bool, msg string) {
+		required := rules.RequiredDifficulty(ctx, event)
+		if required <= 0 {
+			return false, ""
+		}
+
+		if work := nip13.CommittedDifficulty(event); work < required {
+			return true, fmt.Sprintf("pow: difficulty %d is less than %d", work, required)
+		}
+		return false, ""
+	}
+}
+
+// AdvertisePoW sets min_pow_difficulty in the relay's NIP-11 document to the difficulty that is required
+// from all events by the given rules and adds NIP-13 to the list of supported NIPs.
+func AdvertisePoW(rl *khatru.Relay, rules PoWRules) {
+	if rl.Info.Limitation == nil {
+		rl.Info.Limitation = &nip11.RelayLimitationDocument{}
+	}
+	rl.Info.Limitation.MinPowDifficulty = rules.MinDifficulty
+	rl.Info.AddSupportedNIP(13)
+}
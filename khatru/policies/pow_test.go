@@ -0,0 +1,50 @@
+package policies
+
+import (
+	"context"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/khatru"
+	"fiatjaf.com/nostr/nip13"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePoW(t *testing.T) {
+	sk := nostr.Generate()
+	mine := func(kind nostr.Kind, difficulty int) nostr.Event {
+		evt := nostr.Event{Kind: kind, CreatedAt: nostr.Now(), Content: "hello", PubKey: sk.Public()}
+		if difficulty > 0 {
+			nonce, err := nip13.DoWork(context.Background(), evt, difficulty)
+			require.NoError(t, err)
+			evt.Tags = append(evt.Tags, nonce)
+		}
+		require.NoError(t, evt.Sign(sk))
+		return evt
+	}
+
+	ctx := context.Background()
+	policy := RequirePoW(PoWRules{MinDifficulty: 4, Kinds: map[nostr.Kind]int{7: 8}})
+
+	reject, msg := policy(ctx, mine(1, 0))
+	require.True(t, reject)
+	require.Equal(t, "pow: difficulty 0 is less than 4", msg)
+	reject, _ = policy(ctx, mine(1, 4))
+	require.False(t, reject)
+	reject, _ = policy(ctx, mine(7, 4))
+	require.True(t, reject)
+	reject, _ = policy(ctx, mine(7, 8))
+	require.False(t, reject)
+
+	// unauthenticated users must do more work
+	policy = RequirePoW(PoWRules{Unauthenticated: 6})
+	reject, _ = policy(ctx, mine(1, 4))
+	require.True(t, reject)
+	reject, _ = policy(ctx, mine(1, 6))
+	require.False(t, reject)
+
+	rl := khatru.NewRelay()
+	AdvertisePoW(rl, PoWRules{MinDifficulty: 4})
+	require.Equal(t, 4, rl.Info.Limitation.MinPowDifficulty)
+	require.Contains(t, rl.Info.SupportedNIPs, 13)
+}
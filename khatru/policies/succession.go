@@ -0,0 +1,35 @@
+package policies
+
+import (
+	"context"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/khatru"
+	"fiatjaf.com/nostr/succession"
+)
+
+// RejectSupersededKeys returns a function that can be used as OnEvent that will reject events from keys
+// that have handed over to a successor through a key-succession attestation stored in the relay, which
+// should be accepting these. Attestations themselves are still accepted from superseded keys.
+func RejectSupersededKeys(rl *khatru.Relay) func(context.Context, nostr.Event) (bool, string) {
+	return func(ctx context.Context, event nostr.Event) (reject bool, msg string) {
+		if event.Kind == nostr.KindKeySuccession || rl.QueryStored == nil {
+			return false, ""
+		}
+
+		var attestations []succession.Attestation
+		for evt := range rl.QueryStored(ctx, nostr.Filter{
+			Kinds:   []nostr.Kind{nostr.KindKeySuccession},
+			Authors: []nostr.PubKey{event.PubKey},
+		}) {
+			if a, err := succession.Parse(evt); err == nil {
+				attestations = append(attestations, a)
+			}
+		}
+
+		if a, ok := succession.Select(event.PubKey, attestations, nostr.Now()); ok {
+			return true, "blocked: this key was superseded by " + a.To.Hex()
+		}
+		return false, ""
+	}
+}
@@ -0,0 +1,55 @@
+package policies
+
+import (
+	"context"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"fiatjaf.com/nostr/keyer"
+	"fiatjaf.com/nostr/khatru"
+	"fiatjaf.com/nostr/succession"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejectSupersededKeys(t *testing.T) {
+	ctx := context.Background()
+	rl := khatru.NewRelay()
+	db := &slicestore.SliceStore{}
+	db.Init()
+	rl.UseEventstore(db, 500)
+	policy := RejectSupersededKeys(rl)
+
+	old := nostr.Generate()
+	successor := nostr.Generate()
+	note := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "hello"}
+	require.NoError(t, note.Sign(old))
+
+	reject, _ := policy(ctx, note)
+	require.False(t, reject)
+
+	// a timelocked attestation doesn't count yet
+	pending, err := succession.Create(ctx, keyer.NewPlainKeySigner(old), successor.Public(), nostr.Now()+3600, "")
+	require.NoError(t, err)
+	require.NoError(t, db.SaveEvent(pending))
+	reject, _ = policy(ctx, note)
+	require.False(t, reject)
+
+	db.DeleteEvent(pending.ID)
+	attestation, err := succession.Create(ctx, keyer.NewPlainKeySigner(old), successor.Public(), 0, "leaked")
+	require.NoError(t, err)
+	reject, _ = policy(ctx, attestation)
+	require.False(t, reject)
+	require.NoError(t, db.SaveEvent(attestation))
+
+	reject, msg := policy(ctx, note)
+	require.True(t, reject)
+	require.Contains(t, msg, successor.Public().Hex())
+
+	// a conflicting attestation, e.g. from whoever got the leaked key, voids both
+	thief := nostr.Event{Kind: nostr.KindKeySuccession, CreatedAt: 1, Tags: nostr.Tags{{"p", nostr.Generate().Public().Hex()}}}
+	require.NoError(t, thief.Sign(old))
+	require.NoError(t, db.SaveEvent(thief))
+	reject, _ = policy(ctx, note)
+	require.False(t, reject)
+}
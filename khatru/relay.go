@@ -110,6 +110,9 @@ type Relay struct {
 	// set this to true to support negentropy
 	Negentropy bool
 
+	// if set, signatures of incoming events are verified in batches by this instead of one by one
+	Verifier *nostr.Verifier
+
 	// in case you call Server.Start
 	Addr       string
 	serveMux   *http.ServeMux
@@ -124,6 +127,9 @@ type Relay struct {
 
 	// NIP-40 expiration manager
 	expirationManager *expirationManager
+
+	// set when this relay is a replica of some other, see UseReplica
+	replica *Replica
 }
 
 // UseEventstore hooks up an eventstore.Store into the relay in the default way.
@@ -0,0 +1,525 @@
+package khatru
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore"
+	"fiatjaf.com/nostr/eventstore/wrappers"
+	"fiatjaf.com/nostr/nip86"
+)
+
+// ReplicationPath is where a primary serves its stream of changes, relative to the relay URL.
+const ReplicationPath = "/.well-known/khatru/replication"
+
+// replicationMessage is a line in the replication stream.
+//
+// "save", "replace" and "delete" are changes taken from the primary's ChangeFeed, "snapshot" is an event
+// that existed in the primary when the replica connected (for replicas that are too far behind), terminated
+// by a "snapshot-done" with the sequence number the snapshot corresponds to, and "ping" is just a heartbeat.
+// Every message carries the primary's latest sequence number in "head".
+type replicationMessage struct {
+	Op    string          `json:"op"`
+	Seq   uint64          `json:"seq,omitempty"`
+	Head  uint64          `json:"head"`
+	Time  nostr.Timestamp `json:"time,omitempty"`
+	ID    *nostr.ID       `json:"id,omitempty"`
+	Event *nostr.Event    `json:"event,omitempty"`
+}
+
+// ServeReplication turns this relay into a primary that replicas can follow (see UseReplica).
+// feed must be the same store given to UseEventstore, so every write to this relay shows up in it.
+//
+// Replicas must send secret as a bearer token. If secret is empty anyone can download the entire database.
+func (rl *Relay) ServeReplication(feed *wrappers.ChangeFeed, secret string) {
+	rl.Router().HandleFunc(ReplicationPath, func(w http.ResponseWriter, r *http.Request) {
+		if secret != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		from, _ := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+
+		// this is a long-lived response, so get rid of the server write timeout
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		send := func(msg replicationMessage) error {
+			if err := enc.Encode(msg); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		head := feed.LastSeq()
+		if from == 0 || from > head || from+1 < feed.OldestSeq() {
+			// the replica is new, is too far behind or is following some other log: send everything we have
+			for evt := range iterateAllEvents(feed.Store) {
+				if err := send(replicationMessage{Op: "snapshot", Head: head, Event: &evt}); err != nil {
+					return
+				}
+			}
+			if err := send(replicationMessage{Op: "snapshot-done", Seq: head, Head: head}); err != nil {
+				return
+			}
+			from = head
+		} else if err := send(replicationMessage{Op: "ping", Head: head}); err != nil {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		changes := make(chan wrappers.Change)
+		go func() {
+			defer close(changes)
+			for change := range feed.Subscribe(ctx, from+1) {
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		next := from + 1
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if err := send(replicationMessage{Op: "ping", Head: feed.LastSeq()}); err != nil {
+					return
+				}
+			case change, ok := <-changes:
+				if !ok || change.Seq != next {
+					// the feed was closed or retention got rid of changes the replica needed,
+					// it will reconnect and get a snapshot
+					return
+				}
+				next++
+
+				msg := replicationMessage{
+					Op:   change.Op.String(),
+					Seq:  change.Seq,
+					Head: max(change.Seq, feed.LastSeq()),
+					Time: change.Time,
+					ID:   &change.ID,
+				}
+				if change.Op != wrappers.OpDelete {
+					msg.Event = &change.Event
+				}
+				if err := send(msg); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// Replica keeps a local copy of a primary relay's events (see ServeReplication) by tailing its stream of
+// changes. Writes it gets are forwarded to the primary over a normal websocket connection.
+type Replica struct {
+	// PrimaryURL is the primary relay URL, ws(s):// and http(s):// are both accepted.
+	PrimaryURL string
+
+	// Secret is the same secret given to ServeReplication on the primary.
+	Secret string
+
+	// Store is where the replicated events are kept, it must not be written to by anything else.
+	Store eventstore.Store
+
+	// StatePath is a file where the last applied sequence number is kept so we can resume after a restart.
+	// If empty the replica will download a snapshot of the primary's entire database every time it starts.
+	StatePath string
+
+	rl *Relay
+
+	mu          sync.Mutex
+	connected   bool
+	applied     uint64
+	head        uint64
+	lastApplied nostr.Timestamp
+	lastContact time.Time
+	lastSaved   time.Time
+
+	forwardMu sync.Mutex
+	forward   *nostr.Relay
+}
+
+// ReplicationStats is what a replica reports about itself through the NIP-86 "stats" method.
+type ReplicationStats struct {
+	Primary     string `json:"primary"`
+	Connected   bool   `json:"connected"`
+	AppliedSeq  uint64 `json:"applied_seq"`
+	PrimarySeq  uint64 `json:"primary_seq"`
+	LagChanges  uint64 `json:"lag_changes"`
+	LagSeconds  int64  `json:"lag_seconds"`
+	LastContact int64  `json:"last_contact,omitempty"`
+}
+
+// UseReplica sets up this relay as a read-only replica: it answers REQs and COUNTs from replica.Store,
+// which is kept in sync with the primary in the background, while EVENTs are forwarded to the primary and
+// only saved locally after the primary accepts them.
+//
+// Since the primary sees all forwarded events coming from the replica, it shouldn't require NIP-42 AUTH
+// for writes or rate-limit them by IP.
+func (rl *Relay) UseReplica(replica *Replica, maxQueryLimit int) {
+	replica.rl = rl
+	rl.replica = replica
+	rl.UseEventstore(replica.Store, maxQueryLimit)
+
+	rl.StoreEvent = func(ctx context.Context, event nostr.Event) error {
+		if err := replica.forwardEvent(ctx, event); err != nil {
+			return err
+		}
+		// save it locally too so the author can read it back immediately,
+		// when the change comes back from the primary it will be a duplicate
+		replica.Store.SaveEvent(event)
+		return nil
+	}
+	rl.ReplaceEvent = func(ctx context.Context, event nostr.Event) error {
+		if err := replica.forwardEvent(ctx, event); err != nil {
+			return err
+		}
+		replica.Store.ReplaceEvent(event)
+		return nil
+	}
+	// deletion requests are forwarded like any other event and not processed here,
+	// we'll just get the deletions from the primary later
+
+	previousStats := rl.ManagementAPI.Stats
+	rl.ManagementAPI.Stats = func(ctx context.Context) (nip86.Response, error) {
+		result := make(map[string]any)
+		if previousStats != nil {
+			resp, err := previousStats(ctx)
+			if err != nil {
+				return resp, err
+			}
+			if m, ok := resp.Result.(map[string]any); ok {
+				maps.Copy(result, m)
+			} else if resp.Result != nil {
+				result["stats"] = resp.Result
+			}
+		}
+		result["replication"] = replica.Stats()
+		return nip86.Response{Result: result}, nil
+	}
+
+	if replica.StatePath != "" {
+		if data, err := os.ReadFile(replica.StatePath); err == nil {
+			replica.applied, _ = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		}
+	}
+
+	go replica.run(rl.ctx)
+}
+
+// Stats tells how far behind the primary this replica is.
+func (r *Replica) Stats() ReplicationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := ReplicationStats{
+		Primary:    r.PrimaryURL,
+		Connected:  r.connected,
+		AppliedSeq: r.applied,
+		PrimarySeq: r.head,
+	}
+	if r.head > r.applied {
+		stats.LagChanges = r.head - r.applied
+		if r.lastApplied != 0 {
+			stats.LagSeconds = int64(nostr.Now() - r.lastApplied)
+		}
+	}
+	if !r.lastContact.IsZero() {
+		stats.LastContact = r.lastContact.Unix()
+	}
+	return stats
+}
+
+func (r *Replica) forwardEvent(ctx context.Context, event nostr.Event) error {
+	r.forwardMu.Lock()
+	if r.forward == nil || !r.forward.IsConnected() {
+		relay, err := nostr.RelayConnect(r.rl.ctx, r.PrimaryURL, nostr.RelayOptions{})
+		if err != nil {
+			r.forwardMu.Unlock()
+			return fmt.Errorf("error: failed to reach primary: %w", err)
+		}
+		r.forward = relay
+	}
+	relay := r.forward
+	r.forwardMu.Unlock()
+
+	if err := relay.Publish(ctx, event); err != nil {
+		// the primary reason comes as "msg: <reason>", so strip that
+		reason := err.Error()
+		if idx := strings.Index(reason, "msg: "); idx != -1 {
+			reason = reason[idx+5:]
+		}
+		return errors.New(nostr.NormalizeOKMessage(reason, "error"))
+	}
+	return nil
+}
+
+func (r *Replica) run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := r.follow(ctx)
+
+		r.mu.Lock()
+		r.connected = false
+		r.mu.Unlock()
+		r.saveState(true)
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.rl.Log.Printf("replication from %s interrupted: %s", r.PrimaryURL, err)
+		}
+
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (r *Replica) follow(ctx context.Context) error {
+	u, err := url.Parse(r.PrimaryURL)
+	if err != nil {
+		return fmt.Errorf("invalid primary url: %w", err)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + ReplicationPath
+
+	r.mu.Lock()
+	from := r.applied
+	r.mu.Unlock()
+	u.RawQuery = url.Values{"from": {strconv.FormatUint(from, 10)}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Secret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("primary returned status %d", resp.StatusCode)
+	}
+
+	r.mu.Lock()
+	r.connected = true
+	r.mu.Unlock()
+
+	// ids we got in a snapshot, everything else we have will be deleted when it ends
+	var snapshot map[nostr.ID]struct{}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg replicationMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return fmt.Errorf("invalid message from primary: %w", err)
+		}
+
+		r.mu.Lock()
+		r.head = msg.Head
+		r.lastContact = time.Now()
+		r.mu.Unlock()
+
+		switch msg.Op {
+		case "ping":
+		case "snapshot":
+			if msg.Event == nil {
+				return fmt.Errorf("snapshot message without an event")
+			}
+			if snapshot == nil {
+				snapshot = make(map[nostr.ID]struct{})
+			}
+			snapshot[msg.Event.ID] = struct{}{}
+			if err := r.apply(*msg.Event, msg.Event.Kind.IsRegular()); err != nil {
+				return err
+			}
+		case "snapshot-done":
+			var stale []nostr.ID
+			for evt := range iterateAllEvents(r.Store) {
+				if _, ok := snapshot[evt.ID]; !ok {
+					stale = append(stale, evt.ID)
+				}
+			}
+			for _, id := range stale {
+				r.Store.DeleteEvent(id)
+			}
+			snapshot = nil
+			r.advance(msg.Seq, 0)
+		case "save", "replace":
+			if msg.Event == nil {
+				return fmt.Errorf("%s message without an event", msg.Op)
+			}
+			if msg.Seq != r.applied+1 {
+				return fmt.Errorf("expected change %d, got %d", r.applied+1, msg.Seq)
+			}
+			if err := r.apply(*msg.Event, msg.Op == "save"); err != nil {
+				return err
+			}
+			r.advance(msg.Seq, msg.Time)
+		case "delete":
+			if msg.ID == nil {
+				return fmt.Errorf("delete message without an id")
+			}
+			if msg.Seq != r.applied+1 {
+				return fmt.Errorf("expected change %d, got %d", r.applied+1, msg.Seq)
+			}
+			// it may not be here if we have already deleted it ourselves
+			r.Store.DeleteEvent(*msg.ID)
+			r.advance(msg.Seq, msg.Time)
+		default:
+			return fmt.Errorf("unexpected message '%s' from primary", msg.Op)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("primary closed the stream")
+}
+
+func (r *Replica) apply(event nostr.Event, save bool) error {
+	var err error
+	if save {
+		err = r.Store.SaveEvent(event)
+	} else {
+		err = r.Store.ReplaceEvent(event)
+	}
+
+	switch err {
+	case nil:
+		r.rl.BroadcastEvent(event)
+		return nil
+	case eventstore.ErrDupEvent:
+		return nil
+	default:
+		return fmt.Errorf("failed to store %s: %w", event.ID, err)
+	}
+}
+
+func (r *Replica) advance(seq uint64, at nostr.Timestamp) {
+	r.mu.Lock()
+	r.applied = seq
+	if at != 0 {
+		r.lastApplied = at
+	}
+	r.mu.Unlock()
+	r.saveState(false)
+}
+
+// saveState writes the applied sequence number to StatePath at most once per second unless forced,
+// if we crash before that we'll just get a few changes again and they'll be no-ops.
+func (r *Replica) saveState(force bool) {
+	if r.StatePath == "" {
+		return
+	}
+
+	r.mu.Lock()
+	if !force && time.Since(r.lastSaved) < time.Second {
+		r.mu.Unlock()
+		return
+	}
+	r.lastSaved = time.Now()
+	applied := r.applied
+	r.mu.Unlock()
+
+	tmp := r.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(applied, 10)), 0644); err != nil {
+		r.rl.Log.Printf("failed to save replication state: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, r.StatePath); err != nil {
+		r.rl.Log.Printf("failed to save replication state: %s", err)
+	}
+}
+
+// iterateAllEvents goes through an entire store in pages, from newest to oldest.
+func iterateAllEvents(store eventstore.Store) iter.Seq[nostr.Event] {
+	return func(yield func(nostr.Event) bool) {
+		pageSize := 1000
+		filter := nostr.Filter{}
+
+		// ids already emitted with the oldest timestamp we've seen, as these will show up again in the next page
+		var oldest nostr.Timestamp
+		seen := make(map[nostr.ID]struct{})
+
+		for {
+			count := 0
+			emitted := 0
+			for evt := range store.QueryEvents(filter, pageSize) {
+				count++
+				if _, ok := seen[evt.ID]; ok {
+					continue
+				}
+				if oldest == 0 || evt.CreatedAt < oldest {
+					oldest = evt.CreatedAt
+					clear(seen)
+				}
+				seen[evt.ID] = struct{}{}
+				emitted++
+				if !yield(evt) {
+					return
+				}
+			}
+
+			if count < pageSize {
+				return
+			}
+			if emitted == 0 {
+				// a single timestamp has more events than fit in a page
+				pageSize *= 2
+			}
+			filter.Until = oldest
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package khatru
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"fiatjaf.com/nostr/eventstore/wrappers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplication(t *testing.T) {
+	sk := nostr.Generate()
+	makeEvent := func(kind nostr.Kind, content string, tags nostr.Tags) nostr.Event {
+		evt := nostr.Event{Kind: kind, CreatedAt: nostr.Now(), Content: content, Tags: tags}
+		require.NoError(t, evt.Sign(sk))
+		return evt
+	}
+
+	// primary already has an event from before the feed existed
+	primaryStore := &slicestore.SliceStore{}
+	feed := &wrappers.ChangeFeed{Store: primaryStore}
+	require.NoError(t, feed.Init())
+	defer feed.Close()
+	old := makeEvent(1, "from before", nil)
+	require.NoError(t, primaryStore.SaveEvent(old))
+
+	primary := NewRelay()
+	primary.UseEventstore(feed, 500)
+	primary.ServeReplication(feed, "banana")
+	primaryServer := httptest.NewServer(primary)
+	defer primaryServer.Close()
+	defer primaryServer.CloseClientConnections() // the replication streams never end by themselves
+
+	// replica
+	replicaStore := &slicestore.SliceStore{}
+	require.NoError(t, replicaStore.Init())
+	replica := NewRelay()
+	replicaState := &Replica{
+		PrimaryURL: "ws" + primaryServer.URL[4:],
+		Secret:     "banana",
+		Store:      replicaStore,
+		StatePath:  filepath.Join(t.TempDir(), "state"),
+	}
+	replica.UseReplica(replicaState, 500)
+	replicaServer := httptest.NewServer(replica)
+	defer replicaServer.Close()
+
+	has := func(id nostr.ID) bool {
+		return slices.ContainsFunc(
+			slices.Collect(replicaStore.QueryEvents(nostr.Filter{IDs: []nostr.ID{id}}, 1)),
+			func(evt nostr.Event) bool { return evt.ID == id },
+		)
+	}
+
+	// snapshot arrives
+	require.Eventually(t, func() bool { return has(old.ID) }, 2*time.Second, 10*time.Millisecond)
+
+	// writes to the primary show up on the replica, also for live subscribers
+	client, err := nostr.RelayConnect(t.Context(), "ws"+replicaServer.URL[4:], nostr.RelayOptions{})
+	require.NoError(t, err)
+	defer client.Close()
+	sub, err := client.Subscribe(t.Context(), nostr.Filter{Kinds: []nostr.Kind{1}, Since: nostr.Now() - 5}, nostr.SubscriptionOptions{})
+	require.NoError(t, err)
+	defer sub.Unsub()
+
+	fresh := makeEvent(1, "to the primary", nil)
+	_, err = primary.AddEvent(context.Background(), fresh)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return has(fresh.ID) }, 2*time.Second, 10*time.Millisecond)
+
+	timeout := time.After(2 * time.Second)
+live:
+	for {
+		select {
+		case evt := <-sub.Events:
+			if evt.ID == fresh.ID {
+				break live
+			}
+		case <-timeout:
+			t.Fatal("live event not received from replica")
+		}
+	}
+
+	// writes to the replica are forwarded
+	forwarded := makeEvent(1, "to the replica", nil)
+	require.NoError(t, client.Publish(t.Context(), forwarded))
+	require.True(t, has(forwarded.ID))
+	require.Eventually(t, func() bool {
+		return len(slices.Collect(primaryStore.QueryEvents(nostr.Filter{IDs: []nostr.ID{forwarded.ID}}, 1))) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// and so are deletions
+	deletion := makeEvent(5, "", nostr.Tags{{"e", fresh.ID.Hex()}})
+	require.NoError(t, client.Publish(t.Context(), deletion))
+	require.Eventually(t, func() bool {
+		return len(slices.Collect(primaryStore.QueryEvents(nostr.Filter{IDs: []nostr.ID{fresh.ID}}, 1))) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return !has(fresh.ID) }, 2*time.Second, 10*time.Millisecond)
+
+	// lag is zero once we caught up
+	require.Eventually(t, func() bool {
+		stats := replicaState.Stats()
+		return stats.Connected && stats.AppliedSeq == feed.LastSeq() && stats.LagChanges == 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	resp, err := replica.ManagementAPI.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, feed.LastSeq(), resp.Result.(map[string]any)["replication"].(ReplicationStats).PrimarySeq)
+
+	// a wrong secret gets nothing
+	other := NewRelay()
+	otherState := &Replica{PrimaryURL: primaryServer.URL, Secret: "apple", Store: &slicestore.SliceStore{}}
+	otherState.Store.Init()
+	other.UseReplica(otherState, 500)
+	time.Sleep(200 * time.Millisecond)
+	require.False(t, otherState.Stats().Connected)
+	require.Zero(t, otherState.Stats().AppliedSeq)
+}
+
+func TestIterateAllEvents(t *testing.T) {
+	store := &slicestore.SliceStore{}
+	require.NoError(t, store.Init())
+
+	sk := nostr.Generate()
+	for i := range 2500 {
+		// lots of events share the same timestamp
+		evt := nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1000 + i/1500), Content: "x"}
+		evt.Tags = nostr.Tags{{"i", string(rune('a' + i%26)), nostr.Timestamp(i).Time().String()}}
+		require.NoError(t, evt.Sign(sk))
+		require.NoError(t, store.SaveEvent(evt))
+	}
+
+	seen := make(map[nostr.ID]struct{})
+	for evt := range iterateAllEvents(store) {
+		_, dup := seen[evt.ID]
+		require.False(t, dup)
+		seen[evt.ID] = struct{}{}
+	}
+	require.Len(t, seen, 2500)
+}
@@ -84,6 +84,8 @@ func (kind Kind) Name() string {
 		return "StatusClosed"
 	case KindStatusDraft:
 		return "StatusDraft"
+	case KindKeySuccession:
+		return "KeySuccession"
 	case KindProblemTracker:
 		return "ProblemTracker"
 	case KindReporting:
@@ -168,6 +170,8 @@ func (kind Kind) Name() string {
 		return "GoodWikiAuthorList"
 	case KindGoodWikiRelayList:
 		return "GoodWikiRelayList"
+	case KindRoomPresence:
+		return "RoomPresence"
 	case KindNWCWalletInfo:
 		return "NWCWalletInfo"
 	case KindLightningPubRPC:
@@ -228,6 +232,10 @@ func (kind Kind) Name() string {
 		return "ApplicationSpecificData"
 	case KindLiveEvent:
 		return "LiveEvent"
+	case KindMeetingSpace:
+		return "MeetingSpace"
+	case KindMeetingRoom:
+		return "MeetingRoom"
 	case KindUserStatuses:
 		return "UserStatuses"
 	case KindClassifiedListing:
@@ -315,6 +323,7 @@ const (
 	KindStatusApplied            Kind = 1631
 	KindStatusClosed             Kind = 1632
 	KindStatusDraft              Kind = 1633
+	KindKeySuccession            Kind = 1776
 	KindProblemTracker           Kind = 1971
 	KindReporting                Kind = 1984
 	KindLabel                    Kind = 1985
@@ -357,6 +366,7 @@ const (
 	KindFileStorageServerList    Kind = 10096
 	KindGoodWikiAuthorList       Kind = 10101
 	KindGoodWikiRelayList        Kind = 10102
+	KindRoomPresence             Kind = 10312
 	KindNWCWalletInfo            Kind = 13194
 	KindLightningPubRPC          Kind = 21000
 	KindClientAuthentication     Kind = 22242
@@ -387,6 +397,8 @@ const (
 	KindReleaseArtifactSets      Kind = 30063
 	KindApplicationSpecificData  Kind = 30078
 	KindLiveEvent                Kind = 30311
+	KindMeetingSpace             Kind = 30312
+	KindMeetingRoom              Kind = 30313
 	KindUserStatuses             Kind = 30315
 	KindClassifiedListing        Kind = 30402
 	KindDraftClassifiedListing   Kind = 30403
@@ -24,16 +24,30 @@ func SeedFromWords(words string) []byte {
 	return bip39.NewSeed(words, "")
 }
 
+// SeedFromWordsWithPassphrase is like SeedFromWords, but uses the optional BIP-39 passphrase.
+func SeedFromWordsWithPassphrase(words string, passphrase string) []byte {
+	return bip39.NewSeed(words, passphrase)
+}
+
 func PrivateKeyFromSeed(seed []byte) (string, error) {
-	key, err := bip32.NewMasterKey(seed)
+	sk, err := SecretKeyFromSeed(seed, 0)
 	if err != nil {
 		return "", err
 	}
+	return nostr.HexEncodeToString(sk[:]), nil
+}
+
+// SecretKeyFromSeed derives the key for the given account at m/44'/1237'/<account>'/0/0.
+func SecretKeyFromSeed(seed []byte, account uint32) (nostr.SecretKey, error) {
+	key, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return nostr.SecretKey{}, err
+	}
 
 	derivationPath := []uint32{
 		bip32.FirstHardenedChild + 44,
 		bip32.FirstHardenedChild + 1237,
-		bip32.FirstHardenedChild + 0,
+		bip32.FirstHardenedChild + account,
 		0,
 		0,
 	}
@@ -42,11 +56,11 @@ func PrivateKeyFromSeed(seed []byte) (string, error) {
 	for _, idx := range derivationPath {
 		var err error
 		if next, err = next.NewChildKey(idx); err != nil {
-			return "", err
+			return nostr.SecretKey{}, err
 		}
 	}
 
-	return nostr.HexEncodeToString(next.Key), nil
+	return nostr.SecretKey(next.Key), nil
 }
 
 func ValidateWords(words string) bool {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
@@ -130,3 +131,33 @@ func TestDoWorkTimeout(t *testing.T) {
 		}
 	}
 }
+
+func TestService(t *testing.T) {
+	service := NewService(1)
+	service.MaxDifficulty = 12
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	sk := nostr.Generate()
+	event := nostr.Event{
+		Kind:      nostr.KindTextNote,
+		Content:   "someone else is mining my business",
+		CreatedAt: nostr.Now(),
+		PubKey:    sk.Public(),
+		Tags:      nostr.Tags{{"t", "pow"}, {"nonce", "1", "1"}},
+	}
+
+	worked, err := RequestWork(context.Background(), server.URL, event, 10)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, CommittedDifficulty(worked), 10)
+	require.Len(t, worked.Tags, 2)
+	require.NoError(t, worked.Sign(sk))
+	require.GreaterOrEqual(t, CommittedDifficulty(worked), 10)
+
+	_, err = RequestWork(context.Background(), server.URL, event, 20)
+	require.ErrorContains(t, err, "higher than")
+
+	event.PubKey = nostr.ZeroPK
+	_, err = RequestWork(context.Background(), server.URL, event, 4)
+	require.ErrorContains(t, err, "without a pubkey")
+}
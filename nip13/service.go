@@ -0,0 +1,178 @@
+package nip13
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	nostr "fiatjaf.com/nostr"
+)
+
+var ErrDifficultyTooHigh = errors.New("nip13: requested difficulty is higher than what this service allows")
+
+// WorkRequest is what is sent to a Service: an unsigned event (with pubkey set) and the target difficulty.
+type WorkRequest struct {
+	Event      nostr.Event `json:"event"`
+	Difficulty int         `json:"difficulty"`
+}
+
+// WorkResponse is what a Service returns: the same event with a "nonce" tag and the id set, ready to be
+// signed, or an error.
+type WorkResponse struct {
+	Event *nostr.Event `json:"event,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// Service is an http.Handler that does proof-of-work on behalf of clients that can't (or don't want to)
+// spend the CPU. It takes a WorkRequest as a JSON POST body, mines it with DoWork and returns a WorkResponse.
+type Service struct {
+	// MaxDifficulty is the highest difficulty that will be accepted, defaults to 28.
+	MaxDifficulty int
+
+	// Budget is how long each request is allowed to take, defaults to 30 seconds.
+	Budget time.Duration
+
+	slots chan struct{}
+}
+
+// NewService creates a Service that mines at most maxConcurrent events at the same time (each of them
+// already uses all the CPUs). Other requests wait for a slot within their budget.
+func NewService(maxConcurrent int) *Service {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	return &Service{
+		MaxDifficulty: 28,
+		Budget:        time.Second * 30,
+		slots:         make(chan struct{}, maxConcurrent),
+	}
+}
+
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(WorkResponse{Error: "must be a POST"})
+		return
+	}
+
+	var req WorkRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 512000)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(WorkResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	evt, err := s.Work(r.Context(), req.Event, req.Difficulty)
+	if err != nil {
+		switch err {
+		case ErrGenerateTimeout:
+			w.WriteHeader(http.StatusRequestTimeout)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(WorkResponse{Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(WorkResponse{Event: &evt})
+}
+
+// Work does the same as a request to the service would do, returning the event with the "nonce" tag
+// added and its id set. Any existing "nonce" tag and signature are removed.
+func (s *Service) Work(ctx context.Context, event nostr.Event, difficulty int) (nostr.Event, error) {
+	if difficulty > s.MaxDifficulty {
+		return event, ErrDifficultyTooHigh
+	}
+	if event.PubKey == nostr.ZeroPK {
+		return event, ErrMissingPubKey
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Budget)
+	defer cancel()
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return event, ErrGenerateTimeout
+	}
+
+	event.Tags = withoutNonce(event.Tags)
+	event.Sig = [64]byte{}
+
+	nonce, err := DoWork(ctx, event, difficulty)
+	if err != nil {
+		return event, err
+	}
+	event.Tags = append(event.Tags, nonce)
+	event.ID = event.GetID()
+
+	return event, nil
+}
+
+// RequestWork asks the Service at serviceURL to do the proof-of-work for the given unsigned event.
+// The returned event must then be signed without changing anything else.
+func RequestWork(ctx context.Context, serviceURL string, event nostr.Event, difficulty int) (nostr.Event, error) {
+	body, _ := json.Marshal(WorkRequest{Event: event, Difficulty: difficulty})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serviceURL, bytes.NewReader(body))
+	if err != nil {
+		return event, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return event, fmt.Errorf("failed to call pow service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var res WorkResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 512000)).Decode(&res); err != nil {
+		return event, fmt.Errorf("invalid response from pow service (%d): %w", resp.StatusCode, err)
+	}
+	if res.Error != "" {
+		return event, fmt.Errorf("pow service: %s", res.Error)
+	}
+	if res.Event == nil {
+		return event, fmt.Errorf("pow service returned no event")
+	}
+
+	// make sure the service didn't change anything else and actually did the work
+	worked := *res.Event
+	if worked.PubKey != event.PubKey || worked.Kind != event.Kind || worked.CreatedAt != event.CreatedAt ||
+		worked.Content != event.Content ||
+		!slices.EqualFunc(withoutNonce(worked.Tags), withoutNonce(event.Tags), slices.Equal) {
+		return event, fmt.Errorf("pow service returned a different event")
+	}
+	if worked.ID != worked.GetID() || CommittedDifficulty(worked) < difficulty {
+		return event, fmt.Errorf("pow service returned an event without the required work")
+	}
+
+	return worked, nil
+}
+
+func withoutNonce(tags nostr.Tags) nostr.Tags {
+	result := make(nostr.Tags, 0, len(tags)+1)
+	for _, tag := range tags {
+		if len(tag) > 0 && tag[0] == "nonce" {
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package nip23
+
+import (
+	"strconv"
+
+	"fiatjaf.com/nostr"
+)
+
+// Article is a long-form post, either published (kind 30023) or a draft (kind 30024).
+type Article struct {
+	Identifier  string
+	Title       string
+	Summary     string
+	Image       string
+	PublishedAt nostr.Timestamp
+	Hashtags    []string
+	Draft       bool
+
+	// Content is the markdown body.
+	Content string
+
+	// Tags holds all the tags not covered by the fields above, so they are kept when the article is edited.
+	Tags nostr.Tags
+}
+
+// ParseArticle reads a kind 30023 or 30024 event.
+func ParseArticle(event nostr.Event) Article {
+	article := Article{
+		Draft:   event.Kind == nostr.KindDraftArticle,
+		Content: event.Content,
+	}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			article.Tags = append(article.Tags, tag)
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			article.Identifier = tag[1]
+		case "title":
+			article.Title = tag[1]
+		case "summary":
+			article.Summary = tag[1]
+		case "image":
+			article.Image = tag[1]
+		case "published_at":
+			if i, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				article.PublishedAt = nostr.Timestamp(i)
+			}
+		case "t":
+			article.Hashtags = append(article.Hashtags, tag[1])
+		default:
+			article.Tags = append(article.Tags, tag)
+		}
+	}
+	return article
+}
+
+// ToEvent returns an unsigned event for the article, of kind 30024 if it is a draft or 30023 otherwise.
+func (a Article) ToEvent() nostr.Event {
+	kind := nostr.KindArticle
+	if a.Draft {
+		kind = nostr.KindDraftArticle
+	}
+
+	tags := make(nostr.Tags, 0, 5+len(a.Hashtags)+len(a.Tags))
+	tags = append(tags, nostr.Tag{"d", a.Identifier})
+	if a.Title != "" {
+		tags = append(tags, nostr.Tag{"title", a.Title})
+	}
+	if a.Summary != "" {
+		tags = append(tags, nostr.Tag{"summary", a.Summary})
+	}
+	if a.Image != "" {
+		tags = append(tags, nostr.Tag{"image", a.Image})
+	}
+	if a.PublishedAt != 0 {
+		tags = append(tags, nostr.Tag{"published_at", strconv.FormatInt(int64(a.PublishedAt), 10)})
+	}
+	for _, hashtag := range a.Hashtags {
+		tags = append(tags, nostr.Tag{"t", hashtag})
+	}
+	tags = append(tags, a.Tags...)
+
+	return nostr.Event{
+		Kind:      kind,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   a.Content,
+	}
+}
+
+// Publish turns the article, which may be a draft, into a kind 30023 event.
+//
+// previous, if given, must be the currently published version of the same article: its published_at
+// is kept so edits don't change the publication date. Otherwise the article's own PublishedAt is used,
+// or the current time if it isn't set, meaning it is being published for the first time.
+func (a *Article) Publish(previous *nostr.Event) nostr.Event {
+	a.Draft = false
+	if previous != nil && previous.Kind == nostr.KindArticle {
+		if prev := ParseArticle(*previous); prev.Identifier == a.Identifier && prev.PublishedAt != 0 {
+			a.PublishedAt = prev.PublishedAt
+		}
+	}
+	if a.PublishedAt == 0 {
+		a.PublishedAt = nostr.Now()
+	}
+	return a.ToEvent()
+}
+
+// SaveDraft turns the article into a kind 30024 event. Its PublishedAt is kept, so an already published
+// article that is edited as a draft keeps its publication date when published again.
+func (a *Article) SaveDraft() nostr.Event {
+	a.Draft = true
+	return a.ToEvent()
+}
@@ -0,0 +1,123 @@
+package nip23
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"gopkg.in/yaml.v3"
+)
+
+type frontMatter struct {
+	Slug        string   `yaml:"slug,omitempty"`
+	Title       string   `yaml:"title,omitempty"`
+	Summary     string   `yaml:"summary,omitempty"`
+	Description string   `yaml:"description,omitempty"`
+	Image       string   `yaml:"image,omitempty"`
+	PublishedAt any      `yaml:"published_at,omitempty"`
+	Date        any      `yaml:"date,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	Draft       bool     `yaml:"draft,omitempty"`
+}
+
+// ImportMarkdown reads a markdown file with optional YAML front matter into an Article.
+//
+// The front matter fields used are "slug" (the identifier), "title", "summary" (or "description"),
+// "image", "published_at" (or "date", as a unix timestamp or a date), "tags" and "draft".
+func ImportMarkdown(data []byte) (Article, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	article := Article{}
+	if !bytes.HasPrefix(data, []byte("---\n")) {
+		article.Content = string(data)
+		return article, nil
+	}
+
+	// search from the first line break so an empty front matter is also found
+	end := bytes.Index(data[3:], []byte("\n---"))
+	if end == -1 {
+		return article, fmt.Errorf("unterminated front matter")
+	}
+	end += 3
+	header := data[4:max(end, 4)]
+	body := data[end+4:]
+
+	var fm frontMatter
+	if err := yaml.Unmarshal(header, &fm); err != nil {
+		return article, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	article.Identifier = fm.Slug
+	article.Title = fm.Title
+	article.Summary = fm.Summary
+	if article.Summary == "" {
+		article.Summary = fm.Description
+	}
+	article.Image = fm.Image
+	article.Hashtags = fm.Tags
+	article.Draft = fm.Draft
+	article.Content = strings.TrimLeft(string(body), "\n")
+
+	date := fm.PublishedAt
+	if date == nil {
+		date = fm.Date
+	}
+	if date != nil {
+		ts, err := parseFrontMatterDate(date)
+		if err != nil {
+			return article, err
+		}
+		article.PublishedAt = ts
+	}
+
+	return article, nil
+}
+
+// ExportMarkdown writes the article as markdown with YAML front matter, in the format read by ImportMarkdown.
+func (a Article) ExportMarkdown() []byte {
+	fm := frontMatter{
+		Slug:    a.Identifier,
+		Title:   a.Title,
+		Summary: a.Summary,
+		Image:   a.Image,
+		Tags:    a.Hashtags,
+		Draft:   a.Draft,
+	}
+	if a.PublishedAt != 0 {
+		fm.PublishedAt = a.PublishedAt.Time().UTC().Format(time.RFC3339)
+	}
+
+	header, _ := yaml.Marshal(fm)
+
+	buf := bytes.NewBuffer(make([]byte, 0, 8+len(header)+len(a.Content)))
+	buf.WriteString("---\n")
+	buf.Write(header)
+	buf.WriteString("---\n\n")
+	buf.WriteString(a.Content)
+	if !strings.HasSuffix(a.Content, "\n") {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func parseFrontMatterDate(v any) (nostr.Timestamp, error) {
+	switch date := v.(type) {
+	case int:
+		return nostr.Timestamp(date), nil
+	case time.Time:
+		return nostr.Timestamp(date.Unix()), nil
+	case string:
+		if i, err := strconv.ParseInt(date, 10, 64); err == nil {
+			return nostr.Timestamp(i), nil
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+			if t, err := time.Parse(layout, date); err == nil {
+				return nostr.Timestamp(t.Unix()), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid date '%v'", v)
+}
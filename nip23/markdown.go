@@ -34,17 +34,7 @@ func MarkdownToHTML(md string) string {
 
 	md = strings.ReplaceAll(md, "\u00A0", " ")
 
-	// create markdown parser with extensions
-	// this parser is stateful so it must be reinitialized every time
-	doc := parser.NewWithExtensions(
-		parser.AutoHeadingIDs |
-			parser.NoIntraEmphasis |
-			parser.FencedCode |
-			parser.Autolink |
-			parser.Footnotes |
-			parser.SpaceHeadings |
-			parser.Tables,
-	).Parse([]byte(md))
+	doc := newParser().Parse([]byte(md))
 
 	// create HTML renderer with extensions
 	output := string(markdown.Render(doc, renderer))
@@ -55,6 +45,20 @@ func MarkdownToHTML(md string) string {
 	return output
 }
 
+// newParser creates a markdown parser with our extensions.
+// this parser is stateful so it must be reinitialized every time
+func newParser() *parser.Parser {
+	return parser.NewWithExtensions(
+		parser.AutoHeadingIDs |
+			parser.NoIntraEmphasis |
+			parser.FencedCode |
+			parser.Autolink |
+			parser.Footnotes |
+			parser.SpaceHeadings |
+			parser.Tables,
+	)
+}
+
 func sanitizeXSS(html string) string {
 	p := bluemonday.UGCPolicy()
 	p.RequireNoFollowOnLinks(false)
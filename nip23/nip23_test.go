@@ -0,0 +1,97 @@
+package nip23
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishFlow(t *testing.T) {
+	article := Article{Identifier: "hello", Title: "Hello", Content: "# Hello\n\nworld", Hashtags: []string{"intro"}}
+
+	draft := article.SaveDraft()
+	require.Equal(t, nostr.KindDraftArticle, draft.Kind)
+	require.Nil(t, draft.Tags.Find("published_at"))
+
+	published := article.Publish(nil)
+	require.Equal(t, nostr.KindArticle, published.Kind)
+	publishedAt := article.PublishedAt
+	require.NotZero(t, publishedAt)
+	published.Tags = append(published.Tags, nostr.Tag{"client", "test"})
+
+	// edit it later, as a draft first and then publishing it again
+	edited := ParseArticle(published)
+	edited.PublishedAt = 0 // as if we had lost it
+	edited.Content += "\n\nedited"
+	require.Equal(t, nostr.KindDraftArticle, edited.SaveDraft().Kind)
+	republished := edited.Publish(&published)
+	require.Equal(t, publishedAt, ParseArticle(republished).PublishedAt)
+	require.Equal(t, "test", republished.Tags.Find("client")[1])
+	require.Equal(t, []string{"intro"}, ParseArticle(republished).Hashtags)
+}
+
+func TestRewriteReferences(t *testing.T) {
+	npub := "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"
+	note := nip19.EncodeNevent(nostr.MustIDFromHex("dfaecba66155920004e3d4c1e5d4e5c9cef1b2690ebf4488da4e4fb632e02a34"), nil, nostr.ZeroPK)
+	md := "hi nostr:" + npub + ", [see](nostr:" + note + ")\n" +
+		"`nostr:" + npub + "`\n" +
+		"```\nnostr:" + npub + "\n```\n" +
+		"nostr:" + note + "\n"
+
+	rewritten := RewriteReferences(md, LinkReferences("https://njump.me/"))
+	require.Equal(t, "hi [@npub180cvv…jh6w6](https://njump.me/"+npub+"), [see](https://njump.me/"+note+")\n"+
+		"`nostr:"+npub+"`\n"+
+		"```\nnostr:"+npub+"\n```\n"+
+		"["+note[0:10]+"…"+note[len(note)-5:]+"](https://njump.me/"+note+")\n", rewritten)
+
+	embedded := RewriteReferences(md, EmbedReferences("https://njump.me/", func(pointer nostr.Pointer) (nostr.Event, bool) {
+		return nostr.Event{Kind: 1, Content: "quoted\nnote", PubKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")}, true
+	}))
+	require.True(t, strings.HasSuffix(embedded, "> quoted\n> note\n>\n> — [npub180cvv…jh6w6](https://njump.me/"+note+")\n"))
+	require.Contains(t, embedded, "[see](https://njump.me/"+note+")")
+}
+
+func TestTableOfContents(t *testing.T) {
+	md := "# Intro\n\nsome text\n\n## The *first* part\n\nmore\n\n## Second\n"
+	require.Equal(t, []Heading{
+		{Level: 1, Text: "Intro", ID: "intro"},
+		{Level: 2, Text: "The first part", ID: "the-first-part"},
+		{Level: 2, Text: "Second", ID: "second"},
+	}, TableOfContents(md))
+	require.Contains(t, MarkdownToHTML(md), `id="the-first-part"`)
+
+	require.Equal(t, time.Minute, ReadingTime(md, 0))
+	require.Equal(t, 3*time.Minute, ReadingTime(strings.Repeat("word ", 600), 0))
+}
+
+func TestFrontMatter(t *testing.T) {
+	article, err := ImportMarkdown([]byte("---\r\ntitle: My Post\r\nslug: my-post\r\ndescription: about things\r\ndate: 2024-03-01\r\ntags: [a, b]\r\n---\r\n\r\nbody here\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, Article{
+		Identifier:  "my-post",
+		Title:       "My Post",
+		Summary:     "about things",
+		PublishedAt: nostr.Timestamp(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		Hashtags:    []string{"a", "b"},
+		Content:     "body here\n",
+	}, article)
+
+	reimported, err := ImportMarkdown(article.ExportMarkdown())
+	require.NoError(t, err)
+	require.Equal(t, article, reimported)
+
+	plain, err := ImportMarkdown([]byte("just text"))
+	require.NoError(t, err)
+	require.Equal(t, "just text", plain.Content)
+
+	empty, err := ImportMarkdown([]byte("---\n---\nbody"))
+	require.NoError(t, err)
+	require.Equal(t, "body", empty.Content)
+
+	_, err = ImportMarkdown([]byte("---\ntitle: x\nbody"))
+	require.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package nip23
+
+import (
+	"strings"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// Reference is a NIP-21 "nostr:" URI found in markdown.
+type Reference struct {
+	// Code is the NIP-19 code, without the "nostr:" prefix.
+	Code string
+
+	// Pointer is a nostr.ProfilePointer, nostr.EventPointer or nostr.EntityPointer.
+	Pointer nostr.Pointer
+
+	// InLink is true when the reference is the destination of a markdown link, like "[x](nostr:...)", in
+	// which case it must be replaced by an URL.
+	InLink bool
+
+	// Standalone is true when the reference is alone in its line, so it can be replaced by a block.
+	Standalone bool
+}
+
+// RewriteReferences calls replace for each "nostr:" reference found in md, except in code, and puts its
+// return value in the place of the reference.
+func RewriteReferences(md string, replace func(ref Reference) string) string {
+	lines := strings.SplitAfter(md, "\n")
+	fence := ""
+	for l, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[0:3]
+			continue
+		}
+
+		// split by inline code spans and only rewrite outside of them
+		parts := strings.Split(line, "`")
+		for p := 0; p < len(parts); p += 2 {
+			parts[p] = rewriteReferencesInText(parts[p], trimmed, replace)
+		}
+		lines[l] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "")
+}
+
+func rewriteReferencesInText(text string, line string, replace func(ref Reference) string) string {
+	matches := nostrEveryMatcher.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	b := strings.Builder{}
+	prev := 0
+	for _, match := range matches {
+		uri := text[match[0]:match[1]]
+		code := uri[6:]
+		prefix, data, err := nip19.Decode(code)
+		if err != nil {
+			continue
+		}
+
+		ref := Reference{
+			Code:       code,
+			InLink:     match[0] >= 2 && text[match[0]-2:match[0]] == "](",
+			Standalone: line == uri,
+		}
+		switch prefix {
+		case "npub":
+			ref.Pointer = nostr.ProfilePointer{PublicKey: data.(nostr.PubKey)}
+		case "note":
+			ref.Pointer = nostr.EventPointer{ID: data.(nostr.ID)}
+		default:
+			ref.Pointer = data.(nostr.Pointer)
+		}
+
+		b.WriteString(text[prev:match[0]])
+		b.WriteString(replace(ref))
+		prev = match[1]
+	}
+	b.WriteString(text[prev:])
+	return b.String()
+}
+
+// LinkReferences returns a function to be used with RewriteReferences that turns references into markdown
+// links to baseURL followed by the NIP-19 code, e.g. "https://njump.me/".
+func LinkReferences(baseURL string) func(ref Reference) string {
+	return func(ref Reference) string {
+		url := baseURL + ref.Code
+		if ref.InLink {
+			return url
+		}
+		return "[" + referenceLabel(ref) + "](" + url + ")"
+	}
+}
+
+// EmbedReferences is like LinkReferences, but references to events that stand alone in their lines are
+// replaced by a quote of the event, fetched with the given function, followed by a link to it.
+func EmbedReferences(baseURL string, fetch func(pointer nostr.Pointer) (nostr.Event, bool)) func(ref Reference) string {
+	link := LinkReferences(baseURL)
+	return func(ref Reference) string {
+		if _, isProfile := ref.Pointer.(nostr.ProfilePointer); isProfile || ref.InLink || !ref.Standalone {
+			return link(ref)
+		}
+
+		evt, ok := fetch(ref.Pointer)
+		if !ok {
+			return link(ref)
+		}
+
+		content := evt.Content
+		if evt.Kind == nostr.KindArticle {
+			content = ParseArticle(evt).Title
+		}
+		quote := "> " + strings.ReplaceAll(strings.TrimSpace(content), "\n", "\n> ")
+		return quote + "\n>\n> — [" + shortCode(nip19.EncodeNpub(evt.PubKey)) + "](" + baseURL + ref.Code + ")"
+	}
+}
+
+func referenceLabel(ref Reference) string {
+	if pp, ok := ref.Pointer.(nostr.ProfilePointer); ok {
+		return "@" + shortCode(nip19.EncodeNpub(pp.PublicKey))
+	}
+	return shortCode(ref.Code)
+}
+
+func shortCode(code string) string {
+	if len(code) < 20 {
+		return code
+	}
+	return code[0:10] + "…" + code[len(code)-5:]
+}
@@ -0,0 +1,58 @@
+package nip23
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Heading is an entry in the table of contents of an article.
+type Heading struct {
+	Level int
+	Text  string
+
+	// ID is the anchor given to the heading by MarkdownToHTML.
+	ID string
+}
+
+// TableOfContents lists the headings in the markdown, in order.
+func TableOfContents(md string) []Heading {
+	doc := newParser().Parse([]byte(strings.ReplaceAll(md, "\u00A0", " ")))
+
+	var headings []Heading
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		text := strings.Builder{}
+		ast.WalkFunc(heading, func(node ast.Node, entering bool) ast.WalkStatus {
+			if leaf := node.AsLeaf(); leaf != nil && entering {
+				text.Write(leaf.Literal)
+			}
+			return ast.GoToNext
+		})
+		headings = append(headings, Heading{
+			Level: heading.Level,
+			Text:  strings.TrimSpace(text.String()),
+			ID:    heading.HeadingID,
+		})
+		return ast.SkipChildren
+	})
+
+	return headings
+}
+
+// ReadingTime estimates how long it takes to read the markdown at the given words per minute, or at 238
+// if wordsPerMinute is zero. It is rounded up to the next minute.
+func ReadingTime(md string, wordsPerMinute int) time.Duration {
+	if wordsPerMinute <= 0 {
+		wordsPerMinute = 238
+	}
+	words := len(strings.Fields(md))
+	minutes := math.Ceil(float64(words) / float64(wordsPerMinute))
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,262 @@
+// Package render turns note contents into a typed tree of nodes (text, mentions, hashtags, custom emoji,
+// media, invoices, cashu tokens etc.) on top of nip27.Parse, which can then be rendered by a Backend such
+// as HTML or Text.
+package render
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip27"
+	"fiatjaf.com/nostr/nip73"
+	"fiatjaf.com/nostr/nip92"
+)
+
+// Node is one of the node types defined in this package.
+type Node interface {
+	// Source is the piece of the original content this node was parsed from.
+	Source() string
+}
+
+// Text is plain text, which may contain line breaks.
+type Text struct {
+	Text string
+}
+
+// Mention is a reference to a profile.
+type Mention struct {
+	Raw     string
+	Pointer nostr.ProfilePointer
+
+	// Name is the profile name, only set when the Parser has a ResolveName function.
+	Name string
+}
+
+// Quote is a reference to an event, either a regular one (nostr.EventPointer) or an addressable one
+// (nostr.EntityPointer).
+type Quote struct {
+	Raw     string
+	Pointer nostr.Pointer
+}
+
+// Hashtag is a "#" followed by a word.
+type Hashtag struct {
+	Raw string
+	Tag string
+}
+
+// Emoji is a NIP-30 custom emoji declared in the event "emoji" tags.
+type Emoji struct {
+	Shortcode string
+	URL       string
+}
+
+// Link is a URL that isn't media.
+type Link struct {
+	URL string
+}
+
+// Relay is a websocket URL.
+type Relay struct {
+	URL string
+}
+
+// MediaType is the kind of media a Media node points to.
+type MediaType int
+
+const (
+	Image MediaType = iota
+	Video
+	Audio
+)
+
+// Media is an URL that points to an image, video or audio, with its NIP-92 "imeta" information, if any.
+type Media struct {
+	URL   string
+	Type  MediaType
+	IMeta nip92.IMetaEntry
+}
+
+// Invoice is a BOLT-11 lightning invoice.
+type Invoice struct {
+	Raw     string
+	Invoice string
+}
+
+// Cashu is a cashu token.
+type Cashu struct {
+	Token string
+}
+
+// DisplayName returns the profile name, if it was fetched, or a shortened npub.
+func (n Mention) DisplayName() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	npub := nip19.EncodeNpub(n.Pointer.PublicKey)
+	return npub[0:7] + "…" + npub[58:]
+}
+
+func (n Text) Source() string    { return n.Text }
+func (n Mention) Source() string { return n.Raw }
+func (n Quote) Source() string   { return n.Raw }
+func (n Hashtag) Source() string { return n.Raw }
+func (n Emoji) Source() string   { return ":" + n.Shortcode + ":" }
+func (n Link) Source() string    { return n.URL }
+func (n Relay) Source() string   { return n.URL }
+func (n Media) Source() string   { return n.URL }
+func (n Invoice) Source() string { return n.Raw }
+func (n Cashu) Source() string   { return n.Token }
+
+// Backend turns nodes into their final representation.
+type Backend interface {
+	Render(nodes []Node) string
+}
+
+// Parser holds the optional dependencies for parsing.
+type Parser struct {
+	// ResolveName, if given, is used to get the names of mentioned profiles. It is called concurrently for
+	// all the mentions in the content, so with an sdk.System it can be something like
+	//
+	//	func(ctx context.Context, pubkey nostr.PubKey) string {
+	//		return sys.FetchProfileMetadata(ctx, pubkey).ShortName()
+	//	}
+	//
+	// and the profiles will be fetched in a single batch.
+	ResolveName func(ctx context.Context, pubkey nostr.PubKey) string
+}
+
+var (
+	inlineMatcher = regexp.MustCompile(
+		`#[\p{L}\p{N}_]+` +
+			`|:[a-zA-Z0-9_\-]+:` +
+			`|(?i:(?:lightning:)?ln(?:bc|tb|tbs|bcrt)[0-9]*[munp]?1[02-9ac-hj-np-z]{50,})` +
+			`|cashu[AB][A-Za-z0-9_\-+/=]{20,}`,
+	)
+
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg"}
+	videoExtensions = []string{".mp4", ".webm", ".mov", ".m3u8"}
+	audioExtensions = []string{".mp3", ".ogg", ".wav", ".flac", ".m4a", ".opus"}
+)
+
+// ParseEvent is like Parse, using the event content and tags.
+func (p Parser) ParseEvent(ctx context.Context, evt nostr.Event) []Node {
+	return p.Parse(ctx, evt.Content, evt.Tags)
+}
+
+// Parse turns content into a list of nodes. tags are used for finding custom emoji and media metadata.
+func (p Parser) Parse(ctx context.Context, content string, tags nostr.Tags) []Node {
+	imeta := nip92.ParseTags(tags)
+	emoji := make(map[string]string)
+	for _, tag := range tags {
+		if len(tag) >= 3 && tag[0] == "emoji" {
+			emoji[tag[1]] = tag[2]
+		}
+	}
+
+	nodes := make([]Node, 0, 8)
+	var mentions []int
+	for block := range nip27.Parse(content) {
+		switch pointer := block.Pointer.(type) {
+		case nil:
+			nodes = parseInline(nodes, block.Text, emoji)
+		case nostr.ProfilePointer:
+			mentions = append(mentions, len(nodes))
+			nodes = append(nodes, Mention{Raw: block.Text, Pointer: pointer})
+		case nostr.EventPointer, nostr.EntityPointer:
+			nodes = append(nodes, Quote{Raw: block.Text, Pointer: pointer})
+		case nip73.ExternalPointer:
+			nodes = append(nodes, parseURL(pointer.Thing, imeta))
+		default:
+			nodes = appendText(nodes, block.Text)
+		}
+	}
+
+	if p.ResolveName != nil {
+		wg := sync.WaitGroup{}
+		for _, i := range mentions {
+			mention := nodes[i].(Mention)
+			wg.Go(func() {
+				mention.Name = p.ResolveName(ctx, mention.Pointer.PublicKey)
+				nodes[i] = mention
+			})
+		}
+		wg.Wait()
+	}
+
+	return nodes
+}
+
+func parseURL(url string, imeta nip92.IMeta) Node {
+	if strings.HasPrefix(url, "ws") {
+		return Relay{URL: url}
+	}
+
+	entry, hasIMeta := imeta.Get(url)
+	ext := strings.ToLower(path.Ext(strings.SplitN(strings.SplitN(url, "?", 2)[0], "#", 2)[0]))
+	switch {
+	case slices.Contains(videoExtensions, ext):
+		return Media{URL: url, Type: Video, IMeta: entry}
+	case slices.Contains(audioExtensions, ext):
+		return Media{URL: url, Type: Audio, IMeta: entry}
+	case slices.Contains(imageExtensions, ext) || hasIMeta:
+		return Media{URL: url, Type: Image, IMeta: entry}
+	default:
+		return Link{URL: url}
+	}
+}
+
+func parseInline(nodes []Node, text string, emoji map[string]string) []Node {
+	prev := 0
+	for _, match := range inlineMatcher.FindAllStringIndex(text, -1) {
+		start, end := match[0], match[1]
+		raw := text[start:end]
+
+		var node Node
+		switch {
+		case raw[0] == '#':
+			if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(r) || unicode.IsNumber(r) || r == '&') {
+				continue
+			}
+			node = Hashtag{Raw: raw, Tag: strings.ToLower(raw[1:])}
+		case raw[0] == ':':
+			url, ok := emoji[raw[1:len(raw)-1]]
+			if !ok {
+				continue
+			}
+			node = Emoji{Shortcode: raw[1 : len(raw)-1], URL: url}
+		case strings.HasPrefix(raw, "cashu"):
+			node = Cashu{Token: raw}
+		default:
+			invoice := strings.ToLower(raw)
+			invoice = strings.TrimPrefix(invoice, "lightning:")
+			node = Invoice{Raw: raw, Invoice: invoice}
+		}
+
+		nodes = appendText(nodes, text[prev:start])
+		nodes = append(nodes, node)
+		prev = end
+	}
+	return appendText(nodes, text[prev:])
+}
+
+func appendText(nodes []Node, text string) []Node {
+	if text == "" {
+		return nodes
+	}
+	if last := len(nodes) - 1; last >= 0 {
+		if prev, ok := nodes[last].(Text); ok {
+			nodes[last] = Text{Text: prev.Text + text}
+			return nodes
+		}
+	}
+	return append(nodes, Text{Text: text})
+}
@@ -0,0 +1,124 @@
+package render
+
+import (
+	stdhtml "html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fiatjaf.com/nostr/nip19"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var _ Backend = HTML{}
+
+// HTML renders nodes as HTML, which is then sanitized.
+type HTML struct {
+	// Link returns the href for mentions, quotes and hashtags. When it is nil or returns an empty string
+	// mentions and quotes link to their "nostr:" URIs and hashtags aren't links.
+	Link func(node Node) string
+
+	// Policy is used to sanitize the output, defaults to DefaultPolicy().
+	Policy *bluemonday.Policy
+}
+
+// DefaultPolicy is the bluemonday UGC policy plus what is needed for the elements emitted by HTML.
+func DefaultPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(false)
+	p.AllowURLSchemes("nostr", "lightning", "cashu")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z\-]+$`)).OnElements("a", "span", "img", "video", "audio")
+	p.AllowElements("video", "audio", "source")
+	p.AllowAttrs("controls", "width", "height", "src").OnElements("video", "audio")
+	p.AllowAttrs("alt", "title", "data-blurhash").OnElements("img")
+	return p
+}
+
+func (h HTML) Render(nodes []Node) string {
+	b := strings.Builder{}
+	for _, node := range nodes {
+		h.renderNode(&b, node)
+	}
+
+	policy := h.Policy
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+	return policy.Sanitize(b.String())
+}
+
+func (h HTML) renderNode(b *strings.Builder, node Node) {
+	esc := stdhtml.EscapeString
+
+	switch n := node.(type) {
+	case Text:
+		b.WriteString(strings.ReplaceAll(esc(n.Text), "\n", "<br>"))
+	case Mention:
+		href := h.link(n)
+		if href == "" {
+			href = "nostr:" + nip19.EncodeNprofile(n.Pointer.PublicKey, n.Pointer.Relays)
+		}
+		b.WriteString(`<a class="mention" href="` + esc(href) + `">@` + esc(n.DisplayName()) + `</a>`)
+	case Quote:
+		href := h.link(n)
+		if href == "" {
+			href = n.Raw
+		}
+		b.WriteString(`<a class="quote" href="` + esc(href) + `">` + esc(shortenURI(n.Raw)) + `</a>`)
+	case Hashtag:
+		if href := h.link(n); href != "" {
+			b.WriteString(`<a class="hashtag" href="` + esc(href) + `">` + esc(n.Raw) + `</a>`)
+		} else {
+			b.WriteString(`<span class="hashtag">` + esc(n.Raw) + `</span>`)
+		}
+	case Emoji:
+		code := esc(":" + n.Shortcode + ":")
+		b.WriteString(`<img class="emoji" src="` + esc(n.URL) + `" alt="` + code + `" title="` + code + `">`)
+	case Link:
+		b.WriteString(`<a href="` + esc(n.URL) + `">` + esc(n.URL) + `</a>`)
+	case Relay:
+		b.WriteString(`<span class="relay">` + esc(n.URL) + `</span>`)
+	case Media:
+		var dim string
+		if n.IMeta.Width > 0 && n.IMeta.Height > 0 {
+			dim = ` width="` + strconv.Itoa(n.IMeta.Width) + `" height="` + strconv.Itoa(n.IMeta.Height) + `"`
+		}
+		switch n.Type {
+		case Video:
+			b.WriteString(`<video controls src="` + esc(n.URL) + `"` + dim + `></video>`)
+		case Audio:
+			b.WriteString(`<audio controls src="` + esc(n.URL) + `"></audio>`)
+		default:
+			b.WriteString(`<img src="` + esc(n.URL) + `" alt="` + esc(n.IMeta.Alt) + `"` + dim)
+			if n.IMeta.Blurhash != "" {
+				b.WriteString(` data-blurhash="` + esc(n.IMeta.Blurhash) + `"`)
+			}
+			b.WriteString(`>`)
+		}
+	case Invoice:
+		b.WriteString(`<a class="invoice" href="lightning:` + esc(n.Invoice) + `">` +
+			esc(shorten(n.Invoice, 16)) + `</a>`)
+	case Cashu:
+		b.WriteString(`<a class="cashu" href="cashu:` + esc(n.Token) + `">` + esc(shorten(n.Token, 16)) + `</a>`)
+	default:
+		b.WriteString(esc(node.Source()))
+	}
+}
+
+func (h HTML) link(node Node) string {
+	if h.Link == nil {
+		return ""
+	}
+	return h.Link(node)
+}
+
+func shortenURI(uri string) string {
+	return shorten(strings.TrimPrefix(uri, "nostr:"), 16)
+}
+
+func shorten(s string, keep int) string {
+	if len(s) <= keep*2+1 {
+		return s
+	}
+	return s[0:keep] + "…" + s[len(s)-keep/2:]
+}
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip92"
+	"github.com/stretchr/testify/require"
+)
+
+const invoice = "lnbc10u1pjq8a6ypp5x8xgfz8nsnzm43f0f3rjn3vclnsfmv7kx3m6p4x9h2ugjdq5fj0sdqqcqzzsxqyz5vqsp5h6t2e5xk8wz9g2hqupyh9mq8ph2w60h6e5h4l8d3t5z2dfv0s9q9qyyssq8mn9lkh6w7s2vafcj9adf0xjf3m6knwj8y4cvj3jg2t5rhw0j6tu6d2lrj2yngd8fd0m6c3q0cz3sjvj0xlq4y3kxn8kfy5x5wr3wqqp9ylqps"
+
+func TestParse(t *testing.T) {
+	content := "gm #Nostr, look at this :soapbox: by nostr:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6\n" +
+		"https://example.com/cat.jpg?x=1 https://example.com/page https://video.host/clip.mp4 and pay " + invoice +
+		" or lightning:" + strings.ToUpper(invoice) + " on wss://relay.example.com, issue#12"
+	tags := nostr.Tags{
+		{"emoji", "soapbox", "https://example.com/soapbox.png"},
+		{"imeta", "url https://example.com/cat.jpg?x=1", "dim 640x480", "blurhash LKO2?U%2Tw=w]~RBVZRi};RPxuwH", "alt a cat"},
+	}
+
+	nodes := Parser{}.Parse(context.Background(), content, tags)
+	require.Equal(t, []Node{
+		Text{Text: "gm "},
+		Hashtag{Raw: "#Nostr", Tag: "nostr"},
+		Text{Text: ", look at this "},
+		Emoji{Shortcode: "soapbox", URL: "https://example.com/soapbox.png"},
+		Text{Text: " by "},
+		Mention{
+			Raw:     "nostr:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6",
+			Pointer: nostr.ProfilePointer{PublicKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")},
+		},
+		Text{Text: "\n"},
+		Media{URL: "https://example.com/cat.jpg?x=1", Type: Image, IMeta: nip92.IMetaEntry{
+			URL:      "https://example.com/cat.jpg?x=1",
+			Width:    640,
+			Height:   480,
+			Blurhash: "LKO2?U%2Tw=w]~RBVZRi};RPxuwH",
+			Alt:      "a cat",
+		}},
+		Text{Text: " "},
+		Link{URL: "https://example.com/page"},
+		Text{Text: " "},
+		Media{URL: "https://video.host/clip.mp4", Type: Video},
+		Text{Text: " and pay "},
+		Invoice{Raw: invoice, Invoice: invoice},
+		Text{Text: " or "},
+		Invoice{Raw: "lightning:" + strings.ToUpper(invoice), Invoice: invoice},
+		Text{Text: " on "},
+		Relay{URL: "wss://relay.example.com"},
+		Text{Text: ", issue#12"},
+	}, nodes)
+
+	// unknown emoji are left alone
+	nodes = Parser{}.Parse(context.Background(), "hi :unknown:", nil)
+	require.Equal(t, []Node{Text{Text: "hi :unknown:"}}, nodes)
+}
+
+func TestParseResolveName(t *testing.T) {
+	alice := nostr.Generate().Public()
+	bob := nostr.Generate().Public()
+	names := map[nostr.PubKey]string{alice: "alice", bob: "bob"}
+
+	// both lookups must be in flight at the same time for the names to be resolved
+	inflight := sync.WaitGroup{}
+	inflight.Add(2)
+	all := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(all)
+	}()
+
+	parser := Parser{ResolveName: func(ctx context.Context, pubkey nostr.PubKey) string {
+		inflight.Done()
+		select {
+		case <-all:
+			return names[pubkey]
+		case <-time.After(2 * time.Second):
+			return ""
+		}
+	}}
+	nodes := parser.Parse(context.Background(),
+		"hi nostr:"+nip19.EncodeNpub(alice)+" and nostr:"+nip19.EncodeNpub(bob), nil)
+	require.Len(t, nodes, 4)
+	require.Equal(t, "alice", nodes[1].(Mention).Name)
+	require.Equal(t, "bob", nodes[3].(Mention).Name)
+}
+
+func TestHTML(t *testing.T) {
+	nodes := []Node{
+		Text{Text: "<script>alert(1)</script>\nhello "},
+		Mention{Pointer: nostr.ProfilePointer{PublicKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")}, Name: "fiatjaf"},
+		Text{Text: " "},
+		Hashtag{Raw: "#nostr", Tag: "nostr"},
+		Emoji{Shortcode: "soapbox", URL: "https://example.com/soapbox.png"},
+		Media{URL: "https://example.com/cat.jpg", Type: Image, IMeta: nip92.IMetaEntry{Width: 640, Height: 480, Blurhash: "LKO2", Alt: "a cat"}},
+		Link{URL: "javascript:alert(1)"},
+	}
+
+	html := HTML{}.Render(nodes)
+	require.NotContains(t, html, "<script>")
+	require.NotContains(t, html, `href="javascript`)
+	require.Contains(t, html, "&lt;script&gt;alert(1)&lt;/script&gt;<br>hello ")
+	require.Contains(t, html, `<a class="mention" href="nostr:nprofile1`)
+	require.Contains(t, html, `>@fiatjaf</a>`)
+	require.Contains(t, html, `<span class="hashtag">#nostr</span>`)
+	require.Contains(t, html, `<img class="emoji" src="https://example.com/soapbox.png" alt=":soapbox:" title=":soapbox:">`)
+	require.Contains(t, html, `<img src="https://example.com/cat.jpg" alt="a cat" width="640" height="480" data-blurhash="LKO2">`)
+
+	html = HTML{Link: func(node Node) string {
+		if h, ok := node.(Hashtag); ok {
+			return "/t/" + h.Tag
+		}
+		return ""
+	}}.Render(nodes)
+	require.Contains(t, html, `<a class="hashtag" href="/t/nostr">#nostr</a>`)
+}
+
+func TestPlainText(t *testing.T) {
+	nodes := []Node{
+		Text{Text: "hello "},
+		Mention{Pointer: nostr.ProfilePointer{PublicKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")}},
+		Text{Text: " "},
+		Media{URL: "https://example.com/cat.jpg", Type: Image, IMeta: nip92.IMetaEntry{Alt: "a cat"}},
+	}
+	require.Equal(t, "hello @npub180…jh6w6 [image: a cat] https://example.com/cat.jpg", PlainText{}.Render(nodes))
+	require.Contains(t, PlainText{ANSI: true}.Render(nodes), "\x1b]8;;https://example.com/cat.jpg\x1b\\")
+
+	// content can't bring its own escape sequences
+	evil := []Node{
+		Text{Text: "a\x1b[2J\x1b]8;;https://evil.example.com\x1b\\b\u009bc\n\td"},
+		Mention{Name: "x\x07y", Pointer: nostr.ProfilePointer{PublicKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")}},
+		Link{URL: "https://example.com/\x1b\\"},
+	}
+	require.Equal(t, "a[2J]8;;https://evil.example.com\\bc\n\td@xyhttps://example.com/\\", PlainText{}.Render(evil))
+	ansi := PlainText{ANSI: true}.Render(evil)
+	require.NotContains(t, ansi, "\x1b[2J")
+	require.NotContains(t, ansi, "evil.example.com\x1b")
+	require.Equal(t, 3+2+4, strings.Count(ansi, "\x1b")) // only our own styles and hyperlink
+}
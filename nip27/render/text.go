@@ -0,0 +1,96 @@
+package render
+
+import (
+	"strings"
+)
+
+var _ Backend = PlainText{}
+
+// PlainText renders nodes as plain text, optionally with ANSI escape codes for terminals. Control characters
+// (other than newlines and tabs) are stripped from the content in both modes, so it can't inject its own
+// escape sequences.
+type PlainText struct {
+	// ANSI enables colors and OSC 8 hyperlinks.
+	ANSI bool
+}
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiCyan    = "\x1b[36m"
+	ansiBlue    = "\x1b[34m"
+	ansiYellow  = "\x1b[33m"
+	ansiMagenta = "\x1b[35m"
+)
+
+func (t PlainText) Render(nodes []Node) string {
+	b := strings.Builder{}
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case Text:
+			b.WriteString(stripControl(n.Text, true))
+		case Mention:
+			t.write(&b, ansiBold+ansiCyan, "@"+n.DisplayName(), "")
+		case Quote:
+			t.write(&b, ansiCyan, n.Raw, "")
+		case Hashtag:
+			t.write(&b, ansiBlue, n.Raw, "")
+		case Emoji:
+			b.WriteString(":" + stripControl(n.Shortcode, true) + ":")
+		case Link:
+			t.write(&b, ansiBlue, n.URL, n.URL)
+		case Relay:
+			t.write(&b, ansiBlue, n.URL, "")
+		case Media:
+			label := "image"
+			switch n.Type {
+			case Video:
+				label = "video"
+			case Audio:
+				label = "audio"
+			}
+			if n.IMeta.Alt != "" {
+				label += ": " + n.IMeta.Alt
+			}
+			t.write(&b, ansiMagenta, "["+label+"] "+n.URL, n.URL)
+		case Invoice:
+			t.write(&b, ansiYellow, "[invoice] "+n.Invoice, "")
+		case Cashu:
+			t.write(&b, ansiYellow, "[cashu] "+n.Token, "")
+		default:
+			b.WriteString(stripControl(node.Source(), true))
+		}
+	}
+	return b.String()
+}
+
+func (t PlainText) write(b *strings.Builder, color string, text string, link string) {
+	text = stripControl(text, true)
+	link = stripControl(link, false)
+	if !t.ANSI {
+		b.WriteString(text)
+		return
+	}
+	if link != "" {
+		b.WriteString("\x1b]8;;" + link + "\x1b\\")
+	}
+	b.WriteString(color + text + ansiReset)
+	if link != "" {
+		b.WriteString("\x1b]8;;\x1b\\")
+	}
+}
+
+// stripControl removes C0 and C1 control characters (and DEL), keeping newlines and tabs only if
+// keepWhitespace is true.
+func stripControl(s string, keepWhitespace bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case keepWhitespace && (r == '\n' || r == '\t'):
+			return r
+		case r < 0x20, r >= 0x7f && r <= 0x9f:
+			return -1
+		default:
+			return r
+		}
+	}, s)
+}
@@ -22,18 +22,33 @@ const (
 )
 
 func Encrypt(secretKey [32]byte, password string, logn uint8, ksb KeySecurityByte) (b32code string, err error) {
+	concat, err := EncryptBytes(secretKey[:], password, logn, ksb)
+	if err != nil {
+		return "", err
+	}
+
+	bits5, err := bech32.ConvertBits(concat, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode("ncryptsec", bits5)
+}
+
+// EncryptBytes encrypts arbitrary data in the same way as Encrypt does with keys, but returns the raw
+// binary payload (version, logn, salt, nonce, key security byte and ciphertext) instead of a bech32 string.
+func EncryptBytes(data []byte, password string, logn uint8, ksb KeySecurityByte) ([]byte, error) {
 	salt := make([]byte, 16)
 	if _, err := rand.Read(salt); err != nil {
-		return "", fmt.Errorf("failed to read salt: %w", err)
+		return nil, fmt.Errorf("failed to read salt: %w", err)
 	}
 	n := int(math.Pow(2, float64(int(logn))))
 
 	key, err := getKey(password, salt, n)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	concat := make([]byte, 91)
+	concat := make([]byte, 2+16+24+1, 2+16+24+1+len(data)+chacha20poly1305.Overhead)
 	concat[0] = 0x02
 	concat[1] = byte(logn)
 	copy(concat[2:2+16], salt)
@@ -43,16 +58,9 @@ func Encrypt(secretKey [32]byte, password string, logn uint8, ksb KeySecurityByt
 
 	c2p1, err := chacha20poly1305.NewX(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to start xchacha20poly1305: %w", err)
-	}
-	ciphertext := c2p1.Seal(nil, concat[2+16:2+16+24], secretKey[:], ad)
-	copy(concat[2+16+24+1:], ciphertext)
-
-	bits5, err := bech32.ConvertBits(concat, 8, 5, true)
-	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to start xchacha20poly1305: %w", err)
 	}
-	return bech32.Encode("ncryptsec", bits5)
+	return c2p1.Seal(concat, concat[2+16:2+16+24], data, ad), nil
 }
 
 func Decrypt(bech32string string, password string) (secretKey nostr.SecretKey, err error) {
@@ -74,6 +82,15 @@ func DecryptToBytes(bech32string string, password string) (secretKey []byte, err
 		return nil, fmt.Errorf("failed translating data into 8 bits: %s", err.Error())
 	}
 
+	return DecryptBytes(data, password)
+}
+
+// DecryptBytes decrypts a payload produced by EncryptBytes.
+func DecryptBytes(data []byte, password string) ([]byte, error) {
+	if len(data) < 2+16+24+1 {
+		return nil, fmt.Errorf("payload too short")
+	}
+
 	version := data[0]
 	if version != 0x02 {
 		return nil, fmt.Errorf("expected version 0x02, got %v", version)
@@ -0,0 +1,124 @@
+package nip52
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// Calendar is a kind 31924 collection of calendar events.
+type Calendar struct {
+	PubKey      nostr.PubKey
+	Identifier  string
+	Title       string
+	Description string
+	Events      []nostr.EntityPointer
+}
+
+// ParseCalendar reads a kind 31924 event.
+func ParseCalendar(event nostr.Event) Calendar {
+	cal := Calendar{
+		PubKey:      event.PubKey,
+		Description: event.Content,
+	}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			cal.Identifier = tag[1]
+		case "title":
+			cal.Title = tag[1]
+		case "a":
+			if ptr, err := nostr.EntityPointerFromTag(tag); err == nil &&
+				(ptr.Kind == DateBased || ptr.Kind == TimeBased) {
+				cal.Events = append(cal.Events, ptr)
+			}
+		}
+	}
+	return cal
+}
+
+// ToEvent returns an unsigned kind 31924 event for the calendar.
+func (cal Calendar) ToEvent() nostr.Event {
+	tags := make(nostr.Tags, 0, 2+len(cal.Events))
+	tags = append(tags, nostr.Tag{"d", cal.Identifier}, nostr.Tag{"title", cal.Title})
+	for _, ptr := range cal.Events {
+		tags = append(tags, ptr.AsTag())
+	}
+	return nostr.Event{
+		Kind:      nostr.KindCalendar,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   cal.Description,
+	}
+}
+
+// Filter returns a filter that matches all the events in the calendar (and possibly some others, so
+// results must be checked with Contains).
+func (cal Calendar) Filter() nostr.Filter {
+	filter := nostr.Filter{Tags: nostr.TagMap{"d": make([]string, 0, len(cal.Events))}}
+	for _, ptr := range cal.Events {
+		if !slices.Contains(filter.Kinds, ptr.Kind) {
+			filter.Kinds = append(filter.Kinds, ptr.Kind)
+		}
+		if !slices.Contains(filter.Authors, ptr.PublicKey) {
+			filter.Authors = append(filter.Authors, ptr.PublicKey)
+		}
+		if !slices.Contains(filter.Tags["d"], ptr.Identifier) {
+			filter.Tags["d"] = append(filter.Tags["d"], ptr.Identifier)
+		}
+	}
+	return filter
+}
+
+// Contains tells whether the event is referenced by the calendar.
+func (cal Calendar) Contains(event nostr.Event) bool {
+	return slices.ContainsFunc(cal.Events, func(ptr nostr.EntityPointer) bool { return ptr.MatchesEvent(event) })
+}
+
+// EventsInRange returns the calendar events that overlap the range between from and to, sorted by start.
+func EventsInRange(events []CalendarEvent, from, to time.Time) []CalendarEvent {
+	result := make([]CalendarEvent, 0, len(events))
+	for _, calev := range events {
+		if calev.Overlaps(from, to) {
+			result = append(result, calev)
+		}
+	}
+	slices.SortFunc(result, func(a, b CalendarEvent) int { return a.Start.Compare(b.Start) })
+	return result
+}
+
+// FetchRange fetches the events referenced by the calendar from the given relays and returns those that
+// overlap the range between from and to, sorted by start. Only the newest version of each is considered.
+func FetchRange(
+	ctx context.Context,
+	pool *nostr.Pool,
+	relays []string,
+	cal Calendar,
+	from, to time.Time,
+) []CalendarEvent {
+	if len(cal.Events) == 0 {
+		return nil
+	}
+
+	latest := make(map[string]nostr.Event, len(cal.Events))
+	for ie := range pool.FetchMany(ctx, relays, cal.Filter(), nostr.SubscriptionOptions{Label: "calendar"}) {
+		if !cal.Contains(ie.Event) {
+			continue
+		}
+		key := nostr.EntityPointer{PublicKey: ie.PubKey, Kind: ie.Kind, Identifier: ie.Tags.GetD()}.AsTagReference()
+		if prev, ok := latest[key]; !ok || prev.CreatedAt < ie.CreatedAt {
+			latest[key] = ie.Event
+		}
+	}
+
+	events := make([]CalendarEvent, 0, len(latest))
+	for _, evt := range latest {
+		events = append(events, ParseCalendarEvent(evt))
+	}
+	return EventsInRange(events, from, to)
+}
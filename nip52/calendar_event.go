@@ -16,6 +16,7 @@ const (
 
 type CalendarEvent struct {
 	CalendarEventKind
+	PubKey       nostr.PubKey
 	Identifier   string
 	Title        string
 	Image        string
@@ -27,6 +28,7 @@ type CalendarEvent struct {
 	Hashtags     []string
 	StartTzid    string
 	EndTzid      string
+	Description  string
 }
 
 type Participant struct {
@@ -38,6 +40,8 @@ type Participant struct {
 func ParseCalendarEvent(event nostr.Event) CalendarEvent {
 	calev := CalendarEvent{
 		CalendarEventKind: CalendarEventKind(event.Kind),
+		PubKey:            event.PubKey,
+		Description:       event.Content,
 	}
 	for _, tag := range event.Tags {
 		if len(tag) < 2 {
@@ -107,7 +111,7 @@ func (calev CalendarEvent) ToHashtags() nostr.Tags {
 	tags = append(tags, nostr.Tag{"d", calev.Identifier})
 	tags = append(tags, nostr.Tag{"title", calev.Title})
 	if calev.Image != "" {
-		tags = append(tags, nostr.Tag{"image", calev.Title})
+		tags = append(tags, nostr.Tag{"image", calev.Image})
 	}
 
 	if calev.CalendarEventKind == TimeBased {
@@ -122,6 +126,13 @@ func (calev CalendarEvent) ToHashtags() nostr.Tags {
 		}
 	}
 
+	if calev.StartTzid != "" {
+		tags = append(tags, nostr.Tag{"start_tzid", calev.StartTzid})
+	}
+	if calev.EndTzid != "" {
+		tags = append(tags, nostr.Tag{"end_tzid", calev.EndTzid})
+	}
+
 	for _, location := range calev.Locations {
 		tags = append(tags, nostr.Tag{"location", location})
 	}
@@ -140,3 +151,37 @@ func (calev CalendarEvent) ToHashtags() nostr.Tags {
 
 	return tags
 }
+
+// ToEvent returns an unsigned event with the tags from ToHashtags and the description as content.
+func (calev CalendarEvent) ToEvent() nostr.Event {
+	return nostr.Event{
+		Kind:      nostr.Kind(calev.CalendarEventKind),
+		CreatedAt: nostr.Now(),
+		Tags:      calev.ToHashtags(),
+		Content:   calev.Description,
+	}
+}
+
+// Pointer returns the address of the calendar event.
+func (calev CalendarEvent) Pointer() nostr.EntityPointer {
+	return nostr.EntityPointer{
+		PublicKey:  calev.PubKey,
+		Kind:       nostr.Kind(calev.CalendarEventKind),
+		Identifier: calev.Identifier,
+	}
+}
+
+// Overlaps tells whether the calendar event happens, even partially, between from (inclusive) and to
+// (exclusive). The end is exclusive too, and when it isn't set date-based events last for a day while
+// time-based events are instantaneous.
+func (calev CalendarEvent) Overlaps(from, to time.Time) bool {
+	end := calev.End
+	if end.IsZero() || !end.After(calev.Start) {
+		if calev.CalendarEventKind == DateBased {
+			end = calev.Start.AddDate(0, 0, 1)
+		} else {
+			return !calev.Start.Before(from) && calev.Start.Before(to)
+		}
+	}
+	return calev.Start.Before(to) && end.After(from)
+}
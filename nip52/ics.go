@@ -0,0 +1,519 @@
+package nip52
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"fiatjaf.com/nostr"
+)
+
+const (
+	icsDateFormat     = "20060102"
+	icsDateTimeFormat = "20060102T150405"
+
+	// maxRecurrences is the maximum number of occurrences ImportICS creates for a recurring event.
+	maxRecurrences = 500
+)
+
+// ExportICS writes the calendar and its events as an iCalendar (RFC 5545) file. Time-based events with
+// a start_tzid are written in that time zone, referenced by its IANA name.
+func ExportICS(cal Calendar, events []CalendarEvent) []byte {
+	w := &icsWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//fiatjaf.com/nostr//nip52//EN")
+	w.line("CALSCALE:GREGORIAN")
+	if cal.Title != "" {
+		w.line("X-WR-CALNAME:" + icsEscape(cal.Title))
+	}
+	if cal.Description != "" {
+		w.line("X-WR-CALDESC:" + icsEscape(cal.Description))
+	}
+
+	stamp := time.Now().UTC().Format(icsDateTimeFormat) + "Z"
+	for _, calev := range events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + icsEscape(calev.Identifier))
+		w.line("DTSTAMP:" + stamp)
+		if calev.CalendarEventKind == DateBased {
+			w.line("DTSTART;VALUE=DATE:" + calev.Start.Format(icsDateFormat))
+			if !calev.End.IsZero() {
+				w.line("DTEND;VALUE=DATE:" + calev.End.Format(icsDateFormat))
+			}
+		} else {
+			w.line("DTSTART" + icsDateTime(calev.Start, calev.StartTzid))
+			if !calev.End.IsZero() {
+				tzid := calev.EndTzid
+				if tzid == "" {
+					tzid = calev.StartTzid
+				}
+				w.line("DTEND" + icsDateTime(calev.End, tzid))
+			}
+		}
+		if calev.Title != "" {
+			w.line("SUMMARY:" + icsEscape(calev.Title))
+		}
+		if calev.Description != "" {
+			w.line("DESCRIPTION:" + icsEscape(calev.Description))
+		}
+		if len(calev.Locations) > 0 {
+			w.line("LOCATION:" + icsEscape(strings.Join(calev.Locations, ", ")))
+		}
+		if len(calev.Hashtags) > 0 {
+			escaped := make([]string, len(calev.Hashtags))
+			for i, hashtag := range calev.Hashtags {
+				escaped[i] = icsEscape(hashtag)
+			}
+			w.line("CATEGORIES:" + strings.Join(escaped, ","))
+		}
+		if len(calev.References) > 0 {
+			w.line("URL:" + calev.References[0])
+		}
+		if calev.Image != "" {
+			w.line("IMAGE;VALUE=URI:" + calev.Image)
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+func icsDateTime(t time.Time, tzid string) string {
+	if tzid != "" {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			return ";TZID=" + tzid + ":" + t.In(loc).Format(icsDateTimeFormat)
+		}
+	}
+	return ":" + t.UTC().Format(icsDateTimeFormat) + "Z"
+}
+
+type icsWriter struct {
+	buf bytes.Buffer
+}
+
+// line writes a content line folded at 75 octets, as required by the RFC.
+func (w *icsWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.buf.WriteString(s[0:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // the leading space counts
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string { return icsEscaper.Replace(s) }
+
+var icsUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func icsUnescape(s string) string { return icsUnescaper.Replace(s) }
+
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ImportICS reads an iCalendar (RFC 5545) file into a calendar and its events, which are all set as
+// authored by the given key. Identifiers are taken from the UIDs.
+//
+// Recurring events are expanded into one calendar event for each occurrence (up to 500 of them, or 5
+// years if the rule is unbounded), with the date of the occurrence appended to the identifier. Only
+// FREQ, INTERVAL, COUNT, UNTIL and, for weekly rules, BYDAY are supported, along with EXDATE; events
+// with other rules are imported as single events.
+func ImportICS(data []byte, author nostr.PubKey) (Calendar, []CalendarEvent, error) {
+	properties, err := parseICS(data)
+	if err != nil {
+		return Calendar{}, nil, err
+	}
+
+	cal := Calendar{PubKey: author}
+	var events []CalendarEvent
+	var current []icsProperty
+	inEvent := false
+	nested := 0
+
+	for _, prop := range properties {
+		switch {
+		case prop.name == "BEGIN" && prop.value == "VEVENT" && !inEvent:
+			inEvent = true
+			current = current[:0]
+		case prop.name == "END" && prop.value == "VEVENT" && inEvent && nested == 0:
+			inEvent = false
+			occurrences, err := icsEvent(current, author)
+			if err != nil {
+				return cal, nil, err
+			}
+			events = append(events, occurrences...)
+		case inEvent && prop.name == "BEGIN":
+			nested++ // e.g. VALARM
+		case inEvent && prop.name == "END":
+			nested--
+		case inEvent && nested == 0:
+			current = append(current, prop)
+		case prop.name == "X-WR-CALNAME":
+			cal.Title = icsUnescape(prop.value)
+		case prop.name == "X-WR-CALDESC":
+			cal.Description = icsUnescape(prop.value)
+		}
+	}
+
+	for _, calev := range events {
+		cal.Events = append(cal.Events, calev.Pointer())
+	}
+	return cal, events, nil
+}
+
+func parseICS(data []byte) ([]icsProperty, error) {
+	// unfold lines
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0] != "BEGIN:VCALENDAR" {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+
+	properties := make([]icsProperty, 0, len(lines))
+	for _, line := range lines {
+		// find the colon that separates the value, skipping quoted parameter values
+		colon := -1
+		quoted := false
+		for i, c := range line {
+			if c == '"' {
+				quoted = !quoted
+			} else if c == ':' && !quoted {
+				colon = i
+				break
+			}
+		}
+		if colon == -1 {
+			return nil, fmt.Errorf("invalid line '%s'", line)
+		}
+
+		parts := strings.Split(line[0:colon], ";")
+		prop := icsProperty{name: strings.ToUpper(parts[0]), value: line[colon+1:]}
+		for _, param := range parts[1:] {
+			if k, v, ok := strings.Cut(param, "="); ok {
+				if prop.params == nil {
+					prop.params = make(map[string]string, len(parts)-1)
+				}
+				prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+			}
+		}
+		properties = append(properties, prop)
+	}
+	return properties, nil
+}
+
+func icsEvent(properties []icsProperty, author nostr.PubKey) ([]CalendarEvent, error) {
+	calev := CalendarEvent{CalendarEventKind: TimeBased, PubKey: author}
+	var rrule string
+	var duration time.Duration
+	var exdates []time.Time
+
+	for _, prop := range properties {
+		switch prop.name {
+		case "UID":
+			calev.Identifier = icsUnescape(prop.value)
+		case "SUMMARY":
+			calev.Title = icsUnescape(prop.value)
+		case "DESCRIPTION":
+			calev.Description = icsUnescape(prop.value)
+		case "LOCATION":
+			calev.Locations = append(calev.Locations, icsUnescape(prop.value))
+		case "CATEGORIES":
+			for _, category := range splitICSList(prop.value) {
+				calev.Hashtags = append(calev.Hashtags, icsUnescape(category))
+			}
+		case "URL":
+			calev.References = append(calev.References, prop.value)
+		case "IMAGE":
+			calev.Image = prop.value
+		case "DTSTART":
+			t, dateOnly, tzid, err := parseICSTime(prop)
+			if err != nil {
+				return nil, err
+			}
+			calev.Start = t
+			calev.StartTzid = tzid
+			if dateOnly {
+				calev.CalendarEventKind = DateBased
+			}
+		case "DTEND":
+			t, _, tzid, err := parseICSTime(prop)
+			if err != nil {
+				return nil, err
+			}
+			calev.End = t
+			calev.EndTzid = tzid
+		case "DURATION":
+			d, err := parseICSDuration(prop.value)
+			if err != nil {
+				return nil, err
+			}
+			duration = d
+		case "RRULE":
+			rrule = prop.value
+		case "EXDATE":
+			for _, value := range strings.Split(prop.value, ",") {
+				t, _, _, err := parseICSTime(icsProperty{params: prop.params, value: value})
+				if err == nil {
+					exdates = append(exdates, t)
+				}
+			}
+		}
+	}
+
+	if calev.Start.IsZero() {
+		return nil, fmt.Errorf("event '%s' has no start", calev.Identifier)
+	}
+	if calev.Identifier == "" {
+		return nil, fmt.Errorf("event '%s' has no UID", calev.Title)
+	}
+	if calev.End.IsZero() && duration > 0 {
+		calev.End = calev.Start.Add(duration)
+		calev.EndTzid = calev.StartTzid
+	}
+	if calev.EndTzid == calev.StartTzid {
+		calev.EndTzid = ""
+	}
+
+	if rrule == "" {
+		return []CalendarEvent{calev}, nil
+	}
+	starts, ok := expandRRule(calev.Start, rrule)
+	if !ok {
+		return []CalendarEvent{calev}, nil
+	}
+
+	occurrences := make([]CalendarEvent, 0, len(starts))
+	length := calev.End.Sub(calev.Start)
+	for _, start := range starts {
+		if slices.ContainsFunc(exdates, start.Equal) {
+			continue
+		}
+		occurrence := calev
+		occurrence.Start = start
+		if !calev.End.IsZero() {
+			occurrence.End = start.Add(length)
+		}
+		if calev.CalendarEventKind == DateBased {
+			occurrence.Identifier = calev.Identifier + "-" + start.Format(icsDateFormat)
+		} else {
+			occurrence.Identifier = calev.Identifier + "-" + start.UTC().Format(icsDateTimeFormat)
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+func splitICSList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == ',' {
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+func parseICSTime(prop icsProperty) (t time.Time, dateOnly bool, tzid string, err error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.Parse(icsDateFormat, value)
+		return t, true, "", err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(icsDateTimeFormat, value[0:len(value)-1])
+		return t, false, "", err
+	}
+
+	loc := time.UTC
+	if tzid = prop.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		} else {
+			tzid = ""
+		}
+	}
+	t, err = time.ParseInLocation(icsDateTimeFormat, value, loc)
+	return t, false, tzid, err
+}
+
+var icsDurationMatcher = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICSDuration(value string) (time.Duration, error) {
+	m := icsDurationMatcher.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] != "" {
+			n, _ := strconv.Atoi(m[i+2])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// expandRRule returns the start of each occurrence of a recurrence rule, or false if the rule isn't supported.
+func expandRRule(start time.Time, rrule string) ([]time.Time, bool) {
+	var freq string
+	interval := 1
+	count := 0
+	until := start.AddDate(5, 0, 0)
+	var byday []time.Weekday
+	wkst := time.Monday
+
+	for _, part := range strings.Split(rrule, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			freq = strings.ToUpper(v)
+		case "INTERVAL":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, false
+			}
+			interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, false
+			}
+			count = n
+		case "UNTIL":
+			t, _, _, err := parseICSTime(icsProperty{value: v})
+			if err != nil {
+				return nil, false
+			}
+			if len(v) == 8 {
+				t = t.AddDate(0, 0, 1).Add(-time.Second) // the whole day is included
+			}
+			until = t
+		case "BYDAY":
+			for _, day := range strings.Split(v, ",") {
+				wd, ok := icsWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, false // ordinal days like "1MO" are not supported
+				}
+				byday = append(byday, wd)
+			}
+		case "WKST":
+			wd, ok := icsWeekdays[strings.ToUpper(v)]
+			if !ok {
+				return nil, false
+			}
+			wkst = wd
+		default:
+			return nil, false
+		}
+	}
+	if len(byday) > 0 && freq != "WEEKLY" {
+		return nil, false
+	}
+
+	limit := maxRecurrences
+	if count > 0 && count < limit {
+		limit = count
+	}
+
+	var starts []time.Time
+	add := func(t time.Time) bool {
+		if t.After(until) || len(starts) >= limit {
+			return false
+		}
+		starts = append(starts, t)
+		return true
+	}
+
+	if freq == "WEEKLY" && len(byday) > 0 {
+		// the start is always the first instance, then each period is a whole week beginning at WKST
+		if !add(start) {
+			return starts, true
+		}
+		weekStart := start.AddDate(0, 0, -int((start.Weekday()-wkst+7)%7))
+		for i := 0; ; i++ {
+			week := weekStart.AddDate(0, 0, 7*i*interval)
+			for d := 0; d < 7; d++ {
+				day := week.AddDate(0, 0, d)
+				if !day.After(start) || !slices.Contains(byday, day.Weekday()) {
+					continue
+				}
+				if !add(day) {
+					return starts, true
+				}
+			}
+		}
+	}
+
+	for i := 0; ; i++ {
+		var t time.Time
+		switch freq {
+		case "DAILY":
+			t = start.AddDate(0, 0, i*interval)
+		case "WEEKLY":
+			t = start.AddDate(0, 0, 7*i*interval)
+		case "MONTHLY":
+			t = start.AddDate(0, i*interval, 0)
+			if t.Day() != start.Day() {
+				continue // e.g. the 31st in a shorter month doesn't happen
+			}
+		case "YEARLY":
+			t = start.AddDate(i*interval, 0, 0)
+			if t.Day() != start.Day() {
+				continue
+			}
+		default:
+			return nil, false
+		}
+
+		if !add(t) {
+			return starts, true
+		}
+	}
+}
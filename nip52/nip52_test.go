@@ -0,0 +1,191 @@
+package nip52
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSVPAggregation(t *testing.T) {
+	organizer := nostr.Generate().Public()
+	alice := nostr.Generate().Public()
+	bob := nostr.Generate().Public()
+	calev := CalendarEvent{CalendarEventKind: TimeBased, PubKey: organizer, Identifier: "meetup"}
+
+	rsvp := func(author nostr.PubKey, status RSVPStatus, at nostr.Timestamp) nostr.Event {
+		evt := RSVP{Event: calev.Pointer(), Status: status, FreeBusy: "busy"}.ToEvent()
+		evt.PubKey = author
+		evt.CreatedAt = at
+		return evt
+	}
+
+	declined := rsvp(bob, Declined, 30)
+	require.Empty(t, declined.Tags.Find("fb"))
+	parsed, err := ParseRSVP(declined)
+	require.NoError(t, err)
+	require.Equal(t, Declined, parsed.Status)
+	require.Equal(t, calev.Pointer().AsTagReference(), parsed.Event.AsTagReference())
+
+	summary := AggregateRSVPs(calev.Pointer(), []nostr.Event{
+		rsvp(alice, Tentative, 10),
+		rsvp(alice, Accepted, 20),
+		rsvp(bob, Accepted, 10),
+		declined,
+	})
+	require.Equal(t, []nostr.PubKey{alice}, summary.Accepted)
+	require.Equal(t, []nostr.PubKey{bob}, summary.Declined)
+	require.Empty(t, summary.Tentative)
+
+	_, err = ParseRSVP(nostr.Event{Kind: nostr.KindCalendarEventRSVP, Tags: nostr.Tags{{"status", "maybe"}}})
+	require.Error(t, err)
+}
+
+func TestEventsInRange(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	events := []CalendarEvent{
+		{CalendarEventKind: TimeBased, Identifier: "late", Start: day(3).Add(10 * time.Hour), End: day(3).Add(12 * time.Hour)},
+		{CalendarEventKind: DateBased, Identifier: "allday", Start: day(2)},
+		{CalendarEventKind: DateBased, Identifier: "long", Start: day(1), End: day(10)},
+		{CalendarEventKind: TimeBased, Identifier: "before", Start: day(1), End: day(2)},
+	}
+
+	found := EventsInRange(events, day(2), day(4))
+	ids := make([]string, len(found))
+	for i, calev := range found {
+		ids[i] = calev.Identifier
+	}
+	require.Equal(t, []string{"long", "allday", "late"}, ids)
+}
+
+func TestCalendarRoundtrip(t *testing.T) {
+	pk := nostr.Generate().Public()
+	calev := CalendarEvent{CalendarEventKind: DateBased, PubKey: pk, Identifier: "conf", Title: "Conference", Image: "https://example.com/conf.png", Start: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)}
+	cal := Calendar{PubKey: pk, Identifier: "work", Title: "Work", Description: "things", Events: []nostr.EntityPointer{calev.Pointer()}}
+
+	evt := cal.ToEvent()
+	evt.PubKey = pk
+	require.Equal(t, cal, ParseCalendar(evt))
+
+	evt = calev.ToEvent()
+	evt.PubKey = pk
+	require.True(t, cal.Contains(evt))
+	parsed := ParseCalendarEvent(evt)
+	require.Equal(t, calev.Start, parsed.Start)
+	require.Equal(t, calev.Title, parsed.Title)
+	require.Equal(t, calev.Image, parsed.Image)
+}
+
+func TestICSRoundtrip(t *testing.T) {
+	pk := nostr.Generate().Public()
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	require.NoError(t, err)
+
+	events := []CalendarEvent{
+		{
+			CalendarEventKind: TimeBased,
+			PubKey:            pk,
+			Identifier:        "standup",
+			Title:             "Standup; daily, short",
+			Description:       "first line\nsecond line with a long text that certainly needs to be folded somewhere in the middle of it",
+			Start:             time.Date(2024, 3, 30, 9, 30, 0, 0, lisbon),
+			End:               time.Date(2024, 3, 30, 10, 0, 0, 0, lisbon),
+			StartTzid:         "Europe/Lisbon",
+			Locations:         []string{"office"},
+			Hashtags:          []string{"work", "daily"},
+			Image:             "https://example.com/standup.png",
+		},
+		{
+			CalendarEventKind: DateBased,
+			PubKey:            pk,
+			Identifier:        "holiday",
+			Title:             "Holiday",
+			Start:             time.Date(2024, 4, 25, 0, 0, 0, 0, time.UTC),
+			End:               time.Date(2024, 4, 26, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	data := ExportICS(Calendar{Title: "Things"}, events)
+	require.Contains(t, string(data), "DTSTART;TZID=Europe/Lisbon:20240330T093000\r\n")
+	for _, line := range strings.Split(string(data), "\r\n") {
+		require.LessOrEqual(t, len(line), 75)
+	}
+
+	cal, imported, err := ImportICS(data, pk)
+	require.NoError(t, err)
+	require.Equal(t, "Things", cal.Title)
+	require.Len(t, cal.Events, 2)
+	require.Len(t, imported, 2)
+	for i := range events {
+		require.Equal(t, events[i].Identifier, imported[i].Identifier)
+		require.Equal(t, events[i].CalendarEventKind, imported[i].CalendarEventKind)
+		require.Equal(t, events[i].Title, imported[i].Title)
+		require.Equal(t, events[i].Description, imported[i].Description)
+		require.Equal(t, events[i].StartTzid, imported[i].StartTzid)
+		require.Equal(t, events[i].Hashtags, imported[i].Hashtags)
+		require.Equal(t, events[i].Image, imported[i].Image)
+		require.True(t, events[i].Start.Equal(imported[i].Start))
+		require.True(t, events[i].End.Equal(imported[i].End))
+	}
+}
+
+func TestICSRecurrence(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:class",
+		"SUMMARY:Class",
+		"DTSTART;TZID=America/New_York:20240304T180000",
+		"DURATION:PT1H30M",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=5",
+		"EXDATE;TZID=America/New_York:20240311T180000",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	_, events, err := ImportICS([]byte(data), nostr.Generate().Public())
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	days := make([]string, len(events))
+	for i, calev := range events {
+		days[i] = calev.Start.Format("Mon 02 15:04")
+		require.Equal(t, 90*time.Minute, calev.End.Sub(calev.Start))
+		require.Equal(t, "America/New_York", calev.StartTzid)
+	}
+	require.Equal(t, []string{"Mon 04 18:00", "Wed 06 18:00", "Wed 13 18:00", "Mon 18 18:00"}, days)
+	require.Equal(t, "class-20240304T230000", events[0].Identifier)
+
+	in := EventsInRange(events, time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC))
+	require.Len(t, in, 1)
+}
+
+func TestExpandRRuleWeeklyInterval(t *testing.T) {
+	start := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC) // a wednesday
+
+	days := func(rrule string) []string {
+		starts, ok := expandRRule(start, rrule)
+		require.True(t, ok)
+		res := make([]string, len(starts))
+		for i, s := range starts {
+			res[i] = s.Format("Mon 01-02")
+		}
+		return res
+	}
+
+	// every other week counting from the week that contains the start, with the start itself first
+	require.Equal(t,
+		[]string{"Wed 01-03", "Fri 01-05", "Mon 01-15", "Fri 01-19", "Mon 01-29"},
+		days("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=5"))
+
+	// weeks starting on sunday put the start in the same week as the 31st of december
+	require.Equal(t,
+		[]string{"Wed 01-03", "Fri 01-05", "Sun 01-14", "Fri 01-19"},
+		days("FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,FR;WKST=SU;COUNT=4"))
+}
@@ -0,0 +1,154 @@
+package nip52
+
+import (
+	"fmt"
+
+	"fiatjaf.com/nostr"
+)
+
+// RSVPStatus is the response of a user to a calendar event.
+type RSVPStatus string
+
+const (
+	Accepted  RSVPStatus = "accepted"
+	Declined  RSVPStatus = "declined"
+	Tentative RSVPStatus = "tentative"
+)
+
+// RSVP is a kind 31925 response to a calendar event.
+type RSVP struct {
+	PubKey     nostr.PubKey
+	CreatedAt  nostr.Timestamp
+	Identifier string
+
+	// Event is the calendar event this is a response to.
+	Event nostr.EntityPointer
+
+	// EventID optionally points to the specific version of the calendar event.
+	EventID *nostr.EventPointer
+
+	Status RSVPStatus
+
+	// FreeBusy is "free" or "busy", telling if the user would be busy during the event. It is not used
+	// when declining.
+	FreeBusy string
+
+	Note string
+}
+
+// ParseRSVP reads a kind 31925 event.
+func ParseRSVP(event nostr.Event) (RSVP, error) {
+	if event.Kind != nostr.KindCalendarEventRSVP {
+		return RSVP{}, fmt.Errorf("event is kind %d, not %d", event.Kind, nostr.KindCalendarEventRSVP)
+	}
+
+	rsvp := RSVP{PubKey: event.PubKey, CreatedAt: event.CreatedAt, Note: event.Content}
+	var hasAddress bool
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			rsvp.Identifier = tag[1]
+		case "a":
+			ptr, err := nostr.EntityPointerFromTag(tag)
+			if err != nil || (ptr.Kind != DateBased && ptr.Kind != TimeBased) {
+				return RSVP{}, fmt.Errorf("invalid calendar event address '%s'", tag[1])
+			}
+			rsvp.Event = ptr
+			hasAddress = true
+		case "e":
+			if ptr, err := nostr.EventPointerFromTag(tag); err == nil {
+				rsvp.EventID = &ptr
+			}
+		case "status":
+			switch RSVPStatus(tag[1]) {
+			case Accepted, Declined, Tentative:
+				rsvp.Status = RSVPStatus(tag[1])
+			default:
+				return RSVP{}, fmt.Errorf("invalid status '%s'", tag[1])
+			}
+		case "fb":
+			rsvp.FreeBusy = tag[1]
+		}
+	}
+
+	if !hasAddress {
+		return RSVP{}, fmt.Errorf("missing calendar event address")
+	}
+	if rsvp.Status == "" {
+		return RSVP{}, fmt.Errorf("missing status")
+	}
+	return rsvp, nil
+}
+
+// ToEvent returns an unsigned kind 31925 event. If Identifier is empty the calendar event address is
+// used, so new responses from the same user to the same event replace the previous.
+func (rsvp RSVP) ToEvent() nostr.Event {
+	d := rsvp.Identifier
+	if d == "" {
+		d = rsvp.Event.AsTagReference()
+	}
+
+	tags := nostr.Tags{
+		{"d", d},
+		rsvp.Event.AsTag(),
+	}
+	if rsvp.EventID != nil {
+		tags = append(tags, rsvp.EventID.AsTag())
+	}
+	tags = append(tags, nostr.Tag{"status", string(rsvp.Status)})
+	if rsvp.FreeBusy != "" && rsvp.Status != Declined {
+		tags = append(tags, nostr.Tag{"fb", rsvp.FreeBusy})
+	}
+	tags = append(tags, nostr.Tag{"p", rsvp.Event.PublicKey.Hex()})
+
+	return nostr.Event{
+		Kind:      nostr.KindCalendarEventRSVP,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   rsvp.Note,
+	}
+}
+
+// RSVPSummary holds the users that responded to a calendar event, grouped by status.
+type RSVPSummary struct {
+	Accepted  []nostr.PubKey
+	Declined  []nostr.PubKey
+	Tentative []nostr.PubKey
+}
+
+// AggregateRSVPs takes RSVP events and groups the users that responded to the given calendar event by
+// status, considering only the newest response from each user.
+func AggregateRSVPs(calev nostr.EntityPointer, events []nostr.Event) RSVPSummary {
+	latest := make(map[nostr.PubKey]RSVP, len(events))
+	order := make([]nostr.PubKey, 0, len(events))
+	for _, evt := range events {
+		rsvp, err := ParseRSVP(evt)
+		if err != nil || rsvp.Event.AsTagReference() != calev.AsTagReference() {
+			continue
+		}
+		if prev, ok := latest[rsvp.PubKey]; ok {
+			if prev.CreatedAt < rsvp.CreatedAt {
+				latest[rsvp.PubKey] = rsvp
+			}
+			continue
+		}
+		latest[rsvp.PubKey] = rsvp
+		order = append(order, rsvp.PubKey)
+	}
+
+	var summary RSVPSummary
+	for _, pubkey := range order {
+		switch latest[pubkey].Status {
+		case Accepted:
+			summary.Accepted = append(summary.Accepted, pubkey)
+		case Declined:
+			summary.Declined = append(summary.Declined, pubkey)
+		case Tentative:
+			summary.Tentative = append(summary.Tentative, pubkey)
+		}
+	}
+	return summary
+}
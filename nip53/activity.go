@@ -0,0 +1,458 @@
+package nip53
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip57"
+)
+
+// StaleAfter is how long a live event can go without updates before it is considered ended even if its
+// status still says otherwise.
+var StaleAfter = time.Hour
+
+// how many zap receipts for participants are held while the activity event itself hasn't arrived
+const maxPendingZaps = 500
+
+// Zap is a zap receipt for a live activity.
+type Zap struct {
+	Receipt nostr.Event
+	Sender  nostr.PubKey
+	Amount  uint64 // in millisatoshis, from the invoice
+	Comment string
+}
+
+// Member is someone in the roster of a live activity, either listed in it with a role or seen chatting
+// or announcing their presence.
+type Member struct {
+	PubKey     nostr.PubKey
+	Role       string
+	LastSeen   nostr.Timestamp
+	HandRaised bool
+}
+
+type TrackOptions struct {
+	// ChatLimit is the maximum number of chat messages kept, older ones are dropped. Defaults to 500.
+	ChatLimit int
+
+	// OnUpdate is called whenever a newer version of the activity event arrives.
+	OnUpdate func(snapshot nostr.Event)
+
+	// OnStatusChange is called when a newer version of the activity event has a different status, e.g.
+	// from "planned" to "live".
+	OnStatusChange func(previous, current string)
+
+	// Zappers are the pubkeys allowed to sign zap receipts, usually the nostrPubkey from the LNURL
+	// endpoint of the host.
+	Zappers []nostr.PubKey
+
+	// ZapProvider returns the pubkey allowed to sign zap receipts for the given recipient, i.e. the
+	// nostrPubkey from their LNURL endpoint, or nostr.ZeroPK if they don't have one. It can be
+	// sdk.System.FetchZapProvider. It is only asked about the host and the participants listed in the
+	// activity. Receipts not signed by one of Zappers or by the recipient's zap provider are ignored,
+	// so if neither is set no zaps are tracked.
+	ZapProvider func(ctx context.Context, recipient nostr.PubKey) nostr.PubKey
+
+	OnChatMessage func(ChatMessage)
+	OnZap         func(Zap)
+	OnPresence    func(Presence)
+}
+
+// Activity keeps the state of a live event (kind 30311), meeting space (kind 30312) or meeting room
+// (kind 30313) up to date as events arrive from relays. All the callbacks are called from the same
+// goroutine, in order.
+type Activity struct {
+	Address nostr.EntityPointer
+
+	ctx  context.Context
+	opts TrackOptions
+	done chan struct{}
+
+	mu       sync.Mutex
+	snapshot nostr.Event
+	chat     []ChatMessage
+	seen     map[nostr.ID]struct{}
+	zaps     []Zap
+	zapTotal uint64
+	members  map[nostr.PubKey]*Member
+
+	// receipts for people that may be participants, waiting for the first snapshot to tell
+	pendingZaps []nostr.Event
+}
+
+// Track subscribes to the activity at the given address along with its chat messages, zaps and
+// presence announcements. If no relays are given the ones in the address are used. Tracking stops
+// when the context is canceled.
+func Track(
+	ctx context.Context,
+	pool *nostr.Pool,
+	relays []string,
+	address nostr.EntityPointer,
+	opts TrackOptions,
+) *Activity {
+	a := newActivity(ctx, address, opts)
+	if len(relays) == 0 {
+		relays = address.Relays
+	}
+
+	snapshots := pool.SubscribeMany(ctx, relays, address.AsFilter(), nostr.SubscriptionOptions{Label: "live-activity"})
+	related := pool.SubscribeMany(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{nostr.KindLiveChatMessage, nostr.KindZap, nostr.KindRoomPresence},
+		Tags:  nostr.TagMap{"a": []string{address.AsTagReference()}},
+	}, nostr.SubscriptionOptions{Label: "live-activity"})
+
+	go func() {
+		defer close(a.done)
+		for snapshots != nil || related != nil {
+			select {
+			case ie, ok := <-snapshots:
+				if !ok {
+					snapshots = nil
+					continue
+				}
+				a.handle(ie.Event)
+			case ie, ok := <-related:
+				if !ok {
+					related = nil
+					continue
+				}
+				a.handle(ie.Event)
+			}
+		}
+	}()
+
+	return a
+}
+
+func newActivity(ctx context.Context, address nostr.EntityPointer, opts TrackOptions) *Activity {
+	if opts.ChatLimit == 0 {
+		opts.ChatLimit = 500
+	}
+	return &Activity{
+		Address: address,
+		ctx:     ctx,
+		opts:    opts,
+		done:    make(chan struct{}),
+		seen:    make(map[nostr.ID]struct{}),
+		members: make(map[nostr.PubKey]*Member),
+	}
+}
+
+// Done is closed when tracking stops.
+func (a *Activity) Done() <-chan struct{} { return a.done }
+
+func (a *Activity) handle(evt nostr.Event) {
+	var callback func()
+	var pending []nostr.Event
+
+	// this may hit the network, so do it before locking
+	if evt.Kind == nostr.KindZap && !a.isZapperAllowed(evt) {
+		return
+	}
+
+	a.mu.Lock()
+	switch evt.Kind {
+	case a.Address.Kind:
+		callback = a.handleSnapshot(evt)
+		if callback != nil {
+			pending = a.pendingZaps
+			a.pendingZaps = nil
+		}
+	case nostr.KindLiveChatMessage:
+		callback = a.handleChatMessage(evt)
+	case nostr.KindZap:
+		callback = a.handleZap(evt)
+	case nostr.KindRoomPresence:
+		callback = a.handlePresence(evt)
+	}
+	a.mu.Unlock()
+
+	if callback != nil {
+		callback()
+	}
+
+	// now that we know the participants
+	for _, receipt := range pending {
+		a.handle(receipt)
+	}
+}
+
+func (a *Activity) handleSnapshot(evt nostr.Event) func() {
+	if !a.Address.MatchesEvent(evt) || evt.CreatedAt <= a.snapshot.CreatedAt {
+		return nil
+	}
+
+	previous := a.status()
+	a.snapshot = evt
+	current := a.status()
+
+	// roles come only from the newest version
+	for _, member := range a.members {
+		member.Role = ""
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "p" {
+			continue
+		}
+		if part, ok := parseParticipant(tag); ok {
+			a.member(part.PubKey).Role = part.Role
+		}
+	}
+
+	return func() {
+		if a.opts.OnUpdate != nil {
+			a.opts.OnUpdate(evt)
+		}
+		if previous != current && a.opts.OnStatusChange != nil {
+			a.opts.OnStatusChange(previous, current)
+		}
+	}
+}
+
+func (a *Activity) handleChatMessage(evt nostr.Event) func() {
+	msg, err := ParseChatMessage(evt)
+	if err != nil || msg.Activity.AsTagReference() != a.Address.AsTagReference() || a.isDuplicate(evt.ID) {
+		return nil
+	}
+
+	// history may arrive out of order
+	idx, _ := slices.BinarySearchFunc(a.chat, evt.CreatedAt, func(m ChatMessage, ts nostr.Timestamp) int {
+		return cmp.Compare(m.Event.CreatedAt, ts+1)
+	})
+	a.chat = slices.Insert(a.chat, idx, msg)
+	if len(a.chat) > a.opts.ChatLimit {
+		a.chat = slices.Delete(a.chat, 0, len(a.chat)-a.opts.ChatLimit)
+	}
+
+	a.seenAt(evt.PubKey, evt.CreatedAt)
+
+	if a.opts.OnChatMessage == nil {
+		return nil
+	}
+	return func() { a.opts.OnChatMessage(msg) }
+}
+
+func (a *Activity) handleZap(evt nostr.Event) func() {
+	if !a.references(evt) || a.isDuplicate(evt.ID) {
+		return nil
+	}
+
+	// the amount must come from the invoice, the "amount" tags are just what the sender asked for
+	bolt11 := evt.Tags.Find("bolt11")
+	if bolt11 == nil {
+		return nil
+	}
+	amount, err := nip57.GetAmountFromBolt11(bolt11[1])
+	if err != nil || amount == 0 {
+		return nil
+	}
+
+	zap := Zap{Receipt: evt, Amount: amount}
+	if tag := evt.Tags.Find("P"); tag != nil {
+		zap.Sender, _ = nostr.PubKeyFromHex(tag[1])
+	}
+	if tag := evt.Tags.Find("description"); tag != nil {
+		var request nostr.Event
+		if err := json.Unmarshal([]byte(tag[1]), &request); err == nil {
+			if tag := request.Tags.Find("amount"); tag != nil && tag[1] != strconv.FormatUint(amount, 10) {
+				return nil
+			}
+			zap.Sender = request.PubKey
+			zap.Comment = request.Content
+		}
+	}
+
+	a.zaps = append(a.zaps, zap)
+	a.zapTotal += zap.Amount
+
+	if a.opts.OnZap == nil {
+		return nil
+	}
+	return func() { a.opts.OnZap(zap) }
+}
+
+func (a *Activity) handlePresence(evt nostr.Event) func() {
+	presence, err := ParsePresence(evt)
+	if err != nil || presence.Room.AsTagReference() != a.Address.AsTagReference() {
+		return nil
+	}
+
+	member := a.member(presence.PubKey)
+	if presence.CreatedAt < member.LastSeen {
+		return nil
+	}
+	member.LastSeen = presence.CreatedAt
+	member.HandRaised = presence.HandRaised
+
+	if a.opts.OnPresence == nil {
+		return nil
+	}
+	return func() { a.opts.OnPresence(presence) }
+}
+
+func (a *Activity) isZapperAllowed(receipt nostr.Event) bool {
+	if slices.Contains(a.opts.Zappers, receipt.PubKey) {
+		return true
+	}
+	if a.opts.ZapProvider == nil {
+		return false
+	}
+	tag := receipt.Tags.Find("p")
+	if tag == nil {
+		return false
+	}
+	recipient, err := nostr.PubKeyFromHex(tag[1])
+	if err != nil || !a.isZapRecipient(recipient, receipt) {
+		return false
+	}
+	provider := a.opts.ZapProvider(a.ctx, recipient)
+	return provider != nostr.ZeroPK && provider == receipt.PubKey
+}
+
+// isZapRecipient tells if pubkey is the host or one of the participants of the current snapshot, as
+// otherwise anyone could tag the activity in receipts for themselves, signed by their own provider.
+// Before any snapshot arrives we can't know, so the receipt is kept to be checked again later.
+func (a *Activity) isZapRecipient(pubkey nostr.PubKey, receipt nostr.Event) bool {
+	if pubkey == a.Address.PublicKey {
+		return true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.snapshot.Kind == 0 {
+		if len(a.pendingZaps) < maxPendingZaps {
+			a.pendingZaps = append(a.pendingZaps, receipt)
+		}
+		return false
+	}
+	hex := pubkey.Hex()
+	for _, tag := range a.snapshot.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && tag[1] == hex {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Activity) references(evt nostr.Event) bool {
+	ref := a.Address.AsTagReference()
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "a" && tag[1] == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Activity) isDuplicate(id nostr.ID) bool {
+	if _, ok := a.seen[id]; ok {
+		return true
+	}
+	a.seen[id] = struct{}{}
+	return false
+}
+
+func (a *Activity) member(pubkey nostr.PubKey) *Member {
+	member, ok := a.members[pubkey]
+	if !ok {
+		member = &Member{PubKey: pubkey}
+		a.members[pubkey] = member
+	}
+	return member
+}
+
+func (a *Activity) seenAt(pubkey nostr.PubKey, ts nostr.Timestamp) {
+	if member := a.member(pubkey); member.LastSeen < ts {
+		member.LastSeen = ts
+	}
+}
+
+func (a *Activity) status() string {
+	if a.snapshot.Kind == 0 {
+		return ""
+	}
+	status := a.snapshot.Tags.Find("status")
+	if status == nil {
+		return ""
+	}
+	if status[1] == StatusLive && a.snapshot.Kind != nostr.KindMeetingSpace &&
+		time.Since(a.snapshot.CreatedAt.Time()) > StaleAfter {
+		return StatusEnded
+	}
+	return status[1]
+}
+
+// Status returns the status of the activity: "planned", "live" or "ended" for live events and meeting
+// rooms, "open", "private" or "closed" for meeting spaces. A live event that hasn't been updated in
+// StaleAfter is reported as ended. It is empty if the activity event hasn't been seen yet.
+func (a *Activity) Status() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.status()
+}
+
+// Snapshot returns the newest version of the activity event seen so far, which can be read with
+// ParseLiveEvent, ParseMeetingSpace or ParseMeetingRoom. It is false if none was seen yet.
+func (a *Activity) Snapshot() (nostr.Event, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.snapshot, a.snapshot.Kind != 0
+}
+
+// Chat returns the chat messages seen so far, oldest first.
+func (a *Activity) Chat() []ChatMessage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.chat)
+}
+
+// Zaps returns the zap receipts seen so far and their total amount in millisatoshis.
+func (a *Activity) Zaps() ([]Zap, uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.zaps), a.zapTotal
+}
+
+// Roster returns the members listed in the activity with a role followed by everybody else that was
+// seen, most recently active first.
+func (a *Activity) Roster() []Member {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	roster := make([]Member, 0, len(a.members))
+	for _, member := range a.members {
+		roster = append(roster, *member)
+	}
+	slices.SortFunc(roster, func(x, y Member) int {
+		if (x.Role == "") != (y.Role == "") {
+			if x.Role != "" {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(y.LastSeen, x.LastSeen); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.PubKey.Hex(), y.PubKey.Hex())
+	})
+	return roster
+}
+
+// Present returns the members that announced their presence or chatted within the given window.
+func (a *Activity) Present(window time.Duration) []Member {
+	since := nostr.Timestamp(time.Now().Add(-window).Unix())
+	roster := a.Roster()
+	present := roster[:0]
+	for _, member := range roster {
+		if member.LastSeen >= since {
+			present = append(present, member)
+		}
+	}
+	return present
+}
@@ -0,0 +1,79 @@
+package nip53
+
+import (
+	"fmt"
+
+	"fiatjaf.com/nostr"
+)
+
+// ChatMessage is a kind 1311 message sent to the chat of a live event or meeting space.
+type ChatMessage struct {
+	Event nostr.Event
+
+	// Activity is the live event or meeting space the message belongs to.
+	Activity nostr.EntityPointer
+
+	// ReplyTo is the message this one replies to, if any.
+	ReplyTo *nostr.EventPointer
+}
+
+// ParseChatMessage reads a kind 1311 event.
+func ParseChatMessage(event nostr.Event) (ChatMessage, error) {
+	if event.Kind != nostr.KindLiveChatMessage {
+		return ChatMessage{}, fmt.Errorf("event is kind %d, not %d", event.Kind, nostr.KindLiveChatMessage)
+	}
+
+	msg := ChatMessage{Event: event}
+	var hasActivity bool
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "a":
+			if hasActivity {
+				continue
+			}
+			ptr, err := nostr.EntityPointerFromTag(tag)
+			if err != nil || !isActivityKind(ptr.Kind) {
+				continue
+			}
+			msg.Activity = ptr
+			hasActivity = true
+		case "e":
+			if ptr, err := nostr.EventPointerFromTag(tag); err == nil {
+				msg.ReplyTo = &ptr
+			}
+		}
+	}
+
+	if !hasActivity {
+		return msg, fmt.Errorf("missing activity address")
+	}
+	return msg, nil
+}
+
+// NewChatMessage returns an unsigned kind 1311 event for the chat of the given live event or meeting
+// space, optionally replying to another message.
+func NewChatMessage(activity nostr.EntityPointer, content string, replyTo *nostr.EventPointer) nostr.Event {
+	relay := ""
+	if len(activity.Relays) > 0 {
+		relay = activity.Relays[0]
+	}
+
+	tags := nostr.Tags{{"a", activity.AsTagReference(), relay, "root"}}
+	if replyTo != nil {
+		tags = append(tags, replyTo.AsTag())
+	}
+
+	return nostr.Event{
+		Kind:      nostr.KindLiveChatMessage,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   content,
+	}
+}
+
+func isActivityKind(kind nostr.Kind) bool {
+	return kind == nostr.KindLiveEvent || kind == nostr.KindMeetingSpace || kind == nostr.KindMeetingRoom
+}
@@ -2,12 +2,23 @@ package nip53
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	"fiatjaf.com/nostr"
 )
 
+// Status values for live events and meeting rooms.
+const (
+	StatusPlanned = "planned"
+	StatusLive    = "live"
+	StatusEnded   = "ended"
+)
+
+// LiveEvent is a kind 30311 live streaming event.
 type LiveEvent struct {
+	PubKey              nostr.PubKey
+	CreatedAt           nostr.Timestamp
 	Identifier          string
 	Title               string
 	Summary             string
@@ -21,6 +32,7 @@ type LiveEvent struct {
 	Participants        []Participant
 	Hashtags            []string
 	Relays              []string
+	Pinned              []nostr.ID
 }
 
 type Participant struct {
@@ -30,7 +42,7 @@ type Participant struct {
 }
 
 func ParseLiveEvent(event nostr.Event) LiveEvent {
-	liev := LiveEvent{}
+	liev := LiveEvent{PubKey: event.PubKey, CreatedAt: event.CreatedAt}
 	for _, tag := range event.Tags {
 		if len(tag) < 2 {
 			continue
@@ -54,9 +66,9 @@ func ParseLiveEvent(event nostr.Event) LiveEvent {
 			}
 			v = time.Unix(i, 0)
 			switch tag[0] {
-			case "start":
+			case "starts":
 				liev.Starts = v
-			case "end":
+			case "ends":
 				liev.Ends = v
 			}
 		case "streaming":
@@ -64,20 +76,15 @@ func ParseLiveEvent(event nostr.Event) LiveEvent {
 		case "recording":
 			liev.Recording = append(liev.Recording, tag[1])
 		case "p":
-			if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
-				part := Participant{
-					PubKey: pk,
-				}
-				if len(tag) > 2 {
-					part.Relay = tag[2]
-					if len(tag) > 3 {
-						part.Role = tag[3]
-					}
-				}
+			if part, ok := parseParticipant(tag); ok {
 				liev.Participants = append(liev.Participants, part)
 			}
 		case "relays":
-			liev.Relays = append(liev.Relays, tag[1])
+			liev.Relays = append(liev.Relays, tag[1:]...)
+		case "pinned":
+			if id, err := nostr.IDFromHex(tag[1]); err == nil {
+				liev.Pinned = append(liev.Pinned, id)
+			}
 		case "t":
 			liev.Hashtags = append(liev.Hashtags, tag[1])
 		case "current_participants":
@@ -89,26 +96,70 @@ func ParseLiveEvent(event nostr.Event) LiveEvent {
 	return liev
 }
 
+func parseParticipant(tag nostr.Tag) (Participant, bool) {
+	pk, err := nostr.PubKeyFromHex(tag[1])
+	if err != nil {
+		return Participant{}, false
+	}
+	part := Participant{
+		PubKey: pk,
+	}
+	if len(tag) > 2 {
+		part.Relay = tag[2]
+		if len(tag) > 3 {
+			part.Role = tag[3]
+		}
+	}
+	return part, true
+}
+
 func (liev LiveEvent) GetHost() *Participant {
 	for _, part := range liev.Participants {
-		if part.Role == "host" {
+		if strings.EqualFold(part.Role, "host") {
 			return &part
 		}
 	}
 	return nil
 }
 
+// Pointer returns the address of the live event.
+func (liev LiveEvent) Pointer() nostr.EntityPointer {
+	return nostr.EntityPointer{
+		PublicKey:  liev.PubKey,
+		Kind:       nostr.KindLiveEvent,
+		Identifier: liev.Identifier,
+		Relays:     liev.Relays,
+	}
+}
+
+// ToEvent returns an unsigned kind 30311 event.
+func (liev LiveEvent) ToEvent() nostr.Event {
+	return nostr.Event{
+		Kind:      nostr.KindLiveEvent,
+		CreatedAt: nostr.Now(),
+		Tags:      liev.ToHashtags(),
+	}
+}
+
 func (liev LiveEvent) ToHashtags() nostr.Tags {
 	tags := make(nostr.Tags, 0, 26)
 	tags = append(tags, nostr.Tag{"d", liev.Identifier})
 	tags = append(tags, nostr.Tag{"title", liev.Title})
+	if liev.Summary != "" {
+		tags = append(tags, nostr.Tag{"summary", liev.Summary})
+	}
 	if liev.Image != "" {
-		tags = append(tags, nostr.Tag{"image", liev.Title})
+		tags = append(tags, nostr.Tag{"image", liev.Image})
+	}
+	if liev.Status != "" {
+		tags = append(tags, nostr.Tag{"status", liev.Status})
 	}
 
-	tags = append(tags, nostr.Tag{"start", strconv.FormatInt(liev.Starts.Unix(), 10)})
+	if !liev.Starts.IsZero() {
+		tags = append(tags, nostr.Tag{"starts", strconv.FormatInt(liev.Starts.Unix(), 10)})
+	}
 	if !liev.Ends.IsZero() {
-		tags = append(tags, nostr.Tag{"end", strconv.FormatInt(liev.Ends.Unix(), 10)})
+		tags = append(tags, nostr.Tag{"ends", strconv.FormatInt(liev.Ends.Unix(), 10)})
 	}
 
 	for _, url := range liev.Streaming {
@@ -129,8 +180,11 @@ func (liev LiveEvent) ToHashtags() nostr.Tags {
 	if liev.TotalParticipants != 0 {
 		tags = append(tags, nostr.Tag{"total_participants", strconv.Itoa(liev.TotalParticipants)})
 	}
-	for _, r := range liev.Relays {
-		tags = append(tags, nostr.Tag{"relay", r})
+	if len(liev.Relays) > 0 {
+		tags = append(tags, append(nostr.Tag{"relays"}, liev.Relays...))
+	}
+	for _, id := range liev.Pinned {
+		tags = append(tags, nostr.Tag{"pinned", id.Hex()})
 	}
 
 	return tags
@@ -0,0 +1,246 @@
+package nip53
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveEventRoundtrip(t *testing.T) {
+	host := nostr.Generate().Public()
+	liev := LiveEvent{
+		PubKey:       host,
+		Identifier:   "show",
+		Title:        "The Show",
+		Image:        "https://example.com/cover.png",
+		Status:       StatusPlanned,
+		Starts:       time.Unix(1700000000, 0),
+		Ends:         time.Unix(1700003600, 0),
+		Streaming:    []string{"https://example.com/stream.m3u8"},
+		Participants: []Participant{{PubKey: host, Role: "Host"}},
+		Relays:       []string{"wss://one.example.com", "wss://two.example.com"},
+	}
+
+	evt := liev.ToEvent()
+	evt.PubKey = host
+	parsed := ParseLiveEvent(evt)
+	parsed.CreatedAt = 0
+	require.Equal(t, liev, parsed)
+	require.Equal(t, host, parsed.GetHost().PubKey)
+	require.True(t, parsed.Pointer().MatchesEvent(evt))
+}
+
+// NIP-53 uses "starts", "ends" and a single "relays" tag, which this package used to get wrong (reading and
+// writing "start", "end" and one "relay" tag per url, and putting the title in the "image" tag).
+func TestLiveEventTags(t *testing.T) {
+	host := nostr.Generate().Public()
+	evt := nostr.Event{
+		Kind:   nostr.KindLiveEvent,
+		PubKey: host,
+		Tags: nostr.Tags{
+			{"d", "show"},
+			{"title", "The Show"},
+			{"image", "https://example.com/cover.png"},
+			{"starts", "1700000000"},
+			{"ends", "1700003600"},
+			{"relays", "wss://one.example.com", "wss://two.example.com"},
+			{"p", host.Hex(), "", "host"},
+		},
+	}
+
+	liev := ParseLiveEvent(evt)
+	require.Equal(t, "The Show", liev.Title)
+	require.Equal(t, "https://example.com/cover.png", liev.Image)
+	require.Equal(t, int64(1700000000), liev.Starts.Unix())
+	require.Equal(t, int64(1700003600), liev.Ends.Unix())
+	require.Equal(t, []string{"wss://one.example.com", "wss://two.example.com"}, liev.Relays)
+	require.Equal(t, host, liev.GetHost().PubKey)
+
+	tags := liev.ToHashtags()
+	for _, tag := range evt.Tags {
+		require.Contains(t, tags, tag)
+	}
+	for _, name := range []string{"start", "end", "relay"} {
+		require.Nil(t, tags.Find(name))
+	}
+}
+
+func TestMeetingRoomAndPresence(t *testing.T) {
+	pk := nostr.Generate().Public()
+	space := MeetingSpace{PubKey: pk, Identifier: "lobby", Room: "Lobby", Status: SpaceOpen, Service: "https://meet.example.com/lobby"}
+	evt := space.ToEvent()
+	evt.PubKey = pk
+	parsedSpace := ParseMeetingSpace(evt)
+	parsedSpace.CreatedAt = 0
+	require.Equal(t, space, parsedSpace)
+
+	room := MeetingRoom{PubKey: pk, Identifier: "weekly", Space: space.Pointer(), Title: "Weekly", Status: StatusLive, Starts: time.Unix(1700000000, 0)}
+	evt = room.ToEvent()
+	evt.PubKey = pk
+	parsedRoom := ParseMeetingRoom(evt)
+	require.Equal(t, space.Pointer().AsTagReference(), parsedRoom.Space.AsTagReference())
+	require.Equal(t, room.Starts, parsedRoom.Starts)
+
+	evt = Presence{Room: space.Pointer(), HandRaised: true}.ToEvent()
+	evt.PubKey = pk
+	presence, err := ParsePresence(evt)
+	require.NoError(t, err)
+	require.True(t, presence.HandRaised)
+	require.Equal(t, space.Pointer().AsTagReference(), presence.Room.AsTagReference())
+}
+
+func TestActivityTracking(t *testing.T) {
+	host := nostr.Generate().Public()
+	alice := nostr.Generate().Public()
+	bob := nostr.Generate().Public()
+	address := nostr.EntityPointer{PublicKey: host, Kind: nostr.KindLiveEvent, Identifier: "show"}
+	now := nostr.Now()
+
+	zapper := nostr.Generate().Public()
+	bobZapper := nostr.Generate().Public()
+	mallory := nostr.Generate().Public()
+	malloryZapper := nostr.Generate().Public()
+
+	var transitions [][2]string
+	var zapped uint64
+	a := newActivity(context.Background(), address, TrackOptions{
+		ChatLimit: 2,
+		ZapProvider: func(ctx context.Context, recipient nostr.PubKey) nostr.PubKey {
+			switch recipient {
+			case host:
+				return zapper
+			case bob:
+				return bobZapper
+			case mallory:
+				return malloryZapper
+			}
+			return nostr.ZeroPK
+		},
+		OnStatusChange: func(previous, current string) { transitions = append(transitions, [2]string{previous, current}) },
+		OnZap:          func(zap Zap) { zapped += zap.Amount },
+	})
+
+	snapshot := func(status string, at nostr.Timestamp) nostr.Event {
+		evt := LiveEvent{
+			Identifier:   "show",
+			Status:       status,
+			Participants: []Participant{{PubKey: host, Role: "Host"}, {PubKey: bob, Role: "Speaker"}},
+		}.ToEvent()
+		evt.PubKey = host
+		evt.CreatedAt = at
+		return evt
+	}
+	chat := func(author nostr.PubKey, content string, at nostr.Timestamp) nostr.Event {
+		evt := NewChatMessage(address, content, nil)
+		evt.PubKey = author
+		evt.CreatedAt = at
+		evt.ID = evt.GetID()
+		return evt
+	}
+
+	a.handle(snapshot(StatusLive, now-10))
+	a.handle(snapshot(StatusPlanned, now-100)) // older, ignored
+	a.handle(snapshot(StatusEnded, now))
+	require.Equal(t, [][2]string{{"", StatusLive}, {StatusLive, StatusEnded}}, transitions)
+	require.Equal(t, StatusEnded, a.Status())
+
+	a.handle(chat(alice, "second", now-5))
+	a.handle(chat(alice, "first", now-8))
+	a.handle(chat(bob, "third", now-2))
+	a.handle(chat(bob, "third", now-2)) // duplicate
+	messages := a.Chat()
+	require.Len(t, messages, 2)
+	require.Equal(t, "second", messages[0].Event.Content)
+	require.Equal(t, "third", messages[1].Event.Content)
+
+	zapReceipt := func(signer, recipient nostr.PubKey, bolt11 string, amount string) nostr.Event {
+		request := nostr.Event{Kind: nostr.KindZapRequest, PubKey: alice, Content: "great show", Tags: nostr.Tags{{"amount", amount}, address.AsTag()}}
+		receipt := nostr.Event{
+			Kind:      nostr.KindZap,
+			PubKey:    signer,
+			CreatedAt: now,
+			Tags:      nostr.Tags{address.AsTag(), {"p", recipient.Hex()}, {"bolt11", bolt11}, {"amount", amount}, {"description", request.String()}},
+		}
+		receipt.ID = receipt.GetID()
+		return receipt
+	}
+	const invoice = "lnbc210n1pjq8a6ypp5x8xgfz8nsnzm43f0f3rjn3vclnsfmv7kx3m6p4x9h2ugjdq5fj0s"
+	a.handle(zapReceipt(alice, host, invoice, "21000"))                     // not signed by the host's zapper
+	a.handle(zapReceipt(zapper, host, invoice, "2100000"))                  // amount doesn't match the invoice
+	a.handle(zapReceipt(zapper, host, "lnbc1pjq8a6ypp5x8xgfz8nsnzm4", "0")) // no amount in the invoice
+	a.handle(zapReceipt(malloryZapper, mallory, invoice, "21000"))          // mallory isn't in the activity
+	receipt := zapReceipt(zapper, host, invoice, "21000")
+	a.handle(receipt)
+	a.handle(receipt)
+	a.handle(zapReceipt(bobZapper, bob, invoice, "21000")) // participants can be zapped too
+	zaps, total := a.Zaps()
+	require.Len(t, zaps, 2)
+	require.Equal(t, uint64(42000), total)
+	require.Equal(t, total, zapped)
+	require.Equal(t, alice, zaps[0].Sender)
+	require.Equal(t, "great show", zaps[0].Comment)
+
+	roster := a.Roster()
+	require.Len(t, roster, 3)
+	require.Equal(t, bob, roster[0].PubKey) // has a role and chatted most recently
+	require.Equal(t, "Speaker", roster[0].Role)
+	require.Equal(t, host, roster[1].PubKey)
+	require.Equal(t, alice, roster[2].PubKey)
+	require.Empty(t, roster[2].Role)
+
+	require.Len(t, a.Present(time.Minute), 2)
+}
+
+func TestActivityZapsBeforeSnapshot(t *testing.T) {
+	host := nostr.Generate().Public()
+	bob := nostr.Generate().Public()
+	bobZapper := nostr.Generate().Public()
+	mallory := nostr.Generate().Public()
+	malloryZapper := nostr.Generate().Public()
+	address := nostr.EntityPointer{PublicKey: host, Kind: nostr.KindLiveEvent, Identifier: "show"}
+
+	a := newActivity(context.Background(), address, TrackOptions{
+		ZapProvider: func(ctx context.Context, recipient nostr.PubKey) nostr.PubKey {
+			switch recipient {
+			case bob:
+				return bobZapper
+			case mallory:
+				return malloryZapper
+			}
+			return nostr.ZeroPK
+		},
+	})
+
+	const invoice = "lnbc210n1pjq8a6ypp5x8xgfz8nsnzm43f0f3rjn3vclnsfmv7kx3m6p4x9h2ugjdq5fj0s"
+	for _, zap := range [][2]nostr.PubKey{{bobZapper, bob}, {malloryZapper, mallory}} {
+		receipt := nostr.Event{
+			Kind:      nostr.KindZap,
+			PubKey:    zap[0],
+			CreatedAt: nostr.Now(),
+			Tags:      nostr.Tags{address.AsTag(), {"p", zap[1].Hex()}, {"bolt11", invoice}},
+		}
+		receipt.ID = receipt.GetID()
+		a.handle(receipt)
+	}
+
+	// we don't know who the participants are yet
+	zaps, _ := a.Zaps()
+	require.Empty(t, zaps)
+
+	snapshot := LiveEvent{
+		Identifier:   "show",
+		Status:       StatusLive,
+		Participants: []Participant{{PubKey: bob, Role: "Speaker"}},
+	}.ToEvent()
+	snapshot.PubKey = host
+	snapshot.CreatedAt = nostr.Now()
+	a.handle(snapshot)
+
+	zaps, total := a.Zaps()
+	require.Len(t, zaps, 1)
+	require.Equal(t, bobZapper, zaps[0].Receipt.PubKey)
+	require.Equal(t, uint64(21000), total)
+}
@@ -0,0 +1,66 @@
+package nip53
+
+import (
+	"fmt"
+
+	"fiatjaf.com/nostr"
+)
+
+// Presence is a kind 10312 event announcing a user is in a meeting space (or live event). Since it is
+// replaceable, a user can only be present in one place at a time.
+type Presence struct {
+	PubKey     nostr.PubKey
+	CreatedAt  nostr.Timestamp
+	Room       nostr.EntityPointer
+	HandRaised bool
+}
+
+// ParsePresence reads a kind 10312 event.
+func ParsePresence(event nostr.Event) (Presence, error) {
+	if event.Kind != nostr.KindRoomPresence {
+		return Presence{}, fmt.Errorf("event is kind %d, not %d", event.Kind, nostr.KindRoomPresence)
+	}
+
+	presence := Presence{PubKey: event.PubKey, CreatedAt: event.CreatedAt}
+	var hasRoom bool
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "a":
+			ptr, err := nostr.EntityPointerFromTag(tag)
+			if err != nil || !isActivityKind(ptr.Kind) {
+				continue
+			}
+			presence.Room = ptr
+			hasRoom = true
+		case "hand":
+			presence.HandRaised = tag[1] == "1"
+		}
+	}
+
+	if !hasRoom {
+		return presence, fmt.Errorf("missing room address")
+	}
+	return presence, nil
+}
+
+// ToEvent returns an unsigned kind 10312 event.
+func (presence Presence) ToEvent() nostr.Event {
+	relay := ""
+	if len(presence.Room.Relays) > 0 {
+		relay = presence.Room.Relays[0]
+	}
+
+	tags := nostr.Tags{{"a", presence.Room.AsTagReference(), relay, "root"}}
+	if presence.HandRaised {
+		tags = append(tags, nostr.Tag{"hand", "1"})
+	}
+
+	return nostr.Event{
+		Kind:      nostr.KindRoomPresence,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+	}
+}
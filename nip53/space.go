@@ -0,0 +1,216 @@
+package nip53
+
+import (
+	"strconv"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+// Status values for meeting spaces.
+const (
+	SpaceOpen    = "open"
+	SpacePrivate = "private"
+	SpaceClosed  = "closed"
+)
+
+// MeetingSpace is a kind 30312 interactive room, a persistent place where meetings happen.
+type MeetingSpace struct {
+	PubKey       nostr.PubKey
+	CreatedAt    nostr.Timestamp
+	Identifier   string
+	Room         string
+	Summary      string
+	Image        string
+	Status       string
+	Service      string
+	Endpoint     string
+	Hashtags     []string
+	Participants []Participant
+	Relays       []string
+}
+
+func ParseMeetingSpace(event nostr.Event) MeetingSpace {
+	space := MeetingSpace{PubKey: event.PubKey, CreatedAt: event.CreatedAt}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			space.Identifier = tag[1]
+		case "room":
+			space.Room = tag[1]
+		case "summary":
+			space.Summary = tag[1]
+		case "image":
+			space.Image = tag[1]
+		case "status":
+			space.Status = tag[1]
+		case "service":
+			space.Service = tag[1]
+		case "endpoint":
+			space.Endpoint = tag[1]
+		case "t":
+			space.Hashtags = append(space.Hashtags, tag[1])
+		case "p":
+			if part, ok := parseParticipant(tag); ok {
+				space.Participants = append(space.Participants, part)
+			}
+		case "relays":
+			space.Relays = append(space.Relays, tag[1:]...)
+		}
+	}
+	return space
+}
+
+// Pointer returns the address of the meeting space.
+func (space MeetingSpace) Pointer() nostr.EntityPointer {
+	return nostr.EntityPointer{
+		PublicKey:  space.PubKey,
+		Kind:       nostr.KindMeetingSpace,
+		Identifier: space.Identifier,
+		Relays:     space.Relays,
+	}
+}
+
+// ToEvent returns an unsigned kind 30312 event.
+func (space MeetingSpace) ToEvent() nostr.Event {
+	tags := make(nostr.Tags, 0, 8+len(space.Hashtags)+len(space.Participants))
+	tags = append(tags, nostr.Tag{"d", space.Identifier}, nostr.Tag{"room", space.Room})
+	if space.Summary != "" {
+		tags = append(tags, nostr.Tag{"summary", space.Summary})
+	}
+	if space.Image != "" {
+		tags = append(tags, nostr.Tag{"image", space.Image})
+	}
+	if space.Status != "" {
+		tags = append(tags, nostr.Tag{"status", space.Status})
+	}
+	if space.Service != "" {
+		tags = append(tags, nostr.Tag{"service", space.Service})
+	}
+	if space.Endpoint != "" {
+		tags = append(tags, nostr.Tag{"endpoint", space.Endpoint})
+	}
+	for _, hashtag := range space.Hashtags {
+		tags = append(tags, nostr.Tag{"t", hashtag})
+	}
+	for _, part := range space.Participants {
+		tags = append(tags, nostr.Tag{"p", part.PubKey.Hex(), part.Relay, part.Role})
+	}
+	if len(space.Relays) > 0 {
+		tags = append(tags, append(nostr.Tag{"relays"}, space.Relays...))
+	}
+
+	return nostr.Event{
+		Kind:      nostr.KindMeetingSpace,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+	}
+}
+
+// MeetingRoom is a kind 30313 scheduled meeting happening inside a meeting space.
+type MeetingRoom struct {
+	PubKey              nostr.PubKey
+	CreatedAt           nostr.Timestamp
+	Identifier          string
+	Space               nostr.EntityPointer
+	Title               string
+	Summary             string
+	Image               string
+	Status              string
+	Starts, Ends        time.Time
+	CurrentParticipants int
+	TotalParticipants   int
+	Participants        []Participant
+}
+
+func ParseMeetingRoom(event nostr.Event) MeetingRoom {
+	room := MeetingRoom{PubKey: event.PubKey, CreatedAt: event.CreatedAt}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			room.Identifier = tag[1]
+		case "a":
+			if ptr, err := nostr.EntityPointerFromTag(tag); err == nil && ptr.Kind == nostr.KindMeetingSpace {
+				room.Space = ptr
+			}
+		case "title":
+			room.Title = tag[1]
+		case "summary":
+			room.Summary = tag[1]
+		case "image":
+			room.Image = tag[1]
+		case "status":
+			room.Status = tag[1]
+		case "starts", "ends":
+			i, err := strconv.ParseInt(tag[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			if tag[0] == "starts" {
+				room.Starts = time.Unix(i, 0)
+			} else {
+				room.Ends = time.Unix(i, 0)
+			}
+		case "p":
+			if part, ok := parseParticipant(tag); ok {
+				room.Participants = append(room.Participants, part)
+			}
+		case "current_participants":
+			room.CurrentParticipants, _ = strconv.Atoi(tag[1])
+		case "total_participants":
+			room.TotalParticipants, _ = strconv.Atoi(tag[1])
+		}
+	}
+	return room
+}
+
+// Pointer returns the address of the meeting room.
+func (room MeetingRoom) Pointer() nostr.EntityPointer {
+	return nostr.EntityPointer{
+		PublicKey:  room.PubKey,
+		Kind:       nostr.KindMeetingRoom,
+		Identifier: room.Identifier,
+	}
+}
+
+// ToEvent returns an unsigned kind 30313 event.
+func (room MeetingRoom) ToEvent() nostr.Event {
+	tags := make(nostr.Tags, 0, 10+len(room.Participants))
+	tags = append(tags, nostr.Tag{"d", room.Identifier}, room.Space.AsTag(), nostr.Tag{"title", room.Title})
+	if room.Summary != "" {
+		tags = append(tags, nostr.Tag{"summary", room.Summary})
+	}
+	if room.Image != "" {
+		tags = append(tags, nostr.Tag{"image", room.Image})
+	}
+	if room.Status != "" {
+		tags = append(tags, nostr.Tag{"status", room.Status})
+	}
+	if !room.Starts.IsZero() {
+		tags = append(tags, nostr.Tag{"starts", strconv.FormatInt(room.Starts.Unix(), 10)})
+	}
+	if !room.Ends.IsZero() {
+		tags = append(tags, nostr.Tag{"ends", strconv.FormatInt(room.Ends.Unix(), 10)})
+	}
+	for _, part := range room.Participants {
+		tags = append(tags, nostr.Tag{"p", part.PubKey.Hex(), part.Relay, part.Role})
+	}
+	if room.CurrentParticipants != 0 {
+		tags = append(tags, nostr.Tag{"current_participants", strconv.Itoa(room.CurrentParticipants)})
+	}
+	if room.TotalParticipants != 0 {
+		tags = append(tags, nostr.Tag{"total_participants", strconv.Itoa(room.TotalParticipants)})
+	}
+
+	return nostr.Event{
+		Kind:      nostr.KindMeetingRoom,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+	}
+}
@@ -0,0 +1,112 @@
+package nip54
+
+import (
+	"fiatjaf.com/nostr"
+)
+
+// Article is a kind 30818 wiki article.
+type Article struct {
+	Event nostr.Event
+
+	Identifier string
+	Title      string
+	Summary    string
+
+	// Forks are the versions this article was forked from.
+	Forks []Reference
+
+	// Defers are the versions the author of this article considers better than their own.
+	Defers []Reference
+}
+
+// Reference points to another version of an article, by address and/or by a specific event id.
+type Reference struct {
+	Address *nostr.EntityPointer
+	Event   *nostr.EventPointer
+}
+
+// Matches tells whether the reference points to the given article.
+func (ref Reference) Matches(a Article) bool {
+	if ref.Event != nil && ref.Event.ID == a.Event.ID {
+		return true
+	}
+	if ref.Address != nil && ref.Address.MatchesEvent(a.Event) {
+		return true
+	}
+	return false
+}
+
+// Pointer returns the address of the article.
+func (a Article) Pointer() nostr.EntityPointer {
+	return nostr.EntityPointer{PublicKey: a.Event.PubKey, Kind: a.Event.Kind, Identifier: a.Identifier}
+}
+
+// ParseArticle reads a kind 30818 event.
+func ParseArticle(event nostr.Event) Article {
+	article := Article{Event: event}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			article.Identifier = tag[1]
+		case "title":
+			article.Title = tag[1]
+		case "summary":
+			article.Summary = tag[1]
+		case "a", "e":
+			if len(tag) < 4 {
+				continue
+			}
+			var ref Reference
+			if tag[0] == "a" {
+				ptr, err := nostr.EntityPointerFromTag(tag)
+				if err != nil || ptr.Kind != nostr.KindWikiArticle {
+					continue
+				}
+				ref.Address = &ptr
+			} else {
+				ptr, err := nostr.EventPointerFromTag(tag[0:3])
+				if err != nil {
+					continue
+				}
+				ref.Event = &ptr
+			}
+			switch tag[3] {
+			case "fork":
+				article.Forks = append(article.Forks, ref)
+			case "defer":
+				article.Defers = append(article.Defers, ref)
+			}
+		}
+	}
+	if article.Title == "" {
+		article.Title = article.Identifier
+	}
+	return article
+}
+
+// Fork returns an unsigned event for a new article based on this one, with the same content and the tags
+// marking it as a fork. It must be signed by the forking author.
+func (a Article) Fork(relay string) nostr.Event {
+	return nostr.Event{
+		Kind:      nostr.KindWikiArticle,
+		CreatedAt: nostr.Now(),
+		Content:   a.Event.Content,
+		Tags: nostr.Tags{
+			{"d", a.Identifier},
+			{"title", a.Title},
+			{"a", a.Pointer().AsTagReference(), relay, "fork"},
+			{"e", a.Event.ID.Hex(), relay, "fork"},
+		},
+	}
+}
+
+// DeferTo adds tags to event, which must be the author's own version of an article, saying they consider
+// the given version to be better.
+func DeferTo(event *nostr.Event, better Article, relay string) {
+	event.Tags = append(event.Tags,
+		nostr.Tag{"a", better.Pointer().AsTagReference(), relay, "defer"},
+	)
+}
@@ -0,0 +1,118 @@
+package nip54
+
+import (
+	"strings"
+)
+
+// DiffOp says what happened to a line between two versions.
+type DiffOp int
+
+const (
+	Equal DiffOp = iota
+	Insert
+	Delete
+)
+
+// DiffLine is a line in the output of Diff.
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// maxDiffTable is the most cells the LCS table can have (16MB of int32s), as contents come from relays
+// and could be made huge. Above that the changed part is shown as deleted and then inserted as a whole.
+const maxDiffTable = 4_000_000
+
+// Diff computes a line-level diff between two versions of an article content.
+func Diff(before, after string) []DiffLine {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	// skip the common prefix and suffix, which is usually most of it
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]DiffLine, 0, len(a)+len(b))
+	for _, line := range a[0:prefix] {
+		result = append(result, DiffLine{Equal, line})
+	}
+
+	// longest common subsequence of the middle parts
+	ma := a[prefix : len(a)-suffix]
+	mb := b[prefix : len(b)-suffix]
+	if len(ma)*len(mb) > maxDiffTable {
+		for _, line := range ma {
+			result = append(result, DiffLine{Delete, line})
+		}
+		for _, line := range mb {
+			result = append(result, DiffLine{Insert, line})
+		}
+		for _, line := range a[len(a)-suffix:] {
+			result = append(result, DiffLine{Equal, line})
+		}
+		return result
+	}
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(ma) && j < len(mb) {
+		switch {
+		case ma[i] == mb[j]:
+			result = append(result, DiffLine{Equal, ma[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, DiffLine{Delete, ma[i]})
+			i++
+		default:
+			result = append(result, DiffLine{Insert, mb[j]})
+			j++
+		}
+	}
+	for ; i < len(ma); i++ {
+		result = append(result, DiffLine{Delete, ma[i]})
+	}
+	for ; j < len(mb); j++ {
+		result = append(result, DiffLine{Insert, mb[j]})
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, DiffLine{Equal, line})
+	}
+	return result
+}
+
+// FormatDiff renders a diff with "+ ", "- " and "  " prefixes.
+func FormatDiff(diff []DiffLine) string {
+	b := strings.Builder{}
+	for _, line := range diff {
+		switch line.Op {
+		case Insert:
+			b.WriteString("+ ")
+		case Delete:
+			b.WriteString("- ")
+		default:
+			b.WriteString("  ")
+		}
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package nip54
+
+import (
+	"cmp"
+	"slices"
+
+	"fiatjaf.com/nostr"
+)
+
+// Versions holds all the known versions of articles (usually for a single identifier), so forks and
+// defers between them can be followed.
+type Versions struct {
+	articles []Article
+}
+
+// NewVersions parses the kind 30818 events. If there is more than one event for the same address only
+// the newest is kept.
+func NewVersions(events []nostr.Event) *Versions {
+	v := &Versions{articles: make([]Article, 0, len(events))}
+	for _, evt := range events {
+		if evt.Kind != nostr.KindWikiArticle {
+			continue
+		}
+		article := ParseArticle(evt)
+		if idx := slices.IndexFunc(v.articles, func(a Article) bool {
+			return a.Event.PubKey == evt.PubKey && a.Identifier == article.Identifier
+		}); idx != -1 {
+			if v.articles[idx].Event.CreatedAt < evt.CreatedAt {
+				v.articles[idx] = article
+			}
+			continue
+		}
+		v.articles = append(v.articles, article)
+	}
+	return v
+}
+
+// All returns all the versions, newest first.
+func (v *Versions) All() []Article {
+	all := slices.Clone(v.articles)
+	slices.SortFunc(all, func(a, b Article) int { return cmp.Compare(b.Event.CreatedAt, a.Event.CreatedAt) })
+	return all
+}
+
+// Find returns the version that matches the reference.
+func (v *Versions) Find(ref Reference) (Article, bool) {
+	for _, a := range v.articles {
+		if ref.Matches(a) {
+			return a, true
+		}
+	}
+	return Article{}, false
+}
+
+// Parents returns the known versions the article was forked from.
+func (v *Versions) Parents(article Article) []Article {
+	parents := make([]Article, 0, len(article.Forks))
+	for _, ref := range article.Forks {
+		if parent, ok := v.Find(ref); ok && !slices.ContainsFunc(parents, func(p Article) bool {
+			return p.Event.ID == parent.Event.ID
+		}) {
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+// Forks returns the known versions that were forked from the article.
+func (v *Versions) Forks(article Article) []Article {
+	var forks []Article
+	for _, a := range v.articles {
+		if slices.ContainsFunc(a.Forks, func(ref Reference) bool { return ref.Matches(article) }) {
+			forks = append(forks, a)
+		}
+	}
+	return forks
+}
+
+// Lineage follows the first fork reference of each version back to the original one. The result starts
+// with the given article and ends with the oldest known ancestor.
+func (v *Versions) Lineage(article Article) []Article {
+	lineage := []Article{article}
+	for {
+		parents := v.Parents(lineage[len(lineage)-1])
+		if len(parents) == 0 ||
+			slices.ContainsFunc(lineage, func(a Article) bool { return a.Event.ID == parents[0].Event.ID }) {
+			return lineage
+		}
+		lineage = append(lineage, parents[0])
+	}
+}
+
+// Deferred follows the defer references starting at the given article and returns the version everybody
+// in the chain defers to, which is the article itself if its author doesn't defer to any known version.
+func (v *Versions) Deferred(article Article) Article {
+	seen := []nostr.ID{article.Event.ID}
+	current := article
+	for {
+		var next *Article
+		for _, ref := range current.Defers {
+			if target, ok := v.Find(ref); ok && !slices.Contains(seen, target.Event.ID) {
+				next = &target
+				break
+			}
+		}
+		if next == nil {
+			return current
+		}
+		seen = append(seen, next.Event.ID)
+		current = *next
+	}
+}
@@ -0,0 +1,97 @@
+package nip54
+
+import (
+	"fmt"
+
+	"fiatjaf.com/nostr"
+)
+
+// MergeRequest is a kind 818 event asking the author of an article to merge changes from another version.
+type MergeRequest struct {
+	Event nostr.Event
+
+	// Target is the article the changes should be merged into.
+	Target nostr.EntityPointer
+
+	// Base is the version of the target the changes were made against, if known.
+	Base *nostr.EventPointer
+
+	// Source is the version containing the changes.
+	Source nostr.EventPointer
+
+	// Explanation is the content of the request.
+	Explanation string
+}
+
+// NewMergeRequest returns an unsigned kind 818 event asking the author of target to merge source into it.
+// target should be the version source was forked from, if there is one.
+func NewMergeRequest(target Article, source Article, relay string, explanation string) nostr.Event {
+	return nostr.Event{
+		Kind:      nostr.KindMergeRequests,
+		CreatedAt: nostr.Now(),
+		Content:   explanation,
+		Tags: nostr.Tags{
+			{"a", target.Pointer().AsTagReference(), relay},
+			{"e", target.Event.ID.Hex(), relay},
+			{"p", target.Event.PubKey.Hex()},
+			{"e", source.Event.ID.Hex(), relay, "source"},
+		},
+	}
+}
+
+// ParseMergeRequest reads a kind 818 event.
+func ParseMergeRequest(event nostr.Event) (MergeRequest, error) {
+	if event.Kind != nostr.KindMergeRequests {
+		return MergeRequest{}, fmt.Errorf("event is kind %d, not %d", event.Kind, nostr.KindMergeRequests)
+	}
+
+	mr := MergeRequest{Event: event, Explanation: event.Content}
+	var hasTarget, hasSource bool
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "a":
+			ptr, err := nostr.EntityPointerFromTag(tag)
+			if err != nil || ptr.Kind != nostr.KindWikiArticle {
+				continue
+			}
+			mr.Target = ptr
+			hasTarget = true
+		case "e":
+			ptr, err := nostr.EventPointerFromTag(tag[0:min(len(tag), 3)])
+			if err != nil {
+				continue
+			}
+			if len(tag) >= 4 && tag[3] == "source" {
+				mr.Source = ptr
+				hasSource = true
+			} else {
+				mr.Base = &ptr
+			}
+		}
+	}
+
+	if !hasTarget {
+		return mr, fmt.Errorf("missing target article")
+	}
+	if !hasSource {
+		return mr, fmt.Errorf("missing source version")
+	}
+	return mr, nil
+}
+
+// Diff compares the base version (or the current target, if the base is not known) with the source.
+func (mr MergeRequest) Diff(base Article, source Article) ([]DiffLine, error) {
+	if source.Event.ID != mr.Source.ID {
+		return nil, fmt.Errorf("source is %s, not %s", source.Event.ID.Hex(), mr.Source.ID.Hex())
+	}
+	if mr.Base != nil && base.Event.ID != mr.Base.ID {
+		return nil, fmt.Errorf("base is %s, not %s", base.Event.ID.Hex(), mr.Base.ID.Hex())
+	}
+	if mr.Base == nil && !mr.Target.MatchesEvent(base.Event) {
+		return nil, fmt.Errorf("base is not a version of %s", mr.Target.AsTagReference())
+	}
+	return Diff(base.Event.Content, source.Event.Content), nil
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNormalization(t *testing.T) {
@@ -132,3 +135,95 @@ func TestArticleAsHTML(t *testing.T) {
 		})
 	}
 }
+
+func TestVersions(t *testing.T) {
+	keys := make([]nostr.SecretKey, 5)
+	for i := range keys {
+		keys[i] = nostr.Generate()
+	}
+	publish := func(sk nostr.SecretKey, evt nostr.Event, createdAt nostr.Timestamp) nostr.Event {
+		evt.CreatedAt = createdAt
+		require.NoError(t, evt.Sign(sk))
+		return evt
+	}
+
+	original := publish(keys[0], nostr.Event{
+		Kind:    nostr.KindWikiArticle,
+		Content: "line 1\nline 2\nline 3",
+		Tags:    nostr.Tags{{"d", "bitcoin"}, {"title", "Bitcoin"}},
+	}, 100)
+	fork := ParseArticle(original).Fork("wss://relay.example.com")
+	fork.Content = "line 1\nline two\nline 3\nline 4"
+	forked := publish(keys[1], fork, 200)
+	deferring := nostr.Event{Kind: nostr.KindWikiArticle, Content: "meh", Tags: nostr.Tags{{"d", "Bitcoin"}}}
+	DeferTo(&deferring, ParseArticle(forked), "")
+	deferred := publish(keys[2], deferring, 300)
+	other := publish(keys[3], nostr.Event{Kind: nostr.KindWikiArticle, Content: "other", Tags: nostr.Tags{{"d", "bitcoin"}}}, 400)
+	unrelated := publish(keys[4], nostr.Event{Kind: nostr.KindWikiArticle, Content: "x", Tags: nostr.Tags{{"d", "ethereum"}}}, 500)
+
+	versions := NewVersions([]nostr.Event{original, forked, deferred, other, unrelated})
+
+	a := ParseArticle(forked)
+	require.Len(t, a.Forks, 2)
+	require.Equal(t, []Article{ParseArticle(original)}, versions.Parents(a))
+	require.Equal(t, []Article{a}, versions.Forks(ParseArticle(original)))
+	require.Len(t, versions.Lineage(a), 2)
+	require.Equal(t, forked.ID, versions.Deferred(ParseArticle(deferred)).Event.ID)
+
+	// with no follows the fork wins because of the deferral
+	best, ok := versions.Best("Bitcoin", Reader{})
+	require.True(t, ok)
+	require.Equal(t, forked.ID, best.Event.ID)
+	require.Len(t, versions.Rank("bitcoin", Reader{}), 4)
+
+	// following someone makes their version win
+	best, _ = versions.Best("bitcoin", Reader{Follows: []nostr.PubKey{keys[3].Public()}})
+	require.Equal(t, other.ID, best.Event.ID)
+
+	// trusting two authors that agree beats following one
+	best, _ = versions.Best("bitcoin", Reader{
+		Trusted: func(pk nostr.PubKey) bool { return pk == keys[1].Public() || pk == keys[2].Public() },
+	})
+	require.Equal(t, forked.ID, best.Event.ID)
+
+	_, ok = versions.Best("litecoin", Reader{})
+	require.False(t, ok)
+}
+
+func TestDiff(t *testing.T) {
+	diff := Diff("a\nb\nc\nd\ne", "a\nc\nx\nd\ne\nf")
+	require.Equal(t, "  a\n- b\n  c\n+ x\n  d\n  e\n+ f\n", FormatDiff(diff))
+	require.Equal(t, "  same\n", FormatDiff(Diff("same", "same")))
+
+	// huge changes aren't diffed line by line
+	before := strings.Repeat("x\n", 3000) + "head\n" + strings.Repeat("a\n", 3000) + "tail"
+	after := strings.Repeat("x\n", 3000) + "head\n" + strings.Repeat("b\n", 3000) + "tail"
+	diff = Diff(before, after)
+	require.Len(t, diff, 9002)
+	require.Equal(t, DiffLine{Delete, "a"}, diff[3001])
+	require.Equal(t, DiffLine{Insert, "b"}, diff[6001])
+	require.Equal(t, DiffLine{Equal, "tail"}, diff[9001])
+}
+
+func TestMergeRequest(t *testing.T) {
+	target := nostr.Event{Kind: nostr.KindWikiArticle, Content: "old", Tags: nostr.Tags{{"d", "x"}}}
+	require.NoError(t, target.Sign(nostr.Generate()))
+	source := ParseArticle(target).Fork("")
+	source.Content = "new"
+	require.NoError(t, source.Sign(nostr.Generate()))
+
+	evt := NewMergeRequest(ParseArticle(target), ParseArticle(source), "wss://relay.example.com", "please")
+	require.NoError(t, evt.Sign(nostr.Generate()))
+	mr, err := ParseMergeRequest(evt)
+	require.NoError(t, err)
+	require.Equal(t, target.PubKey, mr.Target.PublicKey)
+	require.Equal(t, target.ID, mr.Base.ID)
+	require.Equal(t, source.ID, mr.Source.ID)
+	require.Equal(t, "please", mr.Explanation)
+
+	diff, err := mr.Diff(ParseArticle(target), ParseArticle(source))
+	require.NoError(t, err)
+	require.Equal(t, []DiffLine{{Delete, "old"}, {Insert, "new"}}, diff)
+	_, err = mr.Diff(ParseArticle(source), ParseArticle(source))
+	require.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package nip54
+
+import (
+	"cmp"
+	"slices"
+
+	"fiatjaf.com/nostr"
+)
+
+// Reader describes whose opinion matters when choosing between the many versions of an article.
+type Reader struct {
+	// PubKey is the reader's own key, their own version always wins.
+	PubKey nostr.PubKey
+
+	// Follows are the keys the reader follows.
+	Follows []nostr.PubKey
+
+	// Trusted is an optional wider circle of trust, e.g. the Contains method of the filter returned by
+	// sdk.System.LoadWoTFilter.
+	Trusted func(nostr.PubKey) bool
+}
+
+func (r Reader) weight(author nostr.PubKey) int {
+	switch {
+	case author == r.PubKey:
+		return 1000
+	case slices.Contains(r.Follows, author):
+		return 100
+	case r.Trusted != nil && r.Trusted(author):
+		return 10
+	default:
+		return 1
+	}
+}
+
+// Rank returns the versions of the article with the given identifier ordered from the one that should be
+// shown to the reader to the least relevant.
+//
+// Each version is weighted by how close its author is to the reader. An author that defers to another
+// version gives their weight to that version (following chains of defers) and their own version goes
+// to the end. Ties are broken by recency.
+func (v *Versions) Rank(identifier string, reader Reader) []Article {
+	identifier = NormalizeIdentifier(identifier)
+
+	candidates := make([]Article, 0, len(v.articles))
+	for _, a := range v.articles {
+		if NormalizeIdentifier(a.Identifier) == identifier {
+			candidates = append(candidates, a)
+		}
+	}
+
+	scores := make(map[nostr.ID]int, len(candidates))
+	for _, a := range candidates {
+		target := v.Deferred(a)
+		if NormalizeIdentifier(target.Identifier) != identifier {
+			target = a
+		}
+		scores[target.Event.ID] += reader.weight(a.Event.PubKey)
+	}
+
+	slices.SortFunc(candidates, func(a, b Article) int {
+		if c := cmp.Compare(scores[b.Event.ID], scores[a.Event.ID]); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Event.CreatedAt, a.Event.CreatedAt)
+	})
+	return candidates
+}
+
+// Best returns the version of the article with the given identifier that should be shown to the reader,
+// according to Rank.
+func (v *Versions) Best(identifier string, reader Reader) (Article, bool) {
+	ranked := v.Rank(identifier, reader)
+	if len(ranked) == 0 {
+		return Article{}, false
+	}
+	return ranked[0], true
+}
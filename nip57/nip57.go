@@ -2,7 +2,9 @@ package nip57
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 
 	"fiatjaf.com/nostr"
 )
@@ -31,3 +33,49 @@ func GetAmountFromZap(event nostr.Event) uint64 {
 
 	return 0
 }
+
+// GetAmountFromBolt11 returns the amount of a bolt11 invoice in millisats, or zero if the invoice doesn't
+// specify an amount. Only the human-readable part is read, the invoice is not otherwise validated.
+func GetAmountFromBolt11(bolt11 string) (uint64, error) {
+	idx := strings.LastIndex(bolt11, "1")
+	if idx == -1 {
+		return 0, fmt.Errorf("invalid invoice")
+	}
+	hrp, ok := strings.CutPrefix(strings.ToLower(bolt11[0:idx]), "ln")
+	if !ok {
+		return 0, fmt.Errorf("invalid invoice")
+	}
+
+	// skip the currency prefix ("bc", "tb", "bcrt" etc)
+	start := strings.IndexAny(hrp, "0123456789")
+	if start == -1 {
+		return 0, nil
+	}
+	amount := hrp[start:]
+
+	multiplier := amount[len(amount)-1]
+	num := amount
+	if multiplier < '0' || multiplier > '9' {
+		num = amount[:len(amount)-1]
+	}
+	am, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+
+	switch multiplier {
+	case 'm':
+		return am * 100_000_000, nil
+	case 'u':
+		return am * 100_000, nil
+	case 'n':
+		return am * 100, nil
+	case 'p':
+		return am / 10, nil
+	default:
+		if multiplier < '0' || multiplier > '9' {
+			return 0, fmt.Errorf("invalid multiplier %q", multiplier)
+		}
+		return am * 100_000_000_000, nil
+	}
+}
@@ -282,7 +282,7 @@ func (pool *Pool) fetchMany(
 		}
 	}
 
-	return pool.subManyEose(ctx, urls, filter, closedChan, opts)
+	return pool.subManyEose(ctx, urls, filter, closedChan, nil, opts)
 }
 
 // SubscribeManyNotifyEOSE is like SubscribeMany, but also returns a channel that is closed when all subscriptions have received an EOSE
@@ -608,6 +608,7 @@ func (pool *Pool) subManyEose(
 	urls []string,
 	filter Filter,
 	closedChan chan RelayClosed,
+	eoseChan chan string,
 	opts SubscriptionOptions,
 ) chan RelayEvent {
 	ctx, cancel := context.WithCancelCause(ctx)
@@ -657,6 +658,12 @@ func (pool *Pool) subManyEose(
 				case <-ctx.Done():
 					return
 				case <-sub.EndOfStoredEvents:
+					if eoseChan != nil {
+						select {
+						case eoseChan <- nm:
+						case <-ctx.Done():
+						}
+					}
 					return
 				case reason := <-sub.ClosedReason:
 					if strings.HasPrefix(reason, "auth-required:") && pool.authHandler != nil && !hasAuthed {
@@ -760,23 +767,37 @@ func (pool *Pool) BatchedQueryManyNotifyClosed(
 	opts SubscriptionOptions,
 ) (chan RelayEvent, chan RelayClosed) {
 	closedChan := make(chan RelayClosed)
-	events := pool.batchedQueryMany(ctx, dfs, closedChan, opts)
+	events := pool.batchedQueryMany(ctx, dfs, closedChan, nil, opts)
 	return events, closedChan
 }
 
+// BatchedQueryManyNotifyEOSE is like BatchedQueryMany, but also returns a channel that emits the URL of
+// each relay that sends an EOSE. With MaxWaitForEOSE set to time.Duration(math.MaxInt64) this can be used
+// to tell the relays that actually answered from the ones that timed out.
+func (pool *Pool) BatchedQueryManyNotifyEOSE(
+	ctx context.Context,
+	dfs []DirectedFilter,
+	opts SubscriptionOptions,
+) (chan RelayEvent, chan string) {
+	eoseChan := make(chan string)
+	events := pool.batchedQueryMany(ctx, dfs, nil, eoseChan, opts)
+	return events, eoseChan
+}
+
 // BatchedQueryMany takes a bunch of filters and sends each to the target relay but deduplicates results smartly.
 func (pool *Pool) BatchedQueryMany(
 	ctx context.Context,
 	dfs []DirectedFilter,
 	opts SubscriptionOptions,
 ) chan RelayEvent {
-	return pool.batchedQueryMany(ctx, dfs, nil, opts)
+	return pool.batchedQueryMany(ctx, dfs, nil, nil, opts)
 }
 
 func (pool *Pool) batchedQueryMany(
 	ctx context.Context,
 	dfs []DirectedFilter,
 	closedChan chan RelayClosed,
+	eoseChan chan string,
 	opts SubscriptionOptions,
 ) chan RelayEvent {
 	res := make(chan RelayEvent)
@@ -798,6 +819,7 @@ func (pool *Pool) batchedQueryMany(
 				[]string{df.Relay},
 				df.Filter,
 				closedChan,
+				eoseChan,
 				opts,
 			) {
 				select {
@@ -45,6 +45,7 @@ type Relay struct {
 	okCallbacks                   map[ID]okcallback
 	okCallbacksMutex              sync.Mutex
 	subscriptionChannelCloseQueue chan *Subscription
+	verifier                      *Verifier
 
 	// custom things that aren't often used
 	//
@@ -64,6 +65,7 @@ func NewRelay(ctx context.Context, url string, opts RelayOptions) *Relay {
 		requestHeader:                 opts.RequestHeader,
 		customHandler:                 opts.CustomHandler,
 		noticeHandler:                 opts.NoticeHandler,
+		verifier:                      opts.Verifier,
 	}
 
 	return r
@@ -92,6 +94,10 @@ type RelayOptions struct {
 
 	// RequestHeader sets the HTTP request header of the websocket preflight request
 	RequestHeader http.Header
+
+	// Verifier, if given, will be used to verify the signatures of received events in batches
+	// instead of one by one. It can (and should) be shared between many relays.
+	Verifier *Verifier
 }
 
 // String just returns the relay URL.
@@ -197,7 +203,20 @@ func (r *Relay) handleMessage(message string) {
 			}
 
 			// check signature, ignore invalid, except from trusted (AssumeValid) relays
-			if !r.AssumeValid {
+			if !r.AssumeValid && r.verifier != nil {
+				// verify in batches together with events from other places and dispatch later
+				evt := env.Event
+				release := sub.holdEose()
+				r.verifier.VerifyAsync(evt, func(valid bool) {
+					if !valid {
+						InfoLogger.Printf("{%s} bad signature on %s\n", r.URL, evt.ID)
+						release()
+						return
+					}
+					sub.deliverEvent(evt, release)
+				})
+				return
+			} else if !r.AssumeValid {
 				if !env.Event.VerifySignature() {
 					InfoLogger.Printf("{%s} bad signature on %s\n", r.URL, env.Event.ID)
 					return
@@ -144,6 +144,57 @@ func TestConnectContextCanceled(t *testing.T) {
 	require.ErrorIs(t, err, context.Canceled)
 }
 
+func TestSubscribeWithVerifier(t *testing.T) {
+	events := makeBatch(5, 2)
+	events[2].Content = "tampered"
+
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var raw []stdjson.RawMessage
+		err := websocket.JSON.Receive(conn, &raw)
+		require.NoError(t, err)
+		var subid string
+		err = json.Unmarshal(raw[1], &subid)
+		require.NoError(t, err)
+
+		for _, evt := range events {
+			err = websocket.JSON.Send(conn, []any{"EVENT", subid, evt})
+			require.NoError(t, err)
+		}
+		err = websocket.JSON.Send(conn, []any{"EOSE", subid})
+		require.NoError(t, err)
+
+		// keep the connection open
+		websocket.JSON.Receive(conn, &raw)
+	})
+	defer ws.Close()
+
+	verifier := NewVerifier(VerifierOptions{})
+	defer verifier.Close()
+	rl, err := RelayConnect(t.Context(), ws.URL, RelayOptions{Verifier: verifier})
+	require.NoError(t, err)
+	defer rl.Close()
+
+	sub, err := rl.Subscribe(t.Context(), Filter{Kinds: []Kind{1}}, SubscriptionOptions{})
+	require.NoError(t, err)
+
+	// all the valid events must come before the EOSE even though they're verified asynchronously
+	received := 0
+	for {
+		select {
+		case evt, ok := <-sub.Events:
+			require.True(t, ok)
+			require.NotEqual(t, "tampered", evt.Content)
+			received++
+			continue
+		case <-sub.EndOfStoredEvents:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+		break
+	}
+	require.Equal(t, 4, received)
+}
+
 func newWebsocketServer(handler func(*websocket.Conn)) *httptest.Server {
 	return httptest.NewServer(&websocket.Server{
 		Handshake: anyOriginHandshake,
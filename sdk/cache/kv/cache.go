@@ -0,0 +1,284 @@
+package cache_kv
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr/sdk/cache"
+	"fiatjaf.com/nostr/sdk/kvstore"
+)
+
+// Codec turns cached values into bytes and back.
+type Codec[V any] interface {
+	Encode(v V) ([]byte, error)
+	Decode(b []byte) (V, error)
+}
+
+// JSONCodec is the default Codec, it only works for values that survive a JSON roundtrip.
+type JSONCodec[V any] struct{}
+
+func (JSONCodec[V]) Encode(v V) ([]byte, error) { return json.Marshal(v) }
+func (JSONCodec[V]) Decode(b []byte) (v V, err error) {
+	err = json.Unmarshal(b, &v)
+	return v, err
+}
+
+type Options[V any] struct {
+	// Codec defaults to JSONCodec.
+	Codec Codec[V]
+
+	// MaxEntries is the maximum number of entries kept, when it is exceeded the least recently used
+	// ones are evicted. Zero means unbounded.
+	MaxEntries int
+
+	// SweepInterval is how often expired entries are removed and the index is persisted.
+	// Defaults to 10 minutes, a negative value disables the background sweeping.
+	SweepInterval time.Duration
+}
+
+var _ cache.Cache32[any] = (*Cache[any])(nil)
+
+// Cache is a cache.Cache32 that stores its values in a kvstore.KVStore, so it survives restarts.
+//
+// Since a KVStore can't be iterated, the cache keeps an index of its keys with their expiration and last
+// access times in memory, which is persisted in the store on every sweep and on Close. Entries written
+// after the last time the index was persisted are still found by Get, but are only subject to expiry
+// sweeping and eviction after they're read again.
+type Cache[V any] struct {
+	store      kvstore.KVStore
+	namespace  string
+	codec      Codec[V]
+	maxEntries int
+
+	mu    sync.Mutex
+	index map[[32]byte]entry
+	dirty bool
+
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	now func() time.Time // replaced in tests
+}
+
+type entry struct {
+	expires int64 // unix seconds, zero means never
+	used    int64 // unix nanoseconds
+}
+
+// New creates a Cache that stores its entries in store under keys starting with namespace, which must not
+// be a prefix of any other keys used in the same store.
+func New[V any](store kvstore.KVStore, namespace string, opts Options[V]) *Cache[V] {
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec[V]{}
+	}
+	if opts.SweepInterval == 0 {
+		opts.SweepInterval = time.Minute * 10
+	}
+
+	c := &Cache[V]{
+		store:      store,
+		namespace:  namespace,
+		codec:      opts.Codec,
+		maxEntries: opts.MaxEntries,
+		index:      make(map[[32]byte]entry),
+		stop:       make(chan struct{}),
+		now:        time.Now,
+	}
+	c.loadIndex()
+
+	if opts.SweepInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(opts.SweepInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					c.Sweep()
+				case <-c.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	return c
+}
+
+func (c *Cache[V]) Get(k [32]byte) (v V, ok bool) {
+	data, _ := c.store.Get(c.key(k))
+	if len(data) < 8 {
+		c.mu.Lock()
+		if _, ok := c.index[k]; ok {
+			delete(c.index, k)
+			c.dirty = true
+		}
+		c.mu.Unlock()
+		return v, false
+	}
+
+	expires := int64(binary.BigEndian.Uint64(data[0:8]))
+	if expires != 0 && expires <= c.now().Unix() {
+		c.Delete(k)
+		return v, false
+	}
+
+	v, err := c.codec.Decode(data[8:])
+	if err != nil {
+		c.Delete(k)
+		return v, false
+	}
+
+	c.mu.Lock()
+	c.index[k] = entry{expires: expires, used: c.now().UnixNano()}
+	c.dirty = true
+	c.mu.Unlock()
+
+	return v, true
+}
+
+func (c *Cache[V]) Delete(k [32]byte) {
+	c.store.Delete(c.key(k))
+
+	c.mu.Lock()
+	delete(c.index, k)
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+func (c *Cache[V]) Set(k [32]byte, v V) bool { return c.SetWithTTL(k, v, 0) }
+
+func (c *Cache[V]) SetWithTTL(k [32]byte, v V, d time.Duration) bool {
+	payload, err := c.codec.Encode(v)
+	if err != nil {
+		return false
+	}
+
+	var expires int64
+	if d > 0 {
+		expires = c.now().Add(d).Unix()
+	}
+
+	data := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(data[0:8], uint64(expires))
+	copy(data[8:], payload)
+	if err := c.store.Set(c.key(k), data); err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	c.index[k] = entry{expires: expires, used: c.now().UnixNano()}
+	c.dirty = true
+	var evicted [][32]byte
+	if c.maxEntries > 0 && len(c.index) > c.maxEntries {
+		evicted = c.evict()
+	}
+	c.mu.Unlock()
+
+	for _, ek := range evicted {
+		c.store.Delete(c.key(ek))
+	}
+
+	return true
+}
+
+// Len returns the number of entries in the index.
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.index)
+}
+
+// Sweep removes all expired entries and persists the index. It is called periodically in the background.
+func (c *Cache[V]) Sweep() {
+	now := c.now().Unix()
+
+	c.mu.Lock()
+	expired := make([][32]byte, 0, 16)
+	for k, e := range c.index {
+		if e.expires != 0 && e.expires <= now {
+			expired = append(expired, k)
+			delete(c.index, k)
+			c.dirty = true
+		}
+	}
+	c.mu.Unlock()
+
+	for _, k := range expired {
+		c.store.Delete(c.key(k))
+	}
+
+	c.saveIndex()
+}
+
+// Close stops the background sweeping and persists the index. It doesn't close the underlying store.
+func (c *Cache[V]) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.saveIndex()
+}
+
+// evict removes the least recently used entries from the index until we're 10% below maxEntries, so we
+// don't have to do this on every Set. It must be called with the lock held.
+func (c *Cache[V]) evict() [][32]byte {
+	type kentry struct {
+		k [32]byte
+		e entry
+	}
+	all := make([]kentry, 0, len(c.index))
+	for k, e := range c.index {
+		all = append(all, kentry{k, e})
+	}
+	slices.SortFunc(all, func(a, b kentry) int { return cmp.Compare(a.e.used, b.e.used) })
+
+	target := c.maxEntries - c.maxEntries/10
+	evicted := make([][32]byte, 0, len(all)-target)
+	for _, ke := range all[0 : len(all)-target] {
+		delete(c.index, ke.k)
+		evicted = append(evicted, ke.k)
+	}
+	return evicted
+}
+
+func (c *Cache[V]) key(k [32]byte) []byte {
+	key := make([]byte, len(c.namespace)+32)
+	copy(key, c.namespace)
+	copy(key[len(c.namespace):], k[:])
+	return key
+}
+
+func (c *Cache[V]) indexKey() []byte {
+	// this can't collide with the entries since it has a different length
+	return []byte(c.namespace + "index")
+}
+
+func (c *Cache[V]) loadIndex() {
+	// format: a sequence of 32 bytes key + 8 bytes expiration + 8 bytes last access
+	data, _ := c.store.Get(c.indexKey())
+	for i := 0; i+48 <= len(data); i += 48 {
+		c.index[[32]byte(data[i:i+32])] = entry{
+			expires: int64(binary.BigEndian.Uint64(data[i+32 : i+40])),
+			used:    int64(binary.BigEndian.Uint64(data[i+40 : i+48])),
+		}
+	}
+}
+
+func (c *Cache[V]) saveIndex() error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	data := make([]byte, 0, len(c.index)*48)
+	for k, e := range c.index {
+		data = append(data, k[:]...)
+		data = binary.BigEndian.AppendUint64(data, uint64(e.expires))
+		data = binary.BigEndian.AppendUint64(data, uint64(e.used))
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	return c.store.Set(c.indexKey(), data)
+}
@@ -0,0 +1,62 @@
+package cache_kv
+
+import (
+	"testing"
+	"time"
+
+	kvstore_memory "fiatjaf.com/nostr/sdk/kvstore/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	store := kvstore_memory.NewStore()
+	c := New(store, "test:", Options[[]string]{MaxEntries: 10, SweepInterval: -1})
+	clock := time.Now()
+	c.now = func() time.Time { return clock }
+
+	c.Set([32]byte{1}, []string{"a", "b"})
+	v, ok := c.Get([32]byte{1})
+	require.True(t, ok)
+	require.Equal(t, []string{"a", "b"}, v)
+
+	// expired entries are gone on read and on sweep
+	c.SetWithTTL([32]byte{2}, []string{"c"}, time.Second)
+	c.SetWithTTL([32]byte{3}, []string{"d"}, time.Second)
+	_, ok = c.Get([32]byte{2})
+	require.True(t, ok)
+	clock = clock.Add(time.Second)
+	_, ok = c.Get([32]byte{2})
+	require.False(t, ok)
+	require.Equal(t, 2, c.Len())
+	c.Sweep()
+	require.Equal(t, 1, c.Len())
+	data, _ := store.Get(c.key([32]byte{3}))
+	require.Nil(t, data)
+
+	c.Delete([32]byte{1})
+	_, ok = c.Get([32]byte{1})
+	require.False(t, ok)
+
+	// least recently used entries are evicted
+	for i := range 10 {
+		c.Set([32]byte{10 + byte(i)}, []string{"x"})
+		clock = clock.Add(time.Millisecond)
+	}
+	c.Get([32]byte{10})
+	c.Set([32]byte{20}, []string{"y"})
+	require.Equal(t, 9, c.Len())
+	_, ok = c.Get([32]byte{10})
+	require.True(t, ok)
+	_, ok = c.Get([32]byte{11})
+	require.False(t, ok)
+	_, ok = c.Get([32]byte{12})
+	require.False(t, ok)
+
+	// everything survives a restart
+	require.NoError(t, c.Close())
+	c = New(store, "test:", Options[[]string]{MaxEntries: 10, SweepInterval: -1})
+	require.Equal(t, 9, c.Len())
+	v, ok = c.Get([32]byte{20})
+	require.True(t, ok)
+	require.Equal(t, []string{"y"}, v)
+}
@@ -0,0 +1,339 @@
+package sdk
+
+import (
+	"context"
+	"encoding/binary"
+	"slices"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip45"
+	"fiatjaf.com/nostr/nip57"
+	"fiatjaf.com/nostr/nip61"
+)
+
+const engagementPrefix = byte('g')
+
+// EngagementCacheDuration is how long stats saved in KVStore are considered good enough
+// to be returned by FetchEngagement without going to relays again.
+var EngagementCacheDuration = time.Minute * 10
+
+// EngagementStats are the aggregated interactions with a single event.
+//
+// Reactions, Reposts and Replies count distinct authors, zaps and nutzaps count receipts.
+type EngagementStats struct {
+	Reactions    int
+	Reposts      int
+	Replies      int
+	Zaps         int
+	ZapAmount    uint64 // in millisats
+	Nutzaps      int
+	NutzapAmount uint64 // in the unit of the mint, usually sats
+	UpdatedAt    nostr.Timestamp
+}
+
+// EngagementUpdate is emitted by StreamEngagement every time the stats for an event change.
+type EngagementUpdate struct {
+	ID    nostr.ID
+	Stats EngagementStats
+}
+
+// FetchEngagement returns reaction, repost, reply and zap stats for each of the given event ids.
+//
+// Stats that were computed less than EngagementCacheDuration ago are read from KVStore, everything else
+// is computed from the events we can find locally and on relays (the ones where the target events were
+// seen plus some fallback relays), complemented with NIP-45 HyperLogLog counts where relays support them.
+func (sys *System) FetchEngagement(ctx context.Context, ids []nostr.ID) map[nostr.ID]EngagementStats {
+	results := make(map[nostr.ID]EngagementStats, len(ids))
+	missing := make([]nostr.ID, 0, len(ids))
+
+	now := nostr.Now()
+	for _, id := range ids {
+		if data, _ := sys.KVStore.Get(makeEngagementKey(id)); data != nil {
+			if stats, ok := decodeEngagementStats(data); ok &&
+				now-stats.UpdatedAt < nostr.Timestamp(EngagementCacheDuration.Seconds()) {
+				results[id] = stats
+				continue
+			}
+		}
+		missing = append(missing, id)
+	}
+
+	if len(missing) > 0 {
+		aggs, _ := sys.aggregateEngagement(ctx, missing)
+		for id, agg := range aggs {
+			results[id] = agg.stats
+		}
+	}
+
+	return results
+}
+
+// StreamEngagement computes the stats for the given event ids from scratch and then keeps listening
+// for new reactions, reposts, replies and zaps, emitting updated stats as they arrive.
+// The initial stats are also emitted. The channel is closed when ctx is canceled.
+func (sys *System) StreamEngagement(ctx context.Context, ids []nostr.ID) <-chan EngagementUpdate {
+	updates := make(chan EngagementUpdate)
+
+	go func() {
+		defer close(updates)
+
+		since := nostr.Now()
+		aggs, relays := sys.aggregateEngagement(ctx, ids)
+		for id, agg := range aggs {
+			select {
+			case updates <- EngagementUpdate{id, agg.stats}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		mu := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		for _, filter := range engagementFilters(ids) {
+			filter.Since = since
+			wg.Go(func() {
+				for ie := range sys.Pool.SubscribeMany(ctx, slices.Clone(relays), filter, nostr.SubscriptionOptions{
+					Label: "engagement",
+				}) {
+					mu.Lock()
+					id, changed := applyEngagementEvent(aggs, ie.Event)
+					var stats EngagementStats
+					if changed {
+						aggs[id].stats.UpdatedAt = nostr.Now()
+						stats = aggs[id].stats
+						sys.KVStore.Set(makeEngagementKey(id), encodeEngagementStats(stats))
+					}
+					mu.Unlock()
+
+					if changed {
+						select {
+						case updates <- EngagementUpdate{id, stats}:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			})
+		}
+		wg.Wait()
+	}()
+
+	return updates
+}
+
+type engagementAggregator struct {
+	stats      EngagementStats
+	reactors   map[nostr.PubKey]struct{}
+	reposters  map[nostr.PubKey]struct{}
+	repliers   map[nostr.PubKey]struct{}
+	commenters map[nostr.PubKey]struct{} // the subset of repliers that wrote kind:1111 comments
+	receipts   map[nostr.ID]struct{}
+}
+
+func (sys *System) aggregateEngagement(
+	ctx context.Context,
+	ids []nostr.ID,
+) (map[nostr.ID]*engagementAggregator, []string) {
+	aggs := make(map[nostr.ID]*engagementAggregator, len(ids))
+	relays := make([]string, 0, 8)
+	for _, id := range ids {
+		aggs[id] = &engagementAggregator{
+			reactors:   make(map[nostr.PubKey]struct{}),
+			reposters:  make(map[nostr.PubKey]struct{}),
+			repliers:   make(map[nostr.PubKey]struct{}),
+			commenters: make(map[nostr.PubKey]struct{}),
+			receipts:   make(map[nostr.ID]struct{}),
+		}
+		for _, url := range sys.GetEventRelays(id) {
+			relays = nostr.AppendUnique(relays, url)
+		}
+	}
+	relays = nostr.AppendUnique(relays, sys.FallbackRelays.Next())
+	relays = nostr.AppendUnique(relays, sys.FallbackRelays.Next())
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	// fetch the actual events so we can dedupe them and sum zap amounts
+	filters := engagementFilters(ids)
+	for _, filter := range filters {
+		for evt := range sys.Store.QueryEvents(filter, 500) {
+			applyEngagementEvent(aggs, evt)
+		}
+	}
+	for _, filter := range filters {
+		wg.Go(func() {
+			for ie := range sys.Pool.FetchMany(ctx, slices.Clone(relays), filter, nostr.SubscriptionOptions{
+				Label: "engagement",
+			}) {
+				mu.Lock()
+				applyEngagementEvent(aggs, ie.Event)
+				mu.Unlock()
+			}
+		})
+	}
+
+	// meanwhile ask for hyperloglog counts, as relays will limit the number of events they return
+	// and these can tell us the full numbers for the cases NIP-45 covers
+	hllCounts := make(map[nostr.ID][2]int, len(ids))
+	for _, id := range ids {
+		wg.Go(func() {
+			var counts [2]int
+			for i, filter := range []nostr.Filter{
+				{Kinds: []nostr.Kind{7}, Tags: nostr.TagMap{"e": []string{id.Hex()}}},
+				{Kinds: []nostr.Kind{1111}, Tags: nostr.TagMap{"E": []string{id.Hex()}}},
+			} {
+				if nip45.HyperLogLogEventPubkeyOffsetForFilter(filter) == -1 {
+					continue
+				}
+				counts[i] = sys.Pool.CountMany(ctx, relays, filter, nostr.SubscriptionOptions{
+					Label: "engagement",
+				})
+			}
+			mu.Lock()
+			hllCounts[id] = counts
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	now := nostr.Now()
+	for id, agg := range aggs {
+		// hyperloglog counts are estimates, so we only use them when they're bigger than what we've seen
+		counts := hllCounts[id]
+		agg.stats.Reactions = max(agg.stats.Reactions, counts[0])
+		if counts[1] > len(agg.commenters) {
+			agg.stats.Replies += counts[1] - len(agg.commenters)
+		}
+		agg.stats.UpdatedAt = now
+		sys.KVStore.Set(makeEngagementKey(id), encodeEngagementStats(agg.stats))
+	}
+
+	return aggs, relays
+}
+
+func engagementFilters(ids []nostr.ID) []nostr.Filter {
+	refs := make([]string, len(ids))
+	for i, id := range ids {
+		refs[i] = id.Hex()
+	}
+
+	return []nostr.Filter{
+		{Kinds: []nostr.Kind{1, 6, 7, 16, 9321, 9735}, Tags: nostr.TagMap{"e": refs}},
+		{Kinds: []nostr.Kind{1111}, Tags: nostr.TagMap{"E": refs}},
+	}
+}
+
+// applyEngagementEvent finds which of the target events evt is about and updates its stats.
+// It returns false when nothing changed (either evt is not related to any of them or it was a duplicate).
+func applyEngagementEvent(aggs map[nostr.ID]*engagementAggregator, evt nostr.Event) (nostr.ID, bool) {
+	switch evt.Kind {
+	case 7:
+		// NIP-25 says the last "e" tag is the target
+		if id, agg := findEngagementTarget(aggs, evt.Tags.FindLast("e")); agg != nil {
+			return id, addToSet(agg.reactors, evt.PubKey, &agg.stats.Reactions)
+		}
+	case 6, 16:
+		if id, agg := findEngagementTarget(aggs, evt.Tags.Find("e")); agg != nil {
+			return id, addToSet(agg.reposters, evt.PubKey, &agg.stats.Reposts)
+		}
+	case 9735:
+		if id, agg := findEngagementTarget(aggs, evt.Tags.Find("e")); agg != nil {
+			if addToSet(agg.receipts, evt.ID, &agg.stats.Zaps) {
+				agg.stats.ZapAmount += nip57.GetAmountFromZap(evt)
+				return id, true
+			}
+		}
+	case 9321:
+		if id, agg := findEngagementTarget(aggs, evt.Tags.Find("e")); agg != nil {
+			if addToSet(agg.receipts, evt.ID, &agg.stats.Nutzaps) {
+				agg.stats.NutzapAmount += nip61.GetAmountFromNutzap(evt)
+				return id, true
+			}
+		}
+	case 1:
+		// any "e" tag that isn't a mention makes this part of the conversation
+		for _, tag := range evt.Tags {
+			if len(tag) < 2 || tag[0] != "e" {
+				continue
+			}
+			if len(tag) >= 4 && tag[3] == "mention" {
+				continue
+			}
+			if id, agg := findEngagementTarget(aggs, tag); agg != nil {
+				return id, addToSet(agg.repliers, evt.PubKey, &agg.stats.Replies)
+			}
+		}
+	case 1111:
+		if id, agg := findEngagementTarget(aggs, evt.Tags.Find("E")); agg != nil {
+			agg.commenters[evt.PubKey] = struct{}{}
+			return id, addToSet(agg.repliers, evt.PubKey, &agg.stats.Replies)
+		}
+	}
+
+	return nostr.ZeroID, false
+}
+
+func findEngagementTarget(aggs map[nostr.ID]*engagementAggregator, tag nostr.Tag) (nostr.ID, *engagementAggregator) {
+	if len(tag) < 2 {
+		return nostr.ZeroID, nil
+	}
+	id, err := nostr.IDFromHex(tag[1])
+	if err != nil {
+		return nostr.ZeroID, nil
+	}
+	return id, aggs[id]
+}
+
+func addToSet[K comparable](set map[K]struct{}, key K, counter *int) bool {
+	if _, exists := set[key]; exists {
+		return false
+	}
+	set[key] = struct{}{}
+	*counter++
+	return true
+}
+
+// makeEngagementKey creates a key for storing engagement stats.
+// It uses the first 8 bytes of the event ID to create a compact key.
+func makeEngagementKey(id nostr.ID) []byte {
+	// format: 'g' + first 8 bytes of event ID
+	key := make([]byte, 9)
+	key[0] = engagementPrefix
+	copy(key[1:], id[:8])
+	return key
+}
+
+// encodeEngagementStats serializes EngagementStats into a fixed-size binary format.
+func encodeEngagementStats(stats EngagementStats) []byte {
+	buf := make([]byte, 40)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(stats.Reactions))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(stats.Reposts))
+	binary.BigEndian.PutUint32(buf[8:12], uint32(stats.Replies))
+	binary.BigEndian.PutUint32(buf[12:16], uint32(stats.Zaps))
+	binary.BigEndian.PutUint64(buf[16:24], stats.ZapAmount)
+	binary.BigEndian.PutUint32(buf[24:28], uint32(stats.Nutzaps))
+	binary.BigEndian.PutUint64(buf[28:36], stats.NutzapAmount)
+	binary.BigEndian.PutUint32(buf[36:40], uint32(stats.UpdatedAt))
+	return buf
+}
+
+// decodeEngagementStats deserializes binary-encoded EngagementStats.
+func decodeEngagementStats(data []byte) (EngagementStats, bool) {
+	if len(data) != 40 {
+		return EngagementStats{}, false
+	}
+	return EngagementStats{
+		Reactions:    int(binary.BigEndian.Uint32(data[0:4])),
+		Reposts:      int(binary.BigEndian.Uint32(data[4:8])),
+		Replies:      int(binary.BigEndian.Uint32(data[8:12])),
+		Zaps:         int(binary.BigEndian.Uint32(data[12:16])),
+		ZapAmount:    binary.BigEndian.Uint64(data[16:24]),
+		Nutzaps:      int(binary.BigEndian.Uint32(data[24:28])),
+		NutzapAmount: binary.BigEndian.Uint64(data[28:36]),
+		UpdatedAt:    nostr.Timestamp(binary.BigEndian.Uint32(data[36:40])),
+	}, true
+}
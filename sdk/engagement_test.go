@@ -0,0 +1,102 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchEngagement(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, _ := newTestSystem(t, url)
+
+	alice := nostr.Generate()
+	bob := nostr.Generate()
+	carol := nostr.Generate()
+
+	publish := func(sk nostr.SecretKey, kind nostr.Kind, content string, tags nostr.Tags) nostr.Event {
+		evt := nostr.Event{Kind: kind, CreatedAt: nostr.Now() - 10, Tags: tags, Content: content}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(evt))
+		return evt
+	}
+
+	note := publish(alice, 1, "hello", nostr.Tags{})
+	other := publish(alice, 1, "bye", nostr.Tags{})
+	ref := note.ID.Hex()
+
+	publish(bob, 7, "+", nostr.Tags{{"e", ref}, {"p", alice.Public().Hex()}})
+	publish(bob, 7, "🤙", nostr.Tags{{"e", ref}, {"p", alice.Public().Hex()}}) // same author
+	publish(carol, 7, "+", nostr.Tags{{"e", other.ID.Hex()}, {"e", ref}})     // last "e" is the target
+	publish(carol, 6, "", nostr.Tags{{"e", ref, url}, {"p", alice.Public().Hex()}})
+	publish(bob, 9735, "", nostr.Tags{{"e", ref}, {"p", alice.Public().Hex()}, {"amount", "21000"}})
+	publish(bob, 9735, "", nostr.Tags{{"e", ref}, {"p", alice.Public().Hex()}, {"amount", "1000"}})
+	publish(bob, 1, "reply", nostr.Tags{{"e", ref, url, "root"}})
+	publish(carol, 1111, "comment", nostr.Tags{{"E", ref}, {"K", "1"}, {"e", ref}, {"k", "1"}})
+	publish(carol, 1, "just mentioning", nostr.Tags{{"e", ref, url, "mention"}})
+
+	stats := sys.FetchEngagement(ctx, []nostr.ID{note.ID, other.ID})
+	require.Equal(t, 2, stats[note.ID].Reactions)
+	require.Equal(t, 1, stats[note.ID].Reposts)
+	require.Equal(t, 2, stats[note.ID].Replies)
+	require.Equal(t, 2, stats[note.ID].Zaps)
+	require.Equal(t, uint64(22000), stats[note.ID].ZapAmount)
+	require.Equal(t, EngagementStats{UpdatedAt: stats[other.ID].UpdatedAt}, stats[other.ID])
+
+	// now this comes from the cache
+	publish(carol, 6, "", nostr.Tags{{"e", other.ID.Hex()}})
+	stats = sys.FetchEngagement(ctx, []nostr.ID{other.ID})
+	require.Equal(t, 0, stats[other.ID].Reposts)
+
+	// live updates
+	liveCtx, liveCancel := context.WithCancel(ctx)
+	defer liveCancel()
+	updates := sys.StreamEngagement(liveCtx, []nostr.ID{note.ID})
+	initial := <-updates
+	require.Equal(t, note.ID, initial.ID)
+	require.Equal(t, 2, initial.Stats.Reactions)
+
+	time.Sleep(200 * time.Millisecond)
+	r, err := sys.Pool.EnsureRelay(url)
+	require.NoError(t, err)
+	for _, sk := range []nostr.SecretKey{bob, alice} {
+		reaction := nostr.Event{Kind: 7, CreatedAt: nostr.Now(), Content: "+", Tags: nostr.Tags{{"e", ref}}}
+		reaction.Sign(sk)
+		require.NoError(t, r.Publish(ctx, reaction))
+	}
+
+	// bob had already reacted so only alice's reaction counts
+	update := <-updates
+	require.Equal(t, 3, update.Stats.Reactions)
+
+	data, _ := sys.KVStore.Get(makeEngagementKey(note.ID))
+	cached, ok := decodeEngagementStats(data)
+	require.True(t, ok)
+	require.Equal(t, 3, cached.Reactions)
+}
+
+func TestEngagementMalformedTags(t *testing.T) {
+	target := nostr.ID{1}
+	aggs := map[nostr.ID]*engagementAggregator{
+		target: {
+			reactors:   make(map[nostr.PubKey]struct{}),
+			reposters:  make(map[nostr.PubKey]struct{}),
+			repliers:   make(map[nostr.PubKey]struct{}),
+			commenters: make(map[nostr.PubKey]struct{}),
+			receipts:   make(map[nostr.ID]struct{}),
+		},
+	}
+
+	reply := nostr.Event{Kind: 1, Tags: nostr.Tags{{}, {"e"}, {"e", target.Hex()}}}
+	id, changed := applyEngagementEvent(aggs, reply)
+	require.True(t, changed)
+	require.Equal(t, target, id)
+	require.Equal(t, 1, aggs[target].stats.Replies)
+}
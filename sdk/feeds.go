@@ -81,6 +81,10 @@ func (sys *System) StreamLiveFeed(
 					sys.KVStore.Set(oldestKey, encodeTimestamp(oldest))
 				}
 
+				if sys.MuteFilter.hides(evt.Event) {
+					continue
+				}
+
 				events <- evt.Event
 			}
 
@@ -130,6 +134,9 @@ func (sys *System) FetchFeedPage(
 
 			count := 0
 			for evt := range sys.Store.QueryEvents(filter, limitPerKey) {
+				if sys.MuteFilter.hides(evt) {
+					continue
+				}
 				events = append(events, evt)
 				count++
 				if count >= limitPerKey {
@@ -164,7 +171,7 @@ func (sys *System) FetchFeedPage(
 			// we should check this because we might be just catching up to the point where the
 			// offset that was requested.
 			// so we don't add these events to our results, just to our local store (above)
-			if ie.Event.CreatedAt < until {
+			if ie.Event.CreatedAt < until && !sys.MuteFilter.hides(ie.Event) {
 				events = append(events, ie.Event)
 			}
 		}
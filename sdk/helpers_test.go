@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"fiatjaf.com/nostr/khatru"
+)
+
+// testRelay is a khatru relay backed by a slicestore that is served until the test ends.
+type testRelay struct {
+	*khatru.Relay
+	DB     *slicestore.SliceStore
+	URL    string
+	server *httptest.Server
+}
+
+// Close stops serving the relay before the test ends.
+func (tr testRelay) Close() { tr.server.Close() }
+
+func startTestRelay(t *testing.T) testRelay {
+	relay := khatru.NewRelay()
+	db := &slicestore.SliceStore{}
+	db.Init()
+	relay.UseEventstore(db, 500)
+	server := httptest.NewServer(relay)
+	t.Cleanup(server.Close)
+	return testRelay{relay, db, "ws" + server.URL[4:], server}
+}
+
+// newTestSystem makes a System that saves events to a local slicestore and, if a relay url is given,
+// uses that relay instead of all the default ones.
+func newTestSystem(t *testing.T, url string) (*System, *slicestore.SliceStore) {
+	sys := NewSystem()
+	t.Cleanup(sys.Close)
+	if url != "" {
+		sys.FallbackRelays = NewRelayStream(url)
+		sys.JustIDRelays = NewRelayStream(url)
+		sys.RelayListRelays = NewRelayStream(url)
+		sys.FollowListRelays = NewRelayStream(url)
+		sys.MetadataRelays = NewRelayStream(url)
+	}
+	local := &slicestore.SliceStore{}
+	local.Init()
+	sys.Store = local
+	sys.Publisher.Store = local
+	return sys, local
+}
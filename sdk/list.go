@@ -15,6 +15,12 @@ type GenericList[V comparable, I TagItemWithValue[V]] struct {
 	Event  *nostr.Event `json:"-"` // may be empty if a contact list event wasn't found
 
 	Items []I
+
+	// PrivateItems are the ones encrypted in the event content, they're only available after
+	// DecryptPrivateItems is called with the keyer of the list owner.
+	PrivateItems []I
+
+	parseTag func(nostr.Tag) (I, bool)
 }
 
 type TagItemWithValue[V comparable] interface {
@@ -55,7 +61,7 @@ func fetchGenericList[V comparable, I TagItemWithValue[V]](
 		return v, true
 	}
 
-	v := GenericList[V, I]{PubKey: pubkey}
+	v := GenericList[V, I]{PubKey: pubkey, parseTag: parseTag}
 
 	for evt := range sys.Store.QueryEvents(nostr.Filter{
 		Kinds:   []nostr.Kind{actualKind},
@@ -117,9 +123,10 @@ func tryFetchListFromNetwork[V comparable, I TagItemWithValue[V]](
 	}
 
 	v := &GenericList[V, I]{
-		PubKey: pubkey,
-		Event:  &evt,
-		Items:  parseItemsFromEventTags(evt, parseTag),
+		PubKey:   pubkey,
+		Event:    &evt,
+		Items:    parseItemsFromEventTags(evt, parseTag),
+		parseTag: parseTag,
 	}
 	sys.Publisher.Publish(ctx, evt)
 
@@ -0,0 +1,209 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"fiatjaf.com/nostr"
+)
+
+// ListMutationQuorum is the number of relays that must answer when the latest version of a replaceable
+// event is fetched right before it is modified by Follow, Unfollow, AddBookmark, AddRelay, AddToList,
+// RemoveFromList or UpdateProfile.
+// If fewer relays than that are queried then all of them must answer.
+var ListMutationQuorum = 2
+
+// ErrQuorumNotReached is returned by the list mutation helpers when not enough relays answered, in which
+// case it's not safe to assume we have the latest version of the list and nothing is published.
+var ErrQuorumNotReached = errors.New("not enough relays answered")
+
+// Follow adds target to the follow list (kind:3) of the signer.
+func (sys *System) Follow(ctx context.Context, signer nostr.Signer, target nostr.PubKey) (nostr.Event, error) {
+	return sys.mutateReplaceable(ctx, signer, 3, "", nil, func(evt *nostr.Event) bool {
+		tag := nostr.Tag{"p", target.Hex()}
+		if evt.Tags.FindWithValue("p", tag[1]) != nil {
+			return false
+		}
+		evt.Tags = append(evt.Tags, tag)
+		return true
+	})
+}
+
+// Unfollow removes target from the follow list (kind:3) of the signer.
+func (sys *System) Unfollow(ctx context.Context, signer nostr.Signer, target nostr.PubKey) (nostr.Event, error) {
+	return sys.mutateReplaceable(ctx, signer, 3, "", nil, func(evt *nostr.Event) bool {
+		n := len(evt.Tags)
+		evt.Tags = removeTagsLike(evt.Tags, nostr.Tag{"p", target.Hex()})
+		return len(evt.Tags) != n
+	})
+}
+
+// AddBookmark adds an event or an addressable event to the public bookmark list (kind:10003) of the signer.
+func (sys *System) AddBookmark(ctx context.Context, signer nostr.Signer, pointer nostr.Pointer) (nostr.Event, error) {
+	tag := pointer.AsTag()
+	return sys.mutateReplaceable(ctx, signer, 10003, "", nil, func(evt *nostr.Event) bool {
+		if evt.Tags.FindWithValue(tag[0], tag[1]) != nil {
+			return false
+		}
+		evt.Tags = append(evt.Tags, tag)
+		return true
+	})
+}
+
+// AddRelay adds a relay to the relay list (kind:10002) of the signer, or changes its read/write markers
+// if it was already there. At least one of read and write must be true.
+//
+// The updated list is also published to the relay itself if it is a write relay.
+func (sys *System) AddRelay(ctx context.Context, signer nostr.Signer, url string, read bool, write bool) (nostr.Event, error) {
+	if !read && !write {
+		return nostr.Event{}, fmt.Errorf("relay must be either read or write")
+	}
+	if !nostr.IsValidRelayURL(url) {
+		return nostr.Event{}, fmt.Errorf("invalid relay url '%s'", url)
+	}
+	url = nostr.NormalizeURL(url)
+
+	tag := nostr.Tag{"r", url}
+	if !write {
+		tag = append(tag, "read")
+	} else if !read {
+		tag = append(tag, "write")
+	}
+
+	var extra []string
+	if write {
+		extra = []string{url}
+	}
+
+	return sys.mutateReplaceable(ctx, signer, 10002, "", extra, func(evt *nostr.Event) bool {
+		idx := slices.IndexFunc(evt.Tags, func(t nostr.Tag) bool {
+			return len(t) >= 2 && t[0] == "r" && nostr.NormalizeURL(t[1]) == url
+		})
+		if idx != -1 && slices.Equal(evt.Tags[idx], tag) {
+			return false
+		}
+		evt.Tags = slices.DeleteFunc(evt.Tags, func(t nostr.Tag) bool {
+			return len(t) >= 2 && t[0] == "r" && nostr.NormalizeURL(t[1]) == url
+		})
+		evt.Tags = append(evt.Tags, tag)
+		return true
+	})
+}
+
+// mutateReplaceable does a read-modify-write on a replaceable event of the signer (or on the addressable
+// event with the given identifier): it refreshes the event from the user's write relays, refusing to go on
+// if less than ListMutationQuorum of them answer, then applies modify to a copy of it, signs and publishes
+// the result to the write relays (plus extraRelays). modify gets the previous tags and content (which may
+// contain encrypted entries) and should keep whatever it doesn't know about.
+//
+// If modify reports that nothing has changed the current event (if any) is returned and nothing is published.
+func (sys *System) mutateReplaceable(
+	ctx context.Context,
+	signer nostr.Signer,
+	kind nostr.Kind,
+	identifier string,
+	extraRelays []string,
+	modify func(evt *nostr.Event) bool,
+) (nostr.Event, error) {
+	pubkey, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to get public key: %w", err)
+	}
+
+	prev, err := sys.refreshReplaceable(ctx, pubkey, kind, identifier)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	evt := nostr.Event{Kind: kind, CreatedAt: nostr.Now(), PubKey: pubkey}
+	if prev != nil {
+		evt.Tags = slices.Clone(prev.Tags)
+		evt.Content = prev.Content
+		if evt.CreatedAt <= prev.CreatedAt {
+			evt.CreatedAt = prev.CreatedAt + 1
+		}
+	} else if kind.IsAddressable() {
+		evt.Tags = nostr.Tags{{"d", identifier}}
+	}
+
+	if changed := modify(&evt); !changed {
+		if prev == nil {
+			return nostr.Event{}, nil
+		}
+		return *prev, nil
+	}
+
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	if err := sys.Publisher.Publish(ctx, evt); err != nil {
+		return evt, fmt.Errorf("failed to save: %w", err)
+	}
+	sys.invalidateListCache(kind, pubkey)
+
+	relays := append(sys.FetchWriteRelays(ctx, pubkey), extraRelays...)
+	if len(relays) == 0 {
+		relays = sys.FetchOutboxRelays(ctx, pubkey, 3)
+	}
+	slices.Sort(relays)
+	relays = slices.Compact(relays)
+
+	var lastErr error
+	success := false
+	for res := range sys.Pool.PublishMany(ctx, relays, evt) {
+		if res.Error == nil {
+			success = true
+		} else {
+			lastErr = res.Error
+		}
+	}
+	if !success && lastErr != nil {
+		return evt, fmt.Errorf("failed to publish to any relay: %w", lastErr)
+	}
+
+	return evt, nil
+}
+
+// refreshReplaceable fetches the latest version of a replaceable event (or of the addressable event with
+// the given identifier) through the forced variant of the replaceable loader, which bypasses all caches
+// and the local store throttling and fails unless enough of the user's write relays answered. The newest
+// event found (locally or remotely) is saved and returned.
+func (sys *System) refreshReplaceable(
+	ctx context.Context,
+	pubkey nostr.PubKey,
+	kind nostr.Kind,
+	identifier string,
+) (*nostr.Event, error) {
+	filter := nostr.Filter{Kinds: []nostr.Kind{kind}, Authors: []nostr.PubKey{pubkey}}
+	if kind.IsAddressable() {
+		filter.Tags = nostr.TagMap{"d": []string{identifier}}
+	} else {
+		identifier = ""
+	}
+
+	var latest *nostr.Event
+	for evt := range sys.Store.QueryEvents(filter, 1) {
+		latest = &evt
+	}
+
+	evt, err := sys.replaceableRefresher.Load(ctx, refreshKey{Kind: kind, PubKey: pubkey, D: identifier})
+	if err != nil {
+		return nil, err
+	}
+	if evt.ID != nostr.ZeroID && (latest == nil || evt.CreatedAt > latest.CreatedAt) {
+		latest = &evt
+	}
+
+	if latest != nil {
+		sys.Store.ReplaceEvent(*latest)
+		if kind.IsReplaceable() {
+			sys.KVStore.Set(makeLastFetchKey(kind, pubkey), encodeTimestamp(nostr.Now()))
+		}
+		sys.invalidateListCache(kind, pubkey)
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMutations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, local := newTestSystem(t, url)
+
+	sk := nostr.Generate()
+	signer := keyer.NewPlainKeySigner(sk)
+	rl := nostr.Event{Kind: 10002, CreatedAt: nostr.Now() - 10, Tags: nostr.Tags{{"r", url}}}
+	rl.Sign(sk)
+	require.NoError(t, db.SaveEvent(rl))
+
+	alice := nostr.Generate().Public()
+	bob := nostr.Generate().Public()
+	carol := nostr.Generate().Public()
+
+	// our local copy is stale, the relay has a newer follow list
+	stale := nostr.Event{Kind: 3, CreatedAt: nostr.Now() - 100, Tags: nostr.Tags{{"p", alice.Hex()}}}
+	stale.Sign(sk)
+	require.NoError(t, local.SaveEvent(stale))
+	newer := nostr.Event{Kind: 3, CreatedAt: nostr.Now() - 50, Content: "x", Tags: nostr.Tags{{"p", alice.Hex()}, {"p", bob.Hex()}}}
+	newer.Sign(sk)
+	require.NoError(t, db.SaveEvent(newer))
+
+	evt, err := sys.Follow(ctx, signer, carol)
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{{"p", alice.Hex()}, {"p", bob.Hex()}, {"p", carol.Hex()}}, evt.Tags)
+	require.Equal(t, "x", evt.Content)
+
+	// following again doesn't publish anything
+	again, err := sys.Follow(ctx, signer, carol)
+	require.NoError(t, err)
+	require.Equal(t, evt.ID, again.ID)
+
+	evt, err = sys.Unfollow(ctx, signer, alice)
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{{"p", bob.Hex()}, {"p", carol.Hex()}}, evt.Tags)
+	for stored := range db.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{3}}, 1) {
+		require.Equal(t, evt.ID, stored.ID)
+	}
+	require.Len(t, sys.FetchFollowList(ctx, sk.Public()).Items, 2)
+
+	target := nostr.EventPointer{ID: nostr.ID{1, 2, 3}}
+	evt, err = sys.AddBookmark(ctx, signer, target)
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{target.AsTag()}, evt.Tags)
+
+	evt, err = sys.AddRelay(ctx, signer, "wss://other.example.com", true, false)
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{{"r", url}, {"r", "wss://other.example.com", "read"}}, evt.Tags)
+
+	// a relay that doesn't answer makes the quorum fail and nothing is published
+	other := nostr.Generate()
+	orl := nostr.Event{Kind: 10002, CreatedAt: nostr.Now() - 10, Tags: nostr.Tags{{"r", url}, {"r", "ws://127.0.0.1:1"}}}
+	orl.Sign(other)
+	require.NoError(t, db.SaveEvent(orl))
+	_, err = sys.Follow(ctx, keyer.NewPlainKeySigner(other), alice)
+	require.True(t, errors.Is(err, ErrQuorumNotReached))
+	for range db.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{3}, Authors: []nostr.PubKey{other.Public()}}, 1) {
+		t.Fatal("should not have published")
+	}
+}
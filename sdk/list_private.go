@@ -0,0 +1,236 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// DecryptPrivateItems decrypts the entries stored in the content of the list event and puts them in
+// PrivateItems. kr must be the keyer of the list owner.
+func (gl *GenericList[V, I]) DecryptPrivateItems(ctx context.Context, kr nostr.Keyer) error {
+	if gl.Event == nil || gl.parseTag == nil {
+		return nil
+	}
+
+	tags, err := decryptPrivateTags(ctx, kr, *gl.Event)
+	if err != nil {
+		return err
+	}
+	gl.PrivateItems = parseItemsFromEventTags(nostr.Event{Tags: tags}, gl.parseTag)
+	return nil
+}
+
+// DecryptPrivateSets decrypts the entries stored in the content of each set event and puts them in
+// PrivateSets. kr must be the keyer of the sets owner.
+func (gs *GenericSets[V, I]) DecryptPrivateSets(ctx context.Context, kr nostr.Keyer) error {
+	if gs.parseTag == nil {
+		return nil
+	}
+
+	gs.PrivateSets = make(map[string][]I, len(gs.Events))
+	for _, evt := range gs.Events {
+		tags, err := decryptPrivateTags(ctx, kr, evt)
+		if err != nil {
+			return fmt.Errorf("set '%s': %w", evt.Tags.GetD(), err)
+		}
+		gs.PrivateSets[evt.Tags.GetD()] = parseItemsFromEventTags(nostr.Event{Tags: tags}, gs.parseTag)
+	}
+	return nil
+}
+
+// decryptPrivateTags returns the tags encrypted in the content of a NIP-51 list, which are encrypted by
+// the author to themselves. Legacy NIP-04 contents are only supported if kr implements nostr.NIP04Decrypter.
+func decryptPrivateTags(ctx context.Context, kr nostr.Keyer, evt nostr.Event) (nostr.Tags, error) {
+	if evt.Content == "" {
+		return nil, nil
+	}
+
+	var plaintext string
+	var err error
+	if strings.Contains(evt.Content, "?iv=") {
+		legacy, ok := kr.(nostr.NIP04Decrypter)
+		if !ok {
+			return nil, fmt.Errorf("list content is NIP-04 encrypted and the keyer doesn't support that")
+		}
+		plaintext, err = legacy.NIP04Decrypt(ctx, evt.Content, evt.PubKey)
+	} else {
+		plaintext, err = kr.Decrypt(ctx, evt.Content, evt.PubKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private entries: %w", err)
+	}
+
+	var tags nostr.Tags
+	if err := json.Unmarshal([]byte(plaintext), &tags); err != nil {
+		return nil, fmt.Errorf("invalid private entries: %w", err)
+	}
+	return tags, nil
+}
+
+// encryptPrivateTags is the inverse of decryptPrivateTags, but always uses NIP-44.
+func encryptPrivateTags(ctx context.Context, kr nostr.Keyer, pubkey nostr.PubKey, tags nostr.Tags) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	plaintext, err := json.Marshal(tags)
+	if err != nil {
+		return "", err
+	}
+	return kr.Encrypt(ctx, string(plaintext), pubkey)
+}
+
+// AddToList adds a tag to the user's list of the given kind (or, for addressable kinds, to the set with the
+// given identifier), either to its public tags or to its encrypted private part. If an item with the same
+// tag name and value already exists in any of these it is replaced.
+//
+// The list is modified with the same safety checks of Follow and friends, then signed with kr, saved with
+// sys.Publisher and published to the user's write relays.
+func (sys *System) AddToList(
+	ctx context.Context,
+	kr nostr.Keyer,
+	kind nostr.Kind,
+	identifier string,
+	tag nostr.Tag,
+	private bool,
+) (nostr.Event, error) {
+	if len(tag) < 2 {
+		return nostr.Event{}, fmt.Errorf("invalid tag %v", tag)
+	}
+
+	return sys.editList(ctx, kr, kind, identifier, func(public nostr.Tags, secret nostr.Tags) (nostr.Tags, nostr.Tags, bool) {
+		public = removeTagsLike(public, tag)
+		secret = removeTagsLike(secret, tag)
+		if private {
+			secret = append(secret, tag)
+		} else {
+			public = append(public, tag)
+		}
+		return public, secret, true
+	})
+}
+
+// RemoveFromList removes all items with the same tag name and value as the given tag from both the public
+// and the private parts of the user's list of the given kind (or the set with the given identifier).
+//
+// The list is modified with the same safety checks of Follow and friends, then signed with kr, saved with
+// sys.Publisher and published to the user's write relays.
+func (sys *System) RemoveFromList(
+	ctx context.Context,
+	kr nostr.Keyer,
+	kind nostr.Kind,
+	identifier string,
+	tag nostr.Tag,
+) (nostr.Event, error) {
+	if len(tag) < 2 {
+		return nostr.Event{}, fmt.Errorf("invalid tag %v", tag)
+	}
+
+	return sys.editList(ctx, kr, kind, identifier, func(public nostr.Tags, secret nostr.Tags) (nostr.Tags, nostr.Tags, bool) {
+		n := len(public) + len(secret)
+		public = removeTagsLike(public, tag)
+		secret = removeTagsLike(secret, tag)
+		return public, secret, len(public)+len(secret) != n
+	})
+}
+
+// editList runs modify on the public and decrypted private tags of a list through mutateReplaceable.
+func (sys *System) editList(
+	ctx context.Context,
+	kr nostr.Keyer,
+	kind nostr.Kind,
+	identifier string,
+	modify func(public nostr.Tags, private nostr.Tags) (nostr.Tags, nostr.Tags, bool),
+) (nostr.Event, error) {
+	if !kind.IsReplaceable() && !kind.IsAddressable() {
+		return nostr.Event{}, fmt.Errorf("%s is not a list", kind)
+	}
+
+	var modifyErr error
+	evt, err := sys.mutateReplaceable(ctx, kr, kind, identifier, nil, func(evt *nostr.Event) bool {
+		private, err := decryptPrivateTags(ctx, kr, *evt)
+		if err != nil {
+			// we can't risk losing the private entries
+			modifyErr = err
+			return false
+		}
+
+		public, private, changed := modify(evt.Tags, private)
+		if !changed {
+			return false
+		}
+
+		evt.Tags = public
+		evt.Content, err = encryptPrivateTags(ctx, kr, evt.PubKey, private)
+		if err != nil {
+			modifyErr = fmt.Errorf("failed to encrypt private entries: %w", err)
+			return false
+		}
+		return true
+	})
+	if modifyErr != nil {
+		return nostr.Event{}, modifyErr
+	}
+	return evt, err
+}
+
+// removeTagsLike removes all tags with the same name and value as the given tag.
+func removeTagsLike(tags nostr.Tags, tag nostr.Tag) nostr.Tags {
+	return slices.DeleteFunc(tags, func(t nostr.Tag) bool {
+		return len(t) >= 2 && t[0] == tag[0] && t[1] == tag[1]
+	})
+}
+
+func (sys *System) invalidateListCache(kind nostr.Kind, pubkey nostr.PubKey) {
+	switch kind {
+	case 0:
+		sys.MetadataCache.Delete(pubkey)
+	case 3:
+		if sys.FollowListCache != nil {
+			sys.FollowListCache.Delete(pubkey)
+		}
+	case 10000:
+		if sys.MuteListCache != nil {
+			sys.MuteListCache.Delete(pubkey)
+		}
+	case 10001:
+		if sys.PinListCache != nil {
+			sys.PinListCache.Delete(pubkey)
+		}
+	case 10002:
+		sys.RelayListCache.Delete(pubkey)
+	case 10003:
+		if sys.BookmarkListCache != nil {
+			sys.BookmarkListCache.Delete(pubkey)
+		}
+	case 10006:
+		if sys.BlockedRelayListCache != nil {
+			sys.BlockedRelayListCache.Delete(pubkey)
+		}
+	case 10007:
+		if sys.SearchRelayListCache != nil {
+			sys.SearchRelayListCache.Delete(pubkey)
+		}
+	case 10015:
+		if sys.TopicListCache != nil {
+			sys.TopicListCache.Delete(pubkey)
+		}
+	case 30000:
+		if sys.FollowSetsCache != nil {
+			sys.FollowSetsCache.Delete(pubkey)
+		}
+	case 30002:
+		if sys.RelaySetsCache != nil {
+			sys.RelaySetsCache.Delete(pubkey)
+		}
+	case 30015:
+		if sys.TopicSetsCache != nil {
+			sys.TopicSetsCache.Delete(pubkey)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"fiatjaf.com/nostr/nip04"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivateListItems(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, _ := newTestSystem(t, url)
+
+	sk := nostr.Generate()
+	kr := keyer.NewPlainKeySigner(sk)
+	rl := nostr.Event{Kind: 10002, CreatedAt: nostr.Now() - 10, Tags: nostr.Tags{{"r", url}}}
+	rl.Sign(sk)
+	require.NoError(t, db.SaveEvent(rl))
+
+	publicID := nostr.Generate().Public().Hex() // any 32-byte hex will do
+	privateID := nostr.Generate().Public().Hex()
+
+	_, err := sys.AddToList(ctx, kr, 10003, "", nostr.Tag{"e", publicID}, false)
+	require.NoError(t, err)
+	evt, err := sys.AddToList(ctx, kr, 10003, "", nostr.Tag{"e", privateID}, true)
+	require.NoError(t, err)
+	require.NotEmpty(t, evt.Content)
+	require.NotContains(t, evt.Content, privateID)
+	require.Len(t, evt.Tags, 1)
+
+	// it was published to the relay too
+	for stored := range db.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{10003}}, 1) {
+		require.Equal(t, evt.ID, stored.ID)
+	}
+
+	bl := sys.FetchBookmarkList(ctx, sk.Public())
+	require.Len(t, bl.Items, 1)
+	require.Equal(t, publicID, bl.Items[0].Value())
+	require.Empty(t, bl.PrivateItems)
+	require.NoError(t, bl.DecryptPrivateItems(ctx, kr))
+	require.Len(t, bl.PrivateItems, 1)
+	require.Equal(t, privateID, bl.PrivateItems[0].Value())
+
+	// making it public moves it out of the private part
+	evt, err = sys.AddToList(ctx, kr, 10003, "", nostr.Tag{"e", privateID}, false)
+	require.NoError(t, err)
+	require.Empty(t, evt.Content)
+	require.Len(t, evt.Tags, 2)
+
+	evt, err = sys.RemoveFromList(ctx, kr, 10003, "", nostr.Tag{"e", publicID})
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{{"e", privateID}}, evt.Tags)
+
+	// sets
+	friend := nostr.Generate().Public()
+	_, err = sys.AddToList(ctx, kr, 30000, "friends", nostr.Tag{"p", friend.Hex()}, true)
+	require.NoError(t, err)
+	fs := sys.FetchFollowSets(ctx, sk.Public())
+	require.Empty(t, fs.Sets["friends"])
+	require.NoError(t, fs.DecryptPrivateSets(ctx, kr))
+	require.Len(t, fs.PrivateSets["friends"], 1)
+	require.Equal(t, friend, fs.PrivateSets["friends"][0].Value())
+
+	// legacy nip04 contents
+	key, err := nip04.ComputeSharedSecret(sk.Public(), sk)
+	require.NoError(t, err)
+	content, err := nip04.Encrypt(`[["p","`+friend.Hex()+`"]]`, key)
+	require.NoError(t, err)
+	legacy := nostr.Event{Kind: 10000, CreatedAt: nostr.Now(), Content: content}
+	legacy.Sign(sk)
+	tags, err := decryptPrivateTags(ctx, kr, legacy)
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{{"p", friend.Hex()}}, tags)
+
+	// if we can't be sure we have the current list nothing is published, instead of wiping it
+	other := nostr.Generate()
+	orl := nostr.Event{Kind: 10002, CreatedAt: nostr.Now() - 10, Tags: nostr.Tags{{"r", url}, {"r", "ws://127.0.0.1:1"}}}
+	orl.Sign(other)
+	require.NoError(t, db.SaveEvent(orl))
+	_, err = sys.AddToList(ctx, keyer.NewPlainKeySigner(other), 10000, "", nostr.Tag{"p", friend.Hex()}, true)
+	require.ErrorIs(t, err, ErrQuorumNotReached)
+	for range db.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{10000}, Authors: []nostr.PubKey{other.Public()}}, 1) {
+		t.Fatal("should not have published")
+	}
+}
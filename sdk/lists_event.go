@@ -50,5 +50,5 @@ func parseEventRef(tag nostr.Tag) (evr EventRef, ok bool) {
 		return evr, false
 	}
 
-	return evr, false
+	return evr, true
 }
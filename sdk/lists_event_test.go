@@ -0,0 +1,41 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRefLists(t *testing.T) {
+	ctx := context.Background()
+
+	sys, local := newTestSystem(t, "")
+
+	sk := nostr.Generate()
+	id := nostr.Generate().Public().Hex()
+	address := "30023:" + sk.Public().Hex() + ":banana"
+
+	for _, kind := range []nostr.Kind{10001, 10003} {
+		evt := nostr.Event{
+			Kind:      kind,
+			CreatedAt: nostr.Now(),
+			Tags:      nostr.Tags{{"e", id}, {"a", address}, {"t", "ignored"}},
+		}
+		evt.Sign(sk)
+		require.NoError(t, local.SaveEvent(evt))
+
+		// pretend we've just fetched these so we don't go to the network
+		sys.KVStore.Set(makeLastFetchKey(kind, sk.Public()), encodeTimestamp(nostr.Now()))
+	}
+
+	for _, list := range []GenericList[string, EventRef]{
+		sys.FetchBookmarkList(ctx, sk.Public()),
+		sys.FetchPinList(ctx, sk.Public()),
+	} {
+		require.Len(t, list.Items, 2)
+		require.Equal(t, id, list.Items[0].Value())
+		require.Equal(t, address, list.Items[1].Value())
+	}
+}
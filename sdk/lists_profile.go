@@ -17,11 +17,17 @@ type ProfileRef struct {
 
 func (f ProfileRef) Value() nostr.PubKey { return f.Pubkey }
 
+// FetchFollowList fetches the kind 3 follow list of the given key, or, if System.FollowSuccession is
+// enabled, of the key that currently replaces it.
 func (sys *System) FetchFollowList(ctx context.Context, pubkey nostr.PubKey) GenericList[nostr.PubKey, ProfileRef] {
 	if sys.FollowListCache == nil {
 		sys.FollowListCache = cache_memory.New[GenericList[nostr.PubKey, ProfileRef]](1000)
 	}
 
+	if sys.FollowSuccession {
+		pubkey, _ = sys.ResolveSuccession(ctx, pubkey)
+	}
+
 	fl, _ := fetchGenericList(sys, ctx, pubkey, 3, kind_3, parseProfileRef, sys.FollowListCache)
 	return fl
 }
@@ -10,6 +10,7 @@ import (
 	"fiatjaf.com/nostr/nip05"
 	"fiatjaf.com/nostr/nip19"
 	"fiatjaf.com/nostr/sdk/hints"
+	"fiatjaf.com/nostr/succession"
 )
 
 // ProfileMetadata represents user profile information from kind 0 events.
@@ -28,6 +29,16 @@ type ProfileMetadata struct {
 	NIP05       string `json:"nip05,omitempty"`
 	LUD16       string `json:"lud16,omitempty"`
 
+	// Identities are the NIP-39 external identity claims found in the event "i" tags, they're not verified.
+	Identities []IdentityClaim `json:"-"`
+
+	// Extra holds all the other fields found in the event content, which are kept when the profile is updated.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	// Succession is set when System.FollowSuccession is enabled and the requested key was superseded, in
+	// which case PubKey is the current key and these are the attestations that lead to it.
+	Succession []succession.Attestation `json:"-"`
+
 	nip05Valid       bool
 	nip05LastAttempt time.Time
 }
@@ -104,7 +115,18 @@ func (sys System) FetchProfileFromInput(ctx context.Context, nip19OrNip05Code st
 // FetchProfileMetadata fetches metadata for a given user from the local cache, or from the local store,
 // or, failing these, from the target user's defined outbox relays -- then caches the result.
 // It always returns a ProfileMetadata, even if no metadata was found (in which case only the PubKey field is set).
-func (sys *System) FetchProfileMetadata(ctx context.Context, pubkey nostr.PubKey) (pm ProfileMetadata) {
+func (sys *System) FetchProfileMetadata(ctx context.Context, pubkey nostr.PubKey) ProfileMetadata {
+	if sys.FollowSuccession {
+		if current, chain := sys.ResolveSuccession(ctx, pubkey); len(chain) > 0 {
+			pm := sys.fetchProfileMetadata(ctx, current)
+			pm.Succession = chain
+			return pm
+		}
+	}
+	return sys.fetchProfileMetadata(ctx, pubkey)
+}
+
+func (sys *System) fetchProfileMetadata(ctx context.Context, pubkey nostr.PubKey) (pm ProfileMetadata) {
 	if v, ok := sys.MetadataCache.Get(pubkey); ok {
 		return v
 	}
@@ -186,7 +208,24 @@ func ParseMetadata(event nostr.Event) (meta ProfileMetadata, err error) {
 		err = fmt.Errorf("failed to parse metadata (%s) from event %s: %w", cont, event.ID, er)
 	}
 
+	if err == nil {
+		var all map[string]json.RawMessage
+		if er := json.Unmarshal([]byte(event.Content), &all); er != nil {
+			err = fmt.Errorf("failed to parse extra metadata fields from event %s: %w", event.ID, er)
+		} else {
+			for _, key := range knownMetadataFields {
+				delete(all, key)
+			}
+			if len(all) > 0 {
+				meta.Extra = all
+			}
+		}
+	}
+
+	meta.Identities = ParseIdentityClaims(event.Tags)
 	meta.PubKey = event.PubKey
 	meta.Event = &event
 	return meta, err
 }
+
+var knownMetadataFields = []string{"name", "display_name", "about", "website", "picture", "banner", "nip05", "lud16"}
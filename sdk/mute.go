@@ -0,0 +1,151 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// MuteReason says why an event was hidden by a MuteFilter.
+type MuteReason struct {
+	Type  string // "pubkey", "hashtag", "word" or "thread"
+	Value string
+}
+
+func (mr MuteReason) String() string { return mr.Type + ":" + mr.Value }
+
+// MuteFilter is compiled from all the entries of a NIP-51 mute list (kind:10000), both the public
+// ones in the tags and the private ones encrypted in the content.
+//
+// When set as sys.MuteFilter it is applied to StreamLiveFeed, FetchFeedPage, StreamNotifications and
+// SearchUsers.
+type MuteFilter struct {
+	Pubkeys  map[nostr.PubKey]struct{}
+	Hashtags map[string]struct{} // lowercased
+	Words    []string            // lowercased
+	Threads  map[nostr.ID]struct{}
+
+	// OnHidden, if set, is called for every event that gets hidden when this filter is applied by the System.
+	OnHidden func(evt nostr.Event, reason MuteReason)
+}
+
+// NewMuteFilter compiles a MuteFilter from a list of mute list tags ("p", "t", "word" and "e").
+func NewMuteFilter(tags nostr.Tags) *MuteFilter {
+	mf := &MuteFilter{
+		Pubkeys:  make(map[nostr.PubKey]struct{}),
+		Hashtags: make(map[string]struct{}),
+		Words:    make([]string, 0, 4),
+		Threads:  make(map[nostr.ID]struct{}),
+	}
+	mf.add(tags)
+	return mf
+}
+
+func (mf *MuteFilter) add(tags nostr.Tags) {
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[1] == "" {
+			continue
+		}
+		switch tag[0] {
+		case "p":
+			if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+				mf.Pubkeys[pk] = struct{}{}
+			}
+		case "t":
+			mf.Hashtags[strings.ToLower(tag[1])] = struct{}{}
+		case "word":
+			mf.Words = append(mf.Words, strings.ToLower(tag[1]))
+		case "e":
+			if id, err := nostr.IDFromHex(tag[1]); err == nil {
+				mf.Threads[id] = struct{}{}
+			}
+		}
+	}
+}
+
+// Match returns true and a reason if the event should be hidden.
+func (mf *MuteFilter) Match(evt nostr.Event) (MuteReason, bool) {
+	if _, ok := mf.Pubkeys[evt.PubKey]; ok {
+		return MuteReason{"pubkey", evt.PubKey.Hex()}, true
+	}
+
+	if _, ok := mf.Threads[evt.ID]; ok {
+		return MuteReason{"thread", evt.ID.Hex()}, true
+	}
+
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "t":
+			if _, ok := mf.Hashtags[strings.ToLower(tag[1])]; ok {
+				return MuteReason{"hashtag", tag[1]}, true
+			}
+		case "e", "E":
+			if id, err := nostr.IDFromHex(tag[1]); err == nil {
+				if _, ok := mf.Threads[id]; ok {
+					return MuteReason{"thread", tag[1]}, true
+				}
+			}
+		}
+	}
+
+	if len(mf.Words) > 0 {
+		content := strings.ToLower(GetMainContent(evt))
+		for _, word := range mf.Words {
+			if strings.Contains(content, word) {
+				return MuteReason{"word", word}, true
+			}
+		}
+	}
+
+	return MuteReason{}, false
+}
+
+// hides is what the System calls: it is nil-safe and calls OnHidden.
+func (mf *MuteFilter) hides(evt nostr.Event) bool {
+	if mf == nil {
+		return false
+	}
+	reason, muted := mf.Match(evt)
+	if muted && mf.OnHidden != nil {
+		mf.OnHidden(evt, reason)
+	}
+	return muted
+}
+
+// hidesActor is like hides, but for when the relevant pubkey isn't the event author (like in zap receipts).
+func (mf *MuteFilter) hidesActor(evt nostr.Event, actor nostr.PubKey) bool {
+	if mf == nil {
+		return false
+	}
+	_, muted := mf.Pubkeys[actor]
+	if muted && mf.OnHidden != nil {
+		mf.OnHidden(evt, MuteReason{"pubkey", actor.Hex()})
+	}
+	return muted
+}
+
+// LoadMuteFilter fetches the user's mute list and compiles it into a MuteFilter.
+//
+// If kr is given it is used to decrypt the private entries in the list content. kr must be the user's own keyer.
+func (sys *System) LoadMuteFilter(ctx context.Context, pubkey nostr.PubKey, kr nostr.Keyer) (*MuteFilter, error) {
+	ml := sys.FetchMuteList(ctx, pubkey)
+	if ml.Event == nil {
+		return NewMuteFilter(nil), nil
+	}
+
+	mf := NewMuteFilter(ml.Event.Tags)
+	if kr != nil {
+		private, err := decryptPrivateTags(ctx, kr, *ml.Event)
+		if err != nil {
+			return nil, fmt.Errorf("mute list: %w", err)
+		}
+		mf.add(private)
+	}
+
+	return mf, nil
+}
@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuteFilter(t *testing.T) {
+	ctx := context.Background()
+
+	sys, local := newTestSystem(t, "")
+
+	me := nostr.Generate()
+	kr := keyer.NewPlainKeySigner(me)
+	troll := nostr.Generate()
+	secretTroll := nostr.Generate()
+	thread := nostr.Event{Kind: 1, CreatedAt: 1, Content: "flamewar"}
+	thread.Sign(troll)
+
+	private, err := kr.Encrypt(ctx, `[["p","`+secretTroll.Public().Hex()+`"],["word","Crypto"]]`, me.Public())
+	require.NoError(t, err)
+	ml := nostr.Event{Kind: 10000, CreatedAt: nostr.Now(), Content: private, Tags: nostr.Tags{
+		{"p", troll.Public().Hex()},
+		{"t", "Politics"},
+		{"e", thread.ID.Hex()},
+	}}
+	ml.Sign(me)
+	require.NoError(t, local.SaveEvent(ml))
+	sys.KVStore.Set(makeLastFetchKey(10000, me.Public()), encodeTimestamp(nostr.Now()))
+
+	// without the keyer we only get the public stuff
+	public, err := sys.LoadMuteFilter(ctx, me.Public(), nil)
+	require.NoError(t, err)
+	require.Len(t, public.Pubkeys, 1)
+	require.Empty(t, public.Words)
+
+	mf, err := sys.LoadMuteFilter(ctx, me.Public(), kr)
+	require.NoError(t, err)
+	require.Len(t, mf.Pubkeys, 2)
+
+	friend := nostr.Generate()
+	for _, tc := range []struct {
+		sk     nostr.SecretKey
+		evt    nostr.Event
+		reason string
+	}{
+		{troll, nostr.Event{Kind: 1, Content: "hi"}, "pubkey:" + troll.Public().Hex()},
+		{secretTroll, nostr.Event{Kind: 1, Content: "hi"}, "pubkey:" + secretTroll.Public().Hex()},
+		{friend, nostr.Event{Kind: 1, Content: "hi", Tags: nostr.Tags{{"t", "politics"}}}, "hashtag:politics"},
+		{friend, nostr.Event{Kind: 1, Content: "buy CRYPTO now"}, "word:crypto"},
+		{friend, nostr.Event{Kind: 1, Content: "hi", Tags: nostr.Tags{{"e", thread.ID.Hex(), "", "root"}}}, "thread:" + thread.ID.Hex()},
+		{friend, nostr.Event{Kind: 1, Content: "hi"}, ""},
+	} {
+		tc.evt.Sign(tc.sk)
+		reason, muted := mf.Match(tc.evt)
+		require.Equal(t, tc.reason != "", muted)
+		if muted {
+			require.Equal(t, tc.reason, reason.String())
+		}
+	}
+
+	// the system calls OnHidden
+	hidden := make([]MuteReason, 0, 1)
+	mf.OnHidden = func(evt nostr.Event, reason MuteReason) { hidden = append(hidden, reason) }
+	sys.MuteFilter = mf
+	require.True(t, sys.MuteFilter.hides(thread))
+	require.Equal(t, []MuteReason{{"pubkey", troll.Public().Hex()}}, hidden)
+}
@@ -0,0 +1,287 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip10"
+	"fiatjaf.com/nostr/nip22"
+	"fiatjaf.com/nostr/nip57"
+	"fiatjaf.com/nostr/nip61"
+	"fiatjaf.com/nostr/sdk/kvstore"
+)
+
+const notificationsLastSeenPrefix = byte('n')
+
+// NotificationType is the kind of interaction a Notification represents.
+type NotificationType int
+
+const (
+	NotificationReply NotificationType = iota + 1
+	NotificationMention
+	NotificationReaction
+	NotificationRepost
+	NotificationZap
+	NotificationNutzap
+	NotificationFollow
+	NotificationDM
+)
+
+func (nt NotificationType) String() string {
+	switch nt {
+	case NotificationReply:
+		return "reply"
+	case NotificationMention:
+		return "mention"
+	case NotificationReaction:
+		return "reaction"
+	case NotificationRepost:
+		return "repost"
+	case NotificationZap:
+		return "zap"
+	case NotificationNutzap:
+		return "nutzap"
+	case NotificationFollow:
+		return "follow"
+	case NotificationDM:
+		return "dm"
+	}
+	return "unknown"
+}
+
+// Notification is an event that mentions the user, already classified.
+type Notification struct {
+	Type  NotificationType
+	Event nostr.Event
+
+	// Actor is who did the thing. For zaps this is the sender taken from the zap request, not the
+	// zap provider that signed the receipt. For gift-wrapped DMs it is the random wrapper key.
+	Actor nostr.PubKey
+
+	// Target is the event being replied to, reacted to, reposted or zapped, if any.
+	Target nostr.Pointer
+
+	// Amount is in millisats for zaps and in the mint unit for nutzaps.
+	Amount uint64
+
+	// Unread is true when the event was created after the last-seen marker for the user.
+	Unread bool
+}
+
+// StreamNotificationsParameters contains options for StreamNotifications.
+type StreamNotificationsParameters struct {
+	// Since is the timestamp from which to start fetching notifications.
+	// If empty it defaults to 7 days before the last-seen marker (or before now if there is no marker).
+	Since nostr.Timestamp
+
+	// OnlyWoT drops everything that doesn't come from the user's web of trust (see LoadWoTFilter).
+	// Gift-wrapped DMs are always kept since their authors are random keys.
+	OnlyWoT bool
+}
+
+var notificationKinds = []nostr.Kind{1, 3, 4, 6, 7, 16, 1059, 1111, 9321, 9735}
+
+// StreamNotifications subscribes to the user's inbox relays for everything that tags them (replies, mentions,
+// reactions, reposts, zaps, nutzaps, follows and DMs) and emits these classified as Notifications.
+//
+// A follow list only becomes a notification if it tags the user and the previous version of that list in
+// sys.Store didn't, as any update to the list of someone who already follows the user would otherwise show
+// up again as a follow. With the default null store every follow list that tags the user is reported.
+//
+// Events hidden by sys.MuteFilter (or, if that isn't set, by the public entries of the user's mute list) are
+// skipped, and so are events from outside the user's web of trust if params.OnlyWoT is set.
+// Use MarkNotificationsSeen to move the marker that determines Notification.Unread.
+func (sys *System) StreamNotifications(
+	ctx context.Context,
+	pubkey nostr.PubKey,
+	params StreamNotificationsParameters,
+) (<-chan Notification, error) {
+	relays := sys.FetchInboxRelays(ctx, pubkey, 5)
+	if len(relays) == 0 {
+		return nil, fmt.Errorf("no inbox relays found for %s", pubkey.Hex())
+	}
+
+	var wot WotXorFilter
+	if params.OnlyWoT {
+		var err error
+		wot, err = sys.LoadWoTFilter(ctx, pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load wot filter: %w", err)
+		}
+	}
+
+	// without a filter set on the System we can at least use the public entries of the mute list
+	mf := sys.MuteFilter
+	if mf == nil {
+		mf = NewMuteFilter(nil)
+		if ml := sys.FetchMuteList(ctx, pubkey); ml.Event != nil {
+			mf = NewMuteFilter(ml.Event.Tags)
+		}
+	}
+
+	lastSeen := sys.GetNotificationsLastSeen(pubkey)
+	since := params.Since
+	if since == 0 {
+		if lastSeen != 0 {
+			since = lastSeen - 7*24*60*60
+		} else {
+			since = nostr.Now() - 7*24*60*60
+		}
+	}
+
+	filter := nostr.Filter{
+		Kinds: notificationKinds,
+		Tags:  nostr.TagMap{"p": []string{pubkey.Hex()}},
+		Since: since,
+	}
+
+	notifications := make(chan Notification)
+	go func() {
+		defer close(notifications)
+
+		for ie := range sys.Pool.SubscribeMany(ctx, relays, filter, nostr.SubscriptionOptions{
+			Label: "notifications",
+		}) {
+			n, ok := classifyNotification(ie.Event, pubkey)
+			if !ok {
+				continue
+			}
+			if n.Type == NotificationFollow && !sys.isNewFollow(ie.Event, pubkey) {
+				continue
+			}
+
+			if ie.Event.Kind != 1059 {
+				if mf.hides(ie.Event) || mf.hidesActor(ie.Event, n.Actor) {
+					continue
+				}
+				if params.OnlyWoT && !wot.Contains(n.Actor) {
+					continue
+				}
+			}
+
+			// read again since it may have been changed by MarkNotificationsSeen in the meantime
+			n.Unread = ie.Event.CreatedAt > sys.GetNotificationsLastSeen(pubkey)
+
+			select {
+			case notifications <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return notifications, nil
+}
+
+// MarkNotificationsSeen persists in KVStore that the user has seen all notifications up to the given timestamp.
+// The marker never moves backwards.
+func (sys *System) MarkNotificationsSeen(pubkey nostr.PubKey, until nostr.Timestamp) error {
+	return sys.KVStore.Update(makeNotificationsLastSeenKey(pubkey), func(data []byte) ([]byte, error) {
+		if data != nil && decodeTimestamp(data) >= until {
+			return nil, kvstore.NoOp
+		}
+		return encodeTimestamp(until), nil
+	})
+}
+
+// GetNotificationsLastSeen returns the last-seen marker for the user, or 0 if there isn't one.
+func (sys *System) GetNotificationsLastSeen(pubkey nostr.PubKey) nostr.Timestamp {
+	data, _ := sys.KVStore.Get(makeNotificationsLastSeenKey(pubkey))
+	if data == nil {
+		return 0
+	}
+	return decodeTimestamp(data)
+}
+
+// makeNotificationsLastSeenKey creates a key for storing the last-seen marker.
+// It uses the first 8 bytes of the pubkey to create a compact key.
+func makeNotificationsLastSeenKey(pubkey nostr.PubKey) []byte {
+	// format: 'n' + first 8 bytes of pubkey
+	key := make([]byte, 9)
+	key[0] = notificationsLastSeenPrefix
+	copy(key[1:], pubkey[:8])
+	return key
+}
+
+// classifyNotification returns false for events that shouldn't become notifications, like our own events.
+func classifyNotification(evt nostr.Event, pubkey nostr.PubKey) (Notification, bool) {
+	n := Notification{Event: evt, Actor: evt.PubKey}
+
+	switch evt.Kind {
+	case 1:
+		if parent := nip10.GetImmediateParent(evt.Tags); parent != nil {
+			n.Type = NotificationReply
+			n.Target = parent
+		} else {
+			n.Type = NotificationMention
+		}
+	case 1111:
+		n.Type = NotificationReply
+		n.Target = nip22.GetImmediateParent(evt.Tags)
+	case 7:
+		n.Type = NotificationReaction
+		n.Target = targetFromTag(evt.Tags.FindLast("e"))
+	case 6, 16:
+		n.Type = NotificationRepost
+		n.Target = targetFromTag(evt.Tags.Find("e"))
+	case 9735:
+		n.Type = NotificationZap
+		n.Target = targetFromTag(evt.Tags.Find("e"))
+		n.Amount = nip57.GetAmountFromZap(evt)
+		n.Actor = nostr.ZeroPK
+		if desc := evt.Tags.Find("description"); desc != nil {
+			var zapRequest nostr.Event
+			if err := json.Unmarshal([]byte(desc[1]), &zapRequest); err == nil {
+				n.Actor = zapRequest.PubKey
+			}
+		}
+	case 9321:
+		n.Type = NotificationNutzap
+		n.Target = targetFromTag(evt.Tags.Find("e"))
+		n.Amount = nip61.GetAmountFromNutzap(evt)
+	case 3:
+		n.Type = NotificationFollow
+	case 4, 1059:
+		n.Type = NotificationDM
+	default:
+		return n, false
+	}
+
+	if n.Actor == pubkey && n.Type != NotificationDM {
+		// ignore our own stuff
+		return n, false
+	}
+
+	return n, true
+}
+
+// isNewFollow checks if a follow list tags the user while the version of it we had in the store didn't,
+// then stores it so the next version is compared against this one.
+func (sys *System) isNewFollow(evt nostr.Event, pubkey nostr.PubKey) bool {
+	var previous *nostr.Event
+	for stored := range sys.Store.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{3}, Authors: []nostr.PubKey{evt.PubKey}}, 1) {
+		previous = &stored
+	}
+	if previous != nil && previous.CreatedAt >= evt.CreatedAt {
+		// we've seen this one or a newer one already
+		return false
+	}
+
+	sys.Store.ReplaceEvent(evt)
+	sys.invalidateListCache(3, evt.PubKey)
+
+	return previous == nil || !previous.Tags.ContainsAny("p", []string{pubkey.Hex()})
+}
+
+func targetFromTag(tag nostr.Tag) nostr.Pointer {
+	if tag == nil {
+		return nil
+	}
+	ep, err := nostr.EventPointerFromTag(tag)
+	if err != nil {
+		return nil
+	}
+	return ep
+}
@@ -0,0 +1,111 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamNotifications(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, _ := newTestSystem(t, url)
+
+	me := nostr.Generate()
+	friend := nostr.Generate()
+	troll := nostr.Generate()
+	pk := me.Public().Hex()
+
+	publish := func(sk nostr.SecretKey, kind nostr.Kind, ts nostr.Timestamp, tags nostr.Tags) nostr.Event {
+		evt := nostr.Event{Kind: kind, CreatedAt: ts, Tags: tags, Content: "x"}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(evt))
+		return evt
+	}
+
+	base := nostr.Now() - 100
+	publish(me, 10002, base, nostr.Tags{{"r", url, "read"}})
+	publish(me, 10000, base, nostr.Tags{{"p", troll.Public().Hex()}})
+	note := publish(me, 1, base, nostr.Tags{})
+
+	reply := publish(friend, 1, base+1, nostr.Tags{{"e", note.ID.Hex(), url, "root"}, {"p", pk}})
+	publish(friend, 1, base+2, nostr.Tags{{"p", pk}})
+	publish(friend, 7, base+3, nostr.Tags{{"e", note.ID.Hex()}, {"p", pk}})
+	publish(friend, 3, base+4, nostr.Tags{{"p", pk}})
+	zapRequest := nostr.Event{Kind: 9734, CreatedAt: base + 5, Tags: nostr.Tags{{"p", pk}}}
+	zapRequest.Sign(friend)
+	publish(nostr.Generate(), 9735, base+5, nostr.Tags{
+		{"p", pk},
+		{"e", note.ID.Hex()},
+		{"amount", "5000"},
+		{"description", zapRequest.String()},
+	})
+	publish(troll, 1, base+6, nostr.Tags{{"e", note.ID.Hex(), url, "root"}, {"p", pk}})
+	publish(me, 1, base+7, nostr.Tags{{"p", pk}})
+
+	require.NoError(t, sys.MarkNotificationsSeen(me.Public(), base+3))
+	require.NoError(t, sys.MarkNotificationsSeen(me.Public(), base+1)) // doesn't go backwards
+	require.Equal(t, base+3, sys.GetNotificationsLastSeen(me.Public()))
+
+	ch, err := sys.StreamNotifications(ctx, me.Public(), StreamNotificationsParameters{Since: base})
+	require.NoError(t, err)
+
+	byType := make(map[NotificationType]Notification)
+	for n := range ch {
+		byType[n.Type] = n
+		if len(byType) == 5 {
+			break
+		}
+	}
+
+	require.Equal(t, reply.ID, byType[NotificationReply].Event.ID)
+	require.Equal(t, note.ID, byType[NotificationReply].Target.(nostr.EventPointer).ID)
+	require.False(t, byType[NotificationReply].Unread)
+	require.Equal(t, friend.Public(), byType[NotificationMention].Actor)
+	require.Equal(t, note.ID, byType[NotificationReaction].Target.(nostr.EventPointer).ID)
+	require.True(t, byType[NotificationFollow].Unread)
+	require.Equal(t, friend.Public(), byType[NotificationZap].Actor)
+	require.Equal(t, uint64(5000), byType[NotificationZap].Amount)
+
+	// the muted troll and our own note never show up, a DM that arrives later does
+	dm := nostr.Event{Kind: 4, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", pk}}, Content: "secret"}
+	dm.Sign(friend)
+	r, err := sys.Pool.EnsureRelay(url)
+	require.NoError(t, err)
+	require.NoError(t, r.Publish(ctx, dm))
+
+	n := <-ch
+	require.Equal(t, NotificationDM, n.Type)
+	require.Equal(t, dm.ID, n.Event.ID)
+	require.True(t, n.Unread)
+}
+
+func TestNewFollowNotifications(t *testing.T) {
+	sys, _ := newTestSystem(t, "")
+
+	me := nostr.Generate().Public()
+	other := nostr.Generate().Public()
+	friend := nostr.Generate()
+
+	followList := func(ts nostr.Timestamp, follows ...nostr.PubKey) nostr.Event {
+		evt := nostr.Event{Kind: 3, CreatedAt: ts}
+		for _, pk := range follows {
+			evt.Tags = append(evt.Tags, nostr.Tag{"p", pk.Hex()})
+		}
+		evt.Sign(friend)
+		return evt
+	}
+
+	base := nostr.Now() - 100
+	require.True(t, sys.isNewFollow(followList(base, me), me))           // nothing stored, so it's new
+	require.False(t, sys.isNewFollow(followList(base+1, me, other), me)) // already followed us
+	require.False(t, sys.isNewFollow(followList(base+2, other), me))     // unfollowed
+	require.False(t, sys.isNewFollow(followList(base+1, me), me))        // older than what we have
+	require.True(t, sys.isNewFollow(followList(base+3, other, me), me))  // followed again
+}
@@ -0,0 +1,214 @@
+package sdk
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"fiatjaf.com/nostr"
+	cache_kv "fiatjaf.com/nostr/sdk/cache/kv"
+	"fiatjaf.com/nostr/sdk/kvstore"
+	"fiatjaf.com/nostr/succession"
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// UsePersistentCaches replaces all the caches in the System with ones backed by the given KVStore (which can
+// be sys.KVStore itself), so an application can start warm after a restart. Each cache holds at most
+// maxEntries items, zero means unbounded.
+//
+// The caches are flushed when the System is closed, which must happen before the store is closed if it
+// isn't sys.KVStore.
+func (sys *System) UsePersistentCaches(store kvstore.KVStore, maxEntries int) {
+	sys.MetadataCache = newPersistentCache(sys, store, "c:metadata:", maxEntries, metadataCodec{})
+	sys.RelayListCache = newPersistentCache(sys, store, "c:relaylist:", maxEntries,
+		listCodec[string, Relay]{parseRelayFromKind10002})
+	sys.FollowListCache = newPersistentCache(sys, store, "c:followlist:", maxEntries,
+		listCodec[nostr.PubKey, ProfileRef]{parseProfileRef})
+	sys.MuteListCache = newPersistentCache(sys, store, "c:mutelist:", maxEntries,
+		listCodec[nostr.PubKey, ProfileRef]{parseProfileRef})
+	sys.BookmarkListCache = newPersistentCache(sys, store, "c:bookmarklist:", maxEntries,
+		listCodec[string, EventRef]{parseEventRef})
+	sys.PinListCache = newPersistentCache(sys, store, "c:pinlist:", maxEntries,
+		listCodec[string, EventRef]{parseEventRef})
+	sys.BlockedRelayListCache = newPersistentCache(sys, store, "c:blockedrelaylist:", maxEntries,
+		listCodec[string, RelayURL]{parseRelayURL})
+	sys.SearchRelayListCache = newPersistentCache(sys, store, "c:searchrelaylist:", maxEntries,
+		listCodec[string, RelayURL]{parseRelayURL})
+	sys.TopicListCache = newPersistentCache(sys, store, "c:topiclist:", maxEntries,
+		listCodec[string, Topic]{parseTopicString})
+	sys.RelaySetsCache = newPersistentCache(sys, store, "c:relaysets:", maxEntries,
+		setsCodec[string, RelayURL]{parseRelayURL})
+	sys.FollowSetsCache = newPersistentCache(sys, store, "c:followsets:", maxEntries,
+		setsCodec[nostr.PubKey, ProfileRef]{parseProfileRef})
+	sys.TopicSetsCache = newPersistentCache(sys, store, "c:topicsets:", maxEntries,
+		setsCodec[string, Topic]{parseTopicString})
+	sys.ZapProviderCache = newPersistentCache[nostr.PubKey](sys, store, "c:zapprovider:", maxEntries, nil)
+	sys.MintKeysCache = newPersistentCache(sys, store, "c:mintkeys:", maxEntries, mintKeysCodec{})
+	sys.NutZapInfoCache = newPersistentCache(sys, store, "c:nutzapinfo:", maxEntries, nutZapInfoCodec{})
+	sys.SuccessionCache = newPersistentCache(sys, store, "c:succession:", maxEntries, attestationCodec{})
+}
+
+func newPersistentCache[V any](
+	sys *System,
+	store kvstore.KVStore,
+	namespace string,
+	maxEntries int,
+	codec cache_kv.Codec[V],
+) *cache_kv.Cache[V] {
+	c := cache_kv.New(store, namespace, cache_kv.Options[V]{Codec: codec, MaxEntries: maxEntries})
+	sys.persistentCaches = append(sys.persistentCaches, c)
+	return c
+}
+
+// all the values that wrap events are stored as the pubkey followed by the JSON array of events, and then
+// parsed again when decoding, since their items can't be trusted to survive a JSON roundtrip.
+
+func encodeCachedEvents(pubkey nostr.PubKey, events []nostr.Event) ([]byte, error) {
+	j, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	return append(pubkey[:], j...), nil
+}
+
+func decodeCachedEvents(b []byte) (nostr.PubKey, []nostr.Event, error) {
+	if len(b) < 32 {
+		return nostr.ZeroPK, nil, fmt.Errorf("cached value too short")
+	}
+	var events []nostr.Event
+	if err := json.Unmarshal(b[32:], &events); err != nil {
+		return nostr.ZeroPK, nil, err
+	}
+	return nostr.PubKey(b[0:32]), events, nil
+}
+
+func optionalEvent(evt *nostr.Event) []nostr.Event {
+	if evt == nil {
+		return nil
+	}
+	return []nostr.Event{*evt}
+}
+
+type metadataCodec struct{}
+
+func (metadataCodec) Encode(pm ProfileMetadata) ([]byte, error) {
+	return encodeCachedEvents(pm.PubKey, optionalEvent(pm.Event))
+}
+
+func (metadataCodec) Decode(b []byte) (pm ProfileMetadata, err error) {
+	pubkey, events, err := decodeCachedEvents(b)
+	if err != nil {
+		return pm, err
+	}
+	if len(events) > 0 {
+		pm, _ = ParseMetadata(events[0])
+	}
+	pm.PubKey = pubkey
+	return pm, nil
+}
+
+type listCodec[V comparable, I TagItemWithValue[V]] struct {
+	parseTag func(nostr.Tag) (I, bool)
+}
+
+func (lc listCodec[V, I]) Encode(gl GenericList[V, I]) ([]byte, error) {
+	return encodeCachedEvents(gl.PubKey, optionalEvent(gl.Event))
+}
+
+func (lc listCodec[V, I]) Decode(b []byte) (gl GenericList[V, I], err error) {
+	pubkey, events, err := decodeCachedEvents(b)
+	if err != nil {
+		return gl, err
+	}
+	gl = GenericList[V, I]{PubKey: pubkey, parseTag: lc.parseTag}
+	if len(events) > 0 {
+		gl.Event = &events[0]
+		gl.Items = parseItemsFromEventTags(events[0], lc.parseTag)
+	}
+	return gl, nil
+}
+
+type setsCodec[V comparable, I TagItemWithValue[V]] struct {
+	parseTag func(nostr.Tag) (I, bool)
+}
+
+func (sc setsCodec[V, I]) Encode(gs GenericSets[V, I]) ([]byte, error) {
+	return encodeCachedEvents(gs.PubKey, gs.Events)
+}
+
+func (sc setsCodec[V, I]) Decode(b []byte) (gs GenericSets[V, I], err error) {
+	pubkey, events, err := decodeCachedEvents(b)
+	if err != nil {
+		return gs, err
+	}
+	gs = GenericSets[V, I]{PubKey: pubkey, parseTag: sc.parseTag}
+	if len(events) > 0 {
+		gs.Events = events
+		gs.Sets = parseSetsFromEvents(events, sc.parseTag)
+	}
+	return gs, nil
+}
+
+type nutZapInfoCodec struct{}
+
+func (nutZapInfoCodec) Encode(nzi NutZapInfo) ([]byte, error) {
+	return encodeCachedEvents(nzi.PubKey, optionalEvent(nzi.Event))
+}
+
+func (nutZapInfoCodec) Decode(b []byte) (nzi NutZapInfo, err error) {
+	pubkey, events, err := decodeCachedEvents(b)
+	if err != nil {
+		return nzi, err
+	}
+	if len(events) > 0 {
+		nzi, _ = ParseNutZapInfo(events[0])
+	}
+	nzi.PubKey = pubkey
+	return nzi, nil
+}
+
+type attestationCodec struct{}
+
+func (attestationCodec) Encode(a succession.Attestation) ([]byte, error) {
+	// negative results are cached as an empty attestation, which has no event
+	var events []nostr.Event
+	if a.From != nostr.ZeroPK {
+		events = []nostr.Event{a.Event}
+	}
+	return encodeCachedEvents(a.From, events)
+}
+
+func (attestationCodec) Decode(b []byte) (a succession.Attestation, err error) {
+	_, events, err := decodeCachedEvents(b)
+	if err != nil || len(events) == 0 {
+		return a, err
+	}
+	return succession.Parse(events[0])
+}
+
+type mintKeysCodec struct{}
+
+func (mintKeysCodec) Encode(keys map[uint64]*btcec.PublicKey) ([]byte, error) {
+	// format: a sequence of 8 bytes amount + 33 bytes compressed key
+	b := make([]byte, 0, len(keys)*41)
+	for amount, pk := range keys {
+		b = binary.BigEndian.AppendUint64(b, amount)
+		b = append(b, pk.SerializeCompressed()...)
+	}
+	return b, nil
+}
+
+func (mintKeysCodec) Decode(b []byte) (map[uint64]*btcec.PublicKey, error) {
+	if len(b)%41 != 0 {
+		return nil, fmt.Errorf("invalid mint keys length %d", len(b))
+	}
+	keys := make(map[uint64]*btcec.PublicKey, len(b)/41)
+	for i := 0; i < len(b); i += 41 {
+		pk, err := btcec.ParsePubKey(b[i+8 : i+41])
+		if err != nil {
+			return nil, err
+		}
+		keys[binary.BigEndian.Uint64(b[i:i+8])] = pk
+	}
+	return keys, nil
+}
@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	kvstore_memory "fiatjaf.com/nostr/sdk/kvstore/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentCaches(t *testing.T) {
+	ctx := context.Background()
+	kv := kvstore_memory.NewStore()
+
+	sk := nostr.Generate()
+	friend := nostr.Generate().Public()
+	fl := nostr.Event{Kind: 3, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", friend.Hex()}}}
+	fl.Sign(sk)
+	profile := nostr.Event{Kind: 0, CreatedAt: nostr.Now(), Content: `{"name":"bob","lang":"pt"}`}
+	profile.Sign(sk)
+	set := nostr.Event{Kind: 30000, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"d", "friends"}, {"p", friend.Hex()}}}
+	set.Sign(sk)
+	successor := nostr.Generate().Public()
+	attestation := nostr.Event{Kind: nostr.KindKeySuccession, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", successor.Hex()}}}
+	attestation.Sign(sk)
+	nobody := nostr.Generate().Public()
+
+	sys := NewSystem()
+	local := &slicestore.SliceStore{}
+	local.Init()
+	sys.Store = local
+	sys.UsePersistentCaches(kv, 100)
+	for _, evt := range []nostr.Event{fl, profile, set, attestation} {
+		require.NoError(t, local.SaveEvent(evt))
+		sys.KVStore.Set(makeLastFetchKey(evt.Kind, sk.Public()), encodeTimestamp(nostr.Now()))
+	}
+	require.Len(t, sys.FetchFollowList(ctx, sk.Public()).Items, 1)
+	require.Equal(t, "bob", sys.FetchProfileMetadata(ctx, sk.Public()).Name)
+	require.Len(t, sys.FetchFollowSets(ctx, sk.Public()).Sets["friends"], 1)
+	_, ok := sys.FetchSuccessor(ctx, sk.Public())
+	require.True(t, ok)
+	sys.KVStore.Set(makeLastFetchKey(nostr.KindKeySuccession, nobody), encodeTimestamp(nostr.Now()))
+	_, ok = sys.FetchSuccessor(ctx, nobody)
+	require.False(t, ok)
+	sys.Close()
+
+	// a new system with nothing in its store starts warm
+	sys = NewSystem()
+	defer sys.Close()
+	sys.UsePersistentCaches(kv, 100)
+
+	gl, ok := sys.FollowListCache.Get(sk.Public())
+	require.True(t, ok)
+	require.Equal(t, friend, gl.Items[0].Pubkey)
+	require.Equal(t, fl.ID, gl.Event.ID)
+	require.NotNil(t, gl.parseTag)
+
+	pm, ok := sys.MetadataCache.Get(sk.Public())
+	require.True(t, ok)
+	require.Equal(t, "bob", pm.Name)
+	require.Equal(t, sk.Public(), pm.PubKey)
+	require.Contains(t, pm.Extra, "lang")
+
+	gs, ok := sys.FollowSetsCache.Get(sk.Public())
+	require.True(t, ok)
+	require.Equal(t, friend, gs.Sets["friends"][0].Pubkey)
+
+	a, ok := sys.SuccessionCache.Get(sk.Public())
+	require.True(t, ok)
+	require.Equal(t, sk.Public(), a.From)
+	require.Equal(t, successor, a.To)
+
+	a, ok = sys.SuccessionCache.Get(nobody)
+	require.True(t, ok)
+	require.Equal(t, nostr.ZeroPK, a.From)
+}
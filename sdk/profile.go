@@ -0,0 +1,174 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// IdentityClaim is a NIP-39 external identity, taken from an "i" tag like
+// ["i", "github:semisol", "9721ce4ee4fceb91c9711ca2a6c9a5ab"].
+type IdentityClaim struct {
+	Platform string // "github", "twitter", "mastodon", "telegram" or anything else
+	Identity string
+	Proof    string
+}
+
+func (ic IdentityClaim) String() string { return ic.Platform + ":" + ic.Identity }
+
+// Tag returns the "i" tag for this claim.
+func (ic IdentityClaim) Tag() nostr.Tag { return nostr.Tag{"i", ic.String(), ic.Proof} }
+
+// ProofURL returns the URL where a human can see the proof, or an empty string for unknown platforms.
+func (ic IdentityClaim) ProofURL() string {
+	switch ic.Platform {
+	case "github":
+		return "https://gist.github.com/" + ic.Identity + "/" + ic.Proof
+	case "twitter":
+		return "https://twitter.com/" + ic.Identity + "/status/" + ic.Proof
+	case "mastodon":
+		return "https://" + ic.Identity + "/" + ic.Proof
+	case "telegram":
+		return "https://t.me/" + ic.Proof
+	}
+	return ""
+}
+
+// ParseIdentityClaims returns all the NIP-39 claims found in the given tags.
+func ParseIdentityClaims(tags nostr.Tags) []IdentityClaim {
+	var claims []IdentityClaim
+	for _, tag := range tags {
+		if len(tag) < 3 || tag[0] != "i" {
+			continue
+		}
+		platform, identity, ok := strings.Cut(tag[1], ":")
+		if !ok || platform == "" || identity == "" || tag[2] == "" {
+			continue
+		}
+		claims = append(claims, IdentityClaim{Platform: platform, Identity: identity, Proof: tag[2]})
+	}
+	return claims
+}
+
+// ProfileUpdate describes a change to a profile, to be applied with UpdateProfile.
+//
+// Nil fields are left untouched and fields set to an empty string are removed. Fields that aren't
+// known by ProfileMetadata are always kept unless they are explicitly changed in Extra.
+type ProfileUpdate struct {
+	Name        *string
+	DisplayName *string
+	About       *string
+	Website     *string
+	Picture     *string
+	Banner      *string
+	NIP05       *string
+	LUD16       *string
+
+	// Extra sets any other field to the JSON encoding of the given value, nil values remove the field.
+	Extra map[string]any
+
+	// Identities, if not nil, replaces all the NIP-39 "i" tags in the profile event.
+	Identities []IdentityClaim
+}
+
+// UpdateProfile applies the given changes to the latest version of the signer's profile metadata (kind:0)
+// and publishes it to their write relays, with the same safety checks of Follow and friends.
+func (sys *System) UpdateProfile(ctx context.Context, signer nostr.Signer, update ProfileUpdate) (nostr.Event, error) {
+	var modifyErr error
+	evt, err := sys.mutateReplaceable(ctx, signer, 0, "", nil, func(evt *nostr.Event) bool {
+		content := make(map[string]json.RawMessage)
+		if evt.Content != "" {
+			if err := json.Unmarshal([]byte(evt.Content), &content); err != nil {
+				// we don't want to destroy whatever is in there
+				modifyErr = fmt.Errorf("current profile has invalid content: %w", err)
+				return false
+			}
+		}
+
+		for key, value := range map[string]*string{
+			"name":         update.Name,
+			"display_name": update.DisplayName,
+			"about":        update.About,
+			"website":      update.Website,
+			"picture":      update.Picture,
+			"banner":       update.Banner,
+			"nip05":        update.NIP05,
+			"lud16":        update.LUD16,
+		} {
+			if value == nil {
+				continue
+			}
+			if *value == "" {
+				delete(content, key)
+			} else {
+				content[key], _ = json.Marshal(*value)
+			}
+		}
+		for key, value := range update.Extra {
+			if value == nil {
+				delete(content, key)
+				continue
+			}
+			j, err := json.Marshal(value)
+			if err != nil {
+				modifyErr = fmt.Errorf("invalid value for '%s': %w", key, err)
+				return false
+			}
+			content[key] = j
+		}
+
+		changed := false
+		if j, _ := json.Marshal(content); string(j) != evt.Content {
+			// compare the decoded forms so we don't republish just because of key ordering or whitespace
+			var prev map[string]json.RawMessage
+			if json.Unmarshal([]byte(evt.Content), &prev) != nil || !metadataEqual(prev, content) {
+				changed = true
+			}
+			evt.Content = string(j)
+		}
+
+		if update.Identities != nil {
+			tags := slices.DeleteFunc(slices.Clone(evt.Tags), func(tag nostr.Tag) bool {
+				return len(tag) >= 1 && tag[0] == "i"
+			})
+			for _, ic := range update.Identities {
+				tags = append(tags, ic.Tag())
+			}
+			if !slices.EqualFunc(tags, evt.Tags, slices.Equal) {
+				changed = true
+			}
+			evt.Tags = tags
+		}
+
+		return changed
+	})
+	if modifyErr != nil {
+		return nostr.Event{}, modifyErr
+	}
+	return evt, err
+}
+
+func metadataEqual(a, b map[string]json.RawMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, va := range a {
+		vb, ok := b[key]
+		if !ok {
+			return false
+		}
+		var da, db any
+		json.Unmarshal(va, &da)
+		json.Unmarshal(vb, &db)
+		ja, _ := json.Marshal(da)
+		jb, _ := json.Marshal(db)
+		if string(ja) != string(jb) {
+			return false
+		}
+	}
+	return true
+}
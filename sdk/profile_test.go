@@ -0,0 +1,132 @@
+package sdk
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"fiatjaf.com/nostr/nip19"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, _ := newTestSystem(t, url)
+
+	sk := nostr.Generate()
+	signer := keyer.NewPlainKeySigner(sk)
+	rl := nostr.Event{Kind: 10002, CreatedAt: nostr.Now() - 10, Tags: nostr.Tags{{"r", url}}}
+	rl.Sign(sk)
+	require.NoError(t, db.SaveEvent(rl))
+	profile := nostr.Event{
+		Kind:      0,
+		CreatedAt: nostr.Now() - 10,
+		Content:   `{"name":"bob","about":"hello","bot":true,"pronouns":"they/them"}`,
+		Tags:      nostr.Tags{{"i", "github:bob", "abcdef"}, {"alt", "profile"}},
+	}
+	profile.Sign(sk)
+	require.NoError(t, db.SaveEvent(profile))
+
+	name := "robert"
+	empty := ""
+	evt, err := sys.UpdateProfile(ctx, signer, ProfileUpdate{
+		Name:       &name,
+		About:      &empty,
+		Extra:      map[string]any{"bot": nil, "lang": "pt"},
+		Identities: []IdentityClaim{{"mastodon", "example.com/@bob", "123"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, nostr.Tags{{"alt", "profile"}, {"i", "mastodon:example.com/@bob", "123"}}, evt.Tags)
+
+	var content map[string]any
+	require.NoError(t, json.Unmarshal([]byte(evt.Content), &content))
+	require.Equal(t, map[string]any{"name": "robert", "pronouns": "they/them", "lang": "pt"}, content)
+
+	pm := sys.FetchProfileMetadata(ctx, sk.Public())
+	require.Equal(t, "robert", pm.Name)
+	require.Equal(t, json.RawMessage(`"they/them"`), pm.Extra["pronouns"])
+	require.Equal(t, []IdentityClaim{{"mastodon", "example.com/@bob", "123"}}, pm.Identities)
+
+	// nothing changes, nothing is published
+	again, err := sys.UpdateProfile(ctx, signer, ProfileUpdate{Name: &name})
+	require.NoError(t, err)
+	require.Equal(t, evt.ID, again.ID)
+}
+
+func TestVerifyProfile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	sk := nostr.Generate()
+	npub := nip19.EncodeNpub(sk.Public())
+	requests := atomic.Int32{}
+
+	// a single server stands in for all the domains
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/nostr.json", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"names":{"bob":"` + sk.Public().Hex() + `"}}`))
+	})
+	mux.HandleFunc("/bob/abcdef/raw", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("Verifying that I control the following Nostr public key: " + npub))
+	})
+	mux.HandleFunc("/api/v1/statuses/123", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"content":"<p>` + npub + `</p>","account":{"acct":"someoneelse"}}`))
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	sys := NewSystem()
+	defer sys.Close()
+	sys.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	pm := ProfileMetadata{
+		PubKey: sk.Public(),
+		NIP05:  "bob@example.com",
+		Identities: []IdentityClaim{
+			{"github", "bob", "abcdef"},
+			{"github", "bob", "missing"},
+			{"mastodon", "example.com/@bob", "123"},
+			{"telegram", "bob", "1/2"},
+		},
+	}
+
+	results := make(map[string][]VerificationResult)
+	for res := range sys.VerifyProfile(ctx, pm) {
+		results[res.Claim] = append(results[res.Claim], res)
+	}
+	require.Len(t, results, 4)
+	require.True(t, results["bob@example.com"][0].Valid)
+	require.Len(t, results["github:bob"], 2)
+	require.True(t, results["github:bob"][0].Valid != results["github:bob"][1].Valid)
+	require.False(t, results["mastodon:example.com/@bob"][0].Valid)
+	require.NoError(t, results["mastodon:example.com/@bob"][0].Error)
+	require.ErrorIs(t, results["telegram:bob"][0].Error, ErrUnsupportedPlatform)
+	require.Equal(t, int32(3), requests.Load()) // the missing gist hits the mux 404 handler without counting
+
+	// second time everything comes from the cache
+	for range sys.VerifyProfile(ctx, pm) {
+	}
+	require.Equal(t, int32(3), requests.Load())
+}
@@ -3,6 +3,8 @@ package sdk
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"slices"
 	"strconv"
 	"sync"
@@ -48,6 +50,14 @@ func (sys *System) initializeReplaceableDataloaders() {
 	sys.replaceableLoaders[kind_10015] = sys.createReplaceableDataloader(10015)
 	sys.replaceableLoaders[kind_10019] = sys.createReplaceableDataloader(10019)
 	sys.replaceableLoaders[kind_10030] = sys.createReplaceableDataloader(10030)
+
+	sys.replaceableRefresher = dataloader.NewBatchedLoader(
+		sys.batchRefreshReplaceableEvents,
+		dataloader.Options{
+			Wait:         time.Millisecond * 110,
+			MaxThreshold: 30,
+		},
+	)
 }
 
 func (sys *System) createReplaceableDataloader(kind nostr.Kind) *dataloader.Loader[nostr.PubKey, nostr.Event] {
@@ -188,3 +198,133 @@ func (sys *System) determineRelaysToQuery(ctx context.Context, pubkey nostr.PubK
 
 	return relays
 }
+
+// refreshKey identifies a replaceable event (D is empty) or an addressable event to be force-refreshed.
+type refreshKey struct {
+	Kind   nostr.Kind
+	PubKey nostr.PubKey
+	D      string
+}
+
+// batchRefreshReplaceableEvents is the forced variant of batchLoadReplaceableEvents: it works for any
+// replaceable or addressable kind, queries the user's write relays and only counts relays that sent a
+// real EOSE. Keys for which less than ListMutationQuorum relays answered get ErrQuorumNotReached.
+func (sys *System) batchRefreshReplaceableEvents(
+	ctxs []context.Context,
+	keys []refreshKey,
+) map[refreshKey]dataloader.Result[nostr.Event] {
+	results := make(map[refreshKey]dataloader.Result[nostr.Event], len(keys))
+	relaysForKey := make([][]string, len(keys))
+	relayFilter := make([]nostr.DirectedFilter, 0, max(3, len(keys)*2))
+	relayFilterIndex := make(map[string]int, max(3, len(keys)*2))
+	filtersPerRelay := make(map[string]int, max(3, len(keys)*2))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(keys))
+	cm := sync.Mutex{}
+
+	for i, key := range keys {
+		go func(i int, key refreshKey) {
+			defer wg.Done()
+
+			relays := sys.determineRelaysToRefresh(ctxs[i], key.PubKey, key.Kind)
+
+			cm.Lock()
+			defer cm.Unlock()
+
+			relaysForKey[i] = relays
+			for _, relay := range relays {
+				// each relay gets a filter for each kind
+				fk := relay + " " + strconv.Itoa(int(key.Kind))
+				idx, ok := relayFilterIndex[fk]
+				if !ok {
+					dfilter := nostr.DirectedFilter{
+						Relay:  relay,
+						Filter: nostr.Filter{Kinds: []nostr.Kind{key.Kind}},
+					}
+					if key.Kind.IsAddressable() {
+						dfilter.Tags = nostr.TagMap{"d": nil}
+					}
+					idx = len(relayFilter)
+					relayFilterIndex[fk] = idx
+					relayFilter = append(relayFilter, dfilter)
+					filtersPerRelay[relay]++
+				}
+				dfilter := &relayFilter[idx]
+				if !slices.Contains(dfilter.Authors, key.PubKey) {
+					dfilter.Authors = append(dfilter.Authors, key.PubKey)
+				}
+				if key.Kind.IsAddressable() && !slices.Contains(dfilter.Tags["d"], key.D) {
+					dfilter.Tags["d"] = append(dfilter.Tags["d"], key.D)
+				}
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), time.Second*5,
+		errors.New("refreshing replaceable events took too long"),
+	)
+	defer cancel()
+
+	events, eoses := sys.Pool.BatchedQueryManyNotifyEOSE(ctx, relayFilter, nostr.SubscriptionOptions{
+		Label:          "refresh",
+		MaxWaitForEOSE: time.Duration(math.MaxInt64), // only a real EOSE counts, ctx limits the wait
+	})
+	eosesPerRelay := make(map[string]int, len(filtersPerRelay))
+	for events != nil {
+		select {
+		case url := <-eoses:
+			eosesPerRelay[url]++
+		case ie, more := <-events:
+			if !more {
+				events = nil
+				continue
+			}
+
+			key := refreshKey{Kind: ie.Kind, PubKey: ie.PubKey}
+			if ie.Kind.IsAddressable() {
+				key.D = ie.Tags.GetD()
+			}
+			if val, ok := results[key]; !ok || val.Data.CreatedAt < ie.CreatedAt {
+				results[key] = dataloader.Result[nostr.Event]{Data: ie.Event}
+			}
+		}
+	}
+
+	for i, key := range keys {
+		answered := 0
+		for _, relay := range relaysForKey[i] {
+			if eosesPerRelay[relay] >= filtersPerRelay[relay] {
+				answered++
+			}
+		}
+
+		if required := min(ListMutationQuorum, len(relaysForKey[i])); answered < required || answered == 0 {
+			results[key] = dataloader.Result[nostr.Event]{
+				Error: fmt.Errorf("%w: %d out of %d for kind %d",
+					ErrQuorumNotReached, answered, len(relaysForKey[i]), key.Kind),
+			}
+		} else if _, ok := results[key]; !ok {
+			// nothing was found, but we're sure about that
+			results[key] = dataloader.Result[nostr.Event]{}
+		}
+	}
+
+	return results
+}
+
+// determineRelaysToRefresh returns the user's write relays, which are the canonical place for their own
+// lists, but if we don't know these (or if it's the relay list itself that we're refreshing) also what
+// the normal loaders would use.
+func (sys *System) determineRelaysToRefresh(ctx context.Context, pubkey nostr.PubKey, kind nostr.Kind) []string {
+	relays := slices.Clone(sys.FetchWriteRelays(ctx, pubkey))
+	if len(relays) == 0 || kind == 10002 {
+		relays = append(relays, sys.determineRelaysToQuery(ctx, pubkey, kind)...)
+	}
+	for i, url := range relays {
+		relays[i] = nostr.NormalizeURL(url)
+	}
+	slices.Sort(relays)
+	return slices.Compact(relays)
+}
@@ -16,6 +16,9 @@ func (sys *System) SearchUsers(ctx context.Context, query string) []ProfileMetad
 	}, nostr.SubscriptionOptions{
 		Label: "search",
 	}) {
+		if sys.MuteFilter.hides(ie.Event) {
+			continue
+		}
 		m, _ := ParseMetadata(ie.Event)
 		profiles = append(profiles, m)
 	}
@@ -16,6 +16,12 @@ type GenericSets[V comparable, I TagItemWithValue[V]] struct {
 	Events []nostr.Event `json:"-"`
 
 	Sets map[string][]I
+
+	// PrivateSets are the items encrypted in the content of each event, they're only available after
+	// DecryptPrivateSets is called with the keyer of the sets owner.
+	PrivateSets map[string][]I
+
+	parseTag func(nostr.Tag) (I, bool)
 }
 
 func fetchGenericSets[V comparable, I TagItemWithValue[V]](
@@ -44,7 +50,7 @@ func fetchGenericSets[V comparable, I TagItemWithValue[V]](
 		return v, true
 	}
 
-	v := GenericSets[V, I]{PubKey: pubkey}
+	v := GenericSets[V, I]{PubKey: pubkey, parseTag: parseTag}
 
 	events := slices.Collect(
 		sys.Store.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{actualKind}, Authors: []nostr.PubKey{pubkey}}, 100),
@@ -111,9 +117,10 @@ func tryFetchSetsFromNetwork[V comparable, I TagItemWithValue[V]](
 	}
 
 	v := &GenericSets[V, I]{
-		PubKey: pubkey,
-		Events: events,
-		Sets:   parseSetsFromEvents(events, parseTag),
+		PubKey:   pubkey,
+		Events:   events,
+		Sets:     parseSetsFromEvents(events, parseTag),
+		parseTag: parseTag,
 	}
 	for _, evt := range events {
 		sys.Publisher.Publish(ctx, evt)
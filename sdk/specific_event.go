@@ -69,98 +69,113 @@ func (sys *System) FetchSpecificEvent(
 
 	var filter nostr.Filter
 	var author nostr.PubKey
-	relays := make([]string, 0, 10)
-	fallback := make([]string, 0, 10)
 	successRelays = make([]string, 0, 10)
 
 	switch v := pointer.(type) {
 	case nostr.EventPointer:
 		author = v.Author
 		filter.IDs = []nostr.ID{v.ID}
-		relays = append(relays, v.Relays...)
-		relays = nostr.AppendUnique(relays, sys.FallbackRelays.Next())
-		fallback = append(fallback, sys.JustIDRelays.URLs...)
-		fallback = nostr.AppendUnique(fallback, sys.FallbackRelays.Next())
 		priorityRelays = append(priorityRelays, v.Relays...)
 	case nostr.EntityPointer:
 		author = v.PublicKey
 		filter.Authors = []nostr.PubKey{v.PublicKey}
 		filter.Tags = nostr.TagMap{"d": []string{v.Identifier}}
 		filter.Kinds = []nostr.Kind{v.Kind}
-		relays = append(relays, v.Relays...)
-		relays = nostr.AppendUnique(relays, sys.FallbackRelays.Next())
-		fallback = append(fallback, sys.FallbackRelays.Next(), sys.FallbackRelays.Next())
 		priorityRelays = append(priorityRelays, v.Relays...)
 	default:
 		return nil, nil, fmt.Errorf("can't call sys.FetchSpecificEvent() with a %v", pointer)
 	}
 
-	// try to fetch in our internal eventstore first
-	if !params.SkipLocalStore {
-		for evt := range sys.Store.QueryEvents(filter, 1) {
-			return &evt, nil, nil
+	var result *nostr.Event
+	if !params.WithRelays && !params.SkipLocalStore {
+		// in the common case the loader does everything (local store, hints, author relays), batched with
+		// other calls
+		result, err = sys.LoadSpecificEvent(ctx, pointer)
+		if err != nil {
+			return nil, nil, err
+		}
+		if result != nil {
+			go sys.FetchProfileMetadata(ctx, result.PubKey)
+			successRelays = append(successRelays, sys.GetEventRelays(result.ID)...)
+		}
+	} else {
+		// try to fetch in our internal eventstore first
+		if !params.SkipLocalStore {
+			for evt := range sys.Store.QueryEvents(filter, 1) {
+				return &evt, nil, nil
+			}
 		}
-	}
-
-	if author != nostr.ZeroPK {
-		// fetch relays for author
-		authorRelays := sys.FetchOutboxRelays(ctx, author, 3)
 
-		// after that we register these hints as associated with author
-		// (we do this after fetching author outbox relays because we are already going to prioritize these hints)
-		now := nostr.Now()
-		for _, relay := range priorityRelays {
-			sys.Hints.Save(author, nostr.NormalizeURL(relay), hints.LastInHint, now)
+		relays := make([]string, 0, 10)
+		relays = append(relays, priorityRelays...)
+		relays = nostr.AppendUnique(relays, sys.FallbackRelays.Next())
+		fallback := make([]string, 0, 10)
+		if filter.IDs != nil {
+			fallback = append(fallback, sys.JustIDRelays.URLs...)
+			fallback = nostr.AppendUnique(fallback, sys.FallbackRelays.Next())
+		} else {
+			fallback = append(fallback, sys.FallbackRelays.Next(), sys.FallbackRelays.Next())
 		}
 
-		// arrange these
-		relays = nostr.AppendUnique(relays, authorRelays...)
-		priorityRelays = nostr.AppendUnique(priorityRelays, authorRelays...)
-	}
+		if author != nostr.ZeroPK {
+			// fetch relays for author
+			authorRelays := sys.FetchOutboxRelays(ctx, author, 3)
 
-	var result *nostr.Event
-	fetchProfileOnce := sync.Once{}
-
-attempts:
-	for _, attempt := range []struct {
-		label          string
-		relays         []string
-		slowWithRelays bool
-	}{
-		{
-			label:  "fetchspecific",
-			relays: relays,
-			// set this to true if the caller wants relays, so we won't return immediately
-			//   but will instead wait a little while to see if more relays respond
-			slowWithRelays: params.WithRelays,
-		},
-		{
-			label:          "fetchspecific",
-			relays:         fallback,
-			slowWithRelays: false,
-		},
-	} {
-		// actually fetch the event here
-		countdown := 6.0
-		subManyCtx := ctx
-
-		for ie := range sys.Pool.FetchMany(subManyCtx, attempt.relays, filter, nostr.SubscriptionOptions{
-			Label: attempt.label,
-		}) {
-			fetchProfileOnce.Do(func() {
-				go sys.FetchProfileMetadata(ctx, ie.PubKey)
-			})
-
-			successRelays = append(successRelays, ie.Relay.URL)
-			if result == nil || ie.CreatedAt > result.CreatedAt {
-				result = &ie.Event
+			// after that we register these hints as associated with author
+			// (we do this after fetching author outbox relays because we are already going to prioritize these hints)
+			now := nostr.Now()
+			for _, relay := range priorityRelays {
+				sys.Hints.Save(author, nostr.NormalizeURL(relay), hints.LastInHint, now)
 			}
 
-			if !attempt.slowWithRelays {
-				break attempts
-			}
+			// arrange these
+			relays = nostr.AppendUnique(relays, authorRelays...)
+			priorityRelays = nostr.AppendUnique(priorityRelays, authorRelays...)
+		}
 
-			countdown = min(countdown-0.5, 1)
+		fetchProfileOnce := sync.Once{}
+
+	attempts:
+		for _, attempt := range []struct {
+			label          string
+			relays         []string
+			slowWithRelays bool
+		}{
+			{
+				label:  "fetchspecific",
+				relays: relays,
+				// set this to true if the caller wants relays, so we won't return immediately
+				//   but will instead wait a little while to see if more relays respond
+				slowWithRelays: params.WithRelays,
+			},
+			{
+				label:          "fetchspecific",
+				relays:         fallback,
+				slowWithRelays: false,
+			},
+		} {
+			// actually fetch the event here
+			countdown := 6.0
+			subManyCtx := ctx
+
+			for ie := range sys.Pool.FetchMany(subManyCtx, attempt.relays, filter, nostr.SubscriptionOptions{
+				Label: attempt.label,
+			}) {
+				fetchProfileOnce.Do(func() {
+					go sys.FetchProfileMetadata(ctx, ie.PubKey)
+				})
+
+				successRelays = append(successRelays, ie.Relay.URL)
+				if result == nil || ie.CreatedAt > result.CreatedAt {
+					result = &ie.Event
+				}
+
+				if !attempt.slowWithRelays {
+					break attempts
+				}
+
+				countdown = min(countdown-0.5, 1)
+			}
 		}
 	}
 
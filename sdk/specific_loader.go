@@ -0,0 +1,270 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/sdk/dataloader"
+	"fiatjaf.com/nostr/sdk/hints"
+)
+
+// specificEventKey is either an event id or an address, plus the relay hints that came with it
+// (joined by spaces so this can be used as a map key).
+type specificEventKey struct {
+	id     nostr.ID
+	kind   nostr.Kind
+	pubkey nostr.PubKey
+	d      string
+	author nostr.PubKey // only used as a hint for ids
+	relays string
+}
+
+func (k specificEventKey) isAddress() bool { return k.id == nostr.ZeroID }
+
+func (k specificEventKey) matches(evt nostr.Event) bool {
+	if k.isAddress() {
+		return evt.Kind == k.kind && evt.PubKey == k.pubkey && evt.Tags.GetD() == k.d
+	}
+	return evt.ID == k.id
+}
+
+func (sys *System) initializeSpecificEventDataloader() {
+	sys.specificEventLoader = dataloader.NewBatchedLoader(
+		sys.batchLoadSpecificEvents,
+		dataloader.Options{
+			Wait:         time.Millisecond * 80,
+			MaxThreshold: 60,
+		},
+	)
+}
+
+// LoadSpecificEvent gets an event from an EventPointer or an EntityPointer, like FetchSpecificEvent, but
+// all calls made around the same time are batched together: events already in sys.Store are returned
+// directly and the others are fetched with a single filter per relay.
+//
+// It returns nil (and no error) if the event couldn't be found anywhere.
+func (sys *System) LoadSpecificEvent(ctx context.Context, pointer nostr.Pointer) (*nostr.Event, error) {
+	var key specificEventKey
+	switch v := pointer.(type) {
+	case nostr.EventPointer:
+		key = specificEventKey{id: v.ID, author: v.Author, relays: strings.Join(v.Relays, " ")}
+	case *nostr.EventPointer:
+		key = specificEventKey{id: v.ID, author: v.Author, relays: strings.Join(v.Relays, " ")}
+	case nostr.EntityPointer:
+		key = specificEventKey{kind: v.Kind, pubkey: v.PublicKey, d: v.Identifier, relays: strings.Join(v.Relays, " ")}
+	case *nostr.EntityPointer:
+		key = specificEventKey{kind: v.Kind, pubkey: v.PublicKey, d: v.Identifier, relays: strings.Join(v.Relays, " ")}
+	default:
+		return nil, fmt.Errorf("can't load a specific event from %v", pointer)
+	}
+
+	evt, err := sys.specificEventLoader.Load(ctx, key)
+	if err == dataloader.NoValueError {
+		return nil, nil
+	}
+	return evt, err
+}
+
+func (sys *System) batchLoadSpecificEvents(
+	ctxs []context.Context,
+	keys []specificEventKey,
+) map[specificEventKey]dataloader.Result[*nostr.Event] {
+	results := make(map[specificEventKey]dataloader.Result[*nostr.Event], len(keys))
+
+	// try our local store first
+	ids := make([]nostr.ID, 0, len(keys))
+	for _, key := range keys {
+		if !key.isAddress() {
+			ids = append(ids, key.id)
+		}
+	}
+	if len(ids) > 0 {
+		for evt := range sys.Store.QueryEvents(nostr.Filter{IDs: ids}, len(ids)) {
+			for _, key := range keys {
+				if key.matches(evt) {
+					results[key] = dataloader.Result[*nostr.Event]{Data: &evt}
+				}
+			}
+		}
+	}
+	for _, key := range keys {
+		if !key.isAddress() {
+			continue
+		}
+		if _, ok := results[key]; ok {
+			continue
+		}
+		for evt := range sys.Store.QueryEvents(nostr.Filter{
+			Kinds:   []nostr.Kind{key.kind},
+			Authors: []nostr.PubKey{key.pubkey},
+			Tags:    nostr.TagMap{"d": []string{key.d}},
+		}, 1) {
+			results[key] = dataloader.Result[*nostr.Event]{Data: &evt}
+		}
+	}
+
+	// everything else we'll get from relays, on two rounds: first the hinted ones plus the author relays,
+	// then for what wasn't found we try some fallback relays
+	pending := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if _, ok := results[key]; !ok {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return results
+	}
+
+	aggregatedContext, aggregatedCancel := context.WithCancel(context.Background())
+	defer aggregatedCancel()
+	waiting := atomic.Int32{}
+	waiting.Add(int32(len(pending)))
+	for _, i := range pending {
+		go func() {
+			select {
+			case <-ctxs[i].Done():
+				if waiting.Add(-1) == 0 {
+					aggregatedCancel()
+				}
+			case <-aggregatedContext.Done():
+			}
+		}()
+	}
+
+	for round := range 2 {
+		dfs := sys.buildSpecificEventFilters(aggregatedContext, keys, pending, round)
+		if len(dfs) == 0 {
+			break
+		}
+
+		for ie := range sys.Pool.BatchedQueryMany(aggregatedContext, dfs, nostr.SubscriptionOptions{
+			Label:          "specific",
+			MaxWaitForEOSE: time.Second * 4,
+		}) {
+			sys.trackEventRelay(ie.ID, ie.Relay.URL, false)
+
+			for _, i := range pending {
+				key := keys[i]
+				if !key.matches(ie.Event) {
+					continue
+				}
+				if current, ok := results[key]; !ok || current.Data.CreatedAt < ie.CreatedAt {
+					evt := ie.Event
+					results[key] = dataloader.Result[*nostr.Event]{Data: &evt}
+				}
+			}
+		}
+
+		stillPending := pending[:0]
+		for _, i := range pending {
+			if _, ok := results[keys[i]]; !ok {
+				stillPending = append(stillPending, i)
+			}
+		}
+		pending = stillPending
+		if len(pending) == 0 || aggregatedContext.Err() != nil {
+			break
+		}
+	}
+
+	return results
+}
+
+// buildSpecificEventFilters makes one filter with all the ids for each relay, and one filter for each
+// kind-author pair with all the "d" tags for each relay.
+func (sys *System) buildSpecificEventFilters(
+	ctx context.Context,
+	keys []specificEventKey,
+	pending []int,
+	round int,
+) []nostr.DirectedFilter {
+	type addressGroup struct {
+		relay  string
+		kind   nostr.Kind
+		pubkey nostr.PubKey
+	}
+
+	idFilters := make(map[string]int)
+	addrFilters := make(map[addressGroup]int)
+	dfs := make([]nostr.DirectedFilter, 0, len(pending))
+	mu := sync.Mutex{}
+
+	wg := sync.WaitGroup{}
+	for _, i := range pending {
+		key := keys[i]
+		wg.Go(func() {
+			relays := make([]string, 0, 8)
+			if round == 0 {
+				if key.relays != "" {
+					for _, url := range strings.Split(key.relays, " ") {
+						if nostr.IsValidRelayURL(url) {
+							relays = nostr.AppendUnique(relays, nostr.NormalizeURL(url))
+						}
+					}
+				}
+				author := key.author
+				if key.isAddress() {
+					author = key.pubkey
+				}
+				if author != nostr.ZeroPK {
+					hinted := len(relays)
+					relays = nostr.AppendUnique(relays, sys.FetchOutboxRelays(ctx, author, 3)...)
+
+					// register the hints as associated with the author only after getting the outbox relays
+					// as we're already going to prioritize the hints here anyway
+					now := nostr.Now()
+					for _, relay := range relays[0:hinted] {
+						sys.Hints.Save(author, relay, hints.LastInHint, now)
+					}
+				}
+				if len(relays) == 0 {
+					relays = append(relays, sys.FallbackRelays.Next())
+				}
+			} else {
+				if !key.isAddress() {
+					relays = nostr.AppendUnique(relays, sys.JustIDRelays.URLs...)
+				}
+				relays = nostr.AppendUnique(relays, sys.FallbackRelays.Next(), sys.FallbackRelays.Next())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, relay := range relays {
+				if key.isAddress() {
+					group := addressGroup{relay, key.kind, key.pubkey}
+					if idx, ok := addrFilters[group]; ok {
+						dfs[idx].Tags["d"] = nostr.AppendUnique(dfs[idx].Tags["d"], key.d)
+					} else {
+						addrFilters[group] = len(dfs)
+						dfs = append(dfs, nostr.DirectedFilter{
+							Relay: relay,
+							Filter: nostr.Filter{
+								Kinds:   []nostr.Kind{key.kind},
+								Authors: []nostr.PubKey{key.pubkey},
+								Tags:    nostr.TagMap{"d": []string{key.d}},
+							},
+						})
+					}
+				} else {
+					if idx, ok := idFilters[relay]; ok {
+						dfs[idx].IDs = nostr.AppendUnique(dfs[idx].IDs, key.id)
+					} else {
+						idFilters[relay] = len(dfs)
+						dfs = append(dfs, nostr.DirectedFilter{
+							Relay:  relay,
+							Filter: nostr.Filter{IDs: []nostr.ID{key.id}},
+						})
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	return dfs
+}
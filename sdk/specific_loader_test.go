@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSpecificEvent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var reqs atomic.Int32
+	var fallbackReqs atomic.Int32
+	startRelay := func(counter *atomic.Int32) (*slicestore.SliceStore, string) {
+		relay := startTestRelay(t)
+		relay.OnRequest = func(ctx context.Context, filter nostr.Filter) (bool, string) {
+			counter.Add(1)
+			return false, ""
+		}
+		return relay.DB, relay.URL
+	}
+	hintedDB, hintedURL := startRelay(&reqs)
+	fallbackDB, fallbackURL := startRelay(&fallbackReqs)
+
+	sys, local := newTestSystem(t, "")
+	sys.FallbackRelays = NewRelayStream(fallbackURL)
+	sys.JustIDRelays = NewRelayStream(fallbackURL)
+
+	sk := nostr.Generate()
+	notes := make([]nostr.Event, 10)
+	for i := range notes {
+		notes[i] = nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: fmt.Sprintf("note %d", i)}
+		notes[i].Sign(sk)
+		require.NoError(t, hintedDB.SaveEvent(notes[i]))
+	}
+	article := nostr.Event{Kind: 30023, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"d", "banana"}}, Content: "#"}
+	article.Sign(sk)
+	require.NoError(t, hintedDB.SaveEvent(article))
+	hidden := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "only on fallback"}
+	hidden.Sign(sk)
+	require.NoError(t, fallbackDB.SaveEvent(hidden))
+	cached := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "already here"}
+	cached.Sign(sk)
+	require.NoError(t, local.SaveEvent(cached))
+
+	// load everything concurrently
+	pointers := make([]nostr.Pointer, 0, 13)
+	for _, note := range notes {
+		pointers = append(pointers, nostr.EventPointer{ID: note.ID, Relays: []string{hintedURL}})
+	}
+	pointers = append(pointers,
+		nostr.EntityPointer{PublicKey: sk.Public(), Kind: 30023, Identifier: "banana", Relays: []string{hintedURL}},
+		nostr.EventPointer{ID: hidden.ID, Relays: []string{hintedURL}},
+		nostr.EventPointer{ID: cached.ID},
+	)
+	results := make([]*nostr.Event, len(pointers))
+	wg := sync.WaitGroup{}
+	for i, pointer := range pointers {
+		wg.Go(func() {
+			evt, err := sys.LoadSpecificEvent(ctx, pointer)
+			require.NoError(t, err)
+			results[i] = evt
+		})
+	}
+	wg.Wait()
+
+	for i, note := range notes {
+		require.NotNil(t, results[i])
+		require.Equal(t, note.ID, results[i].ID)
+		require.Contains(t, sys.GetEventRelays(note.ID), hintedURL)
+	}
+	require.Equal(t, article.ID, results[10].ID)
+	require.Equal(t, hidden.ID, results[11].ID)
+	require.Contains(t, sys.GetEventRelays(hidden.ID), fallbackURL)
+	require.Equal(t, cached.ID, results[12].ID)
+
+	// one filter for all the ids and one for the address
+	require.Equal(t, int32(2), reqs.Load())
+	// then just one for what we couldn't find
+	require.Equal(t, int32(1), fallbackReqs.Load())
+
+	// things that don't exist anywhere
+	evt, err := sys.LoadSpecificEvent(ctx, nostr.EventPointer{ID: nostr.ID{1, 2, 3}, Relays: []string{hintedURL}})
+	require.NoError(t, err)
+	require.Nil(t, evt)
+
+	// FetchSpecificEvent goes through the loader, which returns local events without touching any relay
+	before := reqs.Load()
+	evt, relays, err := sys.FetchSpecificEvent(ctx, nostr.EventPointer{ID: cached.ID, Relays: []string{hintedURL}}, FetchSpecificEventParameters{})
+	require.NoError(t, err)
+	require.Equal(t, cached.ID, evt.ID)
+	require.Empty(t, relays)
+	require.Equal(t, before, reqs.Load())
+
+	// and batches the others
+	fresh := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "fresh"}
+	fresh.Sign(sk)
+	require.NoError(t, hintedDB.SaveEvent(fresh))
+	evt, relays, err = sys.FetchSpecificEvent(ctx, nostr.EventPointer{ID: fresh.ID, Relays: []string{hintedURL}}, FetchSpecificEventParameters{})
+	require.NoError(t, err)
+	require.Equal(t, fresh.ID, evt.ID)
+	require.Equal(t, []string{hintedURL}, relays)
+	require.Equal(t, before+1, reqs.Load())
+
+	// asking for the relays skips the loader
+	evt, relays, err = sys.FetchSpecificEvent(ctx, nostr.EventPointer{ID: fresh.ID, Relays: []string{hintedURL}}, FetchSpecificEventParameters{WithRelays: true})
+	require.NoError(t, err)
+	require.Equal(t, fresh.ID, evt.ID)
+	require.Contains(t, relays, hintedURL)
+	require.Equal(t, before+2, reqs.Load())
+}
@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"fiatjaf.com/nostr"
+	cache_memory "fiatjaf.com/nostr/sdk/cache/memory"
+	"fiatjaf.com/nostr/succession"
+)
+
+// FetchSuccessor returns the key-succession attestation that must be honored for the given key, if any,
+// looking first in the local store and then, at most once a day, in the key's outbox relays (batched with
+// other calls made around the same time).
+func (sys *System) FetchSuccessor(ctx context.Context, pubkey nostr.PubKey) (succession.Attestation, bool) {
+	if sys.SuccessionCache == nil {
+		sys.SuccessionCache = cache_memory.New[succession.Attestation](1000)
+	}
+	if a, ok := sys.SuccessionCache.Get(pubkey); ok {
+		return a, a.From == pubkey
+	}
+
+	filter := nostr.Filter{Kinds: []nostr.Kind{nostr.KindKeySuccession}, Authors: []nostr.PubKey{pubkey}}
+
+	var attestations []succession.Attestation
+	for evt := range sys.Store.QueryEvents(filter, 100) {
+		if a, err := succession.Parse(evt); err == nil {
+			attestations = append(attestations, a)
+		}
+	}
+
+	lastFetchKey := makeLastFetchKey(nostr.KindKeySuccession, pubkey)
+	lastFetchData, _ := sys.KVStore.Get(lastFetchKey)
+	if lastFetchData == nil || nostr.Now()-decodeTimestamp(lastFetchData) > 24*60*60 {
+		events, _ := sys.successionLoader.Load(ctx, pubkey)
+		for _, evt := range events {
+			a, err := succession.Parse(evt)
+			if err != nil || slices.ContainsFunc(attestations, func(b succession.Attestation) bool {
+				return b.Event.ID == a.Event.ID
+			}) {
+				continue
+			}
+			attestations = append(attestations, a)
+			sys.Store.SaveEvent(evt)
+		}
+		sys.KVStore.Set(lastFetchKey, encodeTimestamp(nostr.Now()))
+	}
+
+	a, ok := succession.Select(pubkey, attestations, nostr.Now())
+	if !ok {
+		// cache the negative result too, but less so if there is a timelock about to expire
+		ttl := time.Hour * 6
+		for _, pending := range attestations {
+			if until := time.Until(pending.NotBefore.Time()); until > 0 && until < ttl {
+				ttl = max(until, time.Minute)
+			}
+		}
+		sys.SuccessionCache.SetWithTTL(pubkey, succession.Attestation{}, ttl)
+		return a, false
+	}
+
+	sys.SuccessionCache.SetWithTTL(pubkey, a, time.Hour*6)
+	return a, true
+}
+
+// ResolveSuccession follows the chain of key successions starting at the given key and returns the key
+// that is currently in use, along with the attestations that lead to it (none if it is the same key).
+// If the chain is broken (by loops or excessive length) the last valid key is returned.
+func (sys *System) ResolveSuccession(ctx context.Context, pubkey nostr.PubKey) (nostr.PubKey, []succession.Attestation) {
+	current, chain, _ := succession.Follow(ctx, pubkey, nostr.Now(),
+		func(ctx context.Context, pubkey nostr.PubKey) []succession.Attestation {
+			if a, ok := sys.FetchSuccessor(ctx, pubkey); ok {
+				return []succession.Attestation{a}
+			}
+			return nil
+		},
+	)
+	return current, chain
+}
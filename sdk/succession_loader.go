@@ -0,0 +1,98 @@
+package sdk
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/sdk/dataloader"
+)
+
+func (sys *System) initializeSuccessionDataloader() {
+	sys.successionLoader = dataloader.NewBatchedLoader(
+		sys.batchLoadSuccessions,
+		dataloader.Options{
+			Wait:         time.Millisecond * 80,
+			MaxThreshold: 60,
+		},
+	)
+}
+
+// batchLoadSuccessions fetches the key-succession attestations published by all the given keys at once,
+// with a single filter per relay listing every key that relay should be asked about.
+func (sys *System) batchLoadSuccessions(
+	ctxs []context.Context,
+	pubkeys []nostr.PubKey,
+) map[nostr.PubKey]dataloader.Result[[]nostr.Event] {
+	results := make(map[nostr.PubKey]dataloader.Result[[]nostr.Event], len(pubkeys))
+	for _, pubkey := range pubkeys {
+		results[pubkey] = dataloader.Result[[]nostr.Event]{Data: []nostr.Event{}}
+	}
+
+	aggregatedContext, aggregatedCancel := context.WithCancel(context.Background())
+	defer aggregatedCancel()
+	waiting := atomic.Int32{}
+	waiting.Add(int32(len(pubkeys)))
+	for i := range pubkeys {
+		go func() {
+			select {
+			case <-ctxs[i].Done():
+				if waiting.Add(-1) == 0 {
+					aggregatedCancel()
+				}
+			case <-aggregatedContext.Done():
+			}
+		}()
+	}
+
+	dfs := make([]nostr.DirectedFilter, 0, len(pubkeys))
+	relayFilterIndex := make(map[string]int, len(pubkeys))
+	mu := sync.Mutex{}
+
+	wg := sync.WaitGroup{}
+	for _, pubkey := range pubkeys {
+		wg.Go(func() {
+			relays := sys.FetchOutboxRelays(aggregatedContext, pubkey, 3)
+			relays = append(relays, sys.MetadataRelays.URLs...)
+			slices.Sort(relays)
+			relays = slices.Compact(relays)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, relay := range relays {
+				if idx, ok := relayFilterIndex[relay]; ok {
+					dfs[idx].Authors = append(dfs[idx].Authors, pubkey)
+				} else {
+					relayFilterIndex[relay] = len(dfs)
+					dfs = append(dfs, nostr.DirectedFilter{
+						Relay: relay,
+						Filter: nostr.Filter{
+							Kinds:   []nostr.Kind{nostr.KindKeySuccession},
+							Authors: []nostr.PubKey{pubkey},
+						},
+					})
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for ie := range sys.Pool.BatchedQueryMany(aggregatedContext, dfs, nostr.SubscriptionOptions{
+		Label:          "succession",
+		MaxWaitForEOSE: time.Second * 4,
+	}) {
+		res, ok := results[ie.PubKey]
+		if !ok || ie.Kind != nostr.KindKeySuccession || slices.ContainsFunc(res.Data, func(evt nostr.Event) bool {
+			return evt.ID == ie.ID
+		}) {
+			continue
+		}
+		res.Data = append(res.Data, ie.Event)
+		results[ie.PubKey] = res
+	}
+
+	return results
+}
@@ -0,0 +1,145 @@
+package sdk
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/sdk/cache"
+	cache_memory "fiatjaf.com/nostr/sdk/cache/memory"
+	"fiatjaf.com/nostr/succession"
+	"github.com/stretchr/testify/require"
+)
+
+func publishAttestation(t *testing.T, relay testRelay, from nostr.SecretKey, to nostr.PubKey, notBefore nostr.Timestamp) {
+	evt := nostr.Event{Kind: nostr.KindKeySuccession, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", to.Hex()}}}
+	if notBefore > 0 {
+		evt.Tags = append(evt.Tags, nostr.Tag{"not-before", strconv.FormatInt(int64(notBefore), 10)})
+	}
+	require.NoError(t, evt.Sign(from))
+	require.NoError(t, relay.DB.SaveEvent(evt))
+}
+
+func TestFollowSuccession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	sys, _ := newTestSystem(t, relay.URL)
+	sys.FollowSuccession = true
+
+	// old was replaced by middle, which was then replaced by current
+	old := nostr.Generate()
+	middle := nostr.Generate()
+	current := nostr.Generate()
+	friend := nostr.Generate().Public()
+	publishAttestation(t, relay, old, middle.Public(), 0)
+	publishAttestation(t, relay, middle, current.Public(), 0)
+	for _, evt := range []nostr.Event{
+		{Kind: 0, CreatedAt: nostr.Now(), Content: `{"name":"current"}`},
+		{Kind: 3, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", friend.Hex()}}},
+	} {
+		require.NoError(t, evt.Sign(current))
+		require.NoError(t, relay.DB.SaveEvent(evt))
+	}
+
+	pm := sys.FetchProfileMetadata(ctx, old.Public())
+	require.Equal(t, current.Public(), pm.PubKey)
+	require.Equal(t, "current", pm.Name)
+	require.Len(t, pm.Succession, 2)
+	require.Equal(t, old.Public(), pm.Succession[0].From)
+	require.Equal(t, middle.Public(), pm.Succession[1].From)
+
+	fl := sys.FetchFollowList(ctx, old.Public())
+	require.Equal(t, current.Public(), fl.PubKey)
+	require.Len(t, fl.Items, 1)
+	require.Equal(t, friend, fl.Items[0].Pubkey)
+
+	// without the flag we get what the key itself published
+	sys.FollowSuccession = false
+	pm = sys.FetchProfileMetadata(ctx, old.Public())
+	require.Equal(t, old.Public(), pm.PubKey)
+	require.Empty(t, pm.Succession)
+}
+
+type ttlRecordingCache struct {
+	cache.Cache32[succession.Attestation]
+	mu   sync.Mutex
+	ttls map[nostr.PubKey]time.Duration
+}
+
+func (c *ttlRecordingCache) SetWithTTL(k [32]byte, v succession.Attestation, d time.Duration) bool {
+	c.mu.Lock()
+	c.ttls[k] = d
+	c.mu.Unlock()
+	return c.Cache32.SetWithTTL(k, v, d)
+}
+
+func TestSuccessionNegativeCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	sys, _ := newTestSystem(t, relay.URL)
+	recorder := &ttlRecordingCache{
+		Cache32: cache_memory.New[succession.Attestation](100),
+		ttls:    make(map[nostr.PubKey]time.Duration),
+	}
+	sys.SuccessionCache = recorder
+
+	// this one is only effective in 10 minutes
+	pending := nostr.Generate()
+	publishAttestation(t, relay, pending, nostr.Generate().Public(), nostr.Now()+10*60)
+	nobody := nostr.Generate().Public()
+
+	_, ok := sys.FetchSuccessor(ctx, pending.Public())
+	require.False(t, ok)
+	_, ok = sys.FetchSuccessor(ctx, nobody)
+	require.False(t, ok)
+
+	// the negative result is kept only until the timelock expires
+	require.InDelta(t, 10*time.Minute, recorder.ttls[pending.Public()], float64(5*time.Second))
+	require.Equal(t, 6*time.Hour, recorder.ttls[nobody])
+}
+
+func TestFetchSuccessorBatching(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	var requests, authors atomic.Int32
+	relay.OnRequest = func(ctx context.Context, filter nostr.Filter) (bool, string) {
+		if slices.Contains(filter.Kinds, nostr.KindKeySuccession) {
+			requests.Add(1)
+			authors.Add(int32(len(filter.Authors)))
+		}
+		return false, ""
+	}
+	sys, _ := newTestSystem(t, relay.URL)
+
+	keys := make([]nostr.SecretKey, 10)
+	successors := make([]nostr.PubKey, len(keys))
+	for i := range keys {
+		keys[i] = nostr.Generate()
+		successors[i] = nostr.Generate().Public()
+		publishAttestation(t, relay, keys[i], successors[i], 0)
+	}
+
+	wg := sync.WaitGroup{}
+	for i, sk := range keys {
+		wg.Go(func() {
+			a, ok := sys.FetchSuccessor(ctx, sk.Public())
+			require.True(t, ok)
+			require.Equal(t, successors[i], a.To)
+		})
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), requests.Load())
+	require.Equal(t, int32(len(keys)), authors.Load())
+}
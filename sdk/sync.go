@@ -0,0 +1,385 @@
+package sdk
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/wrappers"
+	"fiatjaf.com/nostr/nip11"
+	"fiatjaf.com/nostr/nip77"
+	"fiatjaf.com/nostr/sdk/kvstore"
+)
+
+const syncCoveragePrefix = byte('w')
+
+// SyncPageSize is the limit used in each REQ when filling a gap from a relay that doesn't support NIP-77.
+var SyncPageSize = 500
+
+// SyncSettleTime is how far from the moment of a sync a window must end to be marked as covered. Events
+// that were created (or backdated) a little before a sync may only reach the relay after it, so the most
+// recent part of each gap is fetched but left out of the coverage map to be fetched again next time.
+var SyncSettleTime = 5 * time.Minute
+
+// TimeWindow is an inclusive range of timestamps.
+type TimeWindow struct {
+	Since nostr.Timestamp
+	Until nostr.Timestamp
+}
+
+// SyncResult says what happened to one relay during a Sync.
+type SyncResult struct {
+	Relay      string
+	Gaps       int // windows that weren't covered and had to be fetched
+	Events     int // events received from the relay
+	Negentropy bool
+	Err        error
+}
+
+// SyncedQuery is the offline-first way of querying: it calls Sync for the filter and then answers from
+// sys.Store. If some relays can't be reached the local results are still returned, along with an error.
+func (sys *System) SyncedQuery(ctx context.Context, relays []string, filter nostr.Filter) ([]nostr.Event, error) {
+	var errs []error
+	for _, res := range sys.Sync(ctx, relays, filter) {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Relay, res.Err))
+		}
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = 500
+	}
+	events := slices.Collect(sys.Store.QueryEvents(filter, limit))
+
+	return events, errors.Join(errs...)
+}
+
+// Sync ensures sys.Store has all the events matching filter that each of the given relays has between
+// filter.Since and filter.Until (or now). A persistent coverage map of the windows already fetched from each
+// relay for each filter is kept in the KVStore, so only the gaps are fetched, using NIP-77 negentropy for
+// relays that support it. filter.Limit is ignored.
+func (sys *System) Sync(ctx context.Context, relays []string, filter nostr.Filter) []SyncResult {
+	target := TimeWindow{Since: filter.Since, Until: filter.Until}
+	if target.Until == 0 {
+		target.Until = nostr.Now()
+	}
+	settled := nostr.Now() - nostr.Timestamp(SyncSettleTime/time.Second)
+
+	results := make([]SyncResult, len(relays))
+	wg := sync.WaitGroup{}
+	wg.Add(len(relays))
+	for i, url := range relays {
+		go func() {
+			defer wg.Done()
+
+			url = nostr.NormalizeURL(url)
+			res := SyncResult{Relay: url}
+			key := makeSyncCoverageKey(url, filter)
+			gaps := coverageGaps(sys.getCoverage(key), target)
+			res.Gaps = len(gaps)
+			if len(gaps) > 0 {
+				res.Negentropy = sys.supportsNegentropy(ctx, url)
+			}
+
+			for _, gap := range gaps {
+				var n int
+				var err error
+				if res.Negentropy {
+					n, err = sys.syncWindowNegentropy(ctx, url, filter, gap)
+				} else {
+					n, err = sys.syncWindowREQ(ctx, url, filter, gap)
+				}
+				res.Events += n
+				if err != nil {
+					res.Err = err
+					break
+				}
+				if gap.Until > settled {
+					gap.Until = settled
+				}
+				if gap.Since <= gap.Until {
+					sys.addCoverage(key, gap)
+				}
+			}
+
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Coverage returns the time windows that have already been synced from relay for the given filter
+// (Since, Until and Limit are ignored).
+func (sys *System) Coverage(relay string, filter nostr.Filter) []TimeWindow {
+	return sys.getCoverage(makeSyncCoverageKey(nostr.NormalizeURL(relay), filter))
+}
+
+func (sys *System) supportsNegentropy(ctx context.Context, url string) bool {
+	if supported, ok := sys.negentropySupport.Load(url); ok {
+		return supported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	info, err := nip11.Fetch(ctx, url)
+	if err != nil {
+		// don't remember this, it may be a temporary failure
+		return false
+	}
+	supported := slices.ContainsFunc(info.SupportedNIPs, func(n any) bool {
+		switch v := n.(type) {
+		case float64:
+			return v == 77
+		case int:
+			return v == 77
+		}
+		return false
+	})
+
+	sys.negentropySupport.Store(url, supported)
+	return supported
+}
+
+// syncWindowNegentropy downloads the events we don't have in the given window using NIP-77.
+func (sys *System) syncWindowNegentropy(ctx context.Context, url string, filter nostr.Filter, w TimeWindow) (int, error) {
+	filter = filter.Clone()
+	filter.Since = w.Since
+	filter.Until = w.Until
+	filter.Limit = 0
+
+	target := &countingPublisher{StorePublisher: wrappers.StorePublisher{Store: sys.Store, MaxLimit: math.MaxInt32}}
+
+	// nip77.NegentropySync doesn't respect the context if the relay stops answering, so we do it here
+	done := make(chan error, 1)
+	go func() {
+		done <- nip77.NegentropySync(ctx, url, filter, nil, target, nip77.SyncEventsFromIDs)
+	}()
+	select {
+	case err := <-done:
+		return int(target.count.Load()), err
+	case <-ctx.Done():
+		return int(target.count.Load()), context.Cause(ctx)
+	}
+}
+
+// syncWindowREQ downloads all the events in the given window by paginating backwards with normal REQs
+// until a page brings nothing new.
+//
+// Since a REQ can't paginate within a single second, if a whole page is made of events we've seen, all
+// with the same timestamp, there may be more events in that second than a page can hold. In that case
+// the rest of the window is still fetched but an error is returned so it isn't marked as covered.
+func (sys *System) syncWindowREQ(ctx context.Context, url string, filter nostr.Filter, w TimeWindow) (int, error) {
+	r, err := sys.Pool.EnsureRelay(url)
+	if err != nil {
+		return 0, err
+	}
+
+	filter = filter.Clone()
+	filter.Since = w.Since
+	filter.Until = w.Until
+	filter.Limit = SyncPageSize
+
+	seen := make(map[nostr.ID]struct{})
+	largestPage := 0
+	var saturated []nostr.Timestamp
+	for {
+		sub, err := r.Subscribe(ctx, filter, nostr.SubscriptionOptions{
+			Label:          "sync",
+			MaxWaitForEOSE: time.Duration(math.MaxInt64), // we need a real EOSE to trust the window
+		})
+		if err != nil {
+			return len(seen), err
+		}
+
+		fresh := 0
+		received := 0
+		oldest := filter.Until
+	page:
+		for {
+			select {
+			case evt, ok := <-sub.Events:
+				if !ok {
+					return len(seen), fmt.Errorf("subscription ended before EOSE")
+				}
+				received++
+				if _, ok := seen[evt.ID]; ok {
+					continue
+				}
+				seen[evt.ID] = struct{}{}
+				fresh++
+				sys.Publisher.Publish(ctx, evt)
+				if evt.CreatedAt < oldest {
+					oldest = evt.CreatedAt
+				}
+			case <-sub.EndOfStoredEvents:
+				break page
+			case reason := <-sub.ClosedReason:
+				return len(seen), fmt.Errorf("CLOSED: %s", reason)
+			case <-ctx.Done():
+				return len(seen), context.Cause(ctx)
+			}
+		}
+		sub.Unsub()
+		largestPage = max(largestPage, received)
+
+		if fresh == 0 {
+			if received == 0 || received < largestPage {
+				// the relay gave us everything it had
+				break
+			}
+
+			// a full page of events we've already seen, all of them from the second at filter.Until,
+			// so we skip that second to get the rest
+			saturated = append(saturated, filter.Until)
+			if filter.Until <= w.Since {
+				break
+			}
+			filter.Until--
+			continue
+		}
+
+		// keep the same timestamp so we don't miss events that happened in the same second
+		filter.Until = oldest
+	}
+
+	if len(saturated) > 0 {
+		return len(seen), fmt.Errorf("too many events to paginate at timestamps %v", saturated)
+	}
+	return len(seen), nil
+}
+
+type countingPublisher struct {
+	wrappers.StorePublisher
+	count atomic.Int32
+}
+
+func (cp *countingPublisher) Publish(ctx context.Context, evt nostr.Event) error {
+	cp.count.Add(1)
+	return cp.StorePublisher.Publish(ctx, evt)
+}
+
+func (sys *System) getCoverage(key []byte) []TimeWindow {
+	data, _ := sys.KVStore.Get(key)
+	return decodeCoverage(data)
+}
+
+func (sys *System) addCoverage(key []byte, w TimeWindow) {
+	sys.KVStore.Update(key, func(data []byte) ([]byte, error) {
+		windows := decodeCoverage(data)
+		merged := mergeWindows(append(slices.Clone(windows), w))
+		if slices.Equal(merged, windows) {
+			return nil, kvstore.NoOp
+		}
+		return encodeCoverage(merged), nil
+	})
+}
+
+// mergeWindows sorts the windows and merges the ones that overlap or touch.
+func mergeWindows(windows []TimeWindow) []TimeWindow {
+	slices.SortFunc(windows, func(a, b TimeWindow) int { return cmp.Compare(a.Since, b.Since) })
+	merged := make([]TimeWindow, 0, len(windows))
+	for _, w := range windows {
+		if last := len(merged) - 1; last >= 0 && w.Since <= merged[last].Until+1 {
+			merged[last].Until = max(merged[last].Until, w.Until)
+		} else {
+			merged = append(merged, w)
+		}
+	}
+	return merged
+}
+
+// coverageGaps returns the parts of target that aren't in the (merged and sorted) covered windows.
+func coverageGaps(covered []TimeWindow, target TimeWindow) []TimeWindow {
+	if target.Since > target.Until {
+		return nil
+	}
+
+	gaps := make([]TimeWindow, 0, 2)
+	cursor := target.Since
+	for _, w := range covered {
+		if w.Until < cursor {
+			continue
+		}
+		if w.Since > target.Until {
+			break
+		}
+		if w.Since > cursor {
+			gaps = append(gaps, TimeWindow{cursor, w.Since - 1})
+		}
+		if w.Until >= target.Until {
+			return gaps
+		}
+		cursor = w.Until + 1
+	}
+	return append(gaps, TimeWindow{cursor, target.Until})
+}
+
+func encodeCoverage(windows []TimeWindow) []byte {
+	// format: a sequence of 4 bytes since + 4 bytes until
+	data := make([]byte, 0, len(windows)*8)
+	for _, w := range windows {
+		data = append(data, encodeTimestamp(w.Since)...)
+		data = append(data, encodeTimestamp(w.Until)...)
+	}
+	return data
+}
+
+func decodeCoverage(data []byte) []TimeWindow {
+	windows := make([]TimeWindow, 0, len(data)/8)
+	for i := 0; i+8 <= len(data); i += 8 {
+		windows = append(windows, TimeWindow{decodeTimestamp(data[i : i+4]), decodeTimestamp(data[i+4 : i+8])})
+	}
+	return windows
+}
+
+func makeSyncCoverageKey(url string, filter nostr.Filter) []byte {
+	// format: 'w' + first 8 bytes of the hash of the relay url and the filter without its time constraints
+	h := sha256.New()
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+
+	kinds := slices.Clone(filter.Kinds)
+	slices.Sort(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(h, "k%d", kind)
+	}
+	authors := slices.Clone(filter.Authors)
+	slices.SortFunc(authors, func(a, b nostr.PubKey) int { return slices.Compare(a[:], b[:]) })
+	for _, author := range authors {
+		h.Write([]byte{'a'})
+		h.Write(author[:])
+	}
+	ids := slices.Clone(filter.IDs)
+	slices.SortFunc(ids, func(a, b nostr.ID) int { return slices.Compare(a[:], b[:]) })
+	for _, id := range ids {
+		h.Write([]byte{'i'})
+		h.Write(id[:])
+	}
+	tagNames := make([]string, 0, len(filter.Tags))
+	for name := range filter.Tags {
+		tagNames = append(tagNames, name)
+	}
+	slices.Sort(tagNames)
+	for _, name := range tagNames {
+		values := slices.Clone(filter.Tags[name])
+		slices.Sort(values)
+		fmt.Fprintf(h, "t%s%q", name, values)
+	}
+	fmt.Fprintf(h, "s%q", filter.Search)
+
+	key := make([]byte, 1+8)
+	key[0] = syncCoveragePrefix
+	copy(key[1:], h.Sum(nil)[0:8])
+	return key
+}
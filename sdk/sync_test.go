@@ -0,0 +1,130 @@
+package sdk
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/slicestore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	negRelay := startTestRelay(t)
+	negRelay.Negentropy = true
+	negDB, negURL := negRelay.DB, negRelay.URL
+	reqRelay := startTestRelay(t)
+	reqDB, reqURL := reqRelay.DB, reqRelay.URL
+
+	sys, _ := newTestSystem(t, "")
+
+	sk := nostr.Generate()
+	base := nostr.Now() - 1000
+	publish := func(db *slicestore.SliceStore, ts nostr.Timestamp) nostr.Event {
+		evt := nostr.Event{Kind: 1, CreatedAt: ts, Content: "x" + ts.Time().String()}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(evt))
+		return evt
+	}
+	for i := range 7 {
+		publish(negDB, base+nostr.Timestamp(i*10))
+	}
+	SyncPageSize = 3 // force pagination
+	defer func() { SyncPageSize = 500 }()
+	for i := range 7 {
+		publish(reqDB, base+nostr.Timestamp(i*10)+1)
+	}
+
+	filter := nostr.Filter{Kinds: []nostr.Kind{1}, Authors: []nostr.PubKey{sk.Public()}, Since: base + 20}
+	relays := []string{negURL, reqURL}
+	results := sys.Sync(ctx, relays, filter)
+	for _, res := range results {
+		require.NoError(t, res.Err)
+		require.Equal(t, 1, res.Gaps)
+		require.Equal(t, 5, res.Events)
+	}
+	require.True(t, results[0].Negentropy)
+	require.False(t, results[1].Negentropy)
+	require.Len(t, sys.Coverage(negURL, filter), 1)
+
+	// new events inside the covered window aren't fetched again
+	publish(reqDB, base+35)
+	events, err := sys.SyncedQuery(ctx, relays, filter)
+	require.NoError(t, err)
+	require.Len(t, events, 10)
+
+	// but widening the window only fetches the gap
+	filter.Since = base
+	results = sys.Sync(ctx, relays, filter)
+	for _, res := range results {
+		require.NoError(t, res.Err)
+		require.Equal(t, 2, res.Events)
+	}
+	coverage := sys.Coverage(negURL, filter)
+	require.Len(t, coverage, 1)
+	require.Equal(t, base, coverage[0].Since)
+
+	// offline we still get local results
+	negRelay.Close()
+	reqRelay.Close()
+	filter.Until = nostr.Now() + 100
+	events, err = sys.SyncedQuery(ctx, relays, filter)
+	require.Error(t, err)
+	require.Len(t, events, 14)
+}
+
+func TestCoverageGaps(t *testing.T) {
+	covered := mergeWindows([]TimeWindow{{50, 60}, {10, 20}, {21, 30}})
+	require.Equal(t, []TimeWindow{{10, 30}, {50, 60}}, covered)
+	require.Equal(t, []TimeWindow{{0, 9}, {31, 49}, {61, 100}}, coverageGaps(covered, TimeWindow{0, 100}))
+	require.Equal(t, []TimeWindow{{31, 40}}, coverageGaps(covered, TimeWindow{15, 40}))
+	require.Empty(t, coverageGaps(covered, TimeWindow{12, 18}))
+}
+
+func TestSyncIncompleteWindows(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, _ := newTestSystem(t, url)
+
+	sk := nostr.Generate()
+	publish := func(ts nostr.Timestamp, content string) {
+		evt := nostr.Event{Kind: 1, CreatedAt: ts, Content: content}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(evt))
+	}
+
+	SyncPageSize = 3
+	defer func() { SyncPageSize = 500 }()
+
+	// more events in the same second than fit in a page
+	crowded := nostr.Now() - 1000
+	for i := range 5 {
+		publish(crowded, strconv.Itoa(i))
+	}
+	publish(crowded-10, "older")
+	publish(crowded-20, "oldest")
+
+	filter := nostr.Filter{Kinds: []nostr.Kind{1}, Authors: []nostr.PubKey{sk.Public()}, Since: crowded - 100}
+	results := sys.Sync(ctx, []string{url}, filter)
+	require.Error(t, results[0].Err)
+	require.Equal(t, 5, results[0].Events) // a page at the crowded second plus the two older ones
+	require.Empty(t, sys.Coverage(url, filter))
+
+	// the recent part of a window is fetched but not marked as covered
+	SyncPageSize = 500
+	publish(nostr.Now()-5, "recent")
+	results = sys.Sync(ctx, []string{url}, filter)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, 8, results[0].Events)
+	coverage := sys.Coverage(url, filter)
+	require.Len(t, coverage, 1)
+	require.Less(t, coverage[0].Until, nostr.Now()-nostr.Timestamp(SyncSettleTime/time.Second)+2)
+}
@@ -1,7 +1,10 @@
 package sdk
 
 import (
+	"io"
 	"math/rand/v2"
+	"net/http"
+	"time"
 
 	"fiatjaf.com/nostr"
 	"fiatjaf.com/nostr/eventstore"
@@ -14,7 +17,9 @@ import (
 	"fiatjaf.com/nostr/sdk/hints/memoryh"
 	"fiatjaf.com/nostr/sdk/kvstore"
 	kvstore_memory "fiatjaf.com/nostr/sdk/kvstore/memory"
+	"fiatjaf.com/nostr/succession"
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/puzpuzpuz/xsync/v3"
 )
 
 // System represents the core functionality of the SDK, providing access to
@@ -44,6 +49,7 @@ type System struct {
 	ZapProviderCache      cache.Cache32[nostr.PubKey]
 	MintKeysCache         cache.Cache32[map[uint64]*btcec.PublicKey]
 	NutZapInfoCache       cache.Cache32[NutZapInfo]
+	SuccessionCache       cache.Cache32[succession.Attestation]
 	Hints                 hints.HintsDB
 	Pool                  *nostr.Pool
 	RelayListRelays       *RelayStream
@@ -54,11 +60,24 @@ type System struct {
 	UserSearchRelays      *RelayStream
 	NoteSearchRelays      *RelayStream
 	Store                 eventstore.Store
+	MuteFilter            *MuteFilter
+	HTTPClient            *http.Client // used for NIP-05 and NIP-39 verifications
+
+	// FollowSuccession makes profiles and follow lists be fetched from the key that currently replaces
+	// the requested one, if it was superseded through a key-succession attestation.
+	FollowSuccession bool
 
 	Publisher wrappers.StorePublisher
 
-	replaceableLoaders []*dataloader.Loader[nostr.PubKey, nostr.Event]
-	addressableLoaders []*dataloader.Loader[nostr.PubKey, []nostr.Event]
+	replaceableLoaders   []*dataloader.Loader[nostr.PubKey, nostr.Event]
+	replaceableRefresher *dataloader.Loader[refreshKey, nostr.Event]
+	addressableLoaders   []*dataloader.Loader[nostr.PubKey, []nostr.Event]
+	specificEventLoader  *dataloader.Loader[specificEventKey, *nostr.Event]
+	successionLoader     *dataloader.Loader[nostr.PubKey, []nostr.Event]
+
+	negentropySupport *xsync.MapOf[string, bool]
+
+	persistentCaches []io.Closer
 }
 
 // SystemModifier is a function that modifies a System instance.
@@ -119,7 +138,14 @@ func NewSystem() *System {
 			"wss://relay.nostr.band",
 			"wss://search.nos.today",
 		),
-		Hints: memoryh.NewHintDB(),
+		Hints:             memoryh.NewHintDB(),
+		negentropySupport: xsync.NewMapOf[string, bool](),
+		HTTPClient: &http.Client{
+			Timeout: time.Second * 15,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
 	}
 
 	sys.Pool = nostr.NewPool(nostr.PoolOptions{
@@ -153,12 +179,17 @@ func NewSystem() *System {
 
 	sys.initializeReplaceableDataloaders()
 	sys.initializeAddressableDataloaders()
+	sys.initializeSpecificEventDataloader()
+	sys.initializeSuccessionDataloader()
 
 	return sys
 }
 
 // Close releases resources held by the System.
 func (sys *System) Close() {
+	for _, c := range sys.persistentCaches {
+		c.Close()
+	}
 	if sys.KVStore != nil {
 		sys.KVStore.Close()
 	}
@@ -0,0 +1,368 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip10"
+	"fiatjaf.com/nostr/nip22"
+	"fiatjaf.com/nostr/nip73"
+)
+
+// Thread is a conversation tree rebuilt from kind:1 replies (NIP-10) and kind:1111 comments (NIP-22).
+//
+// The tree can keep growing after it is returned by FetchThread (see StreamThread), so while that is
+// happening it should only be read through Nodes() and Find(), which take the internal lock.
+type Thread struct {
+	// Root points to the thread root. It can be a nip73.ExternalPointer when the thread is made of
+	// comments on something that isn't a nostr event.
+	Root nostr.Pointer
+
+	// RootEvent is nil when Root is external or when we couldn't find it anywhere.
+	RootEvent *nostr.Event
+
+	// Replies are the nodes directly under the root, sorted by created_at.
+	// Replies whose parent we don't know yet also sit here until that parent shows up.
+	Replies []*ThreadNode
+
+	mu      sync.Mutex
+	rootRef string
+	nodes   map[string]*ThreadNode
+	waiting map[string][]*ThreadNode // parent reference -> nodes temporarily placed at the top
+	relays  []string
+	filters []nostr.Filter
+}
+
+// ThreadNode is a single reply in a Thread.
+type ThreadNode struct {
+	Event nostr.Event
+
+	// Parent is nil for direct replies to the root.
+	Parent *ThreadNode
+
+	// Replies are sorted by created_at.
+	Replies []*ThreadNode
+}
+
+// NewThread creates an empty Thread for the given root. Most callers should use sys.FetchThread instead.
+func NewThread(root nostr.Pointer) *Thread {
+	return &Thread{
+		Root:    root,
+		rootRef: root.AsTagReference(),
+		nodes:   make(map[string]*ThreadNode),
+		waiting: make(map[string][]*ThreadNode),
+		filters: threadFilters(root),
+	}
+}
+
+// Add puts an event in its place in the tree. It returns false if the event was already there or if it
+// doesn't belong to this thread.
+func (t *Thread) Add(evt nostr.Event) (*ThreadNode, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.RootEvent != nil && t.RootEvent.ID == evt.ID {
+		return nil, false
+	}
+	ref := evt.ID.Hex()
+	if _, exists := t.nodes[ref]; exists {
+		return nil, false
+	}
+	if root := getThreadRoot(evt); root == nil || root.AsTagReference() != t.rootRef {
+		return nil, false
+	}
+
+	node := &ThreadNode{Event: evt}
+	t.nodes[ref] = node
+
+	// find the parent
+	parentRef := t.rootRef
+	if parent := getImmediateParent(evt); parent != nil {
+		parentRef = parent.AsTagReference()
+	}
+	if parentRef == t.rootRef {
+		t.Replies = insertThreadNode(t.Replies, node)
+	} else if parent, ok := t.nodes[parentRef]; ok {
+		node.Parent = parent
+		parent.Replies = insertThreadNode(parent.Replies, node)
+	} else {
+		// we don't have the parent yet, so keep this at the top for now
+		t.Replies = insertThreadNode(t.Replies, node)
+		t.waiting[parentRef] = append(t.waiting[parentRef], node)
+	}
+
+	// adopt nodes that were waiting for this one
+	if children, ok := t.waiting[ref]; ok {
+		delete(t.waiting, ref)
+		for _, child := range children {
+			if idx := slices.Index(t.Replies, child); idx != -1 {
+				t.Replies = slices.Delete(t.Replies, idx, idx+1)
+			}
+			child.Parent = node
+			node.Replies = insertThreadNode(node.Replies, child)
+		}
+	}
+
+	return node, true
+}
+
+// Find returns the node for the given event id, if it is in the thread.
+func (t *Thread) Find(id nostr.ID) *ThreadNode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.nodes[id.Hex()]
+}
+
+// Len returns the number of replies in the thread, not counting the root.
+func (t *Thread) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.nodes)
+}
+
+// Nodes iterates depth-first over the replies in conversation order, yielding each node with its
+// depth (0 for direct replies to the root).
+func (t *Thread) Nodes() iter.Seq2[int, *ThreadNode] {
+	return func(yield func(int, *ThreadNode) bool) {
+		// take a snapshot so we don't hold the lock while yielding
+		type entry struct {
+			depth int
+			node  *ThreadNode
+		}
+		t.mu.Lock()
+		entries := make([]entry, 0, len(t.nodes))
+		var walk func(nodes []*ThreadNode, depth int)
+		walk = func(nodes []*ThreadNode, depth int) {
+			for _, node := range nodes {
+				entries = append(entries, entry{depth, node})
+				walk(node.Replies, depth+1)
+			}
+		}
+		walk(t.Replies, 0)
+		t.mu.Unlock()
+
+		for _, e := range entries {
+			if !yield(e.depth, e.node) {
+				return
+			}
+		}
+	}
+}
+
+func insertThreadNode(nodes []*ThreadNode, node *ThreadNode) []*ThreadNode {
+	idx, _ := slices.BinarySearchFunc(nodes, node, func(a, b *ThreadNode) int {
+		return nostr.CompareEvent(a.Event, b.Event)
+	})
+	return slices.Insert(nodes, idx, node)
+}
+
+// FetchThread takes a pointer to any event in a conversation (or a nip73.ExternalPointer for comments on
+// external content), finds the root and fetches all the replies and comments to it, then arranges them
+// in a tree.
+//
+// Replies are fetched from the local store, from the relays where the root was seen, from the root
+// author's inbox relays and then from the outbox relays of everybody who replied.
+func (sys *System) FetchThread(ctx context.Context, pointer nostr.Pointer) (*Thread, error) {
+	var root nostr.Pointer
+	var rootEvent *nostr.Event
+	relays := make([]string, 0, 16)
+
+	switch v := pointer.(type) {
+	case nip73.ExternalPointer:
+		root = v
+	case nostr.EventPointer, nostr.EntityPointer:
+		evt, successRelays, err := sys.FetchSpecificEvent(ctx, pointer, FetchSpecificEventParameters{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", pointer.AsTagReference(), err)
+		}
+		if evt == nil {
+			return nil, fmt.Errorf("couldn't find %s", pointer.AsTagReference())
+		}
+		relays = append(relays, successRelays...)
+
+		root = getThreadRoot(*evt)
+		if root == nil {
+			// this event is the root itself
+			rootEvent = evt
+			if evt.Kind.IsAddressable() {
+				root = nostr.EntityPointer{PublicKey: evt.PubKey, Kind: evt.Kind, Identifier: evt.Tags.GetD()}
+			} else {
+				root = nostr.EventPointer{ID: evt.ID, Author: evt.PubKey, Kind: evt.Kind}
+			}
+		} else if _, isExternal := root.(nip73.ExternalPointer); !isExternal {
+			if root.MatchesEvent(*evt) {
+				rootEvent = evt
+			} else if re, rootRelays, _ := sys.FetchSpecificEvent(ctx, root, FetchSpecificEventParameters{}); re != nil {
+				rootEvent = re
+				relays = append(relays, rootRelays...)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("can't call sys.FetchThread() with a %v", pointer)
+	}
+
+	thread := NewThread(root)
+	thread.RootEvent = rootEvent
+
+	// relays where replies are supposed to be
+	switch v := root.(type) {
+	case nostr.EventPointer:
+		relays = append(relays, v.Relays...)
+		relays = append(relays, sys.GetEventRelays(v.ID)...)
+	case nostr.EntityPointer:
+		relays = append(relays, v.Relays...)
+	}
+	if rootEvent != nil {
+		relays = append(relays, sys.GetEventRelays(rootEvent.ID)...)
+		relays = append(relays, sys.FetchInboxRelays(ctx, rootEvent.PubKey, 4)...)
+	}
+	relays = append(relays, sys.FallbackRelays.Next(), sys.FallbackRelays.Next())
+	for i, url := range relays {
+		relays[i] = nostr.NormalizeURL(url)
+	}
+	slices.Sort(relays)
+	relays = slices.Compact(relays)
+
+	// first pass: local store and the root relays
+	for _, filter := range thread.filters {
+		for evt := range sys.Store.QueryEvents(filter, 500) {
+			thread.Add(evt)
+		}
+	}
+	sys.fetchThreadReplies(ctx, thread, relays)
+
+	// second pass: outbox relays of everybody who replied
+	repliers := make([]nostr.PubKey, 0, thread.Len())
+	for _, node := range thread.Nodes() {
+		repliers = nostr.AppendUnique(repliers, node.Event.PubKey)
+	}
+	outboxRelays := make([]string, 0, len(repliers)*2)
+	for _, pubkey := range repliers {
+		for _, url := range sys.FetchOutboxRelays(ctx, pubkey, 2) {
+			url = nostr.NormalizeURL(url)
+			if !slices.Contains(relays, url) {
+				outboxRelays = nostr.AppendUnique(outboxRelays, url)
+			}
+		}
+	}
+	if len(outboxRelays) > 0 {
+		sys.fetchThreadReplies(ctx, thread, outboxRelays)
+	}
+
+	thread.relays = append(relays, outboxRelays...)
+	return thread, nil
+}
+
+func (sys *System) fetchThreadReplies(ctx context.Context, thread *Thread, relays []string) {
+	wg := sync.WaitGroup{}
+	for _, filter := range thread.filters {
+		wg.Go(func() {
+			for ie := range sys.Pool.FetchMany(ctx, slices.Clone(relays), filter, nostr.SubscriptionOptions{
+				Label: "thread",
+			}) {
+				if _, added := thread.Add(ie.Event); added {
+					sys.Publisher.Publish(ctx, ie.Event)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// StreamThread keeps listening for new replies to a thread previously returned by FetchThread, adding
+// them to the tree and emitting the new nodes as they arrive. The channel is closed when ctx is canceled.
+func (sys *System) StreamThread(ctx context.Context, thread *Thread) <-chan *ThreadNode {
+	nodes := make(chan *ThreadNode)
+	now := nostr.Now()
+
+	wg := sync.WaitGroup{}
+	for _, filter := range thread.filters {
+		filter.Since = now
+		wg.Go(func() {
+			for ie := range sys.Pool.SubscribeMany(ctx, slices.Clone(thread.relays), filter, nostr.SubscriptionOptions{
+				Label: "thread",
+			}) {
+				node, added := thread.Add(ie.Event)
+				if !added {
+					continue
+				}
+				sys.Publisher.Publish(ctx, ie.Event)
+
+				select {
+				case nodes <- node:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		close(nodes)
+	}()
+
+	return nodes
+}
+
+// getThreadRoot returns nil when the event is not a reply to anything.
+func getThreadRoot(evt nostr.Event) nostr.Pointer {
+	switch evt.Kind {
+	case 1:
+		// events that only mention other events are not replies
+		parent := nip10.GetImmediateParent(evt.Tags)
+		if parent == nil {
+			return nil
+		}
+		// nip10.GetThreadRoot() only looks at "e" tags, but replies to addressable events use "a"
+		for _, tag := range evt.Tags {
+			if len(tag) >= 4 && tag[0] == "a" && tag[3] == "root" {
+				if ep, err := nostr.EntityPointerFromTag(tag); err == nil {
+					return ep
+				}
+			}
+		}
+		if root := nip10.GetThreadRoot(evt.Tags); root != nil {
+			return root
+		}
+		return parent
+	case 1111:
+		return nip22.GetThreadRoot(evt.Tags)
+	}
+	return nil
+}
+
+func getImmediateParent(evt nostr.Event) nostr.Pointer {
+	switch evt.Kind {
+	case 1:
+		return nip10.GetImmediateParent(evt.Tags)
+	case 1111:
+		return nip22.GetImmediateParent(evt.Tags)
+	}
+	return nil
+}
+
+func threadFilters(root nostr.Pointer) []nostr.Filter {
+	ref := root.AsTagReference()
+	switch root.(type) {
+	case nostr.EventPointer:
+		return []nostr.Filter{
+			{Kinds: []nostr.Kind{1}, Tags: nostr.TagMap{"e": []string{ref}}},
+			{Kinds: []nostr.Kind{1111}, Tags: nostr.TagMap{"E": []string{ref}}},
+		}
+	case nostr.EntityPointer:
+		return []nostr.Filter{
+			{Kinds: []nostr.Kind{1}, Tags: nostr.TagMap{"a": []string{ref}}},
+			{Kinds: []nostr.Kind{1111}, Tags: nostr.TagMap{"A": []string{ref}}},
+		}
+	case nip73.ExternalPointer:
+		return []nostr.Filter{
+			{Kinds: []nostr.Kind{1111}, Tags: nostr.TagMap{"I": []string{ref}}},
+		}
+	}
+	return nil
+}
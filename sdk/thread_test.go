@@ -0,0 +1,142 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip73"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchThread(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	relay := startTestRelay(t)
+	db, url := relay.DB, relay.URL
+	sys, _ := newTestSystem(t, url)
+
+	alice := nostr.Generate()
+	bob := nostr.Generate()
+	carol := nostr.Generate()
+	for _, sk := range []nostr.SecretKey{alice, bob, carol} {
+		rl := nostr.Event{Kind: 10002, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"r", url}}}
+		rl.Sign(sk)
+		require.NoError(t, db.SaveEvent(rl))
+	}
+
+	publish := func(sk nostr.SecretKey, kind nostr.Kind, ts nostr.Timestamp, tags nostr.Tags) nostr.Event {
+		evt := nostr.Event{Kind: kind, CreatedAt: ts, Tags: tags, Content: "hello"}
+		evt.Sign(sk)
+		require.NoError(t, db.SaveEvent(evt))
+		return evt
+	}
+
+	base := nostr.Now() - 100
+	root := publish(alice, 1, base, nostr.Tags{})
+	r1 := publish(bob, 1, base+1, nostr.Tags{{"e", root.ID.Hex(), url, "root"}})
+	r2 := publish(carol, 1, base+2, nostr.Tags{
+		{"e", root.ID.Hex(), url, "root"},
+		{"e", r1.ID.Hex(), url, "reply"},
+	})
+	// deprecated positional style
+	r3 := publish(bob, 1, base+3, nostr.Tags{{"e", root.ID.Hex()}, {"e", r2.ID.Hex()}})
+	c1 := publish(carol, 1111, base+4, nostr.Tags{
+		{"E", root.ID.Hex(), url, alice.Public().Hex()},
+		{"K", "1"},
+		{"e", root.ID.Hex(), url, alice.Public().Hex()},
+		{"k", "1"},
+	})
+	// stuff that only mentions the root belongs to another thread
+	publish(bob, 1, base+5, nostr.Tags{{"e", root.ID.Hex(), url, "mention"}, {"e", r3.ID.Hex(), url, "root"}})
+
+	// start from somewhere in the middle
+	thread, err := sys.FetchThread(ctx, nostr.EventPointer{ID: r2.ID, Relays: []string{url}})
+	require.NoError(t, err)
+	require.NotNil(t, thread.RootEvent)
+	require.Equal(t, root.ID, thread.RootEvent.ID)
+	require.Equal(t, 4, thread.Len())
+
+	require.Len(t, thread.Replies, 2)
+	require.Equal(t, r1.ID, thread.Replies[0].Event.ID)
+	require.Equal(t, c1.ID, thread.Replies[1].Event.ID)
+	require.Equal(t, r2.ID, thread.Replies[0].Replies[0].Event.ID)
+	require.Equal(t, r3.ID, thread.Replies[0].Replies[0].Replies[0].Event.ID)
+	require.Equal(t, r2.ID, thread.Find(r3.ID).Parent.Event.ID)
+
+	depths := make([]int, 0, 4)
+	for depth := range thread.Nodes() {
+		depths = append(depths, depth)
+	}
+	require.Equal(t, []int{0, 1, 2, 0}, depths)
+
+	// live updates
+	liveCtx, liveCancel := context.WithCancel(ctx)
+	live := sys.StreamThread(liveCtx, thread)
+	time.Sleep(200 * time.Millisecond)
+	r4 := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "late", Tags: nostr.Tags{
+		{"e", root.ID.Hex(), url, "root"},
+		{"e", c1.ID.Hex(), url, "reply"},
+	}}
+	r4.Sign(alice)
+	r, err := sys.Pool.EnsureRelay(url)
+	require.NoError(t, err)
+	require.NoError(t, r.Publish(ctx, r4))
+	node := <-live
+	require.Equal(t, r4.ID, node.Event.ID)
+	require.Equal(t, c1.ID, node.Parent.Event.ID)
+	require.Equal(t, 5, thread.Len())
+	liveCancel()
+
+	// comments on external content
+	thing := "https://example.com/article"
+	e1 := publish(bob, 1111, base+10, nostr.Tags{{"I", thing}, {"K", "web"}, {"i", thing}, {"k", "web"}})
+	e2 := publish(alice, 1111, base+11, nostr.Tags{
+		{"I", thing}, {"K", "web"},
+		{"e", e1.ID.Hex(), url, bob.Public().Hex()}, {"k", "1111"},
+	})
+
+	external, err := sys.FetchThread(ctx, nip73.ExternalPointer{Thing: thing})
+	require.NoError(t, err)
+	require.Nil(t, external.RootEvent)
+	require.Len(t, external.Replies, 1)
+	require.Equal(t, e1.ID, external.Replies[0].Event.ID)
+	require.Equal(t, e2.ID, external.Replies[0].Replies[0].Event.ID)
+}
+
+func TestThreadOutOfOrder(t *testing.T) {
+	sk := nostr.Generate()
+	root := nostr.Event{Kind: 1, CreatedAt: 1}
+	root.Sign(sk)
+
+	thread := NewThread(nostr.EventPointer{ID: root.ID})
+	thread.RootEvent = &root
+
+	parent := nostr.Event{Kind: 1, CreatedAt: 2, Tags: nostr.Tags{{"e", root.ID.Hex(), "", "root"}}}
+	parent.Sign(sk)
+	child := nostr.Event{Kind: 1, CreatedAt: 3, Tags: nostr.Tags{
+		{"e", root.ID.Hex(), "", "root"},
+		{"e", parent.ID.Hex(), "", "reply"},
+	}}
+	child.Sign(sk)
+
+	// child arrives before its parent and sits at the top
+	_, added := thread.Add(child)
+	require.True(t, added)
+	require.Len(t, thread.Replies, 1)
+
+	// then gets moved once the parent shows up
+	_, added = thread.Add(parent)
+	require.True(t, added)
+	require.Len(t, thread.Replies, 1)
+	require.Equal(t, parent.ID, thread.Replies[0].Event.ID)
+	require.Equal(t, child.ID, thread.Replies[0].Replies[0].Event.ID)
+
+	// duplicates and the root itself are ignored
+	_, added = thread.Add(child)
+	require.False(t, added)
+	_, added = thread.Add(root)
+	require.False(t, added)
+}
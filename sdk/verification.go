@@ -0,0 +1,193 @@
+package sdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip05"
+	"fiatjaf.com/nostr/nip19"
+	"github.com/mailru/easyjson"
+)
+
+const verificationPrefix = byte('v')
+
+// VerificationCacheDuration is for how long the results of NIP-05 and NIP-39 verifications are kept in
+// the KVStore before being checked again.
+var VerificationCacheDuration = time.Hour * 24
+
+// ErrUnsupportedPlatform is returned when verifying NIP-39 claims for platforms we can't check without
+// an API key or a browser, like twitter and telegram.
+var ErrUnsupportedPlatform = errors.New("can't verify claims on this platform")
+
+// VerificationResult is emitted by VerifyProfile for each identifier or claim in a profile.
+type VerificationResult struct {
+	Claim string // the NIP-05 identifier or the NIP-39 "platform:identity"
+	Valid bool
+	Error error // set when the check couldn't be performed, in which case nothing is cached
+}
+
+// VerifyProfile checks the NIP-05 identifier and all the NIP-39 claims of the profile in the background,
+// emitting a result for each. The channel is closed when all checks are done.
+func (sys *System) VerifyProfile(ctx context.Context, pm ProfileMetadata) <-chan VerificationResult {
+	ch := make(chan VerificationResult)
+	wg := sync.WaitGroup{}
+
+	emit := func(res VerificationResult) {
+		select {
+		case ch <- res:
+		case <-ctx.Done():
+		}
+	}
+
+	if pm.NIP05 != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			valid, err := sys.VerifyNIP05(ctx, pm.PubKey, pm.NIP05)
+			emit(VerificationResult{Claim: pm.NIP05, Valid: valid, Error: err})
+		}()
+	}
+
+	for _, ic := range pm.Identities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			valid, err := sys.VerifyIdentityClaim(ctx, pm.PubKey, ic)
+			emit(VerificationResult{Claim: ic.String(), Valid: valid, Error: err})
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// VerifyNIP05 checks if the given NIP-05 identifier points to pubkey, using a result cached in the KVStore
+// if there is a recent one.
+func (sys *System) VerifyNIP05(ctx context.Context, pubkey nostr.PubKey, identifier string) (bool, error) {
+	return sys.cachedVerification(pubkey, "nip05:"+identifier, func() (bool, error) {
+		name, domain, err := nip05.ParseIdentifier(identifier)
+		if err != nil {
+			return false, err
+		}
+
+		body, err := sys.verificationGet(ctx, "https://"+domain+"/.well-known/nostr.json?name="+name)
+		if err != nil || body == nil {
+			return false, err
+		}
+
+		var result nip05.WellKnownResponse
+		if err := easyjson.Unmarshal(body, &result); err != nil {
+			return false, fmt.Errorf("failed to decode json response: %w", err)
+		}
+
+		pk, ok := result.Names[name]
+		return ok && pk == pubkey, nil
+	})
+}
+
+// VerifyIdentityClaim checks a NIP-39 claim by fetching the proof and looking for the npub in it, using a
+// result cached in the KVStore if there is a recent one. Only github and mastodon are supported.
+func (sys *System) VerifyIdentityClaim(ctx context.Context, pubkey nostr.PubKey, ic IdentityClaim) (bool, error) {
+	npub := nip19.EncodeNpub(pubkey)
+
+	return sys.cachedVerification(pubkey, "i:"+ic.String()+":"+ic.Proof, func() (bool, error) {
+		switch ic.Platform {
+		case "github":
+			body, err := sys.verificationGet(ctx, "https://gist.githubusercontent.com/"+ic.Identity+"/"+ic.Proof+"/raw")
+			if err != nil {
+				return false, err
+			}
+			return strings.Contains(string(body), npub), nil
+		case "mastodon":
+			instance, username, ok := strings.Cut(ic.Identity, "/@")
+			if !ok {
+				return false, nil
+			}
+			body, err := sys.verificationGet(ctx, "https://"+instance+"/api/v1/statuses/"+ic.Proof)
+			if err != nil || body == nil {
+				return false, err
+			}
+			var status struct {
+				Content string `json:"content"`
+				Account struct {
+					Acct string `json:"acct"`
+				} `json:"account"`
+			}
+			if err := json.Unmarshal(body, &status); err != nil {
+				return false, fmt.Errorf("failed to decode status: %w", err)
+			}
+			return strings.EqualFold(status.Account.Acct, username) && strings.Contains(status.Content, npub), nil
+		default:
+			return false, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, ic.Platform)
+		}
+	})
+}
+
+func (sys *System) cachedVerification(pubkey nostr.PubKey, claim string, check func() (bool, error)) (bool, error) {
+	key := makeVerificationKey(pubkey, claim)
+	if data, _ := sys.KVStore.Get(key); len(data) == 5 {
+		if nostr.Now()-decodeTimestamp(data[1:]) < nostr.Timestamp(VerificationCacheDuration.Seconds()) {
+			return data[0] == 1, nil
+		}
+	}
+
+	valid, err := check()
+	if err != nil {
+		return false, err
+	}
+
+	data := make([]byte, 5)
+	if valid {
+		data[0] = 1
+	}
+	copy(data[1:], encodeTimestamp(nostr.Now()))
+	sys.KVStore.Set(key, data)
+
+	return valid, nil
+}
+
+func (sys *System) verificationGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a request: %w", err)
+	}
+
+	res, err := sys.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 || res.StatusCode == 410 {
+		// the proof is gone, that is a valid negative result
+		return nil, nil
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned %d", url, res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+func makeVerificationKey(pubkey nostr.PubKey, claim string) []byte {
+	// format: 'v' + first 8 bytes of pubkey + first 8 bytes of the hash of pubkey and claim
+	key := make([]byte, 17)
+	key[0] = verificationPrefix
+	copy(key[1:], pubkey[0:8])
+	hash := sha256.Sum256(append(pubkey[:], claim...))
+	copy(key[9:], hash[0:8])
+	return key
+}
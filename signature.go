@@ -30,6 +30,17 @@ func (evt Event) VerifySignature() bool {
 	return sig.Verify(hash[:], pubkey)
 }
 
+// VerifyBatch checks the signatures of many events at once using BIP-340 batch verification, which is
+// considerably cheaper than calling VerifySignature on each of them. Like VerifySignature it doesn't look
+// at the ID field. Returns a slice with the result for each event, in the same order.
+func VerifyBatch(events []Event) []bool {
+	hashes := make([]ID, len(events))
+	for i, evt := range events {
+		hashes[i] = sha256.Sum256(evt.Serialize())
+	}
+	return verifyBatch(events, hashes)
+}
+
 // Sign signs an event with a given privateKey.
 //
 // It sets the event's ID, PubKey, and Sig fields.
@@ -0,0 +1,179 @@
+package nostr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+var bip340ChallengeTag = sha256.Sum256([]byte("BIP0340/challenge"))
+
+// batchEntry is a parsed signature ready to be checked in a batch.
+type batchEntry struct {
+	idx    int // index in the original slice
+	pubkey int // index in the list of distinct pubkeys
+	r      secp256k1.JacobianPoint
+	s      secp256k1.ModNScalar
+	e      secp256k1.ModNScalar
+}
+
+// verifyBatch checks the signatures using the BIP-340 batch verification equation
+//
+//	(a_1*s_1 + ... + a_u*s_u)*G = a_1*R_1 + ... + a_u*R_u + (a_1*e_1)*P_1 + ... + (a_u*e_u)*P_u
+//
+// with a_1 = 1 and the other a_i random 128-bit numbers. Terms from the same pubkey are merged, so a batch
+// with many events from the same authors is much cheaper than verifying them one by one. When the batch
+// fails it is split in halves until the bad signatures are found.
+func verifyBatch(events []Event, hashes []ID) []bool {
+	results := make([]bool, len(events))
+
+	entries := make([]batchEntry, 0, len(events))
+	pubkeys := make([]secp256k1.JacobianPoint, 0, len(events))
+	pubkeyIndexes := make(map[PubKey]int, len(events))
+	for i, evt := range events {
+		pki, ok := pubkeyIndexes[evt.PubKey]
+		if !ok {
+			pk, err := schnorr.ParsePubKey(evt.PubKey[:])
+			if err != nil {
+				continue
+			}
+			var p secp256k1.JacobianPoint
+			pk.AsJacobian(&p)
+			pki = len(pubkeys)
+			pubkeys = append(pubkeys, p)
+			pubkeyIndexes[evt.PubKey] = pki
+		}
+
+		entry := batchEntry{idx: i, pubkey: pki}
+
+		// r must be a valid x coordinate (< p) and we take the point with the even y
+		var rx secp256k1.FieldVal
+		if overflow := rx.SetBytes((*[32]byte)(evt.Sig[0:32])); overflow != 0 {
+			continue
+		}
+		var ry secp256k1.FieldVal
+		if !secp256k1.DecompressY(&rx, false, &ry) {
+			continue
+		}
+		entry.r = secp256k1.MakeJacobianPoint(&rx, &ry, new(secp256k1.FieldVal).SetInt(1))
+
+		// s must be < n
+		if overflow := entry.s.SetBytes((*[32]byte)(evt.Sig[32:64])); overflow != 0 {
+			continue
+		}
+
+		// e = int(hash_BIP0340/challenge(r || P || m)) mod n
+		h := sha256.New()
+		h.Write(bip340ChallengeTag[:])
+		h.Write(bip340ChallengeTag[:])
+		h.Write(evt.Sig[0:32])
+		h.Write(evt.PubKey[:])
+		h.Write(hashes[i][:])
+		entry.e.SetByteSlice(h.Sum(nil))
+
+		entries = append(entries, entry)
+	}
+
+	var check func(entries []batchEntry)
+	check = func(entries []batchEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		if batchHolds(entries, pubkeys) {
+			for _, entry := range entries {
+				results[entry.idx] = true
+			}
+			return
+		}
+		if len(entries) == 1 {
+			return
+		}
+		half := len(entries) / 2
+		check(entries[0:half])
+		check(entries[half:])
+	}
+	check(entries)
+
+	return results
+}
+
+func batchHolds(entries []batchEntry, pubkeys []secp256k1.JacobianPoint) bool {
+	var sum secp256k1.ModNScalar
+	points := make([]secp256k1.JacobianPoint, 0, len(entries)*2)
+	scalars := make([]secp256k1.ModNScalar, 0, len(entries)*2)
+	pubkeyScalars := make(map[int]int, len(entries)) // index in pubkeys -> index in scalars
+
+	var random [16]byte
+	for i, entry := range entries {
+		var a secp256k1.ModNScalar
+		if i == 0 {
+			a.SetInt(1)
+		} else {
+			rand.Read(random[:])
+			a.SetByteSlice(random[:])
+		}
+
+		sum.Add(new(secp256k1.ModNScalar).Mul2(&a, &entry.s))
+
+		points = append(points, entry.r)
+		scalars = append(scalars, a)
+
+		ae := new(secp256k1.ModNScalar).Mul2(&a, &entry.e)
+		if si, ok := pubkeyScalars[entry.pubkey]; ok {
+			scalars[si].Add(ae)
+		} else {
+			pubkeyScalars[entry.pubkey] = len(scalars)
+			points = append(points, pubkeys[entry.pubkey])
+			scalars = append(scalars, *ae)
+		}
+	}
+
+	// the result of -sum*G + (the right side) must be the point at infinity
+	var result, sG secp256k1.JacobianPoint
+	multiScalarMult(points, scalars, &result)
+	secp256k1.ScalarBaseMultNonConst(sum.Negate(), &sG)
+	secp256k1.AddNonConst(&result, &sG, &result)
+
+	return (result.X.IsZero() && result.Y.IsZero()) || result.Z.IsZero()
+}
+
+// multiScalarMult computes the sum of scalars[i]*points[i] using interleaved 4-bit windows (Strauss), so
+// the doublings are shared by all the points.
+func multiScalarMult(points []secp256k1.JacobianPoint, scalars []secp256k1.ModNScalar, result *secp256k1.JacobianPoint) {
+	tables := make([][16]secp256k1.JacobianPoint, len(points))
+	for i := range points {
+		tables[i][1] = points[i]
+		secp256k1.DoubleNonConst(&points[i], &tables[i][2])
+		for j := 3; j < 16; j++ {
+			secp256k1.AddNonConst(&tables[i][j-1], &points[i], &tables[i][j])
+		}
+	}
+
+	digits := make([][32]byte, len(scalars))
+	for i := range scalars {
+		digits[i] = scalars[i].Bytes()
+	}
+
+	*result = secp256k1.JacobianPoint{}
+	for n := 0; n < 64; n++ {
+		if n > 0 {
+			for range 4 {
+				secp256k1.DoubleNonConst(result, result)
+			}
+		}
+
+		for i := range digits {
+			b := digits[i][n/2]
+			if n%2 == 0 {
+				b >>= 4
+			} else {
+				b &= 0x0f
+			}
+			if b != 0 {
+				secp256k1.AddNonConst(result, &tables[i][b], result)
+			}
+		}
+	}
+}
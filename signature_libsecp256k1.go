@@ -44,6 +44,19 @@ func (evt Event) VerifySignature() bool {
 	return res == 1
 }
 
+// VerifyBatch checks the signatures of many events at once. Like VerifySignature it doesn't look at the
+// ID field. Returns a slice with the result for each event, in the same order.
+//
+// The vendored libsecp256k1 doesn't ship a batch verification module, so this uses the same pure Go
+// BIP-340 batch verification as the default build.
+func VerifyBatch(events []Event) []bool {
+	hashes := make([]ID, len(events))
+	for i, evt := range events {
+		hashes[i] = sha256.Sum256(evt.Serialize())
+	}
+	return verifyBatch(events, hashes)
+}
+
 // Sign signs an event with a given privateKey.
 func (evt *Event) Sign(secretKey [32]byte, signOpts ...schnorr.SignOption) error {
 	if evt.Tags == nil {
@@ -85,12 +85,20 @@ func (sub *Subscription) start() {
 func (sub *Subscription) GetID() string { return sub.id }
 
 func (sub *Subscription) dispatchEvent(evt Event) {
-	added := false
+	sub.deliverEvent(evt, sub.holdEose())
+}
+
+// holdEose must be called when an event is received, before anything asynchronous is done with it,
+// so EndOfStoredEvents isn't emitted before it is delivered. The returned function releases it.
+func (sub *Subscription) holdEose() (release func()) {
 	if !sub.eosed.Load() {
 		sub.storedwg.Add(1)
-		added = true
+		return sub.storedwg.Done
 	}
+	return func() {}
+}
 
+func (sub *Subscription) deliverEvent(evt Event, release func()) {
 	go func() {
 		sub.mu.Lock()
 		defer sub.mu.Unlock()
@@ -102,9 +110,7 @@ func (sub *Subscription) dispatchEvent(evt Event) {
 			}
 		}
 
-		if added {
-			sub.storedwg.Done()
-		}
+		release()
 	}()
 }
 
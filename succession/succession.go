@@ -0,0 +1,179 @@
+// Package succession implements key-succession attestations: events of kind 1776 in which an old key
+// points to the key that replaces it, so clients and relays can migrate followers, profiles and trust
+// when a key is compromised or retired.
+//
+// An attestation has a single "p" tag with the successor key and may have a "not-before" tag with a
+// timestamp before which it must not be honored (a timelock). The content is an optional human-readable
+// reason.
+//
+// Since anyone holding the old key can publish an attestation, including whoever stole it, and they can
+// pick any created_at, attestations from the same key that point to different successors void each
+// other: none of them is honored and the key is left as it is. A thief can then block a migration, but
+// never hijack one. The best defense is to publish, as soon as possible, an attestation to a key kept in
+// cold storage, with a timelock that is the time the owner is willing to wait before the migration
+// happens, during which followers can already be warned and a conflicting attestation would be noticed.
+package succession
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"fiatjaf.com/nostr"
+)
+
+// MaxChainLength is the maximum number of successions Follow will go through.
+const MaxChainLength = 16
+
+// Attestation is a parsed and validated key-succession event.
+type Attestation struct {
+	Event nostr.Event
+
+	From      nostr.PubKey
+	To        nostr.PubKey
+	NotBefore nostr.Timestamp
+	Reason    string
+}
+
+// Create makes and signs an attestation, using the given keyer, saying the successor key replaces it.
+// notBefore can be zero for an attestation that is effective immediately.
+func Create(
+	ctx context.Context,
+	kr nostr.Keyer,
+	successor nostr.PubKey,
+	notBefore nostr.Timestamp,
+	reason string,
+) (nostr.Event, error) {
+	pubkey, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to get public key: %w", err)
+	}
+	if pubkey == successor {
+		return nostr.Event{}, fmt.Errorf("a key can't succeed itself")
+	}
+
+	evt := nostr.Event{
+		Kind:      nostr.KindKeySuccession,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", successor.Hex()}},
+		Content:   reason,
+	}
+	if notBefore > 0 {
+		evt.Tags = append(evt.Tags, nostr.Tag{"not-before", strconv.FormatInt(int64(notBefore), 10)})
+	}
+
+	if err := kr.SignEvent(ctx, &evt); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign: %w", err)
+	}
+	return evt, nil
+}
+
+// Parse validates an attestation event, including its signature.
+func Parse(evt nostr.Event) (Attestation, error) {
+	if evt.Kind != nostr.KindKeySuccession {
+		return Attestation{}, fmt.Errorf("event is kind %d, not %d", evt.Kind, nostr.KindKeySuccession)
+	}
+
+	a := Attestation{Event: evt, From: evt.PubKey, Reason: evt.Content}
+	var hasSuccessor bool
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "p":
+			if hasSuccessor {
+				return Attestation{}, fmt.Errorf("more than one successor")
+			}
+			pk, err := nostr.PubKeyFromHex(tag[1])
+			if err != nil {
+				return Attestation{}, fmt.Errorf("invalid successor: %w", err)
+			}
+			a.To = pk
+			hasSuccessor = true
+		case "not-before":
+			ts, err := strconv.ParseInt(tag[1], 10, 64)
+			if err != nil || ts < 0 {
+				return Attestation{}, fmt.Errorf("invalid not-before '%s'", tag[1])
+			}
+			a.NotBefore = nostr.Timestamp(ts)
+		}
+	}
+	if !hasSuccessor {
+		return Attestation{}, fmt.Errorf("missing successor")
+	}
+	if a.To == a.From {
+		return Attestation{}, fmt.Errorf("a key can't succeed itself")
+	}
+
+	if !evt.VerifySignature() {
+		return Attestation{}, fmt.Errorf("invalid signature")
+	}
+
+	return a, nil
+}
+
+// Effective tells whether the attestation's timelock, if any, has already expired at the given time.
+func (a Attestation) Effective(now nostr.Timestamp) bool {
+	return a.NotBefore <= now
+}
+
+// Select picks the attestation from the given key that must be honored, but only if it is already
+// effective. When the key has more than one attestation they must all point to the same successor,
+// otherwise none is honored; if they do the oldest is picked, as it decides the timelock.
+func Select(from nostr.PubKey, attestations []Attestation, now nostr.Timestamp) (Attestation, bool) {
+	var oldest *Attestation
+	for i, a := range attestations {
+		if a.From != from {
+			continue
+		}
+		if oldest != nil && a.To != oldest.To {
+			return Attestation{}, false
+		}
+		if oldest == nil ||
+			a.Event.CreatedAt < oldest.Event.CreatedAt ||
+			(a.Event.CreatedAt == oldest.Event.CreatedAt && bytes.Compare(a.Event.ID[:], oldest.Event.ID[:]) < 0) {
+			oldest = &attestations[i]
+		}
+	}
+
+	if oldest == nil || !oldest.Effective(now) {
+		return Attestation{}, false
+	}
+	return *oldest, true
+}
+
+// Follow goes through the chain of successions starting at the given key, using lookup to get the
+// attestations published by each key, and returns the key currently in use along with the attestations
+// that lead to it (empty if the key wasn't superseded).
+//
+// If the chain has loops or is too long an error is returned along with the last key that could be
+// resolved safely.
+func Follow(
+	ctx context.Context,
+	start nostr.PubKey,
+	now nostr.Timestamp,
+	lookup func(ctx context.Context, pubkey nostr.PubKey) []Attestation,
+) (nostr.PubKey, []Attestation, error) {
+	current := start
+	seen := map[nostr.PubKey]struct{}{start: {}}
+	var chain []Attestation
+
+	for {
+		a, ok := Select(current, lookup(ctx, current), now)
+		if !ok {
+			return current, chain, nil
+		}
+		if _, loop := seen[a.To]; loop {
+			return current, chain, fmt.Errorf("succession loop at %s", a.To.Hex())
+		}
+		if len(chain) == MaxChainLength {
+			return current, chain, fmt.Errorf("succession chain is longer than %d", MaxChainLength)
+		}
+
+		seen[a.To] = struct{}{}
+		chain = append(chain, a)
+		current = a.To
+	}
+}
@@ -0,0 +1,99 @@
+package succession
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/keyer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAndParse(t *testing.T) {
+	ctx := context.Background()
+	old := nostr.Generate()
+	successor := nostr.Generate().Public()
+
+	evt, err := Create(ctx, keyer.NewPlainKeySigner(old), successor, 1700000000, "lost my phone")
+	require.NoError(t, err)
+
+	a, err := Parse(evt)
+	require.NoError(t, err)
+	require.Equal(t, old.Public(), a.From)
+	require.Equal(t, successor, a.To)
+	require.Equal(t, nostr.Timestamp(1700000000), a.NotBefore)
+	require.Equal(t, "lost my phone", a.Reason)
+
+	evt.Tags = append(evt.Tags, nostr.Tag{"p", nostr.Generate().Public().Hex()})
+	_, err = Parse(evt)
+	require.ErrorContains(t, err, "more than one successor")
+
+	evt.Tags = evt.Tags[0:1]
+	_, err = Parse(evt)
+	require.ErrorContains(t, err, "invalid signature")
+
+	_, err = Create(ctx, keyer.NewPlainKeySigner(old), old.Public(), 0, "")
+	require.Error(t, err)
+}
+
+func TestSelectAndFollow(t *testing.T) {
+	ctx := context.Background()
+	now := nostr.Now()
+	keys := make([]nostr.SecretKey, 4)
+	for i := range keys {
+		keys[i] = nostr.Generate()
+	}
+
+	attest := func(from, to int, createdAt, notBefore nostr.Timestamp) Attestation {
+		evt := nostr.Event{
+			Kind:      nostr.KindKeySuccession,
+			CreatedAt: createdAt,
+			Tags:      nostr.Tags{{"p", keys[to].Public().Hex()}},
+		}
+		if notBefore > 0 {
+			evt.Tags = append(evt.Tags, nostr.Tag{"not-before", strconv.FormatInt(int64(notBefore), 10)})
+		}
+		require.NoError(t, evt.Sign(keys[from]))
+		a, err := Parse(evt)
+		require.NoError(t, err)
+		return a
+	}
+
+	published := map[nostr.PubKey][]Attestation{}
+	lookup := func(ctx context.Context, pubkey nostr.PubKey) []Attestation { return published[pubkey] }
+
+	// repeated attestations to the same successor are fine, the oldest decides the timelock
+	legit := attest(0, 1, now-100, 0)
+	published[keys[0].Public()] = []Attestation{attest(0, 1, now-50, now+1000), legit}
+	a, ok := Select(keys[0].Public(), published[keys[0].Public()], now)
+	require.True(t, ok)
+	require.Equal(t, legit.Event.ID, a.Event.ID)
+
+	// but a conflicting one voids them all, even when backdated by a thief
+	thief := attest(0, 3, now-1000, 0)
+	_, ok = Select(keys[0].Public(), []Attestation{legit, thief}, now)
+	require.False(t, ok)
+	_, ok = Select(keys[0].Public(), []Attestation{thief, legit}, now)
+	require.False(t, ok)
+
+	// a pending timelock blocks the migration
+	published[keys[1].Public()] = []Attestation{attest(1, 2, now-10, now+100)}
+	current, chain, err := Follow(ctx, keys[0].Public(), now, lookup)
+	require.NoError(t, err)
+	require.Equal(t, keys[1].Public(), current)
+	require.Len(t, chain, 1)
+
+	// until it expires
+	current, chain, err = Follow(ctx, keys[0].Public(), now+100, lookup)
+	require.NoError(t, err)
+	require.Equal(t, keys[2].Public(), current)
+	require.Len(t, chain, 2)
+
+	// loops are detected
+	published[keys[2].Public()] = []Attestation{attest(2, 0, now, 0)}
+	current, chain, err = Follow(ctx, keys[0].Public(), now+100, lookup)
+	require.ErrorContains(t, err, "loop")
+	require.Equal(t, keys[2].Public(), current)
+	require.Len(t, chain, 2)
+}
@@ -0,0 +1,205 @@
+package nostr
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Verifier is a pool of workers that verify event signatures in batches (see VerifyBatch). It can be shared
+// by many relay connections (through RelayOptions.Verifier) or by a khatru relay, so events coming from
+// everywhere at the same time get checked together.
+//
+// If CacheSize is set it also remembers the events it has already verified, so the same event arriving
+// from multiple relays is only checked once.
+type Verifier struct {
+	queue     chan verifyRequest
+	batchSize int
+	maxDelay  time.Duration
+	cache     *verifiedCache
+	closeOnce sync.Once
+}
+
+type VerifierOptions struct {
+	// Workers is the number of goroutines verifying batches, defaults to runtime.NumCPU().
+	Workers int
+
+	// BatchSize is the maximum number of events verified together, defaults to 64.
+	BatchSize int
+
+	// MaxDelay is how long a worker will wait for a batch to fill before verifying what it has, defaults to 1ms.
+	MaxDelay time.Duration
+
+	// CacheSize is the number of already-verified events to remember, 0 disables the cache.
+	CacheSize int
+}
+
+type verifyRequest struct {
+	event    Event
+	hash     ID
+	callback func(valid bool)
+}
+
+// NewVerifier starts the workers. Call Close() when done.
+func NewVerifier(opts VerifierOptions) *Verifier {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = time.Millisecond
+	}
+
+	v := &Verifier{
+		queue:     make(chan verifyRequest, opts.BatchSize*opts.Workers),
+		batchSize: opts.BatchSize,
+		maxDelay:  opts.MaxDelay,
+	}
+	if opts.CacheSize > 0 {
+		v.cache = newVerifiedCache(opts.CacheSize)
+	}
+
+	for range opts.Workers {
+		go v.work()
+	}
+
+	return v
+}
+
+// Verify checks the signature of evt like Event.VerifySignature, blocking until its batch is done.
+func (v *Verifier) Verify(evt Event) bool {
+	done := make(chan bool, 1)
+	v.VerifyAsync(evt, func(valid bool) { done <- valid })
+	return <-done
+}
+
+// VerifyAsync queues evt for verification and calls callback with the result from a worker goroutine
+// (or immediately if the result is in the cache).
+func (v *Verifier) VerifyAsync(evt Event, callback func(valid bool)) {
+	hash := sha256.Sum256(evt.Serialize())
+	if v.cache != nil && v.cache.has(hash, evt.Sig) {
+		callback(true)
+		return
+	}
+	v.queue <- verifyRequest{event: evt, hash: hash, callback: callback}
+}
+
+// Close stops the workers. Nothing should be verified after this is called.
+func (v *Verifier) Close() {
+	v.closeOnce.Do(func() { close(v.queue) })
+}
+
+func (v *Verifier) work() {
+	requests := make([]verifyRequest, 0, v.batchSize)
+	events := make([]Event, 0, v.batchSize)
+	hashes := make([]ID, 0, v.batchSize)
+	timer := time.NewTimer(v.maxDelay)
+
+	for {
+		req, ok := <-v.queue
+		if !ok {
+			return
+		}
+		requests = append(requests[:0], req)
+
+		timer.Reset(v.maxDelay)
+	fill:
+		for len(requests) < v.batchSize {
+			select {
+			case req, ok := <-v.queue:
+				if !ok {
+					break fill
+				}
+				requests = append(requests, req)
+			case <-timer.C:
+				break fill
+			}
+		}
+		timer.Stop()
+
+		// the same event may have come from multiple places at the same time, check it only once
+		events = events[:0]
+		hashes = hashes[:0]
+		positions := make([]int, len(requests))
+		for i, req := range requests {
+			positions[i] = -1
+			for j := range events {
+				if hashes[j] == req.hash && events[j].Sig == req.event.Sig {
+					positions[i] = j
+					break
+				}
+			}
+			if positions[i] == -1 {
+				positions[i] = len(events)
+				events = append(events, req.event)
+				hashes = append(hashes, req.hash)
+			}
+		}
+
+		results := verifyBatch(events, hashes)
+		if v.cache != nil {
+			for j, valid := range results {
+				if valid {
+					v.cache.add(hashes[j], events[j].Sig)
+				}
+			}
+		}
+		for i, req := range requests {
+			req.callback(results[positions[i]])
+		}
+	}
+}
+
+// verifiedCache is a simple LRU of the events (by their computed hash and signature) known to be valid.
+type verifiedCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[ID]*list.Element
+	order    *list.List
+}
+
+type verifiedCacheEntry struct {
+	hash ID
+	sig  [64]byte
+}
+
+func newVerifiedCache(capacity int) *verifiedCache {
+	return &verifiedCache{
+		capacity: capacity,
+		items:    make(map[ID]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *verifiedCache) has(hash ID, sig [64]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[hash]
+	if !ok || el.Value.(verifiedCacheEntry).sig != sig {
+		return false
+	}
+	c.order.MoveToFront(el)
+	return true
+}
+
+func (c *verifiedCache) add(hash ID, sig [64]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[hash]; ok {
+		el.Value = verifiedCacheEntry{hash, sig}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[hash] = c.order.PushFront(verifiedCacheEntry{hash, sig})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(verifiedCacheEntry).hash)
+	}
+}
@@ -0,0 +1,94 @@
+package nostr
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func makeBatch(n int, authors int) []Event {
+	keys := make([]SecretKey, authors)
+	for i := range keys {
+		keys[i] = Generate()
+	}
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = Event{Kind: 1, CreatedAt: Timestamp(1700000000 + i), Content: fmt.Sprintf("hello %d", i), Tags: Tags{}}
+		events[i].Sign(keys[i%authors])
+	}
+	return events
+}
+
+func TestVerifyBatch(t *testing.T) {
+	events := makeBatch(50, 7)
+	require.Equal(t, slices.Repeat([]bool{true}, 50), VerifyBatch(events))
+
+	// corrupt some in different ways
+	events[3].Content = "tampered"
+	events[17].Sig[40] ^= 1
+	events[29].Sig[0] ^= 1
+	events[41].PubKey = events[0].PubKey
+	events[44].PubKey[5] ^= 1
+
+	results := VerifyBatch(events)
+	for i, evt := range events {
+		require.Equal(t, evt.VerifySignature(), results[i], "event %d", i)
+	}
+	require.False(t, results[3])
+	require.False(t, results[17])
+	require.False(t, results[41])
+	require.True(t, results[40])
+
+	require.Empty(t, VerifyBatch(nil))
+	require.Equal(t, []bool{true}, VerifyBatch(makeBatch(1, 1)))
+}
+
+func TestVerifier(t *testing.T) {
+	v := NewVerifier(VerifierOptions{Workers: 2, BatchSize: 8, CacheSize: 100})
+	defer v.Close()
+
+	events := makeBatch(30, 3)
+	events[10].Content = "tampered"
+
+	wg := sync.WaitGroup{}
+	results := make([]bool, len(events))
+	for i, evt := range events {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = v.Verify(evt)
+		}()
+	}
+	wg.Wait()
+	for i := range events {
+		require.Equal(t, i != 10, results[i], "event %d", i)
+	}
+
+	// the cache only accepts the exact same event
+	require.True(t, v.cache.has(events[29].GetID(), events[29].Sig))
+	require.True(t, v.Verify(events[29]))
+	evt := events[29]
+	evt.Sig[63] ^= 1
+	require.False(t, v.Verify(evt))
+	require.False(t, v.Verify(events[10]))
+}
+
+func BenchmarkVerify(b *testing.B) {
+	events := makeBatch(256, 16)
+
+	b.Run("one by one", func(b *testing.B) {
+		for b.Loop() {
+			for _, evt := range events {
+				evt.VerifySignature()
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for b.Loop() {
+			VerifyBatch(events)
+		}
+	})
+}